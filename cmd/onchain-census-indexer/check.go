@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/pflag"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// checkResult is the outcome of a single configuration check.
+type checkResult struct {
+	Check  string `json:"check"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// checkReport lists the results of a configuration check.
+type checkReport struct {
+	Passed   int           `json:"passed"`
+	Warnings int           `json:"warnings"`
+	Failed   int           `json:"failed"`
+	Results  []checkResult `json:"results"`
+}
+
+func (r *checkReport) add(check, target, status, detail string) {
+	switch status {
+	case checkPass:
+		r.Passed++
+	case checkWarn:
+		r.Warnings++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, checkResult{Check: check, Target: target, Status: status, Detail: detail})
+}
+
+// checkClient is the subset of the RPC API used to check the configuration.
+type checkClient interface {
+	indexer.CodeReader
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error)
+	Close()
+}
+
+// configChecker verifies the configured RPC endpoints and contracts against
+// the chains they point to.
+type configChecker struct {
+	dial       func(ctx context.Context, uri string) (checkClient, error)
+	timeout    time.Duration
+	scanBlocks uint64
+	batchSize  uint64
+}
+
+func dialCheckClient(ctx context.Context, uri string) (checkClient, error) {
+	return ethclient.DialContext(ctx, uri)
+}
+
+func runCheck(ctx context.Context, args []string) error {
+	fs := pflag.NewFlagSet("check", pflag.ContinueOnError)
+	defineConfigFlags(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "Timeout of each endpoint and contract check")
+	scanBlocks := fs.Uint64("scanBlocks", 100000, "Blocks after the contract creation scanned for its first WeightChanged event")
+	scanBatch := fs.Uint64("scanBatchSize", 5000, "Block batch size per filterLogs while scanning for events")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	source, err := newConfigSource(fs)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(source)
+	if err != nil {
+		return err
+	}
+	checker := &configChecker{
+		dial:       dialCheckClient,
+		timeout:    *timeout,
+		scanBlocks: *scanBlocks,
+		batchSize:  *scanBatch,
+	}
+	report := checker.check(ctx, cfg)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d check(s) failed", report.Failed)
+	}
+	return nil
+}
+
+// check connects to every configured RPC endpoint and verifies the chain it
+// serves, then checks each contract with the first healthy endpoint of its
+// chain.
+func (c *configChecker) check(ctx context.Context, cfg *Config) checkReport {
+	var report checkReport
+	endpoints := cfg.rpcEndpoints()
+	clients := make(map[uint64]checkClient)
+	configured := make(map[uint64]bool)
+	for _, endpoint := range endpoints {
+		configured[endpoint.ChainID] = true
+		client, chainID, err := c.checkEndpoint(ctx, endpoint)
+		if err != nil {
+			report.add("rpc", endpoint.URI, checkFail, err.Error())
+			continue
+		}
+		configured[chainID] = true
+		if endpoint.ChainID != 0 && chainID != endpoint.ChainID {
+			report.add("rpc", endpoint.URI, checkFail,
+				fmt.Sprintf("serves chainID %d, configured for chainID %d", chainID, endpoint.ChainID))
+			client.Close()
+			continue
+		}
+		report.add("rpc", endpoint.URI, checkPass, fmt.Sprintf("serves chainID %d", chainID))
+		if _, ok := clients[chainID]; ok {
+			client.Close()
+			continue
+		}
+		clients[chainID] = client
+	}
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+
+	if len(cfg.Contracts) == 0 {
+		report.add("contracts", "", checkWarn, "no contracts configured")
+	}
+	for _, contract := range cfg.Contracts {
+		target := fmt.Sprintf("%d:%s", contract.ChainID, contract.Address.Hex())
+		if contract.Label != "" {
+			target += " (" + contract.Label + ")"
+		}
+		client, ok := clients[contract.ChainID]
+		switch {
+		case ok:
+			c.checkContract(ctx, &report, client, target, contract)
+		case len(endpoints) == 0:
+			report.add("contract", target, checkWarn,
+				"no RPC endpoints configured; the service would use chainlist endpoints, contract not checked")
+		case configured[contract.ChainID]:
+			report.add("contract", target, checkFail,
+				fmt.Sprintf("no healthy RPC endpoint for chainID %d", contract.ChainID))
+		default:
+			report.add("contract", target, checkFail,
+				fmt.Sprintf("no RPC endpoints configured for chainID %d", contract.ChainID))
+		}
+	}
+	return report
+}
+
+func (c *configChecker) checkEndpoint(ctx context.Context, endpoint rpcEndpoint) (checkClient, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	client, err := c.dial(ctx, endpoint.URI)
+	if err != nil {
+		return nil, 0, fmt.Errorf("connect: %w", err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, 0, fmt.Errorf("eth_chainId: %w", err)
+	}
+	return client, chainID.Uint64(), nil
+}
+
+// checkContract verifies that the contract has bytecode, finds its creation
+// block and first indexed event (WeightChanged unless the contract has an event
+// profile) and compares them with the configured start block.
+func (c *configChecker) checkContract(
+	ctx context.Context,
+	report *checkReport,
+	client checkClient,
+	target string,
+	contract indexer.ContractInfo,
+) {
+	event, err := indexer.ProfileEvent(contract.EventProfile)
+	if err != nil {
+		report.add("events", target, checkFail, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		report.add("bytecode", target, checkFail, fmt.Sprintf("fetch head block: %v", err))
+		return
+	}
+	code, err := client.CodeAt(ctx, contract.Address, new(big.Int).SetUint64(head))
+	if err != nil {
+		report.add("bytecode", target, checkFail, fmt.Sprintf("eth_getCode: %v", err))
+		return
+	}
+	if len(code) == 0 {
+		report.add("bytecode", target, checkFail, fmt.Sprintf("no contract code at block %d", head))
+		return
+	}
+	report.add("bytecode", target, checkPass, fmt.Sprintf("%d bytes at block %d", len(code), head))
+
+	creation, err := indexer.CreationBlock(ctx, client, contract.Address, head)
+	if err != nil {
+		report.add("startBlock", target, checkFail, fmt.Sprintf("find creation block: %v", err))
+		return
+	}
+	scanTo := head
+	if c.scanBlocks > 0 && creation+c.scanBlocks-1 < head {
+		scanTo = creation + c.scanBlocks - 1
+	}
+	firstEvent, found, err := c.firstEvent(ctx, client, contract.Address, event.ID, creation, scanTo)
+	switch {
+	case err != nil:
+		report.add("events", target, checkFail, fmt.Sprintf("filter %s logs: %v", event.Name, err))
+		return
+	case found:
+		report.add("events", target, checkPass, fmt.Sprintf("first %s event at block %d", event.Name, firstEvent))
+	case bytes.Contains(code, event.ID.Bytes()):
+		report.add("events", target, checkWarn,
+			fmt.Sprintf("no %s event between blocks %d and %d", event.Name, creation, scanTo))
+	default:
+		report.add("events", target, checkFail,
+			fmt.Sprintf("the bytecode does not emit %s and no event was found between blocks %d and %d", event.Name, creation, scanTo))
+	}
+
+	switch {
+	case contract.StartBlock == 0:
+		report.add("startBlock", target, checkPass,
+			fmt.Sprintf("detected on startup as the creation block %d", creation))
+	case found && contract.StartBlock > firstEvent:
+		report.add("startBlock", target, checkFail,
+			fmt.Sprintf("startBlock %d is after the first %s event at block %d", contract.StartBlock, event.Name, firstEvent))
+	case !found && contract.StartBlock > scanTo+1:
+		report.add("startBlock", target, checkWarn,
+			fmt.Sprintf("startBlock %d is after the creation block %d and events before it were only scanned up to block %d",
+				contract.StartBlock, creation, scanTo))
+	default:
+		report.add("startBlock", target, checkPass,
+			fmt.Sprintf("startBlock %d, creation block %d", contract.StartBlock, creation))
+	}
+}
+
+// firstEvent returns the block of the first event with the given topic emitted
+// by the contract between from and to.
+func (c *configChecker) firstEvent(
+	ctx context.Context,
+	client checkClient,
+	contract common.Address,
+	topic common.Hash,
+	from, to uint64,
+) (uint64, bool, error) {
+	batchSize := max(c.batchSize, 1)
+	for start := from; start <= to; start += batchSize {
+		end := min(start+batchSize-1, to)
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{contract},
+			Topics:    [][]common.Hash{{topic}},
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		if len(logs) > 0 {
+			first := logs[0].BlockNumber
+			for _, entry := range logs[1:] {
+				first = min(first, entry.BlockNumber)
+			}
+			return first, true, nil
+		}
+		if end == to {
+			break
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+)
+
+type fakeCheckChain struct {
+	chainID  uint64
+	head     uint64
+	code     map[common.Address][]byte
+	creation map[common.Address]uint64
+	events   map[common.Address][]uint64
+}
+
+func (f *fakeCheckChain) ChainID(context.Context) (*big.Int, error) {
+	return new(big.Int).SetUint64(f.chainID), nil
+}
+
+func (f *fakeCheckChain) BlockNumber(context.Context) (uint64, error) {
+	return f.head, nil
+}
+
+func (f *fakeCheckChain) CodeAt(_ context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber.Uint64() < f.creation[account] {
+		return nil, nil
+	}
+	return f.code[account], nil
+}
+
+func (f *fakeCheckChain) FilterLogs(_ context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	var logs []gethtypes.Log
+	for _, block := range f.events[query.Addresses[0]] {
+		if block >= query.FromBlock.Uint64() && block <= query.ToBlock.Uint64() {
+			logs = append(logs, gethtypes.Log{BlockNumber: block, Topics: []common.Hash{indexer.WeightChangedTopic}})
+		}
+	}
+	return logs, nil
+}
+
+func (f *fakeCheckChain) Close() {}
+
+func TestConfigCheckerReport(t *testing.T) {
+	good := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	late := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	empty := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	quiet := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	chain := &fakeCheckChain{
+		chainID: 1,
+		head:    1000,
+		code: map[common.Address][]byte{
+			good:  {0x60, 0x80, 0x60, 0x40},
+			late:  {0x60, 0x80, 0x60, 0x40},
+			quiet: append([]byte{0x7f}, indexer.WeightChangedTopic.Bytes()...),
+		},
+		creation: map[common.Address]uint64{good: 100, late: 200, quiet: 300},
+		events:   map[common.Address][]uint64{good: {150, 120}, late: {210}},
+	}
+	other := &fakeCheckChain{chainID: 5}
+	checker := &configChecker{
+		dial: func(_ context.Context, uri string) (checkClient, error) {
+			switch uri {
+			case "https://mainnet.example.org":
+				return chain, nil
+			case "https://goerli.example.org":
+				return other, nil
+			}
+			return nil, fmt.Errorf("connection refused")
+		},
+		timeout:   time.Second,
+		batchSize: 64,
+	}
+	cfg := &Config{
+		Chains: []ChainConfig{
+			{ChainID: 1, RPCs: []string{"https://mainnet.example.org", "https://down.example.org"}},
+			{ChainID: 10, RPCs: []string{"https://goerli.example.org"}},
+		},
+		Contracts: []indexer.ContractInfo{
+			{ChainID: 1, Address: good, StartBlock: 110},
+			{ChainID: 1, Address: late, StartBlock: 250},
+			{ChainID: 1, Address: empty},
+			{ChainID: 1, Address: quiet},
+			{ChainID: 10, Address: good},
+		},
+	}
+
+	report := checker.check(context.Background(), cfg)
+	statuses := make(map[string]string, len(report.Results))
+	for _, result := range report.Results {
+		statuses[result.Check+" "+result.Target] = result.Status
+	}
+	expected := map[string]string{
+		"rpc https://mainnet.example.org": checkPass,
+		"rpc https://down.example.org":    checkFail,
+		"rpc https://goerli.example.org":  checkFail,
+		"bytecode 1:" + good.Hex():        checkPass,
+		"events 1:" + good.Hex():          checkPass,
+		"startBlock 1:" + good.Hex():      checkPass,
+		"startBlock 1:" + late.Hex():      checkFail,
+		"bytecode 1:" + empty.Hex():       checkFail,
+		"events 1:" + quiet.Hex():         checkWarn,
+		"contract 10:" + good.Hex():       checkFail,
+	}
+	for key, want := range expected {
+		if got := statuses[key]; got != want {
+			t.Fatalf("expected %s to be %q, got %q (report: %+v)", key, want, got, report.Results)
+		}
+	}
+	if report.Failed != 5 {
+		t.Fatalf("expected 5 failed checks, got %d", report.Failed)
+	}
+}
+
+func TestConfigCheckerWithoutEndpoints(t *testing.T) {
+	checker := &configChecker{timeout: time.Second}
+	report := checker.check(context.Background(), &Config{
+		Contracts: []indexer.ContractInfo{{ChainID: 1, Address: common.HexToAddress("0x1111111111111111111111111111111111111111")}},
+	})
+	if report.Failed != 0 || report.Warnings != 1 {
+		t.Fatalf("expected a single warning, got %+v", report)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/pflag"
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/api"
+	"github.com/vocdoni/onchain-census-indexer/internal/archive"
+	"github.com/vocdoni/onchain-census-indexer/internal/backup"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// command is a maintenance subcommand run instead of the indexer service.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "export", summary: "Export a contract from the database into an archive file", run: runExport},
+	{name: "import", summary: "Import a contract archive file into the database", run: runImport},
+	{name: "backup", summary: "Create a consistent backup of the database", run: runBackup},
+	{name: "restore", summary: "Validate a backup and swap it in place of the database", run: runRestore},
+	{name: "fsck", summary: "Check the database for corrupt or orphaned entries", run: runFsck},
+	{name: "reindex", summary: "Schedule a re-index and re-verification of a block range", run: runReindex},
+	{name: "check", summary: "Verify the configured RPC endpoints and contracts against their chains", run: runCheck},
+}
+
+func lookupCommand(args []string) (command, bool) {
+	if len(args) == 0 {
+		return command{}, false
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand executes a subcommand and returns the process exit code.
+func runCommand(cmd command, args []string) int {
+	log.Init(cmp.Or(os.Getenv("LOG_LEVEL"), log.LogLevelInfo), "stderr", nil)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.run(ctx, args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		log.Errorf("%s: %v", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func newCommandFlags(name string) (*pflag.FlagSet, *string) {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	dbPath := fs.String("db.path", cmp.Or(os.Getenv("DB_PATH"), "data"), "Database path")
+	return fs, dbPath
+}
+
+func openStore(path string) (*store.Store, func(), error) {
+	database, err := store.OpenPebble(path, store.PebbleOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	closeFn := func() {
+		if cerr := database.Close(); cerr != nil {
+			log.Warnf("close database: %v", cerr)
+		}
+	}
+	eventStore := store.New(database)
+	if _, err := eventStore.Migrate(context.Background(), store.MigrateOptions{}); err != nil {
+		closeFn()
+		return nil, nil, fmt.Errorf("migrate database: %w", err)
+	}
+	return eventStore, closeFn, nil
+}
+
+func parseContractFlags(chainID uint64, contract string) (common.Address, error) {
+	if chainID == 0 {
+		return common.Address{}, fmt.Errorf("--chainId is required")
+	}
+	if !common.IsHexAddress(contract) {
+		return common.Address{}, fmt.Errorf("--contract must be a valid address")
+	}
+	return common.HexToAddress(contract), nil
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("export")
+	chainID := fs.Uint64("chainId", 0, "Chain ID of the contract to export")
+	contractRaw := fs.String("contract", "", "Address of the contract to export")
+	output := fs.String("output", "", "Archive file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	contract, err := parseContractFlags(*chainID, *contractRaw)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	eventStore, closeStore, err := openStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	tmp, err := os.CreateTemp(filepath.Dir(*output), filepath.Base(*output)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	count, err := archive.Export(ctx, eventStore, *chainID, contract, tmp)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), *output); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	log.Infow("contract exported",
+		"chainID", *chainID,
+		"contract", contract.Hex(),
+		"events", count,
+		"output", *output,
+	)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("import")
+	input := fs.String("input", "", "Archive file to read")
+	force := fs.Bool("force", false, "Replace existing data for the archived contract")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("--input is required")
+	}
+	file, err := os.Open(*input)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	eventStore, closeStore, err := openStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	header, count, err := archive.Import(ctx, eventStore, file, *force)
+	if err != nil {
+		if errors.Is(err, archive.ErrConflict) {
+			return fmt.Errorf("%w (use --force to replace it)", err)
+		}
+		return err
+	}
+	log.Infow("contract imported",
+		"chainID", header.Contract.ChainID,
+		"contract", header.Contract.Contract,
+		"events", count,
+		"archiveVersion", header.Version,
+		"archiveCreatedAt", header.CreatedAt,
+	)
+	return nil
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("backup")
+	dir := fs.String("backup.dir", cmp.Or(os.Getenv("BACKUP_DIR"), defaultBackupDir), "Directory where the backup is written")
+	keep := fs.Int("backup.keep", defaultBackupKeep, "Number of most recent backups to retain")
+	compress := fs.Bool("backup.compress", true, "Compress the backup as a .tar.gz archive")
+	server := fs.String("server", "", "URL of a running indexer to back up online through its admin API")
+	adminToken := fs.String("adminToken", os.Getenv("ADMIN_TOKEN"), "Admin token of the running indexer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var info backup.Info
+	if *server != "" {
+		var err error
+		info, err = requestOnlineBackup(ctx, *server, *adminToken)
+		if err != nil {
+			return err
+		}
+	} else {
+		eventStore, closeStore, err := openStore(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		manager, err := backup.NewManager(eventStore, backup.Config{Dir: *dir, Keep: *keep, Compress: *compress})
+		if err != nil {
+			return err
+		}
+		if info, err = manager.Create(ctx); err != nil {
+			return err
+		}
+	}
+	log.Infow("backup created", "name", info.Name, "path", info.Path, "size", info.Size)
+	return nil
+}
+
+func requestOnlineBackup(ctx context.Context, server, token string) (backup.Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+	var info backup.Info
+	if err := adminRequest(ctx, server, token, http.MethodPost, "/admin/backups", nil, http.StatusCreated, &info); err != nil {
+		return backup.Info{}, fmt.Errorf("request backup: %w", err)
+	}
+	return info, nil
+}
+
+// adminRequest sends a JSON request to the admin API of a running indexer and
+// decodes the response into out.
+func adminRequest(ctx context.Context, server, token, method, path string, body any, wantStatus int, out any) error {
+	if token == "" {
+		return fmt.Errorf("--adminToken is required with --server")
+	}
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(server, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != wantStatus {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("restore")
+	input := fs.String("input", "", "Backup directory or .tar.gz archive to restore")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("--input is required")
+	}
+	previous, err := backup.Restore(ctx, *input, *dbPath)
+	if err != nil {
+		return err
+	}
+	log.Infow("database restored", "input", *input, "dbPath", *dbPath, "previous", previous)
+	return nil
+}
+
+func runFsck(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("fsck")
+	repair := fs.Bool("repair", false, "Delete orphaned and corrupt entries and reset progress cursors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	eventStore, closeStore, err := openStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	report, err := eventStore.Fsck(ctx, store.FsckOptions{Repair: *repair})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if !report.Clean() {
+		return fmt.Errorf("%d finding(s) need attention", len(report.Findings))
+	}
+	return nil
+}
+
+func runReindex(ctx context.Context, args []string) error {
+	fs, dbPath := newCommandFlags("reindex")
+	chainID := fs.Uint64("chainId", 0, "Chain ID of the contract to re-index")
+	contractRaw := fs.String("contract", "", "Address of the contract to re-index")
+	from := fs.Uint64("from", 0, "First block of the range")
+	to := fs.Uint64("to", 0, "Last block of the range")
+	server := fs.String("server", "", "URL of a running indexer to schedule the job through its admin API")
+	adminToken := fs.String("adminToken", os.Getenv("ADMIN_TOKEN"), "Admin token of the running indexer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	contract, err := parseContractFlags(*chainID, *contractRaw)
+	if err != nil {
+		return err
+	}
+	if *to == 0 {
+		return fmt.Errorf("--to is required")
+	}
+
+	var job store.ReindexJob
+	if *server != "" {
+		req := api.ReindexRequest{ChainID: *chainID, Contract: contract.Hex(), From: *from, To: *to}
+		if err := adminRequest(ctx, *server, *adminToken, http.MethodPost, "/admin/reindex", req, http.StatusCreated, &job); err != nil {
+			return fmt.Errorf("schedule reindex: %w", err)
+		}
+	} else {
+		eventStore, closeStore, err := openStore(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		if job, err = eventStore.ScheduleReindex(ctx, *chainID, contract, *from, *to); err != nil {
+			return err
+		}
+	}
+	log.Infow("reindex scheduled",
+		"chainID", job.ChainID,
+		"contract", job.Contract,
+		"job", job.ID,
+		"from", job.From,
+		"to", job.To,
+	)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestLookupCommand(t *testing.T) {
+	if _, ok := lookupCommand(nil); ok {
+		t.Fatalf("expected no command without arguments")
+	}
+	if _, ok := lookupCommand([]string{"--db.path", "data"}); ok {
+		t.Fatalf("expected flags not to match a command")
+	}
+	cmd, ok := lookupCommand([]string{"export", "--chainId", "1"})
+	if !ok || cmd.name != "export" {
+		t.Fatalf("expected export command, got %q (ok=%t)", cmd.name, ok)
+	}
+}
+
+func TestExportImportCommands(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source")
+	targetPath := filepath.Join(dir, "target")
+	archivePath := filepath.Join(dir, "contract.archive")
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	source, closeSource, err := openStore(sourcePath)
+	if err != nil {
+		t.Fatalf("open source store: %v", err)
+	}
+	if err := source.SaveContract(ctx, 1, contract, 1, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := source.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "1", BlockNumber: 2},
+	}, 3); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	closeSource()
+
+	if err := runExport(ctx, []string{"--db.path", sourcePath, "--chainId", "1", "--contract", contract.Hex(), "--output", archivePath}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if err := runImport(ctx, []string{"--db.path", targetPath, "--input", archivePath}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if err := runImport(ctx, []string{"--db.path", targetPath, "--input", archivePath}); err == nil {
+		t.Fatalf("expected conflicting import to fail without --force")
+	}
+	if err := runImport(ctx, []string{"--db.path", targetPath, "--input", archivePath, "--force"}); err != nil {
+		t.Fatalf("forced import: %v", err)
+	}
+
+	target, closeTarget, err := openStore(targetPath)
+	if err != nil {
+		t.Fatalf("open target store: %v", err)
+	}
+	defer closeTarget()
+	if indexed, ok, err := target.LastIndexedBlock(ctx, 1, contract); err != nil || !ok || indexed != 3 {
+		t.Fatalf("expected imported indexed block 3, got %d (ok=%t, err=%v)", indexed, ok, err)
+	}
+}
@@ -11,36 +11,104 @@ import (
 	"github.com/spf13/viper"
 	"github.com/vocdoni/davinci-node/log"
 
+	"github.com/vocdoni/onchain-census-indexer/internal/graphqlapi"
 	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
 )
 
+const (
+	defaultBackupDir  = "backups"
+	defaultBackupKeep = 7
+
+	defaultArchiveGracePeriod = 30 * 24 * time.Hour
+	defaultExpiryWarning      = 72 * time.Hour
+
+	defaultRPCProbeInterval = 30 * time.Second
+	defaultRPCMaxLag        = 50
+
+	defaultMaxBackoff       = 5 * time.Minute
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+
+	defaultRestartThreshold = 5
+	defaultMaxRestartDelay  = 10 * time.Minute
+
+	migrateAuto   = "auto"
+	migrateDryRun = "dry-run"
+
+	dbBackendPebble   = "pebble"
+	dbBackendPostgres = "postgres"
+)
+
 type Config struct {
-	ContractsRaw string                 `mapstructure:"contracts"`
+	ConfigFile   string                 `mapstructure:"config"`
+	ContractsRaw string                 `mapstructure:"-"`
 	Contracts    []indexer.ContractInfo `mapstructure:"-"`
+	Chains       []ChainConfig          `mapstructure:"-"`
 	RPCs         []string               `mapstructure:"rpc"`
 	DB           DBConfig               `mapstructure:"db"`
 	HTTP         HTTPConfig             `mapstructure:"http"`
 	Indexer      IndexerConfig          `mapstructure:"indexer"`
+	Backup       BackupConfig           `mapstructure:"backup"`
 	Log          LogConfig              `mapstructure:"log"`
+
+	// source keeps the flag and environment bindings for Reload.
+	source *viper.Viper
 }
 
 type DBConfig struct {
-	Path string `mapstructure:"path"`
+	Backend     string `mapstructure:"backend"`
+	Path        string `mapstructure:"path"`
+	PostgresDSN string `mapstructure:"postgresDsn"`
+	Migrate     string `mapstructure:"migrate"`
 }
 
 type HTTPConfig struct {
 	ListenAddr         string   `mapstructure:"address"`
 	ListenPort         int      `mapstructure:"port"`
 	CORSAllowedOrigins []string `mapstructure:"corsAllowedOrigins"`
+	AdminToken         string   `mapstructure:"adminToken"`
+	// GraphQL limits; zero values do not limit.
+	GraphQLDefaultPageSize int           `mapstructure:"graphqlDefaultPageSize"`
+	GraphQLMaxPageSize     int           `mapstructure:"graphqlMaxPageSize"`
+	GraphQLMaxDepth        int           `mapstructure:"graphqlMaxDepth"`
+	GraphQLMaxComplexity   int           `mapstructure:"graphqlMaxComplexity"`
+	GraphQLTimeout         time.Duration `mapstructure:"graphqlTimeout"`
 }
 
 type IndexerConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
 	PollInterval         time.Duration `mapstructure:"pollInterval"`
 	ContractSyncInterval time.Duration `mapstructure:"contractSyncInterval"`
 	BatchSize            uint64        `mapstructure:"batchSize"`
 	VerifyBatchSize      uint64        `mapstructure:"verifyBatchSize"`
 	Confirmations        uint64        `mapstructure:"confirmations"`
 	TailRescanDepth      uint64        `mapstructure:"tailRescanDepth"`
+	ArchiveGracePeriod   time.Duration `mapstructure:"archiveGracePeriod"`
+	ExpiryWarning        time.Duration `mapstructure:"expiryWarning"`
+	RPCProbeInterval     time.Duration `mapstructure:"rpcProbeInterval"`
+	RPCMaxLag            uint64        `mapstructure:"rpcMaxLag"`
+	RPCMinHealthy        int           `mapstructure:"rpcMinHealthy"`
+	RPCRateLimit         float64       `mapstructure:"rpcRateLimit"`
+	RPCDailyCap          uint64        `mapstructure:"rpcDailyCap"`
+	MaxBackoff           time.Duration `mapstructure:"maxBackoff"`
+	BreakerThreshold     int           `mapstructure:"breakerThreshold"`
+	BreakerCooldown      time.Duration `mapstructure:"breakerCooldown"`
+	RestartThreshold     int           `mapstructure:"restartThreshold"`
+	MaxRestartDelay      time.Duration `mapstructure:"maxRestartDelay"`
+	AuditInterval        time.Duration `mapstructure:"auditInterval"`
+	AuditSample          int           `mapstructure:"auditSample"`
+	AuditReindex         bool          `mapstructure:"auditReindex"`
+	PauseOnUpgrade       bool          `mapstructure:"pauseOnUpgrade"`
+	BackfillWorkers      int           `mapstructure:"backfillWorkers"`
+	BackfillSegmentSize  uint64        `mapstructure:"backfillSegmentSize"`
+	BackfillChainLimit   int           `mapstructure:"backfillChainLimit"`
+}
+
+type BackupConfig struct {
+	Dir      string        `mapstructure:"dir"`
+	Keep     int           `mapstructure:"keep"`
+	Compress bool          `mapstructure:"compress"`
+	Interval time.Duration `mapstructure:"interval"`
 }
 
 type LogConfig struct {
@@ -48,58 +116,151 @@ type LogConfig struct {
 }
 
 func LoadConfig() (*Config, error) {
-	cfg := &Config{}
-
-	pflag.String("contracts", "", "Contracts in format chainID:contractAddress:blockNumber:expiresAt,chainID:contractAddress:blockNumber:expiresAt")
-	pflag.String("contract", "", "Deprecated: single contract in format chainID:contractAddress:blockNumber:expiresAt")
-	pflag.StringSlice("rpc", nil, "RPC endpoint (repeatable)")
-	pflag.String("db.path", "data", "Database path")
-	pflag.String("http.address", "0.0.0.0", "HTTP listen address")
-	pflag.Int("http.port", 8080, "HTTP listen port")
-	pflag.StringSlice("http.corsAllowedOrigins", []string{"*"}, "Allowed CORS origins (repeatable or comma-separated)")
-	pflag.Duration("indexer.pollInterval", 5*time.Second, "Polling interval")
-	pflag.Duration("indexer.contractSyncInterval", time.Second, "Contract reconciliation and expiration purge interval")
-	pflag.Uint64("indexer.batchSize", 50, "Block batch size per filterLogs")
-	pflag.Uint64("indexer.verifyBatchSize", 0, "Block batch size per verification rescan (defaults to batch size)")
-	pflag.Uint64("indexer.confirmations", 12, "Confirmation depth before blocks are considered safe to verify")
-	pflag.Uint64("indexer.tailRescanDepth", 0, "Depth of the verified tail window to continuously rescan (defaults to verify batch size)")
-	pflag.String("log.level", log.LogLevelDebug, "Log level (debug, info, warn, error)")
+	defineConfigFlags(pflag.CommandLine)
 	pflag.Parse()
+	config, err := newConfigSource(pflag.CommandLine)
+	if err != nil {
+		return nil, err
+	}
+	return loadConfig(config)
+}
+
+// defineConfigFlags defines the service settings on fs.
+func defineConfigFlags(fs *pflag.FlagSet) {
+	fs.String("config", "", "Configuration file (YAML or TOML) with chains, contracts and any other setting; reloaded on SIGHUP")
+	fs.String("contracts", "", "Contracts in format chainID:contractAddress:blockNumber:expiresAt,chainID:contractAddress:blockNumber:expiresAt")
+	fs.String("contract", "", "Deprecated: single contract in format chainID:contractAddress:blockNumber:expiresAt")
+	fs.StringSlice("rpc", nil, "RPC endpoint (repeatable)")
+	fs.String("db.backend", dbBackendPebble, "Storage backend (pebble or postgres)")
+	fs.String("db.path", "data", "Database path")
+	fs.String("db.postgresDsn", "", "PostgreSQL connection string (required by the postgres backend)")
+	fs.String("db.migrate", migrateAuto, "Schema migration mode: auto runs pending migrations on startup, dry-run prints them and exits")
+	fs.String("http.address", "0.0.0.0", "HTTP listen address")
+	fs.Int("http.port", 8080, "HTTP listen port")
+	fs.StringSlice("http.corsAllowedOrigins", []string{"*"}, "Allowed CORS origins (repeatable or comma-separated)")
+	fs.String("http.adminToken", "", "Bearer token enabling the /admin endpoints (disabled when empty)")
+	fs.Int("http.graphqlDefaultPageSize", 100, "Events returned by GraphQL queries with first: 0 (0 returns every event)")
+	fs.Int("http.graphqlMaxPageSize", 1000, "Largest first accepted by GraphQL queries (0 does not limit it)")
+	fs.Int("http.graphqlMaxDepth", 10, "Deepest field nesting accepted by GraphQL queries (0 does not limit it)")
+	fs.Int("http.graphqlMaxComplexity", 10000, "Largest estimated cost accepted by GraphQL queries (0 does not limit it)")
+	fs.Duration("http.graphqlTimeout", 10*time.Second, "Execution deadline of GraphQL queries (0 does not limit it)")
+	fs.Bool("indexer.enabled", true, "Run the indexer (disable for API-only replicas sharing a postgres store)")
+	fs.Duration("indexer.pollInterval", 5*time.Second, "Polling interval")
+	fs.Duration("indexer.contractSyncInterval", time.Second, "Contract reconciliation and expiration purge interval")
+	fs.Uint64("indexer.batchSize", 50, "Block batch size per filterLogs")
+	fs.Uint64("indexer.verifyBatchSize", 0, "Block batch size per verification rescan (defaults to batch size)")
+	fs.Uint64("indexer.confirmations", 12, "Confirmation depth before blocks are considered safe to verify")
+	fs.Uint64("indexer.tailRescanDepth", 0, "Depth of the verified tail window to continuously rescan (defaults to verify batch size)")
+	fs.Duration("indexer.archiveGracePeriod", defaultArchiveGracePeriod, "How long expired contracts stay archived and read-only before deletion (0 deletes them on expiry)")
+	fs.Duration("indexer.expiryWarning", defaultExpiryWarning, "How long before expiresAt contracts are reported as expiring soon and their expiry policy is evaluated")
+	fs.Duration("indexer.rpcProbeInterval", defaultRPCProbeInterval, "Interval between RPC endpoint health probes")
+	fs.Uint64("indexer.rpcMaxLag", defaultRPCMaxLag, "Blocks an RPC endpoint may trail the chain head before it is taken out of rotation")
+	fs.Int("indexer.rpcMinHealthy", 1, "Healthy RPC endpoints per chain below which chainlist endpoints are fetched again (automatic RPC only)")
+	fs.Float64("indexer.rpcRateLimit", 0, "RPC requests per second per chain (0 does not limit them)")
+	fs.Uint64("indexer.rpcDailyCap", 0, "RPC requests per chain and UTC day (0 does not cap them)")
+	fs.Duration("indexer.maxBackoff", defaultMaxBackoff, "Maximum delay between indexer retries after RPC errors")
+	fs.Int("indexer.breakerThreshold", defaultBreakerThreshold, "Consecutive RPC failures on a chain that pause all its indexers")
+	fs.Duration("indexer.breakerCooldown", defaultBreakerCooldown, "How long indexers of a chain stay paused before a single one probes the RPC again")
+	fs.Int("indexer.restartThreshold", defaultRestartThreshold, "Consecutive crashes after which an indexer is marked failed and no longer restarted")
+	fs.Duration("indexer.maxRestartDelay", defaultMaxRestartDelay, "Maximum delay before a crashed indexer is restarted")
+	fs.Duration("indexer.auditInterval", 0, "Interval between audits of ERC20 source balances against balanceOf (0 disables them)")
+	fs.Int("indexer.auditSample", 0, "Accounts compared per balance audit (0 compares all of them)")
+	fs.Bool("indexer.auditReindex", false, "Schedule a re-index of accounts whose audited balance differs from the contract state")
+	fs.Bool("indexer.pauseOnUpgrade", false, "Pause indexing of proxy contracts whose implementation changes until the upgrade is acknowledged")
+	fs.Int("indexer.backfillWorkers", 0, "Segments backfilled concurrently for contracts far behind the chain head (0 or 1 indexes sequentially)")
+	fs.Uint64("indexer.backfillSegmentSize", 0, "Blocks per backfill segment (0 uses 10 batches)")
+	fs.Int("indexer.backfillChainLimit", 0, "Backfill RPC requests in flight per chain across its indexers (0 does not limit them)")
+	fs.String("backup.dir", defaultBackupDir, "Directory where database backups are written")
+	fs.Int("backup.keep", defaultBackupKeep, "Number of most recent backups to retain")
+	fs.Bool("backup.compress", true, "Compress backups as .tar.gz archives")
+	fs.Duration("backup.interval", 0, "Interval between scheduled backups (0 disables them)")
+	fs.String("log.level", log.LogLevelDebug, "Log level (debug, info, warn, error)")
+}
 
+// newConfigSource returns the settings resolved from the flags of fs and the
+// environment.
+func newConfigSource(fs *pflag.FlagSet) (*viper.Viper, error) {
 	config := viper.New()
 	config.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	config.AutomaticEnv()
-	if err := config.BindPFlags(pflag.CommandLine); err != nil {
+	if err := config.BindPFlags(fs); err != nil {
 		return nil, fmt.Errorf("bind flags: %w", err)
 	}
+	_ = config.BindEnv("config", "CONFIG_FILE")
 	_ = config.BindEnv("contracts", "CONTRACTS")
 	_ = config.BindEnv("contract", "CONTRACT", "CONTRACT_ADDRESS")
 	_ = config.BindEnv("rpc", "RPCS", "RPC_ENDPOINTS")
+	_ = config.BindEnv("db.backend", "DB_BACKEND")
 	_ = config.BindEnv("db.path", "DB_PATH")
+	_ = config.BindEnv("db.postgresDsn", "DB_POSTGRES_DSN")
+	_ = config.BindEnv("db.migrate", "DB_MIGRATE")
 	_ = config.BindEnv("http.address", "LISTEN_ADDR", "ADDRESS")
 	_ = config.BindEnv("http.port", "LISTEN_PORT", "PORT")
 	_ = config.BindEnv("http.corsAllowedOrigins", "CORS_ALLOWED_ORIGINS")
+	_ = config.BindEnv("http.adminToken", "ADMIN_TOKEN")
+	_ = config.BindEnv("http.graphqlDefaultPageSize", "GRAPHQL_DEFAULT_PAGE_SIZE")
+	_ = config.BindEnv("http.graphqlMaxPageSize", "GRAPHQL_MAX_PAGE_SIZE")
+	_ = config.BindEnv("http.graphqlMaxDepth", "GRAPHQL_MAX_DEPTH")
+	_ = config.BindEnv("http.graphqlMaxComplexity", "GRAPHQL_MAX_COMPLEXITY")
+	_ = config.BindEnv("http.graphqlTimeout", "GRAPHQL_TIMEOUT")
+	_ = config.BindEnv("indexer.enabled", "INDEXER_ENABLED")
 	_ = config.BindEnv("indexer.pollInterval", "POLL_INTERVAL")
 	_ = config.BindEnv("indexer.contractSyncInterval", "CONTRACT_SYNC_INTERVAL")
 	_ = config.BindEnv("indexer.batchSize", "BATCH_SIZE")
 	_ = config.BindEnv("indexer.verifyBatchSize", "VERIFY_BATCH_SIZE")
 	_ = config.BindEnv("indexer.confirmations", "CONFIRMATIONS")
 	_ = config.BindEnv("indexer.tailRescanDepth", "TAIL_RESCAN_DEPTH")
+	_ = config.BindEnv("indexer.archiveGracePeriod", "ARCHIVE_GRACE_PERIOD")
+	_ = config.BindEnv("indexer.expiryWarning", "EXPIRY_WARNING")
+	_ = config.BindEnv("indexer.rpcProbeInterval", "RPC_PROBE_INTERVAL")
+	_ = config.BindEnv("indexer.rpcMaxLag", "RPC_MAX_LAG")
+	_ = config.BindEnv("indexer.rpcMinHealthy", "RPC_MIN_HEALTHY")
+	_ = config.BindEnv("indexer.rpcRateLimit", "RPC_RATE_LIMIT")
+	_ = config.BindEnv("indexer.rpcDailyCap", "RPC_DAILY_CAP")
+	_ = config.BindEnv("indexer.maxBackoff", "MAX_BACKOFF")
+	_ = config.BindEnv("indexer.breakerThreshold", "BREAKER_THRESHOLD")
+	_ = config.BindEnv("indexer.breakerCooldown", "BREAKER_COOLDOWN")
+	_ = config.BindEnv("indexer.restartThreshold", "RESTART_THRESHOLD")
+	_ = config.BindEnv("indexer.maxRestartDelay", "MAX_RESTART_DELAY")
+	_ = config.BindEnv("indexer.auditInterval", "AUDIT_INTERVAL")
+	_ = config.BindEnv("indexer.auditSample", "AUDIT_SAMPLE")
+	_ = config.BindEnv("indexer.auditReindex", "AUDIT_REINDEX")
+	_ = config.BindEnv("indexer.pauseOnUpgrade", "PAUSE_ON_UPGRADE")
+	_ = config.BindEnv("indexer.backfillWorkers", "BACKFILL_WORKERS")
+	_ = config.BindEnv("indexer.backfillSegmentSize", "BACKFILL_SEGMENT_SIZE")
+	_ = config.BindEnv("indexer.backfillChainLimit", "BACKFILL_CHAIN_LIMIT")
+	_ = config.BindEnv("backup.dir", "BACKUP_DIR")
+	_ = config.BindEnv("backup.keep", "BACKUP_KEEP")
+	_ = config.BindEnv("backup.compress", "BACKUP_COMPRESS")
+	_ = config.BindEnv("backup.interval", "BACKUP_INTERVAL")
 	_ = config.BindEnv("log.level", "LOG_LEVEL")
+	return config, nil
+}
 
-	if err := config.Unmarshal(cfg); err != nil {
-		return nil, fmt.Errorf("unmarshal config: %w", err)
+// Reload reads the configuration file again. Flags and environment variables
+// keep their precedence over the file.
+func (c *Config) Reload() (*Config, error) {
+	if c.ConfigFile == "" || c.source == nil {
+		return nil, fmt.Errorf("no configuration file to reload")
 	}
+	return loadConfig(c.source)
+}
 
-	if cfg.ContractsRaw == "" {
-		cfg.ContractsRaw = strings.TrimSpace(config.GetString("contract"))
-	}
-	if cfg.ContractsRaw != "" {
-		contracts, err := parseContractSpecs(cfg.ContractsRaw)
-		if err != nil {
-			return nil, fmt.Errorf("invalid contracts: %w", err)
+// loadConfig reads the configuration file, if any, and decodes and validates
+// the settings resolved by config.
+func loadConfig(config *viper.Viper) (*Config, error) {
+	if path := strings.TrimSpace(config.GetString("config")); path != "" {
+		config.SetConfigFile(path)
+		if err := config.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
 		}
-		cfg.Contracts = contracts
+	}
+	cfg := &Config{source: config}
+	if err := config.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	if err := decodeConfigFileEntries(config, cfg); err != nil {
+		return nil, err
 	}
 
 	if cfg.Log.Level == "" {
@@ -120,9 +281,90 @@ func LoadConfig() (*Config, error) {
 	if cfg.Indexer.TailRescanDepth == 0 {
 		cfg.Indexer.TailRescanDepth = cfg.Indexer.VerifyBatchSize
 	}
+	if cfg.Indexer.ArchiveGracePeriod < 0 {
+		return nil, fmt.Errorf("indexer.archiveGracePeriod must not be negative")
+	}
+	if cfg.Indexer.ExpiryWarning < 0 {
+		return nil, fmt.Errorf("indexer.expiryWarning must not be negative")
+	}
+	if cfg.Indexer.RPCProbeInterval < 0 {
+		return nil, fmt.Errorf("indexer.rpcProbeInterval must not be negative")
+	}
+	if cfg.Indexer.RPCProbeInterval == 0 {
+		cfg.Indexer.RPCProbeInterval = defaultRPCProbeInterval
+	}
+	if cfg.Indexer.RPCMaxLag == 0 {
+		cfg.Indexer.RPCMaxLag = defaultRPCMaxLag
+	}
+	if cfg.Indexer.RPCMinHealthy <= 0 {
+		cfg.Indexer.RPCMinHealthy = 1
+	}
+	if cfg.Indexer.RPCRateLimit < 0 {
+		return nil, fmt.Errorf("indexer.rpcRateLimit must not be negative")
+	}
+	if cfg.Indexer.MaxBackoff < 0 {
+		return nil, fmt.Errorf("indexer.maxBackoff must not be negative")
+	}
+	if cfg.Indexer.MaxBackoff == 0 {
+		cfg.Indexer.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Indexer.BreakerThreshold <= 0 {
+		cfg.Indexer.BreakerThreshold = defaultBreakerThreshold
+	}
+	if cfg.Indexer.BreakerCooldown < 0 {
+		return nil, fmt.Errorf("indexer.breakerCooldown must not be negative")
+	}
+	if cfg.Indexer.BreakerCooldown == 0 {
+		cfg.Indexer.BreakerCooldown = defaultBreakerCooldown
+	}
+	if cfg.Indexer.RestartThreshold <= 0 {
+		cfg.Indexer.RestartThreshold = defaultRestartThreshold
+	}
+	if cfg.Indexer.MaxRestartDelay < 0 {
+		return nil, fmt.Errorf("indexer.maxRestartDelay must not be negative")
+	}
+	if cfg.Indexer.MaxRestartDelay == 0 {
+		cfg.Indexer.MaxRestartDelay = defaultMaxRestartDelay
+	}
+	if cfg.Indexer.AuditInterval < 0 {
+		return nil, fmt.Errorf("indexer.auditInterval must not be negative")
+	}
+	if cfg.Indexer.AuditSample < 0 {
+		return nil, fmt.Errorf("indexer.auditSample must not be negative")
+	}
+	if cfg.Indexer.BackfillWorkers < 0 {
+		return nil, fmt.Errorf("indexer.backfillWorkers must not be negative")
+	}
+	if cfg.Indexer.BackfillChainLimit < 0 {
+		return nil, fmt.Errorf("indexer.backfillChainLimit must not be negative")
+	}
 	if cfg.DB.Path == "" {
 		cfg.DB.Path = "data"
 	}
+	switch cfg.DB.Backend {
+	case "":
+		cfg.DB.Backend = dbBackendPebble
+	case dbBackendPebble:
+	case dbBackendPostgres:
+		if strings.TrimSpace(cfg.DB.PostgresDSN) == "" {
+			return nil, fmt.Errorf("db.postgresDsn is required by the %s backend", dbBackendPostgres)
+		}
+	default:
+		return nil, fmt.Errorf("invalid db.backend %q (expected %s or %s)", cfg.DB.Backend, dbBackendPebble, dbBackendPostgres)
+	}
+	switch cfg.DB.Migrate {
+	case "":
+		cfg.DB.Migrate = migrateAuto
+	case migrateAuto, migrateDryRun:
+	default:
+		return nil, fmt.Errorf("invalid db.migrate %q (expected %s or %s)", cfg.DB.Migrate, migrateAuto, migrateDryRun)
+	}
+	if cfg.Backup.Dir == "" {
+		cfg.Backup.Dir = defaultBackupDir
+	}
+	if cfg.Backup.Keep <= 0 {
+		cfg.Backup.Keep = defaultBackupKeep
+	}
 	if cfg.HTTP.ListenAddr == "" {
 		cfg.HTTP.ListenAddr = "0.0.0.0"
 	}
@@ -133,10 +375,39 @@ func LoadConfig() (*Config, error) {
 	if len(cfg.HTTP.CORSAllowedOrigins) == 0 {
 		cfg.HTTP.CORSAllowedOrigins = []string{"*"}
 	}
+	if cfg.HTTP.GraphQLDefaultPageSize < 0 {
+		return nil, fmt.Errorf("http.graphqlDefaultPageSize must not be negative")
+	}
+	if cfg.HTTP.GraphQLMaxPageSize < 0 {
+		return nil, fmt.Errorf("http.graphqlMaxPageSize must not be negative")
+	}
+	if cfg.HTTP.GraphQLMaxPageSize > 0 && cfg.HTTP.GraphQLDefaultPageSize > cfg.HTTP.GraphQLMaxPageSize {
+		return nil, fmt.Errorf("http.graphqlDefaultPageSize must not exceed http.graphqlMaxPageSize")
+	}
+	if cfg.HTTP.GraphQLMaxDepth < 0 {
+		return nil, fmt.Errorf("http.graphqlMaxDepth must not be negative")
+	}
+	if cfg.HTTP.GraphQLMaxComplexity < 0 {
+		return nil, fmt.Errorf("http.graphqlMaxComplexity must not be negative")
+	}
+	if cfg.HTTP.GraphQLTimeout < 0 {
+		return nil, fmt.Errorf("http.graphqlTimeout must not be negative")
+	}
 
 	return cfg, nil
 }
 
+// graphqlLimits returns the limits applied to the GraphQL endpoints.
+func (c HTTPConfig) graphqlLimits() graphqlapi.Limits {
+	return graphqlapi.Limits{
+		DefaultPageSize: c.GraphQLDefaultPageSize,
+		MaxPageSize:     c.GraphQLMaxPageSize,
+		MaxDepth:        c.GraphQLMaxDepth,
+		MaxComplexity:   c.GraphQLMaxComplexity,
+		Timeout:         c.GraphQLTimeout,
+	}
+}
+
 func parseContractSpecs(value string) ([]indexer.ContractInfo, error) {
 	entries := strings.FieldsFunc(value, func(r rune) bool {
 		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == ';'
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// ChainConfig is a chain entry of the configuration file: its RPC endpoints and
+// the indexing settings overriding the service-wide ones.
+type ChainConfig struct {
+	ChainID       uint64        `mapstructure:"chainId"`
+	RPCs          []string      `mapstructure:"rpc"`
+	Confirmations *uint64       `mapstructure:"confirmations"`
+	PollInterval  time.Duration `mapstructure:"pollInterval"`
+	BatchSize     uint64        `mapstructure:"batchSize"`
+	// RateLimit and DailyCap override indexer.rpcRateLimit and
+	// indexer.rpcDailyCap for the chain.
+	RateLimit *float64 `mapstructure:"rateLimit"`
+	DailyCap  *uint64  `mapstructure:"dailyCap"`
+	// EndpointLimits limits the requests sent to single RPC endpoints.
+	EndpointLimits []EndpointLimitConfig `mapstructure:"endpointLimits"`
+}
+
+// EndpointLimitConfig limits the requests sent to an RPC endpoint of a chain.
+type EndpointLimitConfig struct {
+	URI       string  `mapstructure:"uri"`
+	RateLimit float64 `mapstructure:"rateLimit"`
+	DailyCap  uint64  `mapstructure:"dailyCap"`
+}
+
+// ContractConfig is a contract entry of the configuration file.
+type ContractConfig struct {
+	ChainID      uint64        `mapstructure:"chainId"`
+	Address      string        `mapstructure:"address"`
+	Label        string        `mapstructure:"label"`
+	StartBlock   uint64        `mapstructure:"startBlock"`
+	ExpiresAt    time.Time     `mapstructure:"expiresAt"`
+	AutoExtend   time.Duration `mapstructure:"autoExtend"`
+	MaxExpiresAt time.Time     `mapstructure:"maxExpiresAt"`
+	DeploymentTx string        `mapstructure:"deploymentTx"`
+	// EventProfile optionally indexes another event than WeightChanged.
+	EventProfile *EventProfileConfig `mapstructure:"eventProfile"`
+	// Source optionally names a predefined event profile, such as erc20.
+	Source string `mapstructure:"source"`
+}
+
+// EventProfileConfig is the event profile of a contract entry. ABI is a JSON
+// ABI fragment holding a single event.
+type EventProfileConfig struct {
+	ABI            string `mapstructure:"abi"`
+	Account        string `mapstructure:"account"`
+	NewWeight      string `mapstructure:"newWeight"`
+	PreviousWeight string `mapstructure:"previousWeight"`
+	From           string `mapstructure:"from"`
+	To             string `mapstructure:"to"`
+	Value          string `mapstructure:"value"`
+}
+
+// rpcEndpoint is a configured RPC endpoint. ChainID is zero for the global
+// endpoints, whose chain is detected from the endpoint itself.
+type rpcEndpoint struct {
+	ChainID uint64
+	URI     string
+}
+
+var configFileDecodeHook = viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToTimeHookFunc(time.RFC3339),
+	mapstructure.StringToSliceHookFunc(","),
+))
+
+// decodeConfigFileEntries decodes the chains and contracts of cfg. Contracts
+// are either the chainID:contractAddress:blockNumber:expiresAt string accepted
+// by --contracts or, in a configuration file, a list of contract entries.
+func decodeConfigFileEntries(config *viper.Viper, cfg *Config) error {
+	if err := config.UnmarshalKey("chains", &cfg.Chains, configFileDecodeHook); err != nil {
+		return fmt.Errorf("decode chains: %w", err)
+	}
+	if err := validateChains(cfg.Chains); err != nil {
+		return fmt.Errorf("invalid chains: %w", err)
+	}
+
+	switch value := config.Get("contracts").(type) {
+	case nil:
+	case string:
+		cfg.ContractsRaw = strings.TrimSpace(value)
+	case []any:
+		var entries []ContractConfig
+		if err := config.UnmarshalKey("contracts", &entries, configFileDecodeHook); err != nil {
+			return fmt.Errorf("decode contracts: %w", err)
+		}
+		contracts, err := contractsFromConfig(entries)
+		if err != nil {
+			return fmt.Errorf("invalid contracts: %w", err)
+		}
+		cfg.Contracts = contracts
+		return nil
+	default:
+		return fmt.Errorf("invalid contracts: expected a string or a list of entries, got %T", value)
+	}
+	if cfg.ContractsRaw == "" {
+		cfg.ContractsRaw = strings.TrimSpace(config.GetString("contract"))
+	}
+	if cfg.ContractsRaw != "" {
+		contracts, err := parseContractSpecs(cfg.ContractsRaw)
+		if err != nil {
+			return fmt.Errorf("invalid contracts: %w", err)
+		}
+		cfg.Contracts = contracts
+	}
+	return nil
+}
+
+func validateChains(chains []ChainConfig) error {
+	seen := make(map[uint64]struct{}, len(chains))
+	for i, chain := range chains {
+		if chain.ChainID == 0 {
+			return fmt.Errorf("entry %d: chainId is required", i)
+		}
+		if _, ok := seen[chain.ChainID]; ok {
+			return fmt.Errorf("chainId %d is configured twice", chain.ChainID)
+		}
+		seen[chain.ChainID] = struct{}{}
+		if chain.PollInterval < 0 {
+			return fmt.Errorf("chainId %d: pollInterval must not be negative", chain.ChainID)
+		}
+		for _, endpoint := range chain.RPCs {
+			if err := validateRPCEndpoint(endpoint); err != nil {
+				return fmt.Errorf("chainId %d: %w", chain.ChainID, err)
+			}
+		}
+		if chain.RateLimit != nil && *chain.RateLimit < 0 {
+			return fmt.Errorf("chainId %d: rateLimit must not be negative", chain.ChainID)
+		}
+		for _, limit := range chain.EndpointLimits {
+			if err := validateRPCEndpoint(limit.URI); err != nil {
+				return fmt.Errorf("chainId %d: endpointLimits: %w", chain.ChainID, err)
+			}
+			if limit.RateLimit < 0 {
+				return fmt.Errorf("chainId %d: endpointLimits: rateLimit must not be negative", chain.ChainID)
+			}
+		}
+	}
+	return nil
+}
+
+func validateRPCEndpoint(endpoint string) error {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return fmt.Errorf("invalid rpc endpoint %q: %w", endpoint, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid rpc endpoint %q (expected an http, https, ws or wss URL)", endpoint)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid rpc endpoint %q (missing host)", endpoint)
+	}
+	return nil
+}
+
+func contractsFromConfig(entries []ContractConfig) ([]indexer.ContractInfo, error) {
+	out := make([]indexer.ContractInfo, 0, len(entries))
+	seen := make(map[string]struct{}, len(entries))
+	for i, entry := range entries {
+		if entry.ChainID == 0 {
+			return nil, fmt.Errorf("entry %d: chainId is required", i)
+		}
+		if !common.IsHexAddress(entry.Address) {
+			return nil, fmt.Errorf("entry %d: invalid contract address %q", i, entry.Address)
+		}
+		if entry.ExpiresAt.IsZero() {
+			return nil, fmt.Errorf("entry %d: expiresAt is required", i)
+		}
+		if entry.AutoExtend < 0 {
+			return nil, fmt.Errorf("entry %d: autoExtend must not be negative", i)
+		}
+		deploymentTx, err := indexer.ParseDeploymentTx(entry.DeploymentTx)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		info := indexer.ContractInfo{
+			ChainID:      entry.ChainID,
+			Address:      common.HexToAddress(entry.Address),
+			StartBlock:   entry.StartBlock,
+			ExpiresAt:    entry.ExpiresAt.UTC(),
+			Label:        strings.TrimSpace(entry.Label),
+			DeploymentTx: deploymentTx,
+		}
+		if entry.EventProfile != nil {
+			info.EventProfile = &store.EventProfile{
+				ABI:            entry.EventProfile.ABI,
+				Account:        entry.EventProfile.Account,
+				NewWeight:      entry.EventProfile.NewWeight,
+				PreviousWeight: entry.EventProfile.PreviousWeight,
+				From:           entry.EventProfile.From,
+				To:             entry.EventProfile.To,
+				Value:          entry.EventProfile.Value,
+			}
+			if err := info.EventProfile.Validate(); err != nil {
+				return nil, fmt.Errorf("entry %d: invalid eventProfile: %w", i, err)
+			}
+		}
+		if entry.Source != "" {
+			if info.EventProfile != nil {
+				return nil, fmt.Errorf("entry %d: source and eventProfile are mutually exclusive", i)
+			}
+			if info.EventProfile, err = indexer.SourceProfile(entry.Source); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		if entry.AutoExtend > 0 || !entry.MaxExpiresAt.IsZero() {
+			info.Policy = &store.ExpiryPolicy{AutoExtend: store.Duration(entry.AutoExtend)}
+			if !entry.MaxExpiresAt.IsZero() {
+				maxExpiresAt := entry.MaxExpiresAt.UTC()
+				info.Policy.MaxExpiresAt = &maxExpiresAt
+			}
+			if err := info.Policy.Validate(info.ExpiresAt); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		if _, ok := seen[info.Key()]; ok {
+			return nil, fmt.Errorf("contract %s on chainId %d is configured twice", info.Address.Hex(), info.ChainID)
+		}
+		seen[info.Key()] = struct{}{}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// rpcEndpoints returns the global endpoints followed by the per-chain ones.
+func (c *Config) rpcEndpoints() []rpcEndpoint {
+	var endpoints []rpcEndpoint
+	for _, uri := range c.RPCs {
+		endpoints = append(endpoints, rpcEndpoint{URI: strings.TrimSpace(uri)})
+	}
+	for _, chain := range c.Chains {
+		for _, uri := range chain.RPCs {
+			endpoints = append(endpoints, rpcEndpoint{ChainID: chain.ChainID, URI: strings.TrimSpace(uri)})
+		}
+	}
+	return endpoints
+}
+
+// chainSettings returns the indexing overrides of the configured chains.
+func (c *Config) chainSettings() map[uint64]indexer.ChainSettings {
+	settings := make(map[uint64]indexer.ChainSettings, len(c.Chains))
+	for _, chain := range c.Chains {
+		settings[chain.ChainID] = indexer.ChainSettings{
+			PollInterval:  chain.PollInterval,
+			BatchSize:     chain.BatchSize,
+			Confirmations: chain.Confirmations,
+		}
+	}
+	return settings
+}
+
+// rateLimits returns the RPC request limits of the chains and endpoints.
+func (c *Config) rateLimits() indexer.RateLimitConfig {
+	limits := indexer.RateLimitConfig{
+		Chain: indexer.RateLimit{
+			RequestsPerSecond: c.Indexer.RPCRateLimit,
+			DailyCap:          c.Indexer.RPCDailyCap,
+		},
+		Chains:    make(map[uint64]indexer.RateLimit),
+		Endpoints: make(map[string]indexer.RateLimit),
+	}
+	for _, chain := range c.Chains {
+		if chain.RateLimit != nil || chain.DailyCap != nil {
+			limit := limits.Chain
+			if chain.RateLimit != nil {
+				limit.RequestsPerSecond = *chain.RateLimit
+			}
+			if chain.DailyCap != nil {
+				limit.DailyCap = *chain.DailyCap
+			}
+			limits.Chains[chain.ChainID] = limit
+		}
+		for _, endpoint := range chain.EndpointLimits {
+			limits.Endpoints[strings.TrimSpace(endpoint.URI)] = indexer.RateLimit{
+				RequestsPerSecond: endpoint.RateLimit,
+				DailyCap:          endpoint.DailyCap,
+			}
+		}
+	}
+	return limits
+}
+
+// chainConfirmations returns the confirmation depths overridden per chain.
+func (c *Config) chainConfirmations() map[uint64]uint64 {
+	confirmations := make(map[uint64]uint64, len(c.Chains))
+	for _, chain := range c.Chains {
+		if chain.Confirmations != nil {
+			confirmations[chain.ChainID] = *chain.Confirmations
+		}
+	}
+	return confirmations
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+const testConfigYAML = `
+db:
+  path: /var/lib/census
+http:
+  graphqlMaxPageSize: 500
+  graphqlTimeout: 5s
+indexer:
+  confirmations: 6
+chains:
+  - chainId: 11155111
+    rpc:
+      - https://sepolia.example.org
+      - wss://sepolia.example.org/ws
+    confirmations: 2
+    pollInterval: 12s
+    batchSize: 500
+    dailyCap: 100000
+    endpointLimits:
+      - uri: https://sepolia.example.org
+        rateLimit: 5
+contracts:
+  - chainId: 11155111
+    address: "0x2E6C3D4ED7dA2bAD613A3Ea30961db7bF8452b29"
+    label: DAO census
+    startBlock: 10085464
+    expiresAt: 2026-03-01T12:00:00Z
+    autoExtend: 168h
+    maxExpiresAt: "2026-06-01T00:00:00Z"
+  - chainId: 1
+    address: "0x1111111111111111111111111111111111111111"
+    expiresAt: "2026-03-15T00:00:00Z"
+    deploymentTx: "0x8f2a5c1e4b3d6a7f9e0c1b2d3a4f5e6d7c8b9a0f1e2d3c4b5a69788796a5b4c3"
+    eventProfile:
+      abi: '[{"type":"event","name":"VotingPowerSet","inputs":[{"name":"holder","type":"address","indexed":true},{"name":"power","type":"uint256"}]}]'
+      account: holder
+      newWeight: power
+`
+
+const testConfigTOML = `
+[db]
+path = "/var/lib/census"
+
+[[chains]]
+chainId = 11155111
+rpc = ["https://sepolia.example.org"]
+confirmations = 0
+
+[[contracts]]
+chainId = 11155111
+address = "0x2E6C3D4ED7dA2bAD613A3Ea30961db7bF8452b29"
+label = "DAO census"
+expiresAt = 2026-03-01T12:00:00Z
+`
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func loadConfigFile(t *testing.T, path string) (*Config, error) {
+	t.Helper()
+	config := viper.New()
+	config.Set("config", path)
+	return loadConfig(config)
+}
+
+func TestLoadConfigFileYAML(t *testing.T) {
+	cfg, err := loadConfigFile(t, writeConfigFile(t, "config.yaml", testConfigYAML))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.DB.Path != "/var/lib/census" || cfg.Indexer.Confirmations != 6 {
+		t.Fatalf("expected file settings to be decoded, got db=%+v indexer=%+v", cfg.DB, cfg.Indexer)
+	}
+	if limits := cfg.HTTP.graphqlLimits(); limits.MaxPageSize != 500 || limits.Timeout != 5*time.Second {
+		t.Fatalf("expected the GraphQL limits of the file, got %+v", limits)
+	}
+	if len(cfg.Chains) != 1 {
+		t.Fatalf("expected 1 chain, got %d", len(cfg.Chains))
+	}
+	chain := cfg.Chains[0]
+	if chain.ChainID != 11155111 || len(chain.RPCs) != 2 || chain.PollInterval != 12*time.Second || chain.BatchSize != 500 {
+		t.Fatalf("unexpected chain: %+v", chain)
+	}
+	if chain.Confirmations == nil || *chain.Confirmations != 2 {
+		t.Fatalf("expected 2 confirmations, got %v", chain.Confirmations)
+	}
+	if endpoints := cfg.rpcEndpoints(); len(endpoints) != 2 || endpoints[0].ChainID != 11155111 {
+		t.Fatalf("expected 2 endpoints for chain 11155111, got %+v", endpoints)
+	}
+	if confirmations := cfg.chainConfirmations(); confirmations[11155111] != 2 {
+		t.Fatalf("expected chain confirmations 2, got %v", confirmations)
+	}
+	limits := cfg.rateLimits()
+	if limit := limits.Chains[11155111]; limit.DailyCap != 100000 || limit.RequestsPerSecond != 0 {
+		t.Fatalf("expected a daily cap of 100000 for chain 11155111, got %+v", limit)
+	}
+	if limit := limits.Endpoints["https://sepolia.example.org"]; limit.RequestsPerSecond != 5 {
+		t.Fatalf("expected 5 requests per second for the endpoint, got %+v", limits.Endpoints)
+	}
+
+	if len(cfg.Contracts) != 2 {
+		t.Fatalf("expected 2 contracts, got %d", len(cfg.Contracts))
+	}
+	contract := cfg.Contracts[0]
+	if contract.Address != common.HexToAddress("0x2E6C3D4ED7dA2bAD613A3Ea30961db7bF8452b29") ||
+		contract.Label != "DAO census" || contract.StartBlock != 10085464 {
+		t.Fatalf("unexpected contract: %+v", contract)
+	}
+	if want := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC); !contract.ExpiresAt.Equal(want) {
+		t.Fatalf("expected expiresAt %s, got %s", want, contract.ExpiresAt)
+	}
+	if contract.Policy == nil || time.Duration(contract.Policy.AutoExtend) != 168*time.Hour || contract.Policy.MaxExpiresAt == nil {
+		t.Fatalf("expected an expiry policy, got %+v", contract.Policy)
+	}
+	if cfg.Contracts[1].Policy != nil || cfg.Contracts[1].Label != "" {
+		t.Fatalf("expected the second contract without policy or label, got %+v", cfg.Contracts[1])
+	}
+	if contract.DeploymentTx != nil {
+		t.Fatalf("expected the first contract without deployment tx, got %s", contract.DeploymentTx.Hex())
+	}
+	want := common.HexToHash("0x8f2a5c1e4b3d6a7f9e0c1b2d3a4f5e6d7c8b9a0f1e2d3c4b5a69788796a5b4c3")
+	if txHash := cfg.Contracts[1].DeploymentTx; txHash == nil || *txHash != want {
+		t.Fatalf("expected deployment tx %s, got %v", want.Hex(), txHash)
+	}
+	if contract.EventProfile != nil {
+		t.Fatalf("expected the first contract without event profile, got %+v", contract.EventProfile)
+	}
+	if profile := cfg.Contracts[1].EventProfile; profile == nil || profile.Account != "holder" || profile.NewWeight != "power" {
+		t.Fatalf("expected the VotingPowerSet profile, got %+v", profile)
+	}
+}
+
+func TestLoadExampleConfigFile(t *testing.T) {
+	cfg, err := loadConfigFile(t, filepath.Join("..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("load example config: %v", err)
+	}
+	if len(cfg.Chains) == 0 || len(cfg.Contracts) == 0 {
+		t.Fatalf("expected the example to configure chains and contracts, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigFileTOML(t *testing.T) {
+	cfg, err := loadConfigFile(t, writeConfigFile(t, "config.toml", testConfigTOML))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.Chains) != 1 || cfg.Chains[0].Confirmations == nil || *cfg.Chains[0].Confirmations != 0 {
+		t.Fatalf("expected a chain with 0 confirmations, got %+v", cfg.Chains)
+	}
+	if len(cfg.Contracts) != 1 || cfg.Contracts[0].Label != "DAO census" {
+		t.Fatalf("expected 1 labeled contract, got %+v", cfg.Contracts)
+	}
+}
+
+func TestLoadConfigKeepsContractSpecString(t *testing.T) {
+	config := viper.New()
+	config.Set("contracts", "1:0x1111111111111111111111111111111111111111:5:2026-03-01T12:00:00Z")
+	cfg, err := loadConfig(config)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.Contracts) != 1 || cfg.Contracts[0].StartBlock != 5 {
+		t.Fatalf("expected 1 contract from the spec string, got %+v", cfg.Contracts)
+	}
+}
+
+func TestLoadConfigFileValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "duplicate chain",
+			content: "chains:\n  - chainId: 1\n  - chainId: 1\n",
+			wantErr: "configured twice",
+		},
+		{
+			name:    "missing chain id",
+			content: "chains:\n  - rpc: [https://rpc.example.org]\n",
+			wantErr: "chainId is required",
+		},
+		{
+			name:    "invalid rpc endpoint",
+			content: "chains:\n  - chainId: 1\n    rpc: [\"rpc.example.org\"]\n",
+			wantErr: "invalid rpc endpoint",
+		},
+		{
+			name:    "invalid contract address",
+			content: "contracts:\n  - chainId: 1\n    address: 0x123\n    expiresAt: 2026-03-01T12:00:00Z\n",
+			wantErr: "invalid contract address",
+		},
+		{
+			name:    "missing expiresAt",
+			content: "contracts:\n  - chainId: 1\n    address: \"0x1111111111111111111111111111111111111111\"\n",
+			wantErr: "expiresAt is required",
+		},
+		{
+			name: "duplicate contract",
+			content: "contracts:\n" +
+				"  - {chainId: 1, address: \"0x1111111111111111111111111111111111111111\", expiresAt: \"2026-03-01T12:00:00Z\"}\n" +
+				"  - {chainId: 1, address: \"0x1111111111111111111111111111111111111111\", expiresAt: \"2026-03-02T12:00:00Z\"}\n",
+			wantErr: "configured twice",
+		},
+		{
+			name: "expiresAt after maxExpiresAt",
+			content: "contracts:\n  - chainId: 1\n    address: \"0x1111111111111111111111111111111111111111\"\n" +
+				"    expiresAt: 2026-03-01T12:00:00Z\n    maxExpiresAt: 2026-02-01T12:00:00Z\n",
+			wantErr: "maxExpiresAt",
+		},
+		{
+			name: "unknown source",
+			content: "contracts:\n  - chainId: 1\n    address: \"0x1111111111111111111111111111111111111111\"\n" +
+				"    expiresAt: 2026-03-01T12:00:00Z\n    source: erc721\n",
+			wantErr: "unknown source",
+		},
+		{
+			name:    "negative rate limit",
+			content: "chains:\n  - chainId: 1\n    rateLimit: -1\n",
+			wantErr: "rateLimit must not be negative",
+		},
+		{
+			name:    "invalid endpoint limit",
+			content: "chains:\n  - chainId: 1\n    endpointLimits:\n      - uri: rpc.example.org\n",
+			wantErr: "endpointLimits",
+		},
+		{
+			name:    "default page size above the maximum",
+			content: "http:\n  graphqlDefaultPageSize: 50\n  graphqlMaxPageSize: 10\n",
+			wantErr: "must not exceed http.graphqlMaxPageSize",
+		},
+		{
+			name:    "invalid duration",
+			content: "chains:\n  - chainId: 1\n    pollInterval: soon\n",
+			wantErr: "decode chains",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfigFile(t, writeConfigFile(t, "config.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -2,23 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
-	"github.com/vocdoni/davinci-node/db"
-	"github.com/vocdoni/davinci-node/db/metadb"
 	"github.com/vocdoni/davinci-node/log"
-	"github.com/vocdoni/davinci-node/web3/rpc"
 
 	"github.com/vocdoni/onchain-census-indexer/internal/api"
+	"github.com/vocdoni/onchain-census-indexer/internal/backup"
 	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
 	"github.com/vocdoni/onchain-census-indexer/internal/store"
 )
 
 func main() {
+	if cmd, ok := lookupCommand(os.Args[1:]); ok {
+		os.Exit(runCommand(cmd, os.Args[2:]))
+	}
+
 	cfg, err := LoadConfig()
 	logLevel := log.LogLevelDebug
 	if err == nil && cfg.Log.Level != "" {
@@ -30,45 +34,84 @@ func main() {
 	}
 
 	log.Infow("starting onchain census indexer",
-		"contracts", cfg.ContractsRaw,
+		"configFile", cfg.ConfigFile,
+		"contracts", len(cfg.Contracts),
+		"chains", len(cfg.Chains),
+		"dbBackend", cfg.DB.Backend,
 		"dbPath", cfg.DB.Path,
 		"listen", cfg.HTTP.ListenAddr,
 		"corsAllowedOrigins", strings.Join(cfg.HTTP.CORSAllowedOrigins, ","),
+		"graphqlMaxPageSize", cfg.HTTP.GraphQLMaxPageSize,
+		"graphqlMaxDepth", cfg.HTTP.GraphQLMaxDepth,
+		"graphqlMaxComplexity", cfg.HTTP.GraphQLMaxComplexity,
+		"graphqlTimeout", cfg.HTTP.GraphQLTimeout.String(),
 		"pollInterval", cfg.Indexer.PollInterval.String(),
 		"contractSyncInterval", cfg.Indexer.ContractSyncInterval.String(),
 		"batchSize", cfg.Indexer.BatchSize,
 		"verifyBatchSize", cfg.Indexer.VerifyBatchSize,
 		"confirmations", cfg.Indexer.Confirmations,
 		"tailRescanDepth", cfg.Indexer.TailRescanDepth,
+		"archiveGracePeriod", cfg.Indexer.ArchiveGracePeriod.String(),
+		"expiryWarning", cfg.Indexer.ExpiryWarning.String(),
+		"rpcProbeInterval", cfg.Indexer.RPCProbeInterval.String(),
+		"rpcMaxLag", cfg.Indexer.RPCMaxLag,
+		"rpcRateLimit", cfg.Indexer.RPCRateLimit,
+		"rpcDailyCap", cfg.Indexer.RPCDailyCap,
+		"maxBackoff", cfg.Indexer.MaxBackoff.String(),
+		"breakerThreshold", cfg.Indexer.BreakerThreshold,
+		"breakerCooldown", cfg.Indexer.BreakerCooldown.String(),
+		"restartThreshold", cfg.Indexer.RestartThreshold,
+		"maxRestartDelay", cfg.Indexer.MaxRestartDelay.String(),
+		"auditInterval", cfg.Indexer.AuditInterval.String(),
+		"pauseOnUpgrade", cfg.Indexer.PauseOnUpgrade,
+		"backfillWorkers", cfg.Indexer.BackfillWorkers,
+		"backfillChainLimit", cfg.Indexer.BackfillChainLimit,
+		"backupDir", cfg.Backup.Dir,
+		"backupInterval", cfg.Backup.Interval.String(),
+		"admin", cfg.HTTP.AdminToken != "",
 		"rpcs", strings.Join(cfg.RPCs, ","),
 	)
 
-	database, err := metadb.New(db.TypePebble, cfg.DB.Path)
+	eventStore, pebbleStore, closeStore, err := openBackend(context.Background(), cfg.DB)
 	if err != nil {
 		log.Fatalf("open database: %v", err)
 	}
-	defer func() {
-		if cerr := database.Close(); cerr != nil {
-			log.Warnf("close database: %v", cerr)
+	defer closeStore()
+	migration, err := eventStore.Migrate(context.Background(), store.MigrateOptions{DryRun: cfg.DB.Migrate == migrateDryRun})
+	if err != nil {
+		log.Fatalf("migrate database: %v", err)
+	}
+	if migration.DryRun {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(migration); err != nil {
+			log.Fatalf("write migration plan: %v", err)
 		}
-	}()
-	eventStore := store.New(database)
+		return
+	}
+	log.Infow("database schema ready", "from", migration.From, "version", migration.To, "applied", len(migration.Pending))
 
-	autoRPC := len(cfg.RPCs) == 0
-	var pool *rpc.Web3Pool
-	if autoRPC {
-		var err error
-		pool, err = rpc.NewAutomaticWeb3Pool()
-		if err != nil {
-			log.Fatalf("create automatic rpc pool: %v", err)
-		}
-	} else {
-		pool = rpc.NewWeb3Pool()
-		for _, endpoint := range cfg.RPCs {
-			if _, err := pool.AddEndpoint(endpoint); err != nil {
-				log.Fatalf("add RPC endpoint %s: %v", endpoint, err)
-			}
-		}
+	limiter, err := indexer.NewRateLimiter(cfg.rateLimits())
+	if err != nil {
+		log.Fatalf("create rpc rate limiter: %v", err)
+	}
+	// The pool and the health probes send their requests through the limiter.
+	pool := indexer.NewRPCPool(limiter.Transport(http.DefaultTransport))
+	endpoints := cfg.rpcEndpoints()
+	autoRPC := len(endpoints) == 0
+	health, err := indexer.NewHealthTracker(indexer.HealthConfig{
+		Pool:                pool,
+		ProbeInterval:       cfg.Indexer.RPCProbeInterval,
+		MaxLag:              cfg.Indexer.RPCMaxLag,
+		MinHealthy:          cfg.Indexer.RPCMinHealthy,
+		AutoRPC:             autoRPC,
+		AutoRPCMaxEndpoints: 3,
+	})
+	if err != nil {
+		log.Fatalf("create rpc health tracker: %v", err)
+	}
+	if err := addRPCEndpoints(context.Background(), health, endpoints); err != nil {
+		log.Fatal(err.Error())
 	}
 
 	indexerService, err := indexer.NewService(indexer.ServiceConfig{
@@ -82,6 +125,23 @@ func main() {
 		ContractSyncInterval: cfg.Indexer.ContractSyncInterval,
 		AutoRPC:              autoRPC,
 		AutoRPCMaxEndpoints:  3,
+		ArchiveGracePeriod:   cfg.Indexer.ArchiveGracePeriod,
+		ExpiryWarning:        cfg.Indexer.ExpiryWarning,
+		Chains:               cfg.chainSettings(),
+		Health:               health,
+		MaxBackoff:           cfg.Indexer.MaxBackoff,
+		BreakerThreshold:     cfg.Indexer.BreakerThreshold,
+		BreakerCooldown:      cfg.Indexer.BreakerCooldown,
+		RestartThreshold:     cfg.Indexer.RestartThreshold,
+		MaxRestartDelay:      cfg.Indexer.MaxRestartDelay,
+		AuditInterval:        cfg.Indexer.AuditInterval,
+		AuditSample:          cfg.Indexer.AuditSample,
+		AuditReindex:         cfg.Indexer.AuditReindex,
+		PauseOnUpgrade:       cfg.Indexer.PauseOnUpgrade,
+		BackfillWorkers:      cfg.Indexer.BackfillWorkers,
+		BackfillSegmentSize:  cfg.Indexer.BackfillSegmentSize,
+		BackfillChainLimit:   cfg.Indexer.BackfillChainLimit,
+		RateLimiter:          limiter,
 	})
 	if err != nil {
 		log.Fatalf("create indexer service: %v", err)
@@ -90,16 +150,38 @@ func main() {
 	if err != nil {
 		log.Fatalf("create api service: %v", err)
 	}
-
-	seeded := 0
-	for _, spec := range cfg.Contracts {
-		if err := eventStore.SaveContract(context.Background(), spec.ChainID, spec.Address, spec.StartBlock, spec.ExpiresAt); err != nil {
-			log.Errorf("store contract %s: %v", spec.Address.Hex(), err)
-			continue
+	apiService.SetExpiryWarning(cfg.Indexer.ExpiryWarning)
+	apiService.SetGraphQLLimits(cfg.HTTP.graphqlLimits())
+	apiService.SetChainConfirmations(cfg.chainConfirmations())
+	apiService.SetRPCStatus(health)
+	apiService.SetRPCLimits(limiter)
+	apiService.SetChainHeads(indexerService.Heads())
+	apiService.SetIndexerStatus(indexerService)
+	// Backups are Pebble checkpoints; PostgreSQL deployments use their own tooling.
+	var backupManager *backup.Manager
+	if pebbleStore != nil {
+		backupManager, err = backup.NewManager(pebbleStore, backup.Config{
+			Dir:      cfg.Backup.Dir,
+			Keep:     cfg.Backup.Keep,
+			Compress: cfg.Backup.Compress,
+		})
+		if err != nil {
+			log.Fatalf("create backup manager: %v", err)
+		}
+	}
+	if cfg.HTTP.AdminToken != "" {
+		if err := apiService.EnableAdmin(api.AdminConfig{
+			Token:    cfg.HTTP.AdminToken,
+			Backups:  backupManager,
+			Indexers: indexerService,
+			// Online repairs race with the indexer writes.
+			AllowRepair: !cfg.Indexer.Enabled,
+		}); err != nil {
+			log.Fatalf("enable admin api: %v", err)
 		}
-		seeded++
 	}
-	if seeded == 0 {
+
+	if seeded := seedContracts(context.Background(), eventStore, cfg.Contracts); seeded == 0 {
 		log.Infow("no contracts provided on startup; waiting for /contracts registration")
 	}
 
@@ -110,8 +192,34 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	indexerErr := indexerService.Start(ctx)
-	go logIndexerErrors(ctx, indexerErr)
+	if cfg.ConfigFile != "" {
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+		defer signal.Stop(reloadSignals)
+		reloader := &configReloader{
+			cfg:     cfg,
+			health:  health,
+			store:   eventStore,
+			indexer: indexerService,
+			api:     apiService,
+			limiter: limiter,
+		}
+		go reloader.run(ctx, reloadSignals)
+	}
+
+	if cfg.Indexer.Enabled {
+		indexerErr := indexerService.Start(ctx)
+		go logIndexerErrors(ctx, indexerErr)
+	} else {
+		log.Infow("indexer disabled; serving the shared store only")
+	}
+	go health.Run(ctx)
+	if backupManager != nil && cfg.Backup.Interval > 0 {
+		go backupManager.Run(ctx, cfg.Backup.Interval)
+	}
+	if pebbleStore != nil {
+		go migrateEventEncoding(ctx, pebbleStore)
+	}
 
 	serverErr := make(chan error, 1)
 	go func() {
@@ -122,7 +230,8 @@ func main() {
 		"addr", cfg.HTTP.ListenAddr,
 		"port", cfg.HTTP.ListenPort,
 		"graphql", "/{chainID}/{contract}/graphql",
-		"healthz", "/healthz")
+		"healthz", "/healthz",
+		"rpc", "/rpc")
 
 	select {
 	case <-ctx.Done():
@@ -133,6 +242,49 @@ func main() {
 	}
 }
 
+// openBackend opens the configured storage backend. The Pebble store is also
+// returned on its own (nil for PostgreSQL) for the features only it supports,
+// such as checkpoint backups.
+func openBackend(ctx context.Context, cfg DBConfig) (store.Backend, *store.Store, func(), error) {
+	if cfg.Backend == dbBackendPostgres {
+		pgStore, err := store.OpenPostgres(ctx, cfg.PostgresDSN)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		closeFn := func() {
+			if cerr := pgStore.Close(); cerr != nil {
+				log.Warnf("close database: %v", cerr)
+			}
+		}
+		return pgStore, nil, closeFn, nil
+	}
+	database, err := store.OpenPebble(cfg.Path, store.PebbleOptions{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	closeFn := func() {
+		if cerr := database.Close(); cerr != nil {
+			log.Warnf("close database: %v", cerr)
+		}
+	}
+	pebbleStore := store.New(database)
+	return pebbleStore, pebbleStore, closeFn, nil
+}
+
+// migrateEventEncoding rewrites legacy JSON event values in the background.
+func migrateEventEncoding(ctx context.Context, eventStore *store.Store) {
+	migrated, err := eventStore.MigrateEventEncoding(ctx)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Warnw("event encoding migration stopped", "migrated", migrated, "err", err)
+		}
+		return
+	}
+	if migrated > 0 {
+		log.Infow("event encoding migration completed", "migrated", migrated)
+	}
+}
+
 func logIndexerErrors(ctx context.Context, errCh <-chan error) {
 	for {
 		select {
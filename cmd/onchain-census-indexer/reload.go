@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/api"
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// addRPCEndpoints adds endpoints to the pool through the health tracker.
+// Endpoints configured for a chain must serve that chain and are not added
+// otherwise.
+func addRPCEndpoints(ctx context.Context, health *indexer.HealthTracker, endpoints []rpcEndpoint) error {
+	var errs []error
+	for _, endpoint := range endpoints {
+		if _, err := health.AddEndpoint(ctx, endpoint.URI, endpoint.ChainID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// seedContracts stores the configured contracts with their labels and expiry
+// policies and returns how many were stored. Expirations moved forward by an
+// automatic extension are kept. Archived contracts are skipped until they are
+// restored.
+func seedContracts(ctx context.Context, backend store.Backend, specs []indexer.ContractInfo) int {
+	seeded := 0
+	for _, spec := range specs {
+		err := seedContract(ctx, backend, spec)
+		if errors.Is(err, store.ErrContractArchived) {
+			log.Debugw("skip archived contract", "chainID", spec.ChainID, "contract", spec.Address.Hex())
+			continue
+		}
+		if err != nil {
+			log.Errorf("store contract %s: %v", spec.Address.Hex(), err)
+			continue
+		}
+		seeded++
+	}
+	return seeded
+}
+
+func seedContract(ctx context.Context, backend store.Backend, spec indexer.ContractInfo) error {
+	record, exists, err := backend.GetContract(ctx, spec.ChainID, spec.Address)
+	if err != nil {
+		return err
+	}
+	if exists && record.Archive != nil {
+		return store.ErrContractArchived
+	}
+	if exists && spec.EventProfile != nil && !indexer.SameEventProfile(record.EventProfile, spec.EventProfile) {
+		return fmt.Errorf("eventProfile of a registered contract cannot be changed")
+	}
+	expiresAt := spec.ExpiresAt
+	policy := spec.Policy
+	if exists && record.Policy != nil && record.Policy.LastEventBlock > 0 {
+		if record.ExpiresAt.After(expiresAt) {
+			expiresAt = record.ExpiresAt
+		}
+		if policy != nil {
+			carried := *policy
+			carried.LastEventBlock = record.Policy.LastEventBlock
+			policy = &carried
+		}
+	}
+	if policy != nil && policy.MaxExpiresAt != nil && expiresAt.After(*policy.MaxExpiresAt) {
+		expiresAt = *policy.MaxExpiresAt
+	}
+	if err := backend.SaveContract(ctx, spec.ChainID, spec.Address, spec.StartBlock, expiresAt); err != nil {
+		return err
+	}
+	// The policy and label are applied even when unset, so removing them
+	// from the configuration clears them.
+	if err := backend.SetContractExpiry(ctx, spec.ChainID, spec.Address, expiresAt, policy); err != nil {
+		return err
+	}
+	if err := backend.SetContractLabel(ctx, spec.ChainID, spec.Address, spec.Label); err != nil {
+		return err
+	}
+	if spec.DeploymentTx != nil {
+		if err := backend.SetContractDeploymentTx(ctx, spec.ChainID, spec.Address, *spec.DeploymentTx); err != nil {
+			return err
+		}
+	}
+	if spec.EventProfile != nil {
+		if err := backend.SetContractEventProfile(ctx, spec.ChainID, spec.Address, spec.EventProfile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configReloader applies configuration file changes to the running services.
+type configReloader struct {
+	cfg     *Config
+	health  *indexer.HealthTracker
+	store   store.Backend
+	indexer *indexer.Service
+	api     *api.Service
+	limiter *indexer.RateLimiter
+}
+
+// run reloads the configuration every time a signal is received.
+func (r *configReloader) run(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := r.reload(ctx); err != nil {
+				log.Warnw("configuration reload failed; keeping the current configuration", "err", err)
+			}
+		}
+	}
+}
+
+// reload reads and validates the configuration file, then adds new RPC
+// endpoints, replaces the per-chain settings and stores the configured
+// contracts. Other settings only take effect after a restart.
+func (r *configReloader) reload(ctx context.Context) error {
+	next, err := r.cfg.Reload()
+	if err != nil {
+		return err
+	}
+	for _, section := range changedRestartSections(r.cfg, next) {
+		log.Warnw("configuration change requires a restart", "section", section)
+	}
+
+	configured := make(map[rpcEndpoint]struct{})
+	for _, endpoint := range r.cfg.rpcEndpoints() {
+		configured[endpoint] = struct{}{}
+	}
+	var added []rpcEndpoint
+	for _, endpoint := range next.rpcEndpoints() {
+		if _, ok := configured[endpoint]; ok {
+			delete(configured, endpoint)
+			continue
+		}
+		added = append(added, endpoint)
+	}
+	if len(configured) > 0 {
+		log.Warnw("removing RPC endpoints requires a restart", "endpoints", len(configured))
+	}
+	if err := addRPCEndpoints(ctx, r.health, added); err != nil {
+		log.Warnw("add reloaded RPC endpoints", "err", err)
+	}
+	r.indexer.SetChainSettings(next.chainSettings())
+	if r.limiter != nil {
+		if err := r.limiter.SetLimits(next.rateLimits()); err != nil {
+			log.Warnw("update rpc rate limits", "err", err)
+		}
+	}
+	r.api.SetChainConfirmations(next.chainConfirmations())
+
+	seeded := seedContracts(ctx, r.store, next.Contracts)
+	if err := r.api.SyncFromStore(ctx); err != nil {
+		return fmt.Errorf("sync api contracts: %w", err)
+	}
+	r.cfg = next
+	log.Infow("configuration reloaded",
+		"file", next.ConfigFile,
+		"contracts", seeded,
+		"rpcEndpointsAdded", len(added),
+		"chains", len(next.Chains),
+	)
+	return nil
+}
+
+// changedRestartSections returns the configuration sections that changed but
+// are only read on startup.
+func changedRestartSections(current, next *Config) []string {
+	var changed []string
+	for _, section := range []struct {
+		name          string
+		current, next any
+	}{
+		{"db", current.DB, next.DB},
+		{"http", current.HTTP, next.HTTP},
+		{"indexer", current.Indexer, next.Indexer},
+		{"backup", current.Backup, next.Backup},
+		{"log", current.Log, next.Log},
+	} {
+		if !reflect.DeepEqual(section.current, section.next) {
+			changed = append(changed, section.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/api"
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestConfigReloaderAppliesContracts(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contractA := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	contractB := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	expiresAt := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second).Format(time.RFC3339)
+	entry := func(address common.Address, label string) string {
+		return fmt.Sprintf("  - {chainId: 1, address: %q, label: %q, startBlock: 1, expiresAt: %q}\n", address.Hex(), label, expiresAt)
+	}
+	path := writeConfigFile(t, "config.yaml", "contracts:\n"+entry(contractA, "first"))
+	cfg, err := loadConfigFile(t, path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	pool := indexer.NewRPCPool(nil)
+	health, err := indexer.NewHealthTracker(indexer.HealthConfig{Pool: pool})
+	if err != nil {
+		t.Fatalf("create health tracker: %v", err)
+	}
+	indexerService, err := indexer.NewService(indexer.ServiceConfig{Pool: pool, Store: eventStore, Health: health})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+	apiService, err := api.New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	if seeded := seedContracts(ctx, eventStore, cfg.Contracts); seeded != 1 {
+		t.Fatalf("expected 1 seeded contract, got %d", seeded)
+	}
+	reloader := &configReloader{cfg: cfg, health: health, store: eventStore, indexer: indexerService, api: apiService}
+
+	content := "chains:\n  - {chainId: 1, confirmations: 3}\ncontracts:\n" + entry(contractA, "renamed") + entry(contractB, "second")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("rewrite config file: %v", err)
+	}
+	if err := reloader.reload(ctx); err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	for address, label := range map[common.Address]string{contractA: "renamed", contractB: "second"} {
+		record, ok, err := eventStore.GetContract(ctx, 1, address)
+		if err != nil || !ok || record.Label != label {
+			t.Fatalf("expected contract %s labeled %q, got %+v (ok=%t, err=%v)", address.Hex(), label, record, ok, err)
+		}
+	}
+	if len(reloader.cfg.Chains) != 1 {
+		t.Fatalf("expected the reloaded configuration to be kept, got %+v", reloader.cfg.Chains)
+	}
+
+	// An invalid file is rejected and the current configuration is kept.
+	if err := os.WriteFile(path, []byte("chains:\n  - chainId: 0\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config file: %v", err)
+	}
+	if err := reloader.reload(ctx); err == nil {
+		t.Fatalf("expected an invalid configuration to be rejected")
+	}
+	if len(reloader.cfg.Contracts) != 2 {
+		t.Fatalf("expected the previous configuration to be kept, got %+v", reloader.cfg.Contracts)
+	}
+}
+
+func TestSeedContractKeepsAutomaticExtensions(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	extended := expiresAt.Add(7 * 24 * time.Hour)
+	spec := indexer.ContractInfo{
+		ChainID:    1,
+		Address:    contract,
+		StartBlock: 1,
+		ExpiresAt:  expiresAt,
+		Policy:     &store.ExpiryPolicy{AutoExtend: store.Duration(7 * 24 * time.Hour)},
+	}
+	if err := seedContract(ctx, eventStore, spec); err != nil {
+		t.Fatalf("seed contract: %v", err)
+	}
+	extendedPolicy := *spec.Policy
+	extendedPolicy.LastEventBlock = 42
+	if err := eventStore.SetContractExpiry(ctx, 1, contract, extended, &extendedPolicy); err != nil {
+		t.Fatalf("extend contract: %v", err)
+	}
+
+	if err := seedContract(ctx, eventStore, spec); err != nil {
+		t.Fatalf("seed contract again: %v", err)
+	}
+	record, _, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("get contract: %v", err)
+	}
+	if !record.ExpiresAt.Equal(extended) {
+		t.Fatalf("expected the extended expiration %s to be kept, got %s", extended, record.ExpiresAt)
+	}
+	if record.Policy == nil || record.Policy.LastEventBlock != 42 {
+		t.Fatalf("expected the policy to keep event block 42, got %+v", record.Policy)
+	}
+	spec.Policy = nil
+	spec.Label = ""
+	if err := eventStore.SetContractLabel(ctx, 1, contract, "old"); err != nil {
+		t.Fatalf("set label: %v", err)
+	}
+	if err := seedContract(ctx, eventStore, spec); err != nil {
+		t.Fatalf("seed contract without policy: %v", err)
+	}
+	if record, _, err = eventStore.GetContract(ctx, 1, contract); err != nil || record.Policy != nil || record.Label != "" {
+		t.Fatalf("expected the removed policy and label to be cleared, got %+v (err=%v)", record, err)
+	}
+
+	if err := eventStore.ArchiveContract(ctx, 1, contract, store.ContractArchive{IndexedUntil: 1}); err != nil {
+		t.Fatalf("archive contract: %v", err)
+	}
+	if seeded := seedContracts(ctx, eventStore, []indexer.ContractInfo{spec}); seeded != 0 {
+		t.Fatalf("expected the archived contract to be skipped, got %d seeded", seeded)
+	}
+}
@@ -0,0 +1,372 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/backup"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// AdminConfig configures the authenticated /admin endpoints.
+type AdminConfig struct {
+	// Token is the bearer token required by every admin request.
+	Token string
+	// Backups handles /admin/backups. Backup endpoints are disabled when nil.
+	Backups *backup.Manager
+	// Indexers handles /admin/indexers. Indexer endpoints are disabled when nil.
+	Indexers IndexerRestarter
+	// AllowRepair enables POST /admin/fsck. It must only be set when no
+	// indexer writes to the store, since a repair reads the cursors before the
+	// events and would delete events committed in between.
+	AllowRepair bool
+}
+
+// IndexerRestarter restarts the indexers that crashed.
+type IndexerRestarter interface {
+	RestartIndexer(chainID uint64, contract common.Address) bool
+}
+
+// EnableAdmin exposes the /admin endpoints. It must be called before Start.
+func (s *Service) EnableAdmin(cfg AdminConfig) error {
+	if strings.TrimSpace(cfg.Token) == "" {
+		return fmt.Errorf("admin token is required")
+	}
+	s.admin = &cfg
+	return nil
+}
+
+func (s *Service) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	if s.admin == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !validAdminToken(r, s.admin.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/")
+	if rest, ok := strings.CutPrefix(path, "archives/"); ok {
+		s.handleAdminArchive(w, r, strings.Split(rest, "/"))
+		return
+	}
+	if rest, ok := strings.CutPrefix(path, "indexers/"); ok {
+		s.handleAdminIndexer(w, r, strings.Split(rest, "/"))
+		return
+	}
+	switch path {
+	case "archives":
+		s.handleAdminArchives(w, r)
+	case "backups":
+		s.handleAdminBackups(w, r)
+	case "fsck":
+		s.handleAdminFsck(w, r)
+	case "reindex":
+		s.handleAdminReindex(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func validAdminToken(r *http.Request, token string) bool {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	provided, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) == 1
+}
+
+func (s *Service) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
+	if s.admin.Backups == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := s.admin.Backups.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, backups)
+	case http.MethodPost:
+		info, err := s.admin.Backups.Create(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminFsck checks the store on GET and checks and repairs it on POST.
+// Repairs are refused while the indexer runs; use the offline fsck command.
+func (s *Service) handleAdminFsck(w http.ResponseWriter, r *http.Request) {
+	var opts store.FsckOptions
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !s.admin.AllowRepair {
+			http.Error(w, "repairs are disabled while the indexer runs; stop the service and run fsck --repair", http.StatusConflict)
+			return
+		}
+		opts.Repair = true
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	checker, ok := s.store.(interface {
+		Fsck(ctx context.Context, opts store.FsckOptions) (store.FsckReport, error)
+	})
+	if !ok {
+		http.Error(w, "fsck is not supported by the storage backend", http.StatusNotImplemented)
+		return
+	}
+	report, err := checker.Fsck(r.Context(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleAdminIndexer restarts a crashed indexer or acknowledges the proxy
+// upgrade that paused it.
+func (s *Service) handleAdminIndexer(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	chainID, contract, _, ok := parseContractRoute(parts[:2])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[2] {
+	case "restart":
+		if s.admin.Indexers == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.admin.Indexers.RestartIndexer(chainID, contract) {
+			http.Error(w, "indexer has not crashed", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case "acknowledge-upgrade":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, ok, err := s.store.GetContract(r.Context(), chainID, contract); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		acknowledged, err := s.store.AcknowledgeContractUpgrade(r.Context(), chainID, contract)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !acknowledged {
+			http.Error(w, "no pending upgrade", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ReindexRequest schedules a re-index of [From,To] for a contract.
+type ReindexRequest struct {
+	ChainID  uint64 `json:"chainId"`
+	Contract string `json:"contract"`
+	From     uint64 `json:"from"`
+	To       uint64 `json:"to"`
+}
+
+// handleAdminReindex lists re-index jobs on GET and schedules one on POST.
+func (s *Service) handleAdminReindex(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var (
+			chainID  uint64
+			contract common.Address
+		)
+		if raw := r.URL.Query().Get("chainId"); raw != "" {
+			var ok bool
+			chainID, contract, _, ok = parseContractRoute([]string{raw, r.URL.Query().Get("contract")})
+			if !ok {
+				http.Error(w, "invalid chainId or contract", http.StatusBadRequest)
+				return
+			}
+		}
+		jobs, err := s.store.ListReindexJobs(r.Context(), chainID, contract)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if jobs == nil {
+			jobs = []store.ReindexJob{}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		var req ReindexRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		if req.ChainID == 0 || !common.IsHexAddress(req.Contract) {
+			http.Error(w, "chainId and a valid contract are required", http.StatusBadRequest)
+			return
+		}
+		job, err := s.store.ScheduleReindex(r.Context(), req.ChainID, common.HexToAddress(req.Contract), req.From, req.To)
+		switch {
+		case errors.Is(err, store.ErrInvalidReindexJob):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, store.ErrContractArchived):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, job)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ArchiveResponse is an archived contract with the census snapshot rebuilt from
+// its retained events. RootMatches reports whether the snapshot still matches
+// the root recorded when the contract was archived.
+type ArchiveResponse struct {
+	Contract    store.ContractRecord `json:"contract"`
+	Snapshot    store.CensusSnapshot `json:"snapshot"`
+	RootMatches bool                 `json:"rootMatches"`
+}
+
+// RestoreRequest restores an archived contract with a new expiration time.
+type RestoreRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// handleAdminArchives lists archived contracts.
+func (s *Service) handleAdminArchives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	records, err := s.store.ListContracts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	archived := []store.ContractRecord{}
+	for _, record := range records {
+		if record.Archive != nil {
+			archived = append(archived, record)
+		}
+	}
+	writeJSON(w, http.StatusOK, archived)
+}
+
+// handleAdminArchive returns an archived contract with its snapshot on
+// GET /admin/archives/{chainId}/{contract} and restores it on
+// POST /admin/archives/{chainId}/{contract}/restore.
+func (s *Service) handleAdminArchive(w http.ResponseWriter, r *http.Request, parts []string) {
+	restore := len(parts) == 3 && parts[2] == "restore"
+	if len(parts) != 2 && !restore {
+		http.NotFound(w, r)
+		return
+	}
+	chainID, contract, _, ok := parseContractRoute(parts)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	method := http.MethodGet
+	if restore {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	record, ok, err := s.store.GetContract(r.Context(), chainID, contract)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok || record.Archive == nil {
+		http.Error(w, "archived contract not found", http.StatusNotFound)
+		return
+	}
+	if !restore {
+		snapshot, err := store.BuildCensusSnapshot(r.Context(), s.store, chainID, contract)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, ArchiveResponse{
+			Contract:    record,
+			Snapshot:    snapshot,
+			RootMatches: snapshot.Root == record.Archive.Root,
+		})
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt == nil || !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+	if err := s.store.UnarchiveContract(r.Context(), chainID, contract, *req.ExpiresAt); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.SyncFromStore(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	record.Archive = nil
+	record.ExpiresAt = req.ExpiresAt.UTC()
+	writeJSON(w, http.StatusOK, record)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
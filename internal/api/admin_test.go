@@ -0,0 +1,410 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/backup"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestAdminBackupsEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	database, err := store.OpenPebble(filepath.Join(dir, "db"), store.PebbleOptions{})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	routes := svc.routes()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/backups", nil)
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected admin endpoints to be disabled by default, got %d", rec.Code)
+	}
+
+	manager, err := backup.NewManager(eventStore, backup.Config{Dir: filepath.Join(dir, "backups")})
+	if err != nil {
+		t.Fatalf("create backup manager: %v", err)
+	}
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret", Backups: manager}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/backups", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d for a wrong token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/backups", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var created backup.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("unmarshal backup info: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/backups", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	var listed []backup.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("unmarshal backup list: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != created.Name {
+		t.Fatalf("expected created backup to be listed, got %+v", listed)
+	}
+}
+
+func TestAdminFsckEndpoint(t *testing.T) {
+	database, err := store.OpenPebble(filepath.Join(t.TempDir(), "db"), store.PebbleOptions{})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := New(store.New(database), nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret"}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+	routes := svc.routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/fsck", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var report store.FsckReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("unmarshal fsck report: %v", err)
+	}
+	if report.Repair || len(report.Findings) != 0 {
+		t.Fatalf("expected a clean read-only report, got %+v", report)
+	}
+
+	repair := httptest.NewRequest(http.MethodPost, "/admin/fsck", nil)
+	repair.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, repair)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected repairs to be refused while indexing, got %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	svc.admin.AllowRepair = true
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, repair)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	// Backends without an integrity checker report the endpoint as unsupported.
+	svc.store = struct{ store.Backend }{store.New(database)}
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusNotImplemented, rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminArchivesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: account.Hex(), PreviousWeight: "0", NewWeight: "4", BlockNumber: 5},
+	}, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	snapshot, err := store.BuildCensusSnapshot(ctx, eventStore, 1, contract)
+	if err != nil {
+		t.Fatalf("build snapshot: %v", err)
+	}
+	if err := eventStore.ArchiveContract(ctx, 1, contract, store.ContractArchive{
+		IndexedUntil: 10, Accounts: 1, TotalWeight: snapshot.TotalWeight, Root: snapshot.Root,
+	}); err != nil {
+		t.Fatalf("archive contract: %v", err)
+	}
+
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret"}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+	routes := svc.routes()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+	listed := func(target string) int {
+		rec := serve(http.MethodGet, target, "")
+		var contracts []json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &contracts); err != nil {
+			t.Fatalf("unmarshal %s: %v", target, err)
+		}
+		return len(contracts)
+	}
+
+	if n := listed("/"); n != 0 {
+		t.Fatalf("expected archived contracts to be hidden from /, got %d", n)
+	}
+	if n := listed("/?archived=true"); n != 1 {
+		t.Fatalf("expected archived contract to be listed with archived=true, got %d", n)
+	}
+	if rec := serve(http.MethodGet, "/1/"+contract.Hex(), ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"newWeight":"4"`) {
+		t.Fatalf("expected archived events to stay readable, got %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	register := `{"chainId":1,"address":"` + contract.Hex() + `","expiresAt":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`
+	if rec := serve(http.MethodPost, "/contracts", register); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d when registering an archived contract, got %d", http.StatusConflict, rec.Code)
+	}
+
+	if n := listed("/admin/archives"); n != 1 {
+		t.Fatalf("expected one archived contract, got %d", n)
+	}
+	rec := serve(http.MethodGet, "/admin/archives/1/"+contract.Hex(), "")
+	var archived ArchiveResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &archived); err != nil {
+		t.Fatalf("unmarshal archive: %v (body=%s)", err, rec.Body.String())
+	}
+	if !archived.RootMatches || len(archived.Snapshot.Accounts) != 1 || archived.Snapshot.Accounts[0].Weight != "4" {
+		t.Fatalf("expected the retained snapshot, got %+v", archived)
+	}
+	if rec := serve(http.MethodGet, "/admin/archives/1/0x2222222222222222222222222222222222222222", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown archive, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	reindex := `{"chainId":1,"contract":"` + contract.Hex() + `","from":1,"to":10}`
+	if rec := serve(http.MethodPost, "/admin/reindex", reindex); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d when re-indexing an archived contract, got %d", http.StatusConflict, rec.Code)
+	}
+
+	past := `{"expiresAt":"` + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339) + `"}`
+	if rec := serve(http.MethodPost, "/admin/archives/1/"+contract.Hex()+"/restore", past); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a past expiresAt, got %d", http.StatusBadRequest, rec.Code)
+	}
+	future := `{"expiresAt":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`
+	if rec := serve(http.MethodPost, "/admin/archives/1/"+contract.Hex()+"/restore", future); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if n := listed("/"); n != 1 {
+		t.Fatalf("expected restored contract to be listed in /, got %d", n)
+	}
+	if n := listed("/admin/archives"); n != 0 {
+		t.Fatalf("expected no archived contracts after restore, got %d", n)
+	}
+	if rec := serve(http.MethodPost, "/admin/reindex", reindex); rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d after restore, got %d (body=%s)", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminReindexEndpoint(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, nil, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret"}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+	routes := svc.routes()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	unknown := `{"chainId":1,"contract":"0x2222222222222222222222222222222222222222","from":1,"to":10}`
+	if rec := serve(http.MethodPost, "/admin/reindex", unknown); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d when re-indexing an unknown contract, got %d", http.StatusBadRequest, rec.Code)
+	}
+	beyond := `{"chainId":1,"contract":"` + contract.Hex() + `","from":1,"to":11}`
+	if rec := serve(http.MethodPost, "/admin/reindex", beyond); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d when re-indexing beyond the indexed cursor, got %d", http.StatusBadRequest, rec.Code)
+	}
+	reindex := `{"chainId":1,"contract":"` + contract.Hex() + `","from":1,"to":10}`
+	if rec := serve(http.MethodPost, "/admin/reindex", reindex); rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodGet, "/", ""); !strings.Contains(rec.Body.String(), `"unverified":true`) {
+		t.Fatalf("expected the contract to be unverified while re-indexed, got %s", rec.Body.String())
+	}
+}
+
+type crashedIndexers map[common.Address]bool
+
+func (c crashedIndexers) RestartIndexer(_ uint64, contract common.Address) bool {
+	crashed := c[contract]
+	delete(c, contract)
+	return crashed
+}
+
+func TestAdminIndexerRestartEndpoint(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := New(store.New(database), nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret", Indexers: crashedIndexers{contract: true}}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+	routes := svc.routes()
+	serve := func(method, target string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	target := "/admin/indexers/1/" + contract.Hex() + "/restart"
+	if code := serve(http.MethodGet, target); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+	if code := serve(http.MethodPost, target); code != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, code)
+	}
+	if code := serve(http.MethodPost, target); code != http.StatusNotFound {
+		t.Fatalf("expected %d once the indexer was restarted, got %d", http.StatusNotFound, code)
+	}
+	if code := serve(http.MethodPost, "/admin/indexers/1/not-an-address/restart"); code != http.StatusNotFound {
+		t.Fatalf("expected %d for an invalid contract, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAdminAcknowledgeUpgradeEndpoint(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	if err := svc.EnableAdmin(AdminConfig{Token: "secret"}); err != nil {
+		t.Fatalf("enable admin: %v", err)
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	routes := svc.routes()
+	serve := func(method, target string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	target := "/admin/indexers/1/" + contract.Hex() + "/acknowledge-upgrade"
+	if code := serve(http.MethodPost, target); code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown contract, got %d", http.StatusNotFound, code)
+	}
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := eventStore.RecordContractUpgrade(ctx, 1, contract, store.ProxyUpgrade{
+		Implementation: "0x00000000000000000000000000000000000000b2",
+		Block:          20,
+	}, true); err != nil {
+		t.Fatalf("record upgrade: %v", err)
+	}
+	if code := serve(http.MethodGet, target); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+	if code := serve(http.MethodPost, target); code != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, code)
+	}
+	if code := serve(http.MethodPost, target); code != http.StatusNotFound {
+		t.Fatalf("expected %d once the upgrade was acknowledged, got %d", http.StatusNotFound, code)
+	}
+	record, _, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || record.UpgradePending {
+		t.Fatalf("expected no pending upgrade, got %+v (err=%v)", record, err)
+	}
+}
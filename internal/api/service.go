@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,7 +16,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/graphql-go/handler"
-	"github.com/vocdoni/davinci-node/web3/rpc"
 
 	"github.com/vocdoni/onchain-census-indexer/internal/graphqlapi"
 	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
@@ -24,12 +24,43 @@ import (
 
 // Service exposes the GraphQL API for indexed contracts.
 type Service struct {
-	store             *store.Store
+	store             store.Backend
 	chainHeadResolver chainHeadResolver
 	syncConfirmations uint64
+	expiryWarning     time.Duration
 	mu                sync.RWMutex
 	handlers          map[string]*handler.Handler
-	contracts         []indexer.ContractInfo
+	// globalHandler serves the contracts of every chain at /graphql.
+	globalHandler *handler.Handler
+	graphqlLimits graphqlapi.Limits
+	contracts     []indexer.ContractInfo
+	// chainConfirmations overrides syncConfirmations per chain ID.
+	chainConfirmations map[uint64]uint64
+	rpcStatus          rpcStatusProvider
+	rpcLimits          rpcLimitProvider
+	chainHeads         chainHeadProvider
+	indexerStatus      indexerStatusProvider
+	admin              *AdminConfig
+}
+
+type rpcStatusProvider interface {
+	Status() []indexer.EndpointStatus
+}
+
+type rpcLimitProvider interface {
+	Wait(ctx context.Context, chainID uint64) error
+	Status() []indexer.RateLimitStatus
+}
+
+type chainHeadProvider interface {
+	chainHeadResolver
+	Status() []indexer.ChainHead
+}
+
+type indexerStatusProvider interface {
+	RetryStatus(chainID uint64, contract common.Address) (indexer.RetryStatus, bool)
+	SupervisorStatus(chainID uint64, contract common.Address) (indexer.SupervisorStatus, bool)
+	AuditStatus(chainID uint64, contract common.Address) (indexer.AuditReport, bool)
 }
 
 type chainHeadResolver interface {
@@ -37,13 +68,20 @@ type chainHeadResolver interface {
 }
 
 type rpcChainHeadResolver struct {
-	pool *rpc.Web3Pool
+	pool   *indexer.RPCPool
+	limits rpcLimitProvider
 }
 
 func (r *rpcChainHeadResolver) HeadBlock(ctx context.Context, chainID uint64) (uint64, error) {
 	if r.pool == nil {
 		return 0, fmt.Errorf("rpc pool is required")
 	}
+	ctx = indexer.WithRequestPriority(ctx, indexer.PriorityHead)
+	if r.limits != nil {
+		if err := r.limits.Wait(ctx, chainID); err != nil {
+			return 0, err
+		}
+	}
 	client, err := r.pool.Client(chainID)
 	if err != nil {
 		return 0, err
@@ -52,7 +90,7 @@ func (r *rpcChainHeadResolver) HeadBlock(ctx context.Context, chainID uint64) (u
 }
 
 // New creates a new API service.
-func New(eventStore *store.Store, pool *rpc.Web3Pool, syncConfirmations uint64) (*Service, error) {
+func New(eventStore store.Backend, pool *indexer.RPCPool, syncConfirmations uint64) (*Service, error) {
 	if eventStore == nil {
 		return nil, fmt.Errorf("store is required")
 	}
@@ -60,12 +98,80 @@ func New(eventStore *store.Store, pool *rpc.Web3Pool, syncConfirmations uint64)
 	if pool != nil {
 		resolver = &rpcChainHeadResolver{pool: pool}
 	}
-	return &Service{
+	s := &Service{
 		store:             eventStore,
 		chainHeadResolver: resolver,
 		syncConfirmations: syncConfirmations,
 		handlers:          make(map[string]*handler.Handler),
-	}, nil
+	}
+	schema, err := graphqlapi.NewGlobalSchema(eventStore, s.contractsWithSyncStatus)
+	if err != nil {
+		return nil, fmt.Errorf("create global graphql schema: %w", err)
+	}
+	s.globalHandler = handler.New(&handler.Config{
+		Schema:   &schema,
+		Pretty:   true,
+		GraphiQL: true,
+	})
+	return s, nil
+}
+
+// SetExpiryWarning sets how long before expiresAt contracts are reported as
+// expiring soon. It must be called before Start.
+func (s *Service) SetExpiryWarning(window time.Duration) {
+	s.expiryWarning = window
+}
+
+// SetChainConfirmations replaces the per-chain confirmation depths used to
+// report sync status. Chains without an entry use the service-wide depth.
+func (s *Service) SetChainConfirmations(confirmations map[uint64]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chainConfirmations = confirmations
+}
+
+// SetGraphQLLimits sets the page size, depth, complexity and time limits of
+// the GraphQL endpoints. It must be called before Start.
+func (s *Service) SetGraphQLLimits(limits graphqlapi.Limits) {
+	s.graphqlLimits = limits
+}
+
+// SetRPCStatus sets the source of the RPC endpoint health served by /rpc. It
+// must be called before Start.
+func (s *Service) SetRPCStatus(provider rpcStatusProvider) {
+	s.rpcStatus = provider
+}
+
+// SetRPCLimits sets the rate limiter applied to head requests and reported by
+// /rpc. It must be called before Start.
+func (s *Service) SetRPCLimits(limits rpcLimitProvider) {
+	s.rpcLimits = limits
+	if resolver, ok := s.chainHeadResolver.(*rpcChainHeadResolver); ok {
+		resolver.limits = limits
+	}
+}
+
+// SetChainHeads replaces the RPC head lookups of the sync status with the
+// head tracker shared with the indexers, whose cached heads are also reported
+// by /rpc. It must be called before Start.
+func (s *Service) SetChainHeads(heads chainHeadProvider) {
+	s.chainHeads = heads
+	s.chainHeadResolver = heads
+}
+
+// SetIndexerStatus sets the source of the indexer retry and crash state
+// reported with the contracts. It must be called before Start.
+func (s *Service) SetIndexerStatus(provider indexerStatusProvider) {
+	s.indexerStatus = provider
+}
+
+func (s *Service) confirmationsFor(chainID uint64) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if confirmations, ok := s.chainConfirmations[chainID]; ok {
+		return confirmations
+	}
+	return s.syncConfirmations
 }
 
 // RegisterContract registers a contract endpoint.
@@ -120,7 +226,8 @@ func (s *Service) registerHandler(info indexer.ContractInfo) error {
 	return nil
 }
 
-// SyncFromStore reconciles GraphQL handlers with current non-expired contracts in the store.
+// SyncFromStore reconciles GraphQL handlers with current non-expired and
+// archived contracts in the store. Archived contracts stay queryable read-only.
 func (s *Service) SyncFromStore(ctx context.Context) error {
 	records, err := s.store.ListContracts(ctx)
 	if err != nil {
@@ -129,19 +236,9 @@ func (s *Service) SyncFromStore(ctx context.Context) error {
 	now := time.Now().UTC()
 	desired := make(map[string]indexer.ContractInfo, len(records))
 	for _, record := range records {
-		if !common.IsHexAddress(record.Contract) {
-			continue
+		if info, ok := servedContract(record, now); ok {
+			desired[info.Key()] = info
 		}
-		info := indexer.ContractInfo{
-			ChainID:    record.ChainID,
-			Address:    common.HexToAddress(record.Contract),
-			StartBlock: record.StartBlock,
-			ExpiresAt:  record.ExpiresAt,
-		}
-		if info.IsExpiredAt(now) {
-			continue
-		}
-		desired[info.Key()] = info
 	}
 
 	for _, info := range desired {
@@ -167,6 +264,32 @@ func (s *Service) SyncFromStore(ctx context.Context) error {
 	return nil
 }
 
+// servedContract converts a stored record into the contract info served by the
+// API. Expired contracts are only served once they have been archived.
+func servedContract(record store.ContractRecord, now time.Time) (indexer.ContractInfo, bool) {
+	if !common.IsHexAddress(record.Contract) {
+		return indexer.ContractInfo{}, false
+	}
+	info := indexer.ContractInfo{
+		ChainID:          record.ChainID,
+		Address:          common.HexToAddress(record.Contract),
+		StartBlock:       record.StartBlock,
+		ExpiresAt:        record.ExpiresAt,
+		Label:            record.Label,
+		Policy:           record.Policy,
+		Archive:          record.Archive,
+		StartBlockMethod: record.StartBlockMethod,
+		EventProfile:     record.EventProfile,
+		Upgrades:         record.Upgrades,
+		UpgradePending:   record.UpgradePending,
+	}
+	info.DeploymentTx, _ = indexer.ParseDeploymentTx(record.DeploymentTx)
+	if info.Archive == nil && info.IsExpiredAt(now) {
+		return indexer.ContractInfo{}, false
+	}
+	return info, true
+}
+
 // Start runs the HTTP server until the context is canceled.
 func (s *Service) Start(ctx context.Context, addr string, port int, allowedOrigins []string) error {
 	if err := s.SyncFromStore(ctx); err != nil {
@@ -305,6 +428,9 @@ func (s *Service) routes() http.Handler {
 		w.WriteHeader(http.StatusOK)
 	})
 	mux.HandleFunc("/contracts", s.handleContracts)
+	mux.HandleFunc("/rpc", s.handleRPCStatus)
+	mux.HandleFunc("/graphql", s.handleGlobalGraphQL)
+	mux.HandleFunc("/admin/", s.handleAdmin)
 	mux.HandleFunc("/", s.handleRoot)
 	return mux
 }
@@ -312,11 +438,15 @@ func (s *Service) routes() http.Handler {
 type registerRequest = indexer.ContractInfo
 
 type registerResponse struct {
-	ChainID      uint64    `json:"chainId"`
-	Contract     string    `json:"contract"`
-	Endpoint     string    `json:"endpoint"`
-	JSONEndpoint string    `json:"jsonEndpoint,omitempty"`
-	ExpiresAt    time.Time `json:"expiresAt"`
+	ChainID      uint64              `json:"chainId"`
+	Contract     string              `json:"contract"`
+	Endpoint     string              `json:"endpoint"`
+	JSONEndpoint string              `json:"jsonEndpoint,omitempty"`
+	ExpiresAt    time.Time           `json:"expiresAt"`
+	Label        string              `json:"label,omitempty"`
+	Policy       *store.ExpiryPolicy `json:"policy,omitempty"`
+	DeploymentTx *common.Hash        `json:"deploymentTx,omitempty"`
+	EventProfile *store.EventProfile `json:"eventProfile,omitempty"`
 }
 
 type weightChangeAccountResponse struct {
@@ -328,6 +458,8 @@ type weightChangeEventResponse struct {
 	PreviousWeight string                      `json:"previousWeight"`
 	NewWeight      string                      `json:"newWeight"`
 	BlockNumber    string                      `json:"blockNumber"`
+	// Args holds the decoded arguments of events indexed with a custom profile.
+	Args map[string]string `json:"args,omitempty"`
 }
 
 type weightChangeEventsResponse struct {
@@ -350,10 +482,66 @@ func (s *Service) handleContracts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	contractAddr := req.Address
+	// Without a new policy, the stored one still bounds the expiration.
+	if req.Policy == nil {
+		record, ok, err := s.store.GetContract(r.Context(), req.ChainID, req.Address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			if err := record.Policy.Validate(req.ExpiresAt); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Policy = record.Policy
+		}
+	}
+	// Events already indexed were decoded with the stored profile, so it
+	// cannot be replaced.
+	if req.EventProfile != nil {
+		record, ok, err := s.store.GetContract(r.Context(), req.ChainID, req.Address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ok && !indexer.SameEventProfile(record.EventProfile, req.EventProfile) {
+			http.Error(w, "eventProfile of a registered contract cannot be changed", http.StatusConflict)
+			return
+		}
+	}
 	if err := s.store.SaveContract(r.Context(), req.ChainID, req.Address, req.StartBlock, req.ExpiresAt); err != nil {
+		if errors.Is(err, store.ErrContractArchived) {
+			http.Error(w, "contract is archived; restore it through /admin/archives", http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if req.Policy != nil {
+		if err := s.store.SetContractExpiry(r.Context(), req.ChainID, req.Address, req.ExpiresAt, req.Policy); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.Label != "" {
+		if err := s.store.SetContractLabel(r.Context(), req.ChainID, req.Address, req.Label); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.DeploymentTx != nil {
+		if err := s.store.SetContractDeploymentTx(r.Context(), req.ChainID, req.Address, *req.DeploymentTx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.EventProfile != nil {
+		if err := s.store.SetContractEventProfile(r.Context(), req.ChainID, req.Address, req.EventProfile); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	if err := s.SyncFromStore(r.Context()); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -365,6 +553,10 @@ func (s *Service) handleContracts(w http.ResponseWriter, r *http.Request) {
 		Endpoint:     fmt.Sprintf("/%d/%s/graphql", req.ChainID, contractAddr.Hex()),
 		JSONEndpoint: fmt.Sprintf("/%d/%s", req.ChainID, contractAddr.Hex()),
 		ExpiresAt:    req.ExpiresAt,
+		Label:        req.Label,
+		Policy:       req.Policy,
+		DeploymentTx: req.DeploymentTx,
+		EventProfile: req.EventProfile,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -378,6 +570,14 @@ func (s *Service) handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 	path := strings.Trim(r.URL.Path, "/")
 	if path == "" {
+		includeArchived := false
+		if raw := r.URL.Query().Get("archived"); raw != "" {
+			var err error
+			if includeArchived, err = strconv.ParseBool(raw); err != nil {
+				http.Error(w, "archived must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		type APIInfo struct {
 			indexer.ContractInfo `json:"info"`
@@ -386,6 +586,9 @@ func (s *Service) handleRoot(w http.ResponseWriter, r *http.Request) {
 		}
 		var apiInfo []APIInfo
 		for _, spec := range s.contractsWithSyncStatus(r.Context()) {
+			if spec.Archive != nil && !includeArchived {
+				continue
+			}
 			apiInfo = append(apiInfo, APIInfo{
 				ContractInfo: spec,
 				Endpoint:     fmt.Sprintf("/%d/%s/graphql", spec.ChainID, spec.Address.Hex()),
@@ -398,7 +601,7 @@ func (s *Service) handleRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	parts := strings.Split(path, "/")
-	if len(parts) != 2 && (len(parts) != 3 || parts[2] != "graphql") {
+	if len(parts) != 2 && (len(parts) != 3 || (parts[2] != "graphql" && parts[2] != "status")) {
 		http.NotFound(w, r)
 		return
 	}
@@ -419,7 +622,69 @@ func (s *Service) handleRoot(w http.ResponseWriter, r *http.Request) {
 		s.handleContractJSON(w, r, chainID, contractAddr)
 		return
 	}
-	graphqlHandler.ServeHTTP(w, r)
+	if parts[2] == "status" {
+		s.handleContractStatus(w, r, key)
+		return
+	}
+	s.graphqlLimits.Handler(graphqlHandler).ServeHTTP(w, r)
+}
+
+// handleGlobalGraphQL serves the GraphQL endpoint spanning every contract.
+func (s *Service) handleGlobalGraphQL(w http.ResponseWriter, r *http.Request) {
+	if err := s.SyncFromStore(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.RLock()
+	served := len(s.contracts)
+	s.mu.RUnlock()
+	limits := s.graphqlLimits
+	limits.ListSizes = map[string]int{"contracts": served}
+	r = r.WithContext(graphqlapi.WithContractListing(r.Context()))
+	limits.Handler(s.globalHandler).ServeHTTP(w, r)
+}
+
+// handleContractStatus returns the listing entry of a single contract,
+// including its sync, expiration and archive state.
+func (s *Service) handleContractStatus(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	for _, info := range s.contractsWithSyncStatus(r.Context()) {
+		if info.Key() == key {
+			writeJSON(w, http.StatusOK, info)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+type rpcStatusResponse struct {
+	Endpoints []indexer.EndpointStatus  `json:"endpoints"`
+	Limits    []indexer.RateLimitStatus `json:"limits,omitempty"`
+	Heads     []indexer.ChainHead       `json:"heads,omitempty"`
+}
+
+// handleRPCStatus returns the health of the RPC endpoints in the pool.
+func (s *Service) handleRPCStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := rpcStatusResponse{Endpoints: []indexer.EndpointStatus{}}
+	if s.rpcStatus != nil {
+		resp.Endpoints = append(resp.Endpoints, s.rpcStatus.Status()...)
+	}
+	if s.rpcLimits != nil {
+		resp.Limits = s.rpcLimits.Status()
+	}
+	if s.chainHeads != nil {
+		resp.Heads = s.chainHeads.Status()
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func parseContractRoute(parts []string) (uint64, common.Address, string, bool) {
@@ -467,6 +732,7 @@ func (s *Service) handleContractJSON(w http.ResponseWriter, r *http.Request, cha
 			PreviousWeight: event.PreviousWeight,
 			NewWeight:      event.NewWeight,
 			BlockNumber:    strconv.FormatUint(event.BlockNumber, 10),
+			Args:           event.Args,
 		})
 	}
 
@@ -535,30 +801,28 @@ func (s *Service) contractsWithSyncStatus(ctx context.Context) []indexer.Contrac
 	if len(contracts) == 0 {
 		return contracts
 	}
+	now := time.Now().UTC()
 	records, err := s.store.ListContracts(ctx)
 	if err == nil {
-		now := time.Now().UTC()
 		metadata := make(map[string]indexer.ContractInfo, len(records))
 		for _, record := range records {
-			if !common.IsHexAddress(record.Contract) {
-				continue
+			if info, ok := servedContract(record, now); ok {
+				metadata[info.Key()] = info
 			}
-			info := indexer.ContractInfo{
-				ChainID:    record.ChainID,
-				Address:    common.HexToAddress(record.Contract),
-				StartBlock: record.StartBlock,
-				ExpiresAt:  record.ExpiresAt,
-			}
-			if info.IsExpiredAt(now) {
-				continue
-			}
-			metadata[info.Key()] = info
 		}
 		filtered := contracts[:0]
 		for i := range contracts {
 			if info, ok := metadata[contracts[i].Key()]; ok {
 				contracts[i].StartBlock = info.StartBlock
+				contracts[i].StartBlockMethod = info.StartBlockMethod
+				contracts[i].DeploymentTx = info.DeploymentTx
+				contracts[i].EventProfile = info.EventProfile
+				contracts[i].Upgrades = info.Upgrades
+				contracts[i].UpgradePending = info.UpgradePending
 				contracts[i].ExpiresAt = info.ExpiresAt
+				contracts[i].Label = info.Label
+				contracts[i].Policy = info.Policy
+				contracts[i].Archive = info.Archive
 				filtered = append(filtered, contracts[i])
 			}
 		}
@@ -571,6 +835,27 @@ func (s *Service) contractsWithSyncStatus(ctx context.Context) []indexer.Contrac
 	}
 	heads := make(map[uint64]chainHead, len(contracts))
 	for i := range contracts {
+		if jobs, err := s.store.ListReindexJobs(ctx, contracts[i].ChainID, contracts[i].Address); err == nil {
+			contracts[i].ReindexJobs = jobs
+			contracts[i].Unverified = slices.ContainsFunc(jobs, func(job store.ReindexJob) bool { return !job.Done() })
+		}
+		// Archived contracts are no longer indexed, so they are never synced.
+		if contracts[i].Archive != nil {
+			contracts[i].Synced = false
+			continue
+		}
+		contracts[i].ExpiringSoon = contracts[i].IsExpiringSoonAt(now, s.expiryWarning)
+		if s.indexerStatus != nil {
+			if retry, ok := s.indexerStatus.RetryStatus(contracts[i].ChainID, contracts[i].Address); ok {
+				contracts[i].Retry = &retry
+			}
+			if supervisor, ok := s.indexerStatus.SupervisorStatus(contracts[i].ChainID, contracts[i].Address); ok {
+				contracts[i].Supervisor = &supervisor
+			}
+			if audit, ok := s.indexerStatus.AuditStatus(contracts[i].ChainID, contracts[i].Address); ok {
+				contracts[i].Audit = &audit
+			}
+		}
 		verifiedBlock, ok, err := s.store.LastVerifiedBlock(ctx, contracts[i].ChainID, contracts[i].Address)
 		if err != nil || !ok {
 			contracts[i].Synced = false
@@ -594,8 +879,8 @@ func (s *Service) contractsWithSyncStatus(ctx context.Context) []indexer.Contrac
 			contracts[i].Synced = false
 			continue
 		}
-		safeHead, ok := safeHead(head.head, s.syncConfirmations)
-		contracts[i].Synced = ok && verifiedBlock >= safeHead
+		safeHead, ok := safeHead(head.head, s.confirmationsFor(contracts[i].ChainID))
+		contracts[i].Synced = ok && verifiedBlock >= safeHead && !contracts[i].Unverified
 	}
 	return contracts
 }
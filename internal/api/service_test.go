@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -15,6 +16,7 @@ import (
 	"github.com/vocdoni/davinci-node/db"
 	"github.com/vocdoni/davinci-node/db/metadb"
 
+	"github.com/vocdoni/onchain-census-indexer/internal/graphqlapi"
 	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
 	"github.com/vocdoni/onchain-census-indexer/internal/store"
 )
@@ -226,6 +228,12 @@ func TestContractsWithSyncStatusUsesVerifiedBlockAndConfirmations(t *testing.T)
 	if contracts[0].Synced {
 		t.Fatalf("expected contract to be unsynced when verified block is below safe head")
 	}
+	// A per-chain confirmation depth overrides the service-wide one.
+	svc.SetChainConfirmations(map[uint64]uint64{1: 11})
+	contracts = svc.contractsWithSyncStatus(ctx)
+	if len(contracts) != 1 || !contracts[0].Synced {
+		t.Fatalf("expected contract to be synced with the chain confirmation depth, got %+v", contracts)
+	}
 }
 
 func TestHandleRootServesContractJSON(t *testing.T) {
@@ -360,7 +368,7 @@ func TestContractsWithSyncStatusRefreshesStartBlockFromStore(t *testing.T) {
 	if err := eventStore.SaveContract(ctx, 1, contract, 0, expiresAt); err != nil {
 		t.Fatalf("save contract with zero start block: %v", err)
 	}
-	if err := eventStore.SetContractStartBlock(ctx, 1, contract, 12345); err != nil {
+	if err := eventStore.SetContractStartBlock(ctx, 1, contract, 12345, "bytecode"); err != nil {
 		t.Fatalf("set contract start block: %v", err)
 	}
 
@@ -376,8 +384,107 @@ func TestContractsWithSyncStatusRefreshesStartBlockFromStore(t *testing.T) {
 	if len(contracts) != 1 {
 		t.Fatalf("expected 1 contract, got %d", len(contracts))
 	}
-	if contracts[0].StartBlock != 12345 {
-		t.Fatalf("expected refreshed start block 12345, got %d", contracts[0].StartBlock)
+	if contracts[0].StartBlock != 12345 || contracts[0].StartBlockMethod != "bytecode" {
+		t.Fatalf("expected refreshed start block 12345 by bytecode, got %d by %q", contracts[0].StartBlock, contracts[0].StartBlockMethod)
+	}
+}
+
+func TestHandleContractsStoresDeploymentTx(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	deploymentTx := common.HexToHash("0xabcdef")
+	expiresAt := futureTime(time.Hour).Format(time.RFC3339)
+	for _, tt := range []struct {
+		deploymentTx string
+		code         int
+	}{
+		{"0x1234", http.StatusBadRequest},
+		{deploymentTx.Hex(), http.StatusCreated},
+	} {
+		reqBody := fmt.Sprintf(`{"chainId":1,"address":%q,"expiresAt":%q,"deploymentTx":%q}`, contract.Hex(), expiresAt, tt.deploymentTx)
+		rec := httptest.NewRecorder()
+		svc.handleContracts(rec, httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody)).WithContext(ctx))
+		if rec.Code != tt.code {
+			t.Fatalf("expected %d for deploymentTx %s, got %d (body=%s)", tt.code, tt.deploymentTx, rec.Code, rec.Body.String())
+		}
+	}
+	record, ok, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok {
+		t.Fatalf("get contract: ok=%t err=%v", ok, err)
+	}
+	if record.DeploymentTx != deploymentTx.Hex() {
+		t.Fatalf("expected deployment tx %s, got %q", deploymentTx.Hex(), record.DeploymentTx)
+	}
+}
+
+func TestHandleContractsStoresEventProfile(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	expiresAt := futureTime(time.Hour).Format(time.RFC3339)
+	transferABI := `[{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]}]`
+	profile := func(account, weight string) string {
+		raw, _ := json.Marshal(store.EventProfile{ABI: transferABI, Account: account, NewWeight: weight})
+		return string(raw)
+	}
+	for _, tt := range []struct {
+		profile string
+		code    int
+	}{
+		{profile("value", "value"), http.StatusBadRequest},
+		{profile("to", "value"), http.StatusCreated},
+		{profile("to", "value"), http.StatusCreated},
+		{profile("from", "value"), http.StatusConflict},
+	} {
+		reqBody := fmt.Sprintf(`{"chainId":1,"address":%q,"expiresAt":%q,"eventProfile":%s}`, contract.Hex(), expiresAt, tt.profile)
+		rec := httptest.NewRecorder()
+		svc.handleContracts(rec, httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody)).WithContext(ctx))
+		if rec.Code != tt.code {
+			t.Fatalf("expected %d for profile %s, got %d (body=%s)", tt.code, tt.profile, rec.Code, rec.Body.String())
+		}
+	}
+	// Registering again without a profile keeps the stored one.
+	reqBody := fmt.Sprintf(`{"chainId":1,"address":%q,"expiresAt":%q}`, contract.Hex(), expiresAt)
+	rec := httptest.NewRecorder()
+	svc.handleContracts(rec, httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody)).WithContext(ctx))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	record, ok, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok {
+		t.Fatalf("get contract: ok=%t err=%v", ok, err)
+	}
+	if record.EventProfile == nil || record.EventProfile.Account != "to" || record.EventProfile.NewWeight != "value" {
+		t.Fatalf("expected the transfer profile to be stored, got %+v", record.EventProfile)
 	}
 }
 
@@ -488,3 +595,355 @@ func TestHandleContractsRejectsMissingExpiresAt(t *testing.T) {
 		t.Fatalf("expected %d, got %d (body=%s)", http.StatusBadRequest, rec.Code, rec.Body.String())
 	}
 }
+
+func TestContractStatusReportsExpiringSoonAndPolicy(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	svc.SetExpiryWarning(2 * time.Hour)
+
+	contract := "0x1111111111111111111111111111111111111111"
+	expiresAt := futureTime(time.Hour)
+	maxExpiresAt := futureTime(48 * time.Hour)
+	reqBody := fmt.Sprintf(`{"chainId":1,"address":%q,"startBlock":1,"expiresAt":%q,"policy":{"autoExtend":"24h","maxExpiresAt":%q}}`,
+		contract, expiresAt.Format(time.RFC3339), maxExpiresAt.Format(time.RFC3339))
+	req := httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody))
+	rec := httptest.NewRecorder()
+	svc.handleContracts(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/1/"+contract+"/status", nil)
+	rec = httptest.NewRecorder()
+	svc.handleRoot(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var status struct {
+		ExpiringSoon bool                `json:"expiringSoon"`
+		Policy       *store.ExpiryPolicy `json:"policy"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	if !status.ExpiringSoon {
+		t.Fatalf("expected the contract to be expiring soon")
+	}
+	if status.Policy == nil || time.Duration(status.Policy.AutoExtend) != 24*time.Hour {
+		t.Fatalf("expected an autoExtend policy of 24h, got %+v", status.Policy)
+	}
+	if status.Policy.MaxExpiresAt == nil || !status.Policy.MaxExpiresAt.Equal(maxExpiresAt) {
+		t.Fatalf("expected maxExpiresAt %s, got %+v", maxExpiresAt, status.Policy.MaxExpiresAt)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	svc.handleRoot(rec, req.WithContext(ctx))
+	var listing []struct {
+		Info struct {
+			ExpiringSoon bool `json:"expiringSoon"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("unmarshal listing: %v", err)
+	}
+	if len(listing) != 1 || !listing[0].Info.ExpiringSoon {
+		t.Fatalf("expected the listing to report expiringSoon, got %s", rec.Body.String())
+	}
+
+	// The stored policy still bounds updates that do not send a new one.
+	reqBody = fmt.Sprintf(`{"chainId":1,"address":%q,"expiresAt":%q}`,
+		contract, maxExpiresAt.Add(time.Hour).Format(time.RFC3339))
+	req = httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody))
+	rec = httptest.NewRecorder()
+	svc.handleContracts(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d (body=%s)", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/1/0x2222222222222222222222222222222222222222/status", nil)
+	rec = httptest.NewRecorder()
+	svc.handleRoot(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+type staticRPCStatus []indexer.EndpointStatus
+
+func (s staticRPCStatus) Status() []indexer.EndpointStatus {
+	return s
+}
+
+type staticChainHeads []indexer.ChainHead
+
+func (s staticChainHeads) HeadBlock(_ context.Context, chainID uint64) (uint64, error) {
+	for _, head := range s {
+		if head.ChainID == chainID {
+			return head.Head, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown chain %d", chainID)
+}
+
+func (s staticChainHeads) Status() []indexer.ChainHead {
+	return s
+}
+
+func TestHandleRPCStatus(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := New(store.New(database), nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	svc.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"endpoints":[]}` {
+		t.Fatalf("expected an empty endpoint list, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	svc.SetRPCStatus(staticRPCStatus{
+		{ChainID: 1, URI: "https://rpc.example.org", State: indexer.EndpointDegraded, Score: 42, Lag: 120},
+	})
+	rec = httptest.NewRecorder()
+	svc.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	var resp rpcStatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal rpc status: %v", err)
+	}
+	if len(resp.Endpoints) != 1 || resp.Endpoints[0].State != indexer.EndpointDegraded || resp.Endpoints[0].Lag != 120 {
+		t.Fatalf("expected the degraded endpoint, got %s", rec.Body.String())
+	}
+	if len(resp.Limits) != 0 {
+		t.Fatalf("expected no limits without a rate limiter, got %s", rec.Body.String())
+	}
+
+	limiter, err := indexer.NewRateLimiter(indexer.RateLimitConfig{Chain: indexer.RateLimit{DailyCap: 1}})
+	if err != nil {
+		t.Fatalf("create rate limiter: %v", err)
+	}
+	svc.SetRPCLimits(limiter)
+	ctx := indexer.WithRequestPriority(context.Background(), indexer.PriorityHead)
+	if err := limiter.Wait(ctx, 1); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if err := limiter.Wait(ctx, 1); !errors.Is(err, indexer.ErrDailyCapReached) {
+		t.Fatalf("expected the daily cap to be reached, got %v", err)
+	}
+	rec = httptest.NewRecorder()
+	svc.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	resp = rpcStatusResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal rpc status: %v", err)
+	}
+	if len(resp.Limits) != 1 || resp.Limits[0].ChainID != 1 || resp.Limits[0].UsedToday != 1 || resp.Limits[0].Rejected != 1 {
+		t.Fatalf("expected chain 1 limit with one request and one rejection, got %s", rec.Body.String())
+	}
+
+	svc.SetChainHeads(staticChainHeads{{ChainID: 1, Head: 120}})
+	if head, err := svc.chainHeadResolver.HeadBlock(context.Background(), 1); err != nil || head != 120 {
+		t.Fatalf("expected the sync status to use the head tracker, got %d %v", head, err)
+	}
+	rec = httptest.NewRecorder()
+	svc.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	resp = rpcStatusResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal rpc status: %v", err)
+	}
+	if len(resp.Heads) != 1 || resp.Heads[0].ChainID != 1 || resp.Heads[0].Head != 120 {
+		t.Fatalf("expected the cached head of chain 1, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	svc.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestGlobalGraphQLEndpoint(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	contract := common.HexToAddress("0x7878787878787878787878787878787878787878")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, futureTime(24*time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xabc", PreviousWeight: "0", NewWeight: "5", BlockNumber: 3},
+	}, 100); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	svc, err := New(eventStore, nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	svc.SetChainHeads(staticChainHeads{{ChainID: 1, Head: 100}})
+
+	body := `{"query":"{ contracts { chainId synced weightChangeEvents(first: 1, skip: 0) { newWeight } } }"}`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	svc.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp struct {
+		Data struct {
+			Contracts []struct {
+				ChainID            string `json:"chainId"`
+				Synced             bool   `json:"synced"`
+				WeightChangeEvents []struct {
+					NewWeight string `json:"newWeight"`
+				} `json:"weightChangeEvents"`
+			} `json:"contracts"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	contracts := resp.Data.Contracts
+	if len(contracts) != 1 || contracts[0].ChainID != "1" || !contracts[0].Synced ||
+		len(contracts[0].WeightChangeEvents) != 1 || contracts[0].WeightChangeEvents[0].NewWeight != "5" {
+		t.Fatalf("expected the synced contract with its event, got %s", rec.Body.String())
+	}
+
+	svc.SetGraphQLLimits(graphqlapi.Limits{MaxDepth: 2})
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	svc.routes().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), graphqlapi.CodeDepthExceeded) {
+		t.Fatalf("expected the query to exceed the depth limit, got %s", rec.Body.String())
+	}
+}
+
+type staticIndexerStatus struct {
+	retry      map[common.Address]indexer.RetryStatus
+	supervisor map[common.Address]indexer.SupervisorStatus
+	audit      map[common.Address]indexer.AuditReport
+}
+
+func (s staticIndexerStatus) RetryStatus(_ uint64, contract common.Address) (indexer.RetryStatus, bool) {
+	status, ok := s.retry[contract]
+	return status, ok
+}
+
+func (s staticIndexerStatus) SupervisorStatus(_ uint64, contract common.Address) (indexer.SupervisorStatus, bool) {
+	status, ok := s.supervisor[contract]
+	return status, ok
+}
+
+func (s staticIndexerStatus) AuditStatus(_ uint64, contract common.Address) (indexer.AuditReport, bool) {
+	report, ok := s.audit[contract]
+	return report, ok
+}
+
+func TestContractStatusReportsRetryAndCrashes(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := New(store.New(database), nil, 0)
+	if err != nil {
+		t.Fatalf("create api service: %v", err)
+	}
+	backingOff := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	healthy := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	svc.SetIndexerStatus(staticIndexerStatus{
+		retry: map[common.Address]indexer.RetryStatus{
+			backingOff: {
+				Failures:  3,
+				Backoff:   store.Duration(20 * time.Second),
+				LastError: "rpc timeout",
+				Circuit:   indexer.CircuitOpen,
+			},
+		},
+		supervisor: map[common.Address]indexer.SupervisorStatus{
+			backingOff: {State: indexer.IndexerFailed, Failures: 5, LastError: "log index overflow"},
+		},
+		audit: map[common.Address]indexer.AuditReport{
+			backingOff: {Block: 9, Accounts: 2, Mismatches: []indexer.AuditMismatch{
+				{Account: healthy.Hex(), Indexed: "10", OnChain: "12", FirstBlock: 4},
+			}},
+		},
+	})
+	for _, contract := range []common.Address{backingOff, healthy} {
+		reqBody := fmt.Sprintf(`{"chainId":1,"address":%q,"startBlock":1,"expiresAt":%q}`,
+			contract.Hex(), futureTime(time.Hour).Format(time.RFC3339))
+		rec := httptest.NewRecorder()
+		svc.handleContracts(rec, httptest.NewRequest(http.MethodPost, "/contracts", strings.NewReader(reqBody)).WithContext(ctx))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected %d, got %d (body=%s)", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	type statusResponse struct {
+		Retry      *indexer.RetryStatus      `json:"retry"`
+		Supervisor *indexer.SupervisorStatus `json:"supervisor"`
+		Audit      *indexer.AuditReport      `json:"audit"`
+	}
+	rec := httptest.NewRecorder()
+	svc.handleRoot(rec, httptest.NewRequest(http.MethodGet, "/1/"+backingOff.Hex()+"/status", nil).WithContext(ctx))
+	var status statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	if status.Retry == nil || status.Retry.Failures != 3 || time.Duration(status.Retry.Backoff) != 20*time.Second ||
+		status.Retry.Circuit != indexer.CircuitOpen || status.Retry.LastError != "rpc timeout" {
+		t.Fatalf("expected the retry backoff, got %s", rec.Body.String())
+	}
+	if status.Supervisor == nil || status.Supervisor.State != indexer.IndexerFailed || status.Supervisor.Failures != 5 ||
+		status.Supervisor.LastError != "log index overflow" {
+		t.Fatalf("expected the failed indexer, got %s", rec.Body.String())
+	}
+	if status.Audit == nil || status.Audit.Block != 9 || len(status.Audit.Mismatches) != 1 || status.Audit.Mismatches[0].OnChain != "12" {
+		t.Fatalf("expected the audit mismatch, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	svc.handleRoot(rec, httptest.NewRequest(http.MethodGet, "/1/"+healthy.Hex()+"/status", nil).WithContext(ctx))
+	status = statusResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	if status.Retry != nil || status.Supervisor != nil || status.Audit != nil {
+		t.Fatalf("expected no retry, crash or audit state, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,382 @@
+// Package archive implements the portable file format used to move an indexed
+// contract between databases.
+//
+// An archive is a gzip stream of newline-delimited JSON records: one header
+// record describing the contract and its progress cursors, one record per
+// event in ascending key order, and a trailer record holding the event count
+// and the SHA-256 checksum of every preceding line.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+const (
+	// Format identifies onchain census indexer archives.
+	Format = "onchain-census-indexer/contract-archive"
+	// Version is the archive version written by this binary. Version 2 adds
+	// the event profile of the contract and the decoded arguments of events.
+	Version = 2
+
+	maxLineBytes    = 1 << 20
+	importBatchSize = 1000
+)
+
+var (
+	// ErrChecksumMismatch is returned when the archive content does not match its trailer.
+	ErrChecksumMismatch = errors.New("archive checksum mismatch")
+	// ErrConflict is returned by Import when the target store already holds data
+	// for the archived contract.
+	ErrConflict = errors.New("contract already present in target store")
+)
+
+// Header describes the archived contract.
+type Header struct {
+	Format        string               `json:"format"`
+	Version       int                  `json:"version"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	Contract      store.ContractRecord `json:"contract"`
+	IndexedUntil  *uint64              `json:"indexedUntil,omitempty"`
+	VerifiedUntil *uint64              `json:"verifiedUntil,omitempty"`
+}
+
+// Trailer closes an archive.
+type Trailer struct {
+	Events   uint64 `json:"events"`
+	Checksum string `json:"checksum"`
+}
+
+type record struct {
+	Header  *Header      `json:"header,omitempty"`
+	Event   *store.Event `json:"event,omitempty"`
+	Trailer *Trailer     `json:"trailer,omitempty"`
+}
+
+// Writer writes a contract archive.
+type Writer struct {
+	gz     *gzip.Writer
+	sum    hash.Hash
+	events uint64
+	closed bool
+}
+
+// NewWriter writes the archive header to w and returns a Writer for the events.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.Format = Format
+	header.Version = Version
+	if header.CreatedAt.IsZero() {
+		header.CreatedAt = time.Now().UTC()
+	}
+	aw := &Writer{
+		gz:  gzip.NewWriter(w),
+		sum: sha256.New(),
+	}
+	if err := aw.writeRecord(record{Header: &header}, true); err != nil {
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+	return aw, nil
+}
+
+// WriteEvent appends an event to the archive.
+func (w *Writer) WriteEvent(event store.Event) error {
+	if w.closed {
+		return fmt.Errorf("archive writer is closed")
+	}
+	if err := w.writeRecord(record{Event: &event}, true); err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+	w.events++
+	return nil
+}
+
+// Close writes the trailer and flushes the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	trailer := Trailer{
+		Events:   w.events,
+		Checksum: hex.EncodeToString(w.sum.Sum(nil)),
+	}
+	if err := w.writeRecord(record{Trailer: &trailer}, false); err != nil {
+		return fmt.Errorf("write trailer: %w", err)
+	}
+	return w.gz.Close()
+}
+
+func (w *Writer) writeRecord(rec record, checksummed bool) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if checksummed {
+		_, _ = w.sum.Write(line)
+	}
+	_, err = w.gz.Write(line)
+	return err
+}
+
+// Reader reads a contract archive.
+type Reader struct {
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	sum     hash.Hash
+	header  Header
+	events  uint64
+	done    bool
+}
+
+// NewReader reads and validates the archive header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	ar := &Reader{
+		gz:      gz,
+		scanner: scanner,
+		sum:     sha256.New(),
+	}
+	rec, err := ar.readRecord()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if rec.Header == nil {
+		return nil, fmt.Errorf("archive does not start with a header")
+	}
+	if rec.Header.Format != Format {
+		return nil, fmt.Errorf("unknown archive format %q", rec.Header.Format)
+	}
+	if rec.Header.Version < 1 || rec.Header.Version > Version {
+		return nil, fmt.Errorf("unsupported archive version %d (supported up to %d)", rec.Header.Version, Version)
+	}
+	ar.header = *rec.Header
+	return ar, nil
+}
+
+// Header returns the archive header.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next archived event. It returns io.EOF once the trailer has
+// been read and the checksum verified, or ErrChecksumMismatch if verification fails.
+func (r *Reader) Next() (store.Event, error) {
+	if r.done {
+		return store.Event{}, io.EOF
+	}
+	expected := hex.EncodeToString(r.sum.Sum(nil))
+	rec, err := r.readRecord()
+	if err != nil {
+		return store.Event{}, err
+	}
+	switch {
+	case rec.Event != nil:
+		r.events++
+		return *rec.Event, nil
+	case rec.Trailer != nil:
+		r.done = true
+		if rec.Trailer.Events != r.events || rec.Trailer.Checksum != expected {
+			return store.Event{}, ErrChecksumMismatch
+		}
+		return store.Event{}, io.EOF
+	default:
+		return store.Event{}, fmt.Errorf("unexpected archive record")
+	}
+}
+
+// Events returns the number of events read so far.
+func (r *Reader) Events() uint64 {
+	return r.events
+}
+
+// Close releases the reader resources.
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
+
+func (r *Reader) readRecord() (record, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return record{}, err
+		}
+		return record{}, fmt.Errorf("archive is truncated")
+	}
+	line := r.scanner.Bytes()
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return record{}, fmt.Errorf("decode archive record: %w", err)
+	}
+	if rec.Trailer == nil {
+		_, _ = r.sum.Write(line)
+		_, _ = r.sum.Write([]byte{'\n'})
+	}
+	return rec, nil
+}
+
+// Verify reads the whole archive from r and checks its checksum without
+// returning the events.
+func Verify(r io.Reader) (Header, uint64, error) {
+	ar, err := NewReader(r)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	defer func() {
+		_ = ar.Close()
+	}()
+	for {
+		if _, err := ar.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ar.Header(), ar.Events(), nil
+			}
+			return Header{}, 0, err
+		}
+	}
+}
+
+// Export writes the contract record, progress cursors and events of a contract
+// into w as an archive and returns the number of exported events.
+func Export(ctx context.Context, eventStore *store.Store, chainID uint64, contract common.Address, w io.Writer) (uint64, error) {
+	record, ok, err := eventStore.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("contract %d:%s not found", chainID, contract.Hex())
+	}
+	header := Header{Contract: record}
+	if indexed, ok, err := eventStore.LastIndexedBlock(ctx, chainID, contract); err != nil {
+		return 0, err
+	} else if ok {
+		header.IndexedUntil = &indexed
+	}
+	if verified, ok, err := eventStore.LastVerifiedBlock(ctx, chainID, contract); err != nil {
+		return 0, err
+	} else if ok {
+		header.VerifiedUntil = &verified
+	}
+	aw, err := NewWriter(w, header)
+	if err != nil {
+		return 0, err
+	}
+	if err := eventStore.IterateEvents(ctx, chainID, contract, aw.WriteEvent); err != nil {
+		return 0, err
+	}
+	if err := aw.Close(); err != nil {
+		return 0, err
+	}
+	return aw.events, nil
+}
+
+// Import loads an archive into the store. The archive is fully verified before
+// anything is written. Imports of contracts that already have a record or
+// progress cursors in the store fail with ErrConflict unless force is set, in
+// which case the existing contract data is replaced.
+func Import(ctx context.Context, eventStore *store.Store, r io.ReadSeeker, force bool) (Header, uint64, error) {
+	header, count, err := Verify(r)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	record := header.Contract
+	if record.ChainID == 0 || !common.IsHexAddress(record.Contract) {
+		return Header{}, 0, fmt.Errorf("archive contains an invalid contract record")
+	}
+	contract := common.HexToAddress(record.Contract)
+
+	conflict, err := hasContractData(ctx, eventStore, record.ChainID, contract)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	if conflict {
+		if !force {
+			return Header{}, 0, fmt.Errorf("%w: chainID %d contract %s", ErrConflict, record.ChainID, contract.Hex())
+		}
+		if err := eventStore.DeleteContractData(ctx, record.ChainID, contract); err != nil {
+			return Header{}, 0, fmt.Errorf("delete existing contract data: %w", err)
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Header{}, 0, fmt.Errorf("rewind archive: %w", err)
+	}
+	ar, err := NewReader(r)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	defer func() {
+		_ = ar.Close()
+	}()
+	batch := make([]store.Event, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := eventStore.PutEvents(ctx, record.ChainID, contract, batch); err != nil {
+			return fmt.Errorf("import events: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		event, err := ar.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Header{}, 0, err
+		}
+		batch = append(batch, event)
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return Header{}, 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return Header{}, 0, err
+	}
+	// The contract record is written last so indexers only pick the contract
+	// up once all of its events are in place.
+	if err := eventStore.RestoreContract(ctx, record, store.ReplaceOptions{
+		IndexedUntil:  header.IndexedUntil,
+		VerifiedUntil: header.VerifiedUntil,
+	}); err != nil {
+		return Header{}, 0, err
+	}
+	return header, count, nil
+}
+
+func hasContractData(ctx context.Context, eventStore *store.Store, chainID uint64, contract common.Address) (bool, error) {
+	if _, ok, err := eventStore.GetContract(ctx, chainID, contract); err != nil || ok {
+		return ok, err
+	}
+	if _, ok, err := eventStore.LastIndexedBlock(ctx, chainID, contract); err != nil || ok {
+		return ok, err
+	}
+	if _, ok, err := eventStore.LastVerifiedBlock(ctx, chainID, contract); err != nil || ok {
+		return ok, err
+	}
+	// Events without a record or cursors are orphans, or were left by an
+	// interrupted import, which writes them before restoring the record.
+	events, err := eventStore.ListEvents(ctx, store.ListOptions{ChainID: chainID, Contract: contract, First: 1})
+	return len(events) > 0, err
+}
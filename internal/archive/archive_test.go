@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	t.Cleanup(func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	})
+	return store.New(database)
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newTestStore(t)
+	target := newTestStore(t)
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	expiresAt := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	if err := source.SaveContract(ctx, 1, contract, 5, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	events := []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "2", BlockNumber: 5, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xbbb", PreviousWeight: "0", NewWeight: "3", BlockNumber: 7, LogIndex: 1},
+	}
+	if err := source.SaveEvents(ctx, 1, contract, events, 9); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := source.SetVerifiedBlock(ctx, 1, contract, 8); err != nil {
+		t.Fatalf("set verified block: %v", err)
+	}
+
+	var buf bytes.Buffer
+	exported, err := Export(ctx, source, 1, contract, &buf)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if exported != 2 {
+		t.Fatalf("expected 2 exported events, got %d", exported)
+	}
+
+	header, imported, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if imported != 2 || header.Contract.StartBlock != 5 {
+		t.Fatalf("unexpected import result: events=%d header=%+v", imported, header)
+	}
+
+	record, ok, err := target.GetContract(ctx, 1, contract)
+	if err != nil || !ok {
+		t.Fatalf("expected imported contract record (ok=%t, err=%v)", ok, err)
+	}
+	if !record.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expected expiresAt %s, got %s", expiresAt, record.ExpiresAt)
+	}
+	if indexed, ok, err := target.LastIndexedBlock(ctx, 1, contract); err != nil || !ok || indexed != 9 {
+		t.Fatalf("expected indexed block 9, got %d (ok=%t, err=%v)", indexed, ok, err)
+	}
+	if verified, ok, err := target.LastVerifiedBlock(ctx, 1, contract); err != nil || !ok || verified != 8 {
+		t.Fatalf("expected verified block 8, got %d (ok=%t, err=%v)", verified, ok, err)
+	}
+	got, err := target.ListEvents(ctx, store.ListOptions{ChainID: 1, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(got) != 2 || !reflect.DeepEqual(got[1], events[1]) {
+		t.Fatalf("unexpected imported events: %+v", got)
+	}
+
+	if _, _, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict error on second import, got %v", err)
+	}
+	if _, _, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("forced import: %v", err)
+	}
+
+	orphans := newTestStore(t)
+	if err := orphans.PutEvents(ctx, 1, contract, events[:1]); err != nil {
+		t.Fatalf("put events: %v", err)
+	}
+	if _, _, err := Import(ctx, orphans, bytes.NewReader(buf.Bytes()), false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict error with events stored without a record, got %v", err)
+	}
+}
+
+func TestImportRejectsTamperedArchive(t *testing.T) {
+	ctx := context.Background()
+	contract := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Contract: store.ContractRecord{
+		ChainID:   1,
+		Contract:  contract.Hex(),
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}})
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	if err := w.WriteEvent(store.Event{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "1", BlockNumber: 1}); err != nil {
+		t.Fatalf("write event: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("open gzip: %v", err)
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("read gzip: %v", err)
+	}
+	tampered := strings.Replace(string(plain), `"newWeight":"1"`, `"newWeight":"9"`, 1)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	if _, err := gw.Write([]byte(tampered)); err != nil {
+		t.Fatalf("write gzip: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+
+	target := newTestStore(t)
+	if _, _, err := Import(ctx, target, bytes.NewReader(out.Bytes()), false); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, ok, err := target.GetContract(ctx, 1, contract); err != nil || ok {
+		t.Fatalf("expected nothing to be imported (ok=%t, err=%v)", ok, err)
+	}
+}
@@ -0,0 +1,455 @@
+// Package backup creates, rotates and restores consistent copies of the
+// indexer database.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+const (
+	namePrefix       = "backup-"
+	nameTimeLayout   = "20060102T150405.000Z"
+	compressedSuffix = ".tar.gz"
+	defaultKeep      = 7
+)
+
+// Config configures backup creation and rotation.
+type Config struct {
+	// Dir is the directory where backups are written.
+	Dir string
+	// Keep is the number of most recent backups retained after each backup.
+	Keep int
+	// Compress stores backups as .tar.gz archives instead of plain directories.
+	Compress bool
+}
+
+// Info describes a stored backup.
+type Info struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+}
+
+// Manager creates and rotates backups of a store.
+type Manager struct {
+	store *store.Store
+	cfg   Config
+	mu    sync.Mutex
+}
+
+// NewManager returns a backup manager for the provided store.
+func NewManager(eventStore *store.Store, cfg Config) (*Manager, error) {
+	if eventStore == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("backup directory is required")
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultKeep
+	}
+	return &Manager{store: eventStore, cfg: cfg}, nil
+}
+
+// Create takes a checkpoint of the store, optionally compresses it and removes
+// backups beyond the configured retention count.
+func (m *Manager) Create(ctx context.Context) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.cfg.Dir, 0o755); err != nil {
+		return Info{}, fmt.Errorf("create backup dir: %w", err)
+	}
+	createdAt := time.Now().UTC()
+	name := namePrefix + createdAt.Format(nameTimeLayout)
+	checkpointDir := filepath.Join(m.cfg.Dir, "."+name+".tmp")
+	if err := m.store.Checkpoint(ctx, checkpointDir); err != nil {
+		_ = os.RemoveAll(checkpointDir)
+		return Info{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(checkpointDir)
+	}()
+
+	target := filepath.Join(m.cfg.Dir, name)
+	if m.cfg.Compress {
+		target += compressedSuffix
+		if err := compressDir(checkpointDir, target); err != nil {
+			return Info{}, fmt.Errorf("compress backup: %w", err)
+		}
+	} else if err := os.Rename(checkpointDir, target); err != nil {
+		return Info{}, fmt.Errorf("store backup: %w", err)
+	}
+
+	info, err := describe(target)
+	if err != nil {
+		return Info{}, err
+	}
+	log.Infow("database backup created", "path", info.Path, "size", info.Size, "compressed", info.Compressed)
+	if err := m.rotate(); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// List returns the stored backups ordered from newest to oldest.
+func (m *Manager) List() ([]Info, error) {
+	return List(m.cfg.Dir)
+}
+
+// Run creates a backup every interval until the context is canceled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Create(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Warnf("scheduled backup failed: %v", err)
+			}
+		}
+	}
+}
+
+func (m *Manager) rotate() error {
+	backups, err := List(m.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for i := m.cfg.Keep; i < len(backups); i++ {
+		if err := os.RemoveAll(backups[i].Path); err != nil {
+			return fmt.Errorf("remove old backup %s: %w", backups[i].Name, err)
+		}
+		log.Infow("removed old database backup", "path", backups[i].Path)
+	}
+	return nil
+}
+
+// List returns the backups stored in dir ordered from newest to oldest.
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []Info{}, nil
+		}
+		return nil, fmt.Errorf("read backup dir: %w", err)
+	}
+	out := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), namePrefix) {
+			continue
+		}
+		info, err := describe(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func describe(path string) (Info, error) {
+	name := filepath.Base(path)
+	compressed := strings.HasSuffix(name, compressedSuffix)
+	createdAt, err := time.Parse(nameTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), compressedSuffix))
+	if err != nil {
+		return Info{}, fmt.Errorf("invalid backup name %q", name)
+	}
+	size, err := pathSize(path)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{
+		Name:       name,
+		Path:       path,
+		CreatedAt:  createdAt,
+		Size:       size,
+		Compressed: compressed,
+	}, nil
+}
+
+func pathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Validate opens the database copy at dir read-only and checks that every
+// contract record and progress cursor can be decoded.
+func Validate(ctx context.Context, dir string) error {
+	database, err := store.OpenPebble(dir, store.PebbleOptions{ReadOnly: true, MustExist: true})
+	if err != nil {
+		return fmt.Errorf("open backup database: %w", err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	eventStore := store.New(database)
+	version, _, err := eventStore.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version > store.CurrentSchemaVersion() {
+		return fmt.Errorf("%w: backup is at version %d, this binary supports up to %d", store.ErrSchemaTooNew, version, store.CurrentSchemaVersion())
+	}
+	records, err := eventStore.ListContracts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.ChainID == 0 || !common.IsHexAddress(record.Contract) {
+			return fmt.Errorf("invalid contract record %d:%s", record.ChainID, record.Contract)
+		}
+		contract := common.HexToAddress(record.Contract)
+		if _, _, err := eventStore.LastIndexedBlock(ctx, record.ChainID, contract); err != nil {
+			return err
+		}
+		if _, _, err := eventStore.LastVerifiedBlock(ctx, record.ChainID, contract); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore validates the backup at source (a backup directory or .tar.gz
+// archive) and swaps it in place of the database at dbPath. The previous
+// database, if any, is kept next to dbPath and its path returned. The database
+// at dbPath must not be in use.
+func Restore(ctx context.Context, source, dbPath string) (string, error) {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("open backup: %w", err)
+	}
+	suffix := time.Now().UTC().Format(nameTimeLayout)
+	staging := dbPath + ".restore-" + suffix
+	if stat.IsDir() {
+		err = copyDir(source, staging)
+	} else {
+		err = extractArchive(source, staging)
+	}
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return "", fmt.Errorf("stage backup: %w", err)
+	}
+	if err := Validate(ctx, staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return "", fmt.Errorf("validate backup: %w", err)
+	}
+
+	previous := ""
+	if _, err := os.Stat(dbPath); err == nil {
+		// Opening the current database acquires its lock, which fails while
+		// the indexer is still running against it.
+		current, err := store.OpenPebble(dbPath, store.PebbleOptions{MustExist: true})
+		if err != nil {
+			_ = os.RemoveAll(staging)
+			return "", fmt.Errorf("open current database (is the indexer still running?): %w", err)
+		}
+		if err := current.Close(); err != nil {
+			_ = os.RemoveAll(staging)
+			return "", fmt.Errorf("close current database: %w", err)
+		}
+		previous = dbPath + ".pre-restore-" + suffix
+		if err := os.Rename(dbPath, previous); err != nil {
+			_ = os.RemoveAll(staging)
+			return "", fmt.Errorf("move current database aside: %w", err)
+		}
+	}
+	if err := os.Rename(staging, dbPath); err != nil {
+		if previous != "" {
+			_ = os.Rename(previous, dbPath)
+		}
+		_ = os.RemoveAll(staging)
+		return "", fmt.Errorf("swap restored database: %w", err)
+	}
+	return previous, nil
+}
+
+// compressDir writes src as a .tar.gz archive at target. The archive is
+// written under a hidden temporary name that List ignores and renamed once
+// synced, so a failed or interrupted write never leaves a partial backup.
+func compressDir(src, target string) (err error) {
+	tmp := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp, target)
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func extractArchive(source, target string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in backup archive: %q", header.Name)
+		}
+		path := filepath.Join(target, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, tr); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry %q in backup archive", header.Name)
+		}
+	}
+}
+
+func copyDir(src, target string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(dest, 0o755)
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+		return writeFile(dest, in)
+	})
+}
+
+func writeFile(path string, r io.Reader) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(out, r)
+	return err
+}
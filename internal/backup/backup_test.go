@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestCreateRotateAndRestore(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "compressed"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			database, err := store.OpenPebble(filepath.Join(dir, "db"), store.PebbleOptions{})
+			if err != nil {
+				t.Fatalf("open pebble: %v", err)
+			}
+			defer func() {
+				if cerr := database.Close(); cerr != nil {
+					t.Fatalf("close db: %v", cerr)
+				}
+			}()
+			eventStore := store.New(database)
+			contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+			if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().UTC().Add(time.Hour)); err != nil {
+				t.Fatalf("save contract: %v", err)
+			}
+			if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+				{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "1", BlockNumber: 2},
+			}, 5); err != nil {
+				t.Fatalf("save events: %v", err)
+			}
+
+			manager, err := NewManager(eventStore, Config{Dir: filepath.Join(dir, "backups"), Keep: 2, Compress: compress})
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+			var latest Info
+			for range 3 {
+				latest, err = manager.Create(ctx)
+				if err != nil {
+					t.Fatalf("create backup: %v", err)
+				}
+				if latest.Compressed != compress {
+					t.Fatalf("expected compressed=%t, got %+v", compress, latest)
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+			backups, err := manager.List()
+			if err != nil {
+				t.Fatalf("list backups: %v", err)
+			}
+			if len(backups) != 2 || backups[0].Name != latest.Name {
+				t.Fatalf("expected 2 backups with the latest first, got %+v", backups)
+			}
+
+			// Writes after the backup must not show up in the restored copy.
+			if err := eventStore.SetIndexedBlock(ctx, 1, contract, 99); err != nil {
+				t.Fatalf("set indexed block: %v", err)
+			}
+
+			restoredPath := filepath.Join(dir, "restored")
+			previous, err := Restore(ctx, latest.Path, restoredPath)
+			if err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+			if previous != "" {
+				t.Fatalf("expected no previous database, got %q", previous)
+			}
+			restoredDB, err := store.OpenPebble(restoredPath, store.PebbleOptions{MustExist: true})
+			if err != nil {
+				t.Fatalf("open restored db: %v", err)
+			}
+			defer func() {
+				if cerr := restoredDB.Close(); cerr != nil {
+					t.Fatalf("close restored db: %v", cerr)
+				}
+			}()
+			restored := store.New(restoredDB)
+			if indexed, ok, err := restored.LastIndexedBlock(ctx, 1, contract); err != nil || !ok || indexed != 5 {
+				t.Fatalf("expected restored indexed block 5, got %d (ok=%t, err=%v)", indexed, ok, err)
+			}
+			events, err := restored.ListEvents(ctx, store.ListOptions{ChainID: 1, Contract: contract})
+			if err != nil {
+				t.Fatalf("list restored events: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("expected 1 restored event, got %d", len(events))
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Restore(context.Background(), t.TempDir(), filepath.Join(dir, "db")); err == nil {
+		t.Fatalf("expected restore of an empty directory to fail")
+	}
+}
+
+func TestCompressDirFailureLeavesNoBackup(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, namePrefix+time.Now().UTC().Format(nameTimeLayout)+compressedSuffix)
+	if err := compressDir(filepath.Join(dir, "missing"), target); err == nil {
+		t.Fatalf("expected compressing a missing directory to fail")
+	}
+	backups, err := List(dir)
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(backups) != 0 {
+		t.Fatalf("expected no backups after a failed compression, got %+v", backups)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read backup dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the temporary archive to be removed, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,252 @@
+package graphqlapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/graphql-go/graphql"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// ContractLister lists the served contracts with their metadata and sync
+// status, including archived contracts.
+type ContractLister func(ctx context.Context) []indexer.ContractInfo
+
+type contractListingKey struct{}
+
+// contractListing holds the contracts listed once for a request.
+type contractListing struct {
+	once      sync.Once
+	contracts []indexer.ContractInfo
+}
+
+// WithContractListing returns a context whose global schema resolvers share
+// a single contract listing, so a query listing or looking up contracts in
+// several fields lists them once.
+func WithContractListing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contractListingKey{}, &contractListing{})
+}
+
+// memoized returns a lister that lists the contracts once per context set
+// up by WithContractListing, and on every call otherwise.
+func (l ContractLister) memoized() ContractLister {
+	return func(ctx context.Context) []indexer.ContractInfo {
+		listing, ok := ctx.Value(contractListingKey{}).(*contractListing)
+		if !ok {
+			return l(ctx)
+		}
+		listing.once.Do(func() { listing.contracts = l(ctx) })
+		return listing.contracts
+	}
+}
+
+// NewGlobalSchema builds the GraphQL schema for querying the contracts of
+// every chain and their WeightChanged events. Only contracts returned by
+// contracts can be queried.
+func NewGlobalSchema(eventStore store.Backend, contracts ContractLister) (graphql.Schema, error) {
+	if eventStore == nil {
+		return graphql.Schema{}, fmt.Errorf("store is required")
+	}
+	if contracts == nil {
+		return graphql.Schema{}, fmt.Errorf("contract lister is required")
+	}
+	contracts = contracts.memoized()
+	types := newEventTypes()
+
+	findContract := func(ctx context.Context, args map[string]interface{}) (*indexer.ContractInfo, error) {
+		chainID, err := uintArg(args, "chainId")
+		if err != nil {
+			return nil, err
+		}
+		raw, _ := args["address"].(string)
+		if raw == "" {
+			raw, _ = args["contract"].(string)
+		}
+		if !common.IsHexAddress(raw) {
+			return nil, fmt.Errorf("invalid contract address %q", raw)
+		}
+		address := common.HexToAddress(raw)
+		for _, info := range contracts(ctx) {
+			if info.ChainID == chainID && info.Address == address {
+				return &info, nil
+			}
+		}
+		return nil, nil
+	}
+
+	contractType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Contract",
+		Fields: graphql.Fields{
+			"chainId": {
+				Type:    graphql.NewNonNull(types.bigInt),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.ChainID }),
+			},
+			"address": {
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.Address.Hex() }),
+			},
+			"label": {
+				Type: graphql.String,
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} {
+					if info.Label == "" {
+						return nil
+					}
+					return info.Label
+				}),
+			},
+			"startBlock": {
+				Type:    graphql.NewNonNull(types.bigInt),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.StartBlock }),
+			},
+			"expiresAt": {
+				Type: graphql.String,
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} {
+					if info.ExpiresAt.IsZero() {
+						return nil
+					}
+					return info.ExpiresAt.UTC().Format(time.RFC3339)
+				}),
+			},
+			"endpoint": {
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} {
+					return fmt.Sprintf("/%d/%s/graphql", info.ChainID, info.Address.Hex())
+				}),
+			},
+			"synced": {
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.Synced }),
+			},
+			"expiringSoon": {
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.ExpiringSoon }),
+			},
+			"archived": {
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.Archive != nil }),
+			},
+			"upgradePending": {
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Resolve: contractField(func(info indexer.ContractInfo) interface{} { return info.UpgradePending }),
+			},
+			"lastVerifiedBlock": {
+				Type: types.bigInt,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					info, ok := p.Source.(indexer.ContractInfo)
+					if !ok {
+						return nil, fmt.Errorf("unexpected source type")
+					}
+					block, ok, err := eventStore.LastVerifiedBlock(p.Context, info.ChainID, info.Address)
+					if err != nil || !ok {
+						return nil, err
+					}
+					return block, nil
+				},
+			},
+			"weightChangeEvents": {
+				Type: types.events,
+				Args: types.eventArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					info, ok := p.Source.(indexer.ContractInfo)
+					if !ok {
+						return nil, fmt.Errorf("unexpected source type")
+					}
+					return listEvents(p, eventStore, info.ChainID, info.Address)
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"contracts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(contractType))),
+				Args: graphql.FieldConfigArgument{
+					"chainId":  &graphql.ArgumentConfig{Type: types.bigInt},
+					"archived": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var chainID uint64
+					if _, ok := p.Args["chainId"]; ok {
+						var err error
+						if chainID, err = uintArg(p.Args, "chainId"); err != nil {
+							return nil, err
+						}
+					}
+					includeArchived, _ := p.Args["archived"].(bool)
+					out := []indexer.ContractInfo{}
+					for _, info := range contracts(p.Context) {
+						if chainID != 0 && info.ChainID != chainID {
+							continue
+						}
+						if info.Archive != nil && !includeArchived {
+							continue
+						}
+						out = append(out, info)
+					}
+					return out, nil
+				},
+			},
+			"contract": &graphql.Field{
+				Type: contractType,
+				Args: graphql.FieldConfigArgument{
+					"chainId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(types.bigInt)},
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					info, err := findContract(p.Context, p.Args)
+					if err != nil || info == nil {
+						return nil, err
+					}
+					return *info, nil
+				},
+			},
+			"weightChangeEvents": &graphql.Field{
+				Type: types.events,
+				Args: types.eventArgs(graphql.FieldConfigArgument{
+					"chainId":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(types.bigInt)},
+					"contract": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					info, err := findContract(p.Context, p.Args)
+					if err != nil {
+						return nil, err
+					}
+					if info == nil {
+						return nil, fmt.Errorf("contract %v is not indexed on chain %v", p.Args["contract"], p.Args["chainId"])
+					}
+					return listEvents(p, eventStore, info.ChainID, info.Address)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func contractField(value func(indexer.ContractInfo) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		info, ok := p.Source.(indexer.ContractInfo)
+		if !ok {
+			return nil, fmt.Errorf("unexpected source type")
+		}
+		return value(info), nil
+	}
+}
+
+func uintArg(args map[string]interface{}, name string) (uint64, error) {
+	raw, _ := args[name].(string)
+	value, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return value, nil
+}
@@ -0,0 +1,159 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/graphql-go/graphql"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/indexer"
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+const globalQuery = `query Global($chainId: BigInt!, $contract: String!) {
+    contracts {
+        chainId
+        address
+        label
+        synced
+        lastVerifiedBlock
+        weightChangeEvents(first: 10, skip: 0) {
+            newWeight
+        }
+    }
+    archived: contracts(archived: true) {
+        address
+        archived
+    }
+    contract(chainId: 10, address: "0x3333333333333333333333333333333333333333") {
+        endpoint
+    }
+    weightChangeEvents(chainId: $chainId, contract: $contract, first: 1, skip: 0, orderDirection: desc) {
+        account {
+            id
+        }
+        blockNumber
+    }
+}`
+
+func TestGlobalSchemaQuery(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	first := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	second := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	archived := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	if err := eventStore.SaveEvents(ctx, 1, first, []store.Event{
+		{ChainID: 1, Contract: first.Hex(), Account: "0xabc", PreviousWeight: "0", NewWeight: "1", BlockNumber: 5},
+		{ChainID: 1, Contract: first.Hex(), Account: "0xdef", PreviousWeight: "0", NewWeight: "2", BlockNumber: 7},
+	}, 7); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 10, second, []store.Event{
+		{ChainID: 10, Contract: second.Hex(), Account: "0x123", PreviousWeight: "0", NewWeight: "3", BlockNumber: 9},
+	}, 9); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := eventStore.SetVerifiedBlock(ctx, 1, first, 6); err != nil {
+		t.Fatalf("set verified block: %v", err)
+	}
+	contracts := []indexer.ContractInfo{
+		{ChainID: 1, Address: first, Label: "first", Synced: true},
+		{ChainID: 10, Address: second},
+		{ChainID: 10, Address: archived, Archive: &store.ContractArchive{ArchivedAt: time.Now()}},
+	}
+
+	listings := 0
+	schema, err := NewGlobalSchema(eventStore, func(context.Context) []indexer.ContractInfo {
+		listings++
+		return contracts
+	})
+	if err != nil {
+		t.Fatalf("build schema: %v", err)
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  globalQuery,
+		VariableValues: map[string]interface{}{"chainId": "1", "contract": first.Hex()},
+		Context:        WithContractListing(ctx),
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("graphql errors: %v", result.Errors)
+	}
+	if listings != 1 {
+		t.Fatalf("expected the contracts to be listed once per request, got %d", listings)
+	}
+	raw, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	var data struct {
+		Contracts []struct {
+			ChainID            string  `json:"chainId"`
+			Address            string  `json:"address"`
+			Label              string  `json:"label"`
+			Synced             bool    `json:"synced"`
+			LastVerifiedBlock  *string `json:"lastVerifiedBlock"`
+			WeightChangeEvents []struct {
+				NewWeight string `json:"newWeight"`
+			} `json:"weightChangeEvents"`
+		} `json:"contracts"`
+		Archived []struct {
+			Archived bool `json:"archived"`
+		} `json:"archived"`
+		Contract *struct {
+			Endpoint string `json:"endpoint"`
+		} `json:"contract"`
+		WeightChangeEvents []struct {
+			Account     struct{ ID string } `json:"account"`
+			BlockNumber string              `json:"blockNumber"`
+		} `json:"weightChangeEvents"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if len(data.Contracts) != 2 {
+		t.Fatalf("expected 2 contracts without the archived one, got %s", raw)
+	}
+	if c := data.Contracts[0]; c.ChainID != "1" || c.Label != "first" || !c.Synced ||
+		c.LastVerifiedBlock == nil || *c.LastVerifiedBlock != "6" || len(c.WeightChangeEvents) != 2 {
+		t.Fatalf("unexpected first contract, got %s", raw)
+	}
+	if c := data.Contracts[1]; c.ChainID != "10" || c.Label != "" ||
+		c.LastVerifiedBlock == nil || *c.LastVerifiedBlock != "9" || len(c.WeightChangeEvents) != 1 {
+		t.Fatalf("unexpected second contract, got %s", raw)
+	}
+	if len(data.Archived) != 3 || !data.Archived[2].Archived {
+		t.Fatalf("expected archived contracts to be listed on request, got %s", raw)
+	}
+	if data.Contract == nil || data.Contract.Endpoint != "/10/"+archived.Hex()+"/graphql" {
+		t.Fatalf("expected the archived contract endpoint, got %s", raw)
+	}
+	if len(data.WeightChangeEvents) != 1 || data.WeightChangeEvents[0].BlockNumber != "7" {
+		t.Fatalf("expected the latest event of the first contract, got %s", raw)
+	}
+
+	result = graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  globalQuery,
+		VariableValues: map[string]interface{}{"chainId": "2", "contract": first.Hex()},
+		Context:        ctx,
+	})
+	if len(result.Errors) == 0 {
+		t.Fatalf("expected an error for a contract that is not indexed")
+	}
+}
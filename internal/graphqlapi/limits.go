@@ -0,0 +1,310 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/handler"
+)
+
+// maxRequestBytes bounds the GraphQL request bodies read by Limits.Handler.
+const maxRequestBytes = 1 << 20
+
+// Limit error codes reported in the extensions of GraphQL errors.
+const (
+	CodePageSizeExceeded   = "PAGE_SIZE_EXCEEDED"
+	CodeDepthExceeded      = "DEPTH_EXCEEDED"
+	CodeComplexityExceeded = "COMPLEXITY_EXCEEDED"
+	CodeTimeout            = "TIMEOUT"
+)
+
+// Limits bounds the cost of GraphQL queries. Zero values do not limit.
+type Limits struct {
+	// DefaultPageSize replaces a first of 0 in weightChangeEvents fields.
+	DefaultPageSize int
+	// MaxPageSize is the largest first accepted.
+	MaxPageSize int
+	// MaxDepth is the deepest field nesting accepted.
+	MaxDepth int
+	// MaxComplexity bounds the estimated cost of a query: every field costs
+	// one, and the fields below a paginated field count once per page entry.
+	MaxComplexity int
+	// ListSizes bounds the entries of the list fields without pagination, such
+	// as contracts, by field name. The fields below them count once per entry.
+	ListSizes map[string]int
+	// Timeout bounds the execution of a query.
+	Timeout time.Duration
+}
+
+// LimitError reports a query rejected by Limits, with its code and limit in
+// the extensions of the GraphQL error.
+type LimitError struct {
+	Code    string
+	Message string
+	Limit   int
+	Value   int
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *LimitError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	if e.Limit > 0 {
+		ext["limit"] = e.Limit
+	}
+	if e.Value > 0 {
+		ext["value"] = e.Value
+	}
+	return ext
+}
+
+type limitsKey struct{}
+
+// WithLimits returns a context whose weightChangeEvents resolvers apply the
+// page size limits of l.
+func WithLimits(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, limitsKey{}, l)
+}
+
+func limitsFrom(ctx context.Context) Limits {
+	l, _ := ctx.Value(limitsKey{}).(Limits)
+	return l
+}
+
+// pageSize returns the number of events a weightChangeEvents field with first
+// may return, 0 meaning unlimited.
+func (l Limits) pageSize(first int) (int, error) {
+	if first == 0 {
+		first = l.DefaultPageSize
+	}
+	if l.MaxPageSize > 0 && first > l.MaxPageSize {
+		return 0, &LimitError{
+			Code:    CodePageSizeExceeded,
+			Message: fmt.Sprintf("first must not exceed %d", l.MaxPageSize),
+			Limit:   l.MaxPageSize,
+			Value:   first,
+		}
+	}
+	return first, nil
+}
+
+// Handler applies l to the GraphQL requests served by next: queries over the
+// depth or complexity limits are rejected before they run, and the others run
+// with the page size limits and the timeout of l.
+func (l Limits) Handler(next *handler.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+			if err != nil {
+				status := http.StatusBadRequest
+				if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(w, "read request body: "+err.Error(), status)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		opts := handler.NewRequestOptions(r)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := l.CheckQuery(opts.Query, opts.OperationName, opts.Variables); err != nil {
+			writeErrors(w, err)
+			return
+		}
+
+		ctx := WithLimits(r.Context(), l)
+		if l.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+			defer cancel()
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CheckQuery checks the depth and complexity of the operation of query.
+// Queries that do not parse are left to the GraphQL handler to report.
+func (l Limits) CheckQuery(query, operationName string, variables map[string]interface{}) error {
+	if l.MaxDepth <= 0 && l.MaxComplexity <= 0 {
+		return nil
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+	a := queryAnalyzer{
+		limits:    l,
+		variables: variables,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		visiting:  make(map[string]bool),
+	}
+	var operations []*ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch def := definition.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operations = append(operations, def)
+			}
+		}
+	}
+	for _, operation := range operations {
+		depth, cost := a.selectionSet(operation.SelectionSet)
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &LimitError{
+				Code:    CodeDepthExceeded,
+				Message: fmt.Sprintf("query depth %d exceeds the limit of %d", depth, l.MaxDepth),
+				Limit:   l.MaxDepth,
+				Value:   depth,
+			}
+		}
+		if l.MaxComplexity > 0 && cost > l.MaxComplexity {
+			return &LimitError{
+				Code:    CodeComplexityExceeded,
+				Message: fmt.Sprintf("query complexity %d exceeds the limit of %d", cost, l.MaxComplexity),
+				Limit:   l.MaxComplexity,
+				Value:   cost,
+			}
+		}
+	}
+	return nil
+}
+
+type queryAnalyzer struct {
+	limits    Limits
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	// visiting guards against fragment cycles, which validation rejects later.
+	visiting map[string]bool
+}
+
+// selectionSet returns the depth and cost of a selection set. Introspection
+// fields are not counted, so GraphiQL keeps working under tight limits.
+func (a queryAnalyzer) selectionSet(set *ast.SelectionSet) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+	var depth, cost int
+	for _, selection := range set.Selections {
+		var d, c int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, c = a.selectionSet(sel.SelectionSet)
+			d++
+			c = saturatingAdd(1, saturatingMul(a.multiplier(sel), c))
+		case *ast.InlineFragment:
+			d, c = a.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			fragment, ok := a.fragments[sel.Name.Value]
+			if !ok || a.visiting[sel.Name.Value] {
+				continue
+			}
+			a.visiting[sel.Name.Value] = true
+			d, c = a.selectionSet(fragment.SelectionSet)
+			delete(a.visiting, sel.Name.Value)
+		}
+		depth = max(depth, d)
+		cost = saturatingAdd(cost, c)
+	}
+	return depth, cost
+}
+
+// multiplier returns the number of entries a field may return: its page size
+// when it takes a first argument, its list size when it has one, and one
+// otherwise.
+func (a queryAnalyzer) multiplier(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		first := a.intValue(arg.Value)
+		if first == 0 {
+			first = a.limits.DefaultPageSize
+		}
+		return max(first, 1)
+	}
+	return max(a.limits.ListSizes[field.Name.Value], 1)
+}
+
+func (a queryAnalyzer) intValue(value ast.Value) int {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.Variable:
+		switch n := a.variables[v.Name.Value].(type) {
+		case int:
+			return n
+		case float64:
+			return int(min(n, math.MaxInt32))
+		case json.Number:
+			i, _ := n.Int64()
+			return int(min(i, math.MaxInt32))
+		}
+	}
+	return 0
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+func writeErrors(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(&gqlerrors.Error{
+			Message:       err.Error(),
+			Locations:     []location.SourceLocation{},
+			OriginalError: err,
+		})},
+	})
+}
+
+// timeoutError reports the queries stopped by the deadline of Limits.Handler.
+func timeoutError(ctx context.Context, err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() == nil {
+		return err
+	}
+	if l := limitsFrom(ctx); l.Timeout > 0 {
+		return &LimitError{
+			Code:    CodeTimeout,
+			Message: fmt.Sprintf("query exceeded the %s timeout", l.Timeout),
+			Limit:   int(l.Timeout.Milliseconds()),
+		}
+	}
+	return err
+}
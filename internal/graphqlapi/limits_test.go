@@ -0,0 +1,172 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/graphql-go/handler"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestLimitsCheckQuery(t *testing.T) {
+	limits := Limits{DefaultPageSize: 10, MaxDepth: 3, MaxComplexity: 100}
+	for _, tc := range []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		code      string
+	}{
+		{
+			name:  "default page",
+			query: `{ weightChangeEvents(first: 0, skip: 0) { account { id } newWeight } }`,
+		},
+		{
+			name:  "too deep",
+			query: `{ contracts { weightChangeEvents(first: 1, skip: 0) { account { id } } } }`,
+			code:  CodeDepthExceeded,
+		},
+		{
+			name:  "too deep through fragments",
+			query: `{ contracts { ...events } } fragment events on Contract { weightChangeEvents(first: 1, skip: 0) { account { id } } }`,
+			code:  CodeDepthExceeded,
+		},
+		{
+			name:      "too complex",
+			query:     `query Q($first: Int!) { weightChangeEvents(first: $first, skip: 0) { account { id } newWeight } }`,
+			variables: map[string]interface{}{"first": float64(50)},
+			code:      CodeComplexityExceeded,
+		},
+		{
+			name:  "aliases add up",
+			query: `{ a: weightChangeEvents(first: 40, skip: 0) { newWeight } b: weightChangeEvents(first: 40, skip: 0) { newWeight } c: weightChangeEvents(first: 40, skip: 0) { newWeight } }`,
+			code:  CodeComplexityExceeded,
+		},
+		{
+			name:  "introspection",
+			query: `{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`,
+		},
+		{
+			name:  "syntax error",
+			query: `{ weightChangeEvents(`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := limits.CheckQuery(tc.query, "", tc.variables)
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("expected the query to pass, got %v", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Code != tc.code {
+				t.Fatalf("expected %s, got %v", tc.code, err)
+			}
+		})
+	}
+
+	query := `{ contracts { weightChangeEvents(first: 5, skip: 0) { newWeight } } }`
+	if err := limits.CheckQuery(query, "", nil); err != nil {
+		t.Fatalf("expected an unsized contracts list to count once, got %v", err)
+	}
+	limits.ListSizes = map[string]int{"contracts": 20}
+	var limitErr *LimitError
+	if err := limits.CheckQuery(query, "", nil); !errors.As(err, &limitErr) ||
+		limitErr.Code != CodeComplexityExceeded || limitErr.Value != 121 {
+		t.Fatalf("expected the contracts list to count once per contract, got %v", err)
+	}
+}
+
+func TestLimitsHandler(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+	contract := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	events := make([]store.Event, 0, 5)
+	for block := uint64(1); block <= 5; block++ {
+		events = append(events, store.Event{
+			ChainID: 1, Contract: contract.Hex(), Account: fmt.Sprintf("0x%d", block),
+			PreviousWeight: "0", NewWeight: "1", BlockNumber: block,
+		})
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, events, 5); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	schema, err := NewSchema(eventStore, 1, contract)
+	if err != nil {
+		t.Fatalf("build schema: %v", err)
+	}
+	limits := Limits{DefaultPageSize: 2, MaxPageSize: 3, MaxDepth: 5, MaxComplexity: 1000, Timeout: time.Second}
+	server := limits.Handler(handler.New(&handler.Config{Schema: &schema}))
+
+	type response struct {
+		Data struct {
+			WeightChangeEvents []struct {
+				NewWeight string `json:"newWeight"`
+			} `json:"weightChangeEvents"`
+		} `json:"data"`
+		Errors []struct {
+			Message    string                 `json:"message"`
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	query := func(first int) response {
+		body := fmt.Sprintf(`{"query":"{ weightChangeEvents(first: %d, skip: 0) { newWeight } }"}`, first)
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		var resp response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal response %s: %v", rec.Body.String(), err)
+		}
+		return resp
+	}
+
+	if resp := query(0); len(resp.Errors) != 0 || len(resp.Data.WeightChangeEvents) != 2 {
+		t.Fatalf("expected first: 0 to return the default page of 2 events, got %+v", resp)
+	}
+	if resp := query(3); len(resp.Errors) != 0 || len(resp.Data.WeightChangeEvents) != 3 {
+		t.Fatalf("expected 3 events, got %+v", resp)
+	}
+	resp := query(4)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != CodePageSizeExceeded ||
+		resp.Errors[0].Extensions["limit"] != float64(3) {
+		t.Fatalf("expected a page size error, got %+v", resp)
+	}
+
+	limits.MaxComplexity = 3
+	server = limits.Handler(handler.New(&handler.Config{Schema: &schema}))
+	resp = query(3)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != CodeComplexityExceeded ||
+		resp.Errors[0].Extensions["value"] != float64(4) {
+		t.Fatalf("expected a complexity error, got %+v", resp)
+	}
+
+	expired, cancel := context.WithDeadline(WithLimits(ctx, limits), time.Now().Add(-time.Second))
+	defer cancel()
+	err = timeoutError(expired, context.DeadlineExceeded)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Code != CodeTimeout {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package graphqlapi
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -12,7 +13,7 @@ import (
 )
 
 // NewSchema builds the GraphQL schema for querying WeightChanged events.
-func NewSchema(eventStore *store.Store, chainID uint64, contract common.Address) (graphql.Schema, error) {
+func NewSchema(eventStore store.Backend, chainID uint64, contract common.Address) (graphql.Schema, error) {
 	if eventStore == nil {
 		return graphql.Schema{}, fmt.Errorf("store is required")
 	}
@@ -22,6 +23,33 @@ func NewSchema(eventStore *store.Store, chainID uint64, contract common.Address)
 	if contract == (common.Address{}) {
 		return graphql.Schema{}, fmt.Errorf("contract is required")
 	}
+	types := newEventTypes()
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"weightChangeEvents": &graphql.Field{
+				Type: types.events,
+				Args: types.eventArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return listEvents(p, eventStore, chainID, contract)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// eventTypes are the types shared by the per-contract and global schemas.
+type eventTypes struct {
+	bigInt         *graphql.Scalar
+	events         graphql.Output
+	orderBy        *graphql.Enum
+	orderDirection *graphql.Enum
+}
+
+func newEventTypes() eventTypes {
 	bigIntScalar := graphql.NewScalar(graphql.ScalarConfig{
 		Name: "BigInt",
 		Serialize: func(value interface{}) interface{} {
@@ -69,6 +97,14 @@ func NewSchema(eventStore *store.Store, chainID uint64, contract common.Address)
 		},
 	})
 
+	eventArgType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EventArg",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
 	weightChangeEventType := graphql.NewObject(graphql.ObjectConfig{
 		Name: "WeightChangeEvent",
 		Fields: graphql.Fields{
@@ -85,6 +121,25 @@ func NewSchema(eventStore *store.Store, chainID uint64, contract common.Address)
 			"previousWeight": {Type: graphql.NewNonNull(bigIntScalar)},
 			"newWeight":      {Type: graphql.NewNonNull(bigIntScalar)},
 			"blockNumber":    {Type: graphql.NewNonNull(bigIntScalar)},
+			"args": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(eventArgType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					event, ok := p.Source.(store.Event)
+					if !ok {
+						return nil, fmt.Errorf("unexpected source type")
+					}
+					names := make([]string, 0, len(event.Args))
+					for name := range event.Args {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					args := make([]map[string]interface{}, 0, len(names))
+					for _, name := range names {
+						args = append(args, map[string]interface{}{"name": name, "value": event.Args[name]})
+					}
+					return args, nil
+				},
+			},
 		},
 	})
 
@@ -102,34 +157,48 @@ func NewSchema(eventStore *store.Store, chainID uint64, contract common.Address)
 		},
 	})
 
-	query := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Query",
-		Fields: graphql.Fields{
-			"weightChangeEvents": &graphql.Field{
-				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(weightChangeEventType))),
-				Args: graphql.FieldConfigArgument{
-					"first":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
-					"skip":           &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
-					"orderBy":        &graphql.ArgumentConfig{Type: orderByEnum},
-					"orderDirection": &graphql.ArgumentConfig{Type: orderDirectionEnum},
-				},
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					first, _ := p.Args["first"].(int)
-					skip, _ := p.Args["skip"].(int)
-					orderBy, _ := p.Args["orderBy"].(string)
-					orderDirection, _ := p.Args["orderDirection"].(string)
-					return eventStore.ListEvents(p.Context, store.ListOptions{
-						First:          first,
-						Skip:           skip,
-						OrderBy:        orderBy,
-						OrderDirection: orderDirection,
-						ChainID:        chainID,
-						Contract:       contract,
-					})
-				},
-			},
-		},
-	})
+	return eventTypes{
+		bigInt:         bigIntScalar,
+		events:         graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(weightChangeEventType))),
+		orderBy:        orderByEnum,
+		orderDirection: orderDirectionEnum,
+	}
+}
 
-	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+// eventArgs returns the pagination and ordering arguments of the
+// weightChangeEvents fields, merged with extra.
+func (t eventTypes) eventArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"first":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"skip":           &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"orderBy":        &graphql.ArgumentConfig{Type: t.orderBy},
+		"orderDirection": &graphql.ArgumentConfig{Type: t.orderDirection},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+func listEvents(p graphql.ResolveParams, eventStore store.Backend, chainID uint64, contract common.Address) ([]store.Event, error) {
+	first, _ := p.Args["first"].(int)
+	skip, _ := p.Args["skip"].(int)
+	orderBy, _ := p.Args["orderBy"].(string)
+	orderDirection, _ := p.Args["orderDirection"].(string)
+	first, err := limitsFrom(p.Context).pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+	events, err := eventStore.ListEvents(p.Context, store.ListOptions{
+		First:          first,
+		Skip:           skip,
+		OrderBy:        orderBy,
+		OrderDirection: orderDirection,
+		ChainID:        chainID,
+		Contract:       contract,
+	})
+	if err != nil {
+		return nil, timeoutError(p.Context, err)
+	}
+	return events, nil
 }
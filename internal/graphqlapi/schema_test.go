@@ -24,6 +24,10 @@ const sampleQuery = `query GetWeightChangeEvents($first: Int!, $skip: Int!) {
         }
         previousWeight
         newWeight
+        args {
+            name
+            value
+        }
     }
 }`
 
@@ -43,7 +47,7 @@ func TestSchemaQuery(t *testing.T) {
 	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
 	events := []store.Event{
 		{ChainID: 1, Contract: contract.Hex(), Account: "0xabc", PreviousWeight: "1", NewWeight: "2", BlockNumber: 1, LogIndex: 0},
-		{ChainID: 1, Contract: contract.Hex(), Account: "0xdef", PreviousWeight: "2", NewWeight: "3", BlockNumber: 2, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xdef", PreviousWeight: "2", NewWeight: "3", BlockNumber: 2, LogIndex: 0, Args: map[string]string{"to": "0xdef", "value": "3"}},
 	}
 	if err := eventStore.SaveEvents(ctx, 1, contract, events, 2); err != nil {
 		t.Fatalf("save events: %v", err)
@@ -92,4 +96,18 @@ func TestSchemaQuery(t *testing.T) {
 	if firstEvent["newWeight"] != "2" {
 		t.Fatalf("expected newWeight 2, got %v", firstEvent["newWeight"])
 	}
+	if args, ok := firstEvent["args"].([]interface{}); !ok || len(args) != 0 {
+		t.Fatalf("expected no args for a WeightChanged event, got %v", firstEvent["args"])
+	}
+	secondEvent, ok := items[1].(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected event type")
+	}
+	args, ok := secondEvent["args"].([]interface{})
+	if !ok || len(args) != 2 {
+		t.Fatalf("expected 2 args, got %v", secondEvent["args"])
+	}
+	if arg, ok := args[0].(map[string]interface{}); !ok || arg["name"] != "to" || arg["value"] != "0xdef" {
+		t.Fatalf("expected args sorted by name, got %v", args)
+	}
 }
@@ -0,0 +1,257 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// balanceOfABI is the ERC20 getter used to audit transfer profile balances.
+// The census validator interface has no per-account weight getter, so
+// WeightChanged contracts are not audited.
+var balanceOfABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"balanceOf","stateMutability":"view",` +
+		`"inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}]`))
+	if err != nil {
+		panic(fmt.Sprintf("parse balanceOf ABI: %v", err))
+	}
+	return parsed
+}()
+
+// AuditReport is the result of the last cross-check of indexed balances
+// against the contract state.
+type AuditReport struct {
+	// Block is the verified block the balances were compared at.
+	Block     uint64    `json:"block"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// Accounts is the number of accounts compared.
+	Accounts   int             `json:"accounts"`
+	Mismatches []AuditMismatch `json:"mismatches,omitempty"`
+	// ReindexJob is the job scheduled to re-index the mismatched accounts.
+	ReindexJob *uint64 `json:"reindexJob,omitempty"`
+	// LastError is the error of the last audit when it failed. The other
+	// fields describe the last completed audit.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// AuditMismatch is an account whose indexed weight differs from its balance
+// on chain.
+type AuditMismatch struct {
+	Account string `json:"account"`
+	Indexed string `json:"indexed"`
+	OnChain string `json:"onChain"`
+	// FirstBlock is the block of the first indexed event of the account.
+	FirstBlock uint64 `json:"firstBlock"`
+}
+
+// balanceAuditor periodically compares the balances folded from the stored
+// events of a transfer profile contract with balanceOf at the verified block.
+type balanceAuditor struct {
+	interval time.Duration
+	// sample caps the accounts compared per audit; zero compares them all.
+	sample  int
+	reindex bool
+
+	lastBlock   uint64
+	lastAuditAt time.Time
+	balanceFunc func(ctx context.Context, account common.Address, block uint64) (*big.Int, error)
+
+	mu      sync.Mutex
+	report  *AuditReport
+	lastErr string
+}
+
+// auditedAccount is the folded state of an account at the audited block.
+type auditedAccount struct {
+	weight     string
+	firstBlock uint64
+}
+
+// due reports whether an audit of block should run at now.
+func (a *balanceAuditor) due(block uint64, now time.Time) bool {
+	return block > a.lastBlock && now.Sub(a.lastAuditAt) >= a.interval
+}
+
+// status returns a copy of the last report with the error of the last audit.
+func (a *balanceAuditor) status() (AuditReport, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.report == nil && a.lastErr == "" {
+		return AuditReport{}, false
+	}
+	var report AuditReport
+	if a.report != nil {
+		report = *a.report
+		report.Mismatches = slices.Clone(report.Mismatches)
+	}
+	report.LastError = a.lastErr
+	return report, true
+}
+
+// auditBalances cross-checks the indexed balances at the verified block once
+// the audit interval elapsed. It is a no-op without an auditor. A failed audit
+// is recorded and retried after the next interval.
+func (i *Indexer) auditBalances(ctx context.Context, verifiedUntil uint64) error {
+	a := i.auditor
+	now := time.Now()
+	if a == nil || verifiedUntil < i.startBlock || !a.due(verifiedUntil, now) {
+		return nil
+	}
+	a.lastAuditAt = now
+	report, err := i.runAudit(ctx, verifiedUntil, now)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.lastErr = err.Error()
+		return err
+	}
+	a.lastBlock = verifiedUntil
+	a.lastErr = ""
+	a.report = &report
+	return nil
+}
+
+// runAudit compares the balances folded from the events up to verifiedUntil
+// with balanceOf at that block.
+func (i *Indexer) runAudit(ctx context.Context, verifiedUntil uint64, now time.Time) (AuditReport, error) {
+	a := i.auditor
+	accounts := make(map[common.Address]auditedAccount)
+	if err := i.store.IterateEventsInRange(ctx, i.chainID, i.contract, 0, verifiedUntil, func(event store.Event) error {
+		account := common.HexToAddress(event.Account)
+		state, ok := accounts[account]
+		if !ok {
+			state.firstBlock = event.BlockNumber
+		}
+		state.weight = event.NewWeight
+		accounts[account] = state
+		return nil
+	}); err != nil {
+		return AuditReport{}, fmt.Errorf("read audited balances: %w", err)
+	}
+	audited := make([]common.Address, 0, len(accounts))
+	for account := range accounts {
+		audited = append(audited, account)
+	}
+	slices.SortFunc(audited, func(x, y common.Address) int { return x.Cmp(y) })
+	if a.sample > 0 && len(audited) > a.sample {
+		rand.Shuffle(len(audited), func(x, y int) { audited[x], audited[y] = audited[y], audited[x] })
+		audited = audited[:a.sample]
+	}
+
+	report := AuditReport{Block: verifiedUntil, CheckedAt: now.UTC(), Accounts: len(audited)}
+	for _, account := range audited {
+		if err := ctx.Err(); err != nil {
+			return AuditReport{}, err
+		}
+		onChain, err := a.balanceFunc(ctx, account, verifiedUntil)
+		if err != nil {
+			return AuditReport{}, fmt.Errorf("audit balance of %s at block %d: %w", account.Hex(), verifiedUntil, err)
+		}
+		state := accounts[account]
+		if onChain.String() == state.weight {
+			continue
+		}
+		report.Mismatches = append(report.Mismatches, AuditMismatch{
+			Account:    account.Hex(),
+			Indexed:    state.weight,
+			OnChain:    onChain.String(),
+			FirstBlock: state.firstBlock,
+		})
+		log.Warnw("indexed balance differs from the contract state",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"account", account.Hex(),
+			"block", verifiedUntil,
+			"indexed", state.weight,
+			"onChain", onChain.String(),
+		)
+	}
+	if a.reindex && len(report.Mismatches) > 0 {
+		from := verifiedUntil
+		for _, mismatch := range report.Mismatches {
+			from = min(from, mismatch.FirstBlock)
+		}
+		job, err := i.scheduleAuditReindex(ctx, from, verifiedUntil)
+		if err != nil {
+			log.Warnw("cannot schedule reindex of mismatched balances",
+				"chainID", i.chainID,
+				"contract", i.contract.Hex(),
+				"from", from,
+				"to", verifiedUntil,
+				"err", err,
+			)
+		} else {
+			report.ReindexJob = &job.ID
+		}
+	}
+	log.Infow("balance audit completed",
+		"chainID", i.chainID,
+		"contract", i.contract.Hex(),
+		"block", verifiedUntil,
+		"accounts", report.Accounts,
+		"mismatches", len(report.Mismatches),
+	)
+	return report, nil
+}
+
+// scheduleAuditReindex schedules a re-index of [from, to] for the mismatches
+// of an audit. A job that is not completed yet and already re-indexes from
+// block is returned instead, so audits running before it completes do not
+// pile up jobs for the same history.
+func (i *Indexer) scheduleAuditReindex(ctx context.Context, from, to uint64) (store.ReindexJob, error) {
+	jobs, err := i.store.ListReindexJobs(ctx, i.chainID, i.contract)
+	if err != nil {
+		return store.ReindexJob{}, err
+	}
+	for _, job := range jobs {
+		if !job.Done() && job.From <= from && from <= job.To {
+			return job, nil
+		}
+	}
+	return i.store.ScheduleReindex(ctx, i.chainID, i.contract, from, to)
+}
+
+// AuditStatus returns the last balance audit of the indexer. It reports false
+// when audits are disabled or none completed yet.
+func (i *Indexer) AuditStatus() (AuditReport, bool) {
+	if i.auditor == nil {
+		return AuditReport{}, false
+	}
+	return i.auditor.status()
+}
+
+// balanceOf calls the ERC20 balanceOf getter of the contract at block.
+func (i *Indexer) balanceOf(ctx context.Context, account common.Address, block uint64) (*big.Int, error) {
+	data, err := balanceOfABI.Pack("balanceOf", account)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.limiter.Wait(ctx, i.chainID); err != nil {
+		return nil, err
+	}
+	output, err := i.client.CallContract(ctx, ethereum.CallMsg{To: &i.contract, Data: data}, new(big.Int).SetUint64(block))
+	if err != nil {
+		return nil, err
+	}
+	values, err := balanceOfABI.Unpack("balanceOf", output)
+	if err != nil {
+		return nil, fmt.Errorf("unpack balanceOf: %w", err)
+	}
+	balance, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balanceOf result %T", values[0])
+	}
+	return balance, nil
+}
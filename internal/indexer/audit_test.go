@@ -0,0 +1,263 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestAuditBalancesReportsMismatches(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x9090909090909090909090909090909090909090")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	verifiedUntil := uint64(6)
+	if err := eventStore.ReplaceEventsInRange(ctx, 1, contract, 1, verifiedUntil, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: alice.Hex(), PreviousWeight: "0", NewWeight: "100", BlockNumber: 2, LogIndex: 1},
+		{ChainID: 1, Contract: contract.Hex(), Account: alice.Hex(), PreviousWeight: "100", NewWeight: "70", BlockNumber: 5, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: bob.Hex(), PreviousWeight: "0", NewWeight: "30", BlockNumber: 5, LogIndex: 1},
+	}, store.ReplaceOptions{IndexedUntil: &verifiedUntil, VerifiedUntil: &verifiedUntil}); err != nil {
+		t.Fatalf("store events: %v", err)
+	}
+
+	var calledAt []uint64
+	idx := &Indexer{
+		store:      eventStore,
+		chainID:    1,
+		contract:   contract,
+		startBlock: 1,
+		auditor: &balanceAuditor{
+			interval: time.Hour,
+			reindex:  true,
+			balanceFunc: func(_ context.Context, account common.Address, block uint64) (*big.Int, error) {
+				calledAt = append(calledAt, block)
+				if account == bob {
+					return big.NewInt(45), nil
+				}
+				return big.NewInt(70), nil
+			},
+		},
+	}
+	if _, ok := idx.AuditStatus(); ok {
+		t.Fatalf("expected no audit report before the first audit")
+	}
+	if err := idx.auditBalances(ctx, verifiedUntil); err != nil {
+		t.Fatalf("audit balances: %v", err)
+	}
+	if len(calledAt) != 2 || calledAt[0] != verifiedUntil {
+		t.Fatalf("expected 2 balanceOf calls at block %d, got %v", verifiedUntil, calledAt)
+	}
+	report, ok := idx.AuditStatus()
+	if !ok {
+		t.Fatalf("expected an audit report")
+	}
+	if report.Block != verifiedUntil || report.Accounts != 2 || len(report.Mismatches) != 1 {
+		t.Fatalf("expected 1 mismatch among 2 accounts at block %d, got %+v", verifiedUntil, report)
+	}
+	mismatch := report.Mismatches[0]
+	if mismatch.Account != bob.Hex() || mismatch.Indexed != "30" || mismatch.OnChain != "45" || mismatch.FirstBlock != 5 {
+		t.Fatalf("expected bob 30 indexed vs 45 on chain from block 5, got %+v", mismatch)
+	}
+	if report.ReindexJob == nil {
+		t.Fatalf("expected a reindex job for the mismatch")
+	}
+	job, ok, err := eventStore.GetReindexJob(ctx, 1, contract, *report.ReindexJob)
+	if err != nil || !ok {
+		t.Fatalf("get reindex job: ok=%t err=%v", ok, err)
+	}
+	if job.From != 5 || job.To != verifiedUntil {
+		t.Fatalf("expected reindex of [5,%d], got [%d,%d]", verifiedUntil, job.From, job.To)
+	}
+
+	// The same verified block is not audited again.
+	if err := idx.auditBalances(ctx, verifiedUntil); err != nil {
+		t.Fatalf("audit balances again: %v", err)
+	}
+	if len(calledAt) != 2 {
+		t.Fatalf("expected no new balanceOf calls, got %v", calledAt)
+	}
+
+	// A later audit finding the same mismatch reuses the pending job.
+	idx.auditor.lastAuditAt = time.Time{}
+	if err := idx.auditBalances(ctx, verifiedUntil+1); err != nil {
+		t.Fatalf("audit balances at a later block: %v", err)
+	}
+	report, _ = idx.AuditStatus()
+	if report.ReindexJob == nil || *report.ReindexJob != job.ID {
+		t.Fatalf("expected the pending reindex job %d, got %+v", job.ID, report)
+	}
+	jobs, err := eventStore.ListReindexJobs(ctx, 1, contract)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected a single reindex job, got %d (err=%v)", len(jobs), err)
+	}
+}
+
+func TestAuditBalancesRecordsFailures(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x9292929292929292929292929292929292929292")
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{{
+		ChainID: 1, Contract: contract.Hex(), Account: contract.Hex(), PreviousWeight: "0", NewWeight: "1", BlockNumber: 1,
+	}}, 1); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	failing := true
+	idx := &Indexer{
+		store:    eventStore,
+		chainID:  1,
+		contract: contract,
+		auditor: &balanceAuditor{
+			interval: time.Hour,
+			balanceFunc: func(context.Context, common.Address, uint64) (*big.Int, error) {
+				if failing {
+					return nil, errors.New("missing trie node")
+				}
+				return big.NewInt(1), nil
+			},
+		},
+	}
+	if err := idx.auditBalances(ctx, 1); err == nil {
+		t.Fatalf("expected the audit to fail")
+	}
+	report, ok := idx.AuditStatus()
+	if !ok || report.LastError == "" || report.Block != 0 {
+		t.Fatalf("expected the audit error without a completed audit, got %+v", report)
+	}
+
+	// The failed audit is retried after the interval only.
+	failing = false
+	if err := idx.auditBalances(ctx, 1); err != nil {
+		t.Fatalf("audit balances within the interval: %v", err)
+	}
+	if report, _ := idx.AuditStatus(); report.LastError == "" {
+		t.Fatalf("expected no audit within the interval, got %+v", report)
+	}
+	idx.auditor.lastAuditAt = time.Time{}
+	if err := idx.auditBalances(ctx, 1); err != nil {
+		t.Fatalf("audit balances: %v", err)
+	}
+	if report, _ := idx.AuditStatus(); report.LastError != "" || report.Block != 1 || report.Accounts != 1 {
+		t.Fatalf("expected a completed audit, got %+v", report)
+	}
+}
+
+func TestAuditOnlyERC20Sources(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	moved := store.EventProfile{
+		ABI: `[{"type":"event","name":"Moved","inputs":[` +
+			`{"name":"from","type":"address","indexed":true},` +
+			`{"name":"to","type":"address","indexed":true},` +
+			`{"name":"amount","type":"uint256","indexed":false}]}]`,
+		From:  "from",
+		To:    "to",
+		Value: "amount",
+	}
+	for _, tc := range []struct {
+		profile store.EventProfile
+		audited bool
+	}{
+		{ERC20TransferProfile, true},
+		{moved, false},
+	} {
+		idx, err := New(Config{
+			Client:        &RPCClient{},
+			Store:         store.New(database),
+			ChainID:       1,
+			Contract:      common.HexToAddress("0x9393939393939393939393939393939393939393"),
+			EventProfile:  &tc.profile,
+			AuditInterval: time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("create indexer: %v", err)
+		}
+		if (idx.auditor != nil) != tc.audited {
+			t.Fatalf("expected audits %t for %s, got %t", tc.audited, tc.profile.ABI, idx.auditor != nil)
+		}
+	}
+}
+
+func TestAuditBalancesSamplesAccounts(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x9191919191919191919191919191919191919191")
+	var events []store.Event
+	for n := range 5 {
+		account := common.BigToAddress(big.NewInt(int64(n + 1)))
+		events = append(events, store.Event{
+			ChainID: 1, Contract: contract.Hex(), Account: account.Hex(), PreviousWeight: "0", NewWeight: "1", BlockNumber: 1, LogIndex: uint32(n),
+		})
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, events, 1); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	calls := 0
+	idx := &Indexer{
+		store:    eventStore,
+		chainID:  1,
+		contract: contract,
+		auditor: &balanceAuditor{
+			sample: 2,
+			balanceFunc: func(context.Context, common.Address, uint64) (*big.Int, error) {
+				calls++
+				return big.NewInt(1), nil
+			},
+		},
+	}
+	if err := idx.auditBalances(ctx, 1); err != nil {
+		t.Fatalf("audit balances: %v", err)
+	}
+	report, ok := idx.AuditStatus()
+	if !ok || calls != 2 || report.Accounts != 2 || len(report.Mismatches) != 0 {
+		t.Fatalf("expected 2 sampled accounts without mismatches, got %d calls and %+v", calls, report)
+	}
+}
@@ -0,0 +1,250 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// ChainLimiter bounds the backfill requests in flight across the indexers of
+// a chain, so parallel backfills stay within the quota of its RPC endpoints.
+// A nil limiter does not limit requests.
+type ChainLimiter struct {
+	slots chan struct{}
+}
+
+func newChainLimiter(limit int) *ChainLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &ChainLimiter{slots: make(chan struct{}, limit)}
+}
+
+func (l *ChainLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *ChainLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// backfill indexes and verifies the range between the verified cursor and the
+// safe head in segments processed concurrently, when it spans at least two
+// segments. Completed segments are recorded in the store and merged into the
+// cursors once every block before them is verified, so a restart only
+// processes the holes left behind. Transfer profiles are indexed sequentially,
+// since their balances are derived in block order.
+//
+// Segments check the proxy implementation at each verified batch end. A
+// segment that finds an upgrade is not completed, and the blocks from it on
+// are left to the sequential verification, which records the upgrade at its
+// batch boundary and pauses indexing if configured.
+func (i *Indexer) backfill(ctx context.Context, state *progressState, safeHead uint64) error {
+	if i.ledger != nil {
+		return nil
+	}
+	if err := i.mergeBackfill(ctx, state); err != nil {
+		return err
+	}
+	if i.backfillWorkers <= 1 || i.upgradePaused() || safeHead < state.verifiedUntil ||
+		safeHead-state.verifiedUntil < 2*i.backfillSegmentSize || state.verifiedUntil < state.upgradeFoundAt {
+		return nil
+	}
+	// Segments compare their implementation with the one at the cursor.
+	if state.verifiedUntil >= i.startBlock {
+		if err := i.checkImplementation(ctx, state.verifiedUntil); err != nil || i.upgradePaused() {
+			return err
+		}
+	}
+	segments, err := i.pendingBackfillSegments(ctx, state.verifiedUntil, safeHead)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	workers := min(i.backfillWorkers, len(segments))
+	log.Infow("backfill starting",
+		"chainID", i.chainID,
+		"contract", i.contract.Hex(),
+		"from", state.verifiedUntil+1,
+		"to", safeHead,
+		"segments", len(segments),
+		"workers", workers,
+	)
+
+	// A failed segment stops feeding new segments; the ones in flight finish
+	// so their work is kept.
+	feedCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		firstErr   error
+		upgradedAt uint64
+	)
+	queue := make(chan store.BackfillSegment)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for segment := range queue {
+				upgraded, err := i.backfillSegment(ctx, segment)
+				if err == nil && upgraded == 0 {
+					continue
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if upgraded > 0 && (upgradedAt == 0 || upgraded < upgradedAt) {
+					upgradedAt = upgraded
+				}
+				mu.Unlock()
+				stop()
+				return
+			}
+		}()
+	}
+feed:
+	for _, segment := range segments {
+		select {
+		case queue <- segment:
+		case <-feedCtx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if upgradedAt > 0 {
+		log.Infow("backfill stopped by a proxy upgrade",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"block", upgradedAt,
+		)
+		state.upgradeFoundAt = upgradedAt
+	}
+	if err := i.mergeBackfill(ctx, state); err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// pendingBackfillSegments splits the blocks after verifiedUntil and up to
+// safeHead that no completed segment covers into segments.
+func (i *Indexer) pendingBackfillSegments(ctx context.Context, verifiedUntil, safeHead uint64) ([]store.BackfillSegment, error) {
+	completed, err := i.store.ListBackfillSegments(ctx, i.chainID, i.contract)
+	if err != nil {
+		return nil, fmt.Errorf("list backfill segments: %w", err)
+	}
+	var pending []store.BackfillSegment
+	split := func(from, to uint64) {
+		for from <= to {
+			end := min(from+i.backfillSegmentSize-1, to)
+			pending = append(pending, store.BackfillSegment{From: from, To: end})
+			from = end + 1
+		}
+	}
+	next := verifiedUntil + 1
+	for _, segment := range completed {
+		if segment.To < next {
+			continue
+		}
+		if segment.From > safeHead {
+			break
+		}
+		if segment.From > next {
+			split(next, segment.From-1)
+		}
+		next = segment.To + 1
+	}
+	if next <= safeHead {
+		split(next, safeHead)
+	}
+	return pending, nil
+}
+
+// backfillSegment runs the first pass and the verification pass over a
+// segment. The last verification batch records the segment as completed. When
+// the proxy implementation at the end of a verification batch differs from the
+// recorded one, the segment stops before that batch and returns its end.
+func (i *Indexer) backfillSegment(ctx context.Context, segment store.BackfillSegment) (uint64, error) {
+	for from := segment.From; from <= segment.To; {
+		to := min(from+i.batchSize-1, segment.To)
+		if err := i.backfillBatch(ctx, from, to, store.ReplaceOptions{}); err != nil {
+			return 0, err
+		}
+		from = to + 1
+	}
+	for from := segment.From; from <= segment.To; {
+		to := min(from+i.verifyBatchSize-1, segment.To)
+		if i.implementationChanged(ctx, to) {
+			return to, nil
+		}
+		opts := store.ReplaceOptions{}
+		if to == segment.To {
+			opts.BackfillSegment = &segment
+		}
+		if err := i.backfillBatch(ctx, from, to, opts); err != nil {
+			return 0, err
+		}
+		from = to + 1
+	}
+	log.Debugw("backfilled segment", "chainID", i.chainID, "contract", i.contract.Hex(), "from", segment.From, "to", segment.To)
+	return 0, nil
+}
+
+func (i *Indexer) backfillBatch(ctx context.Context, from, to uint64, opts store.ReplaceOptions) error {
+	if err := i.backfillLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	events, err := i.eventsFunc(WithRequestPriority(ctx, PriorityBackfill), from, to)
+	i.backfillLimiter.release()
+	if err != nil {
+		return err
+	}
+	if err := i.store.ReplaceEventsInRange(ctx, i.chainID, i.contract, from, to, events, opts); err != nil {
+		return fmt.Errorf("store backfill events: %w", err)
+	}
+	return nil
+}
+
+// mergeBackfill advances the cursors through the completed segments
+// contiguous with the verified cursor.
+func (i *Indexer) mergeBackfill(ctx context.Context, state *progressState) error {
+	merged, err := i.store.MergeBackfillSegments(ctx, i.chainID, i.contract, state.verifiedUntil)
+	if err != nil {
+		return fmt.Errorf("merge backfill segments: %w", err)
+	}
+	if merged <= state.verifiedUntil {
+		return nil
+	}
+	log.Infow("merged backfill segments",
+		"chainID", i.chainID,
+		"contract", i.contract.Hex(),
+		"from", state.verifiedUntil+1,
+		"to", merged,
+	)
+	state.verifiedUntil = merged
+	state.indexedUntil = max(state.indexedUntil, merged)
+	return i.checkImplementation(ctx, merged)
+}
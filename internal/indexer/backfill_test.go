@@ -0,0 +1,292 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestSyncOnceBackfillsSegmentsAndResumesHoles(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b6b")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	var (
+		mu      sync.Mutex
+		failing = true
+	)
+	idx := &Indexer{
+		store:               eventStore,
+		chainID:             1,
+		contract:            contract,
+		startBlock:          1,
+		batchSize:           5,
+		verifyBatchSize:     5,
+		backfillWorkers:     3,
+		backfillSegmentSize: 10,
+		backfillLimiter:     newChainLimiter(2),
+	}
+	idx.headFunc = func(context.Context) (uint64, error) {
+		return 60, nil
+	}
+	idx.eventsFunc = func(_ context.Context, from, to uint64) ([]store.Event, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing && from <= 25 && 25 <= to {
+			return nil, errors.New("rpc unavailable")
+		}
+		var events []store.Event
+		for block := from; block <= to; block++ {
+			if block%5 == 0 {
+				events = append(events, store.Event{
+					ChainID: 1, Contract: contract.Hex(), Account: common.BigToAddress(common.Big1).Hex(),
+					PreviousWeight: "0", NewWeight: "1", BlockNumber: block,
+				})
+			}
+		}
+		return events, nil
+	}
+
+	state, err := idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err == nil {
+		t.Fatalf("expected the failing segment to fail the sync")
+	}
+	// Segment [21,30] failed, so only [1,20] is merged into the cursors.
+	if state.verifiedUntil != 20 || state.indexedUntil != 20 {
+		t.Fatalf("expected cursors merged up to 20, got indexed=%d verified=%d", state.indexedUntil, state.verifiedUntil)
+	}
+	if block, _, _ := eventStore.LastVerifiedBlock(ctx, 1, contract); block != 20 {
+		t.Fatalf("expected stored verified cursor 20, got %d", block)
+	}
+	segments, err := eventStore.ListBackfillSegments(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("list backfill segments: %v", err)
+	}
+	for _, segment := range segments {
+		if segment.Contains(25) {
+			t.Fatalf("expected the failed segment not to be recorded, got %+v", segments)
+		}
+	}
+
+	// A restarted indexer only backfills the hole and merges the rest.
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	var fetched []uint64
+	events := idx.eventsFunc
+	idx.eventsFunc = func(ctx context.Context, from, to uint64) ([]store.Event, error) {
+		mu.Lock()
+		fetched = append(fetched, from)
+		mu.Unlock()
+		return events(ctx, from, to)
+	}
+	state, err = idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once: %v", err)
+	}
+	if state.verifiedUntil != 60 || state.indexedUntil != 60 {
+		t.Fatalf("expected cursors at 60, got indexed=%d verified=%d", state.indexedUntil, state.verifiedUntil)
+	}
+	for _, from := range fetched {
+		if from <= 20 {
+			t.Fatalf("expected merged blocks not to be fetched again, got fetches from %v", fetched)
+		}
+	}
+	if segments, _ := eventStore.ListBackfillSegments(ctx, 1, contract); len(segments) != 0 {
+		t.Fatalf("expected every segment to be merged, got %+v", segments)
+	}
+	stored, err := eventStore.ListEvents(ctx, store.ListOptions{ChainID: 1, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(stored) != 12 {
+		t.Fatalf("expected 12 events, got %d", len(stored))
+	}
+}
+
+func TestPendingBackfillSegmentsSkipsCompleted(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c6c")
+	for _, segment := range []store.BackfillSegment{{From: 11, To: 20}, {From: 36, To: 45}} {
+		if err := eventStore.ReplaceEventsInRange(ctx, 1, contract, segment.From, segment.To, nil,
+			store.ReplaceOptions{BackfillSegment: &segment}); err != nil {
+			t.Fatalf("store segment: %v", err)
+		}
+	}
+	idx := &Indexer{store: eventStore, chainID: 1, contract: contract, backfillSegmentSize: 10}
+	pending, err := idx.pendingBackfillSegments(ctx, 5, 50)
+	if err != nil {
+		t.Fatalf("pending backfill segments: %v", err)
+	}
+	expected := []store.BackfillSegment{{From: 6, To: 10}, {From: 21, To: 30}, {From: 31, To: 35}, {From: 46, To: 50}}
+	if len(pending) != len(expected) {
+		t.Fatalf("expected segments %+v, got %+v", expected, pending)
+	}
+	for n := range expected {
+		if pending[n] != expected[n] {
+			t.Fatalf("expected segments %+v, got %+v", expected, pending)
+		}
+	}
+}
+
+func TestChainLimiterBoundsConcurrency(t *testing.T) {
+	limiter := newChainLimiter(2)
+	var (
+		wg      sync.WaitGroup
+		current atomic.Int32
+		peak    atomic.Int32
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.acquire(context.Background()); err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			n := current.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			current.Add(-1)
+			limiter.release()
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", peak.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	full := newChainLimiter(1)
+	if err := full.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := full.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled acquire, got %v", err)
+	}
+	if newChainLimiter(0) != nil {
+		t.Fatalf("expected no limiter without a limit")
+	}
+}
+
+func TestBackfillStopsAtProxyUpgrade(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c7c")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	first := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	if err := eventStore.RecordContractUpgrade(ctx, 1, contract, store.ProxyUpgrade{
+		Implementation: first.Hex(), Block: 1, DetectedAt: time.Now(),
+	}, false); err != nil {
+		t.Fatalf("record implementation: %v", err)
+	}
+	idx := &Indexer{
+		store:               eventStore,
+		chainID:             1,
+		contract:            contract,
+		startBlock:          1,
+		batchSize:           5,
+		verifyBatchSize:     5,
+		backfillWorkers:     3,
+		backfillSegmentSize: 10,
+		backfillLimiter:     newChainLimiter(2),
+		upgrades: &upgradeTracker{
+			pause: true,
+			readFunc: func(_ context.Context, block uint64) (common.Address, error) {
+				if block < 33 {
+					return first, nil
+				}
+				return second, nil
+			},
+		},
+	}
+	idx.headFunc = func(context.Context) (uint64, error) {
+		return 60, nil
+	}
+	idx.eventsFunc = func(context.Context, uint64, uint64) ([]store.Event, error) {
+		return nil, nil
+	}
+
+	state, err := idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once: %v", err)
+	}
+	// Segments [1,30] are merged, and the sequential verification records the
+	// upgrade at the end of its first batch and pauses.
+	if state.verifiedUntil != 35 {
+		t.Fatalf("expected indexing to pause at block 35, got %d", state.verifiedUntil)
+	}
+	record, _, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("get contract: %v", err)
+	}
+	if len(record.Upgrades) != 2 || record.Upgrades[1].Implementation != second.Hex() || record.Upgrades[1].Block != 35 {
+		t.Fatalf("expected %s recorded at block 35, got %+v", second.Hex(), record.Upgrades)
+	}
+	if !record.UpgradePending {
+		t.Fatalf("expected the upgrade to pause indexing")
+	}
+	if segments, _ := eventStore.ListBackfillSegments(ctx, 1, contract); len(segments) != 0 {
+		t.Fatalf("expected no segment after the upgrade to be completed, got %+v", segments)
+	}
+}
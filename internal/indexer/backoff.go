@@ -0,0 +1,212 @@
+package indexer
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// CircuitState is the state of a chain circuit breaker.
+type CircuitState string
+
+const (
+	// CircuitClosed lets indexers call the chain RPC.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen pauses every indexer of the chain until the cooldown ends.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single indexer probe the chain RPC after the
+	// cooldown; its result closes or reopens the circuit.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+const (
+	defaultMaxBackoff       = 5 * time.Minute
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+// RetryStatus reports the retry backoff of an indexer and the circuit breaker
+// of its chain.
+type RetryStatus struct {
+	Failures       int            `json:"failures"`
+	Backoff        store.Duration `json:"backoff"`
+	RetryAt        *time.Time     `json:"retryAt,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+	Circuit        CircuitState   `json:"circuit"`
+	CircuitRetryAt *time.Time     `json:"circuitRetryAt,omitempty"`
+}
+
+// retryBackoff computes exponential delays with jitter between retries of
+// an indexer.
+type retryBackoff struct {
+	base time.Duration
+	max  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	delay     time.Duration
+	retryAt   time.Time
+	lastError string
+}
+
+// failure records a failed attempt and returns the delay before the next one:
+// base doubled per consecutive failure, capped at max, with the upper half
+// randomized.
+func (b *retryBackoff) failure(err error, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	delay := b.max
+	if shift := b.failures - 1; shift < 32 && b.base<<shift > 0 && b.base<<shift < b.max {
+		delay = b.base << shift
+	}
+	if half := delay / 2; half > 0 {
+		delay = half + rand.N(delay-half+1)
+	}
+	b.delay = delay
+	b.retryAt = now.Add(delay)
+	b.lastError = err.Error()
+	return delay
+}
+
+// success resets the backoff and returns how many failures preceded it.
+func (b *retryBackoff) success() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failures := b.failures
+	b.failures = 0
+	b.delay = 0
+	b.retryAt = time.Time{}
+	b.lastError = ""
+	return failures
+}
+
+func (b *retryBackoff) status() RetryStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := RetryStatus{
+		Failures:  b.failures,
+		Backoff:   store.Duration(b.delay),
+		LastError: b.lastError,
+	}
+	if !b.retryAt.IsZero() {
+		retryAt := b.retryAt.UTC()
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// CircuitBreaker pauses every indexer of a chain after consecutive RPC
+// failures, so an outage is probed by a single indexer instead of all of them.
+// A nil breaker is always closed.
+type CircuitBreaker struct {
+	chainID   uint64
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	retryAt  time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(chainID uint64, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		chainID:   chainID,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// allow reports whether the caller may call the chain RPC now and whether it
+// is probing a half-open circuit. Otherwise it returns when the circuit may be
+// probed again; a zero time means another indexer is probing it.
+func (b *CircuitBreaker) allow(now time.Time) (ok, probe bool, retryAt time.Time) {
+	if b == nil {
+		return true, false, time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if now.Before(b.retryAt) {
+			return false, false, b.retryAt
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true, true, time.Time{}
+	case CircuitHalfOpen:
+		if b.probing {
+			return false, false, time.Time{}
+		}
+		b.probing = true
+		return true, true, time.Time{}
+	default:
+		return true, false, time.Time{}
+	}
+}
+
+// release lets another indexer probe a half-open circuit when the probing one
+// stopped without a result.
+func (b *CircuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *CircuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != CircuitClosed {
+		log.Infow("rpc circuit closed", "chainID", b.chainID)
+	}
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+	b.retryAt = time.Time{}
+}
+
+func (b *CircuitBreaker) failure(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.probing = false
+		b.retryAt = now.Add(b.cooldown)
+		log.Warnw("rpc circuit opened; pausing indexers of the chain",
+			"chainID", b.chainID,
+			"failures", b.failures,
+			"retryAt", b.retryAt,
+		)
+	}
+}
+
+func (b *CircuitBreaker) status() (CircuitState, time.Time) {
+	if b == nil {
+		return CircuitClosed, time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen {
+		return b.state, b.retryAt
+	}
+	return b.state, time.Time{}
+}
@@ -0,0 +1,81 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffGrowsWithJitterUpToMax(t *testing.T) {
+	backoff := &retryBackoff{base: time.Second, max: 10 * time.Second}
+	now := time.Now()
+	for failure, want := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	} {
+		delay := backoff.failure(fmt.Errorf("rpc timeout"), now)
+		if delay < want/2 || delay > want {
+			t.Fatalf("expected failure %d to back off between %s and %s, got %s", failure+1, want/2, want, delay)
+		}
+	}
+	status := backoff.status()
+	if status.Failures != 6 || status.LastError != "rpc timeout" || status.RetryAt == nil {
+		t.Fatalf("expected 6 failures with a retry time, got %+v", status)
+	}
+
+	if failures := backoff.success(); failures != 6 {
+		t.Fatalf("expected success after 6 failures, got %d", failures)
+	}
+	if status := backoff.status(); status.Failures != 0 || status.Backoff != 0 || status.RetryAt != nil || status.LastError != "" {
+		t.Fatalf("expected the backoff to reset, got %+v", status)
+	}
+}
+
+func TestCircuitBreakerOpensAndProbesOnce(t *testing.T) {
+	breaker := newCircuitBreaker(1, 2, time.Minute)
+	now := time.Now()
+
+	breaker.failure(now)
+	if ok, _, _ := breaker.allow(now); !ok {
+		t.Fatalf("expected the circuit to stay closed below the threshold")
+	}
+	breaker.failure(now)
+	ok, _, retryAt := breaker.allow(now)
+	if ok || !retryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the circuit to open until %s, got ok=%v retryAt=%s", now.Add(time.Minute), ok, retryAt)
+	}
+
+	// After the cooldown a single indexer probes the chain.
+	later := now.Add(time.Minute)
+	if ok, probe, _ := breaker.allow(later); !ok || !probe {
+		t.Fatalf("expected the first indexer to probe the half-open circuit")
+	}
+	if ok, _, retryAt := breaker.allow(later); ok || !retryAt.IsZero() {
+		t.Fatalf("expected other indexers to wait for the probe, got ok=%v retryAt=%s", ok, retryAt)
+	}
+	breaker.release()
+	if ok, probe, _ := breaker.allow(later); !ok || !probe {
+		t.Fatalf("expected another indexer to probe after a release")
+	}
+
+	// A failed probe reopens the circuit immediately.
+	breaker.failure(later)
+	if state, retryAt := breaker.status(); state != CircuitOpen || !retryAt.Equal(later.Add(time.Minute)) {
+		t.Fatalf("expected the circuit to reopen until %s, got %s %s", later.Add(time.Minute), state, retryAt)
+	}
+
+	if ok, probe, _ := breaker.allow(later.Add(time.Minute)); !ok || !probe {
+		t.Fatalf("expected a new probe after the cooldown")
+	}
+	breaker.success()
+	if state, _ := breaker.status(); state != CircuitClosed {
+		t.Fatalf("expected the circuit to close, got %s", state)
+	}
+	if ok, probe, _ := breaker.allow(later.Add(time.Minute)); !ok || probe {
+		t.Fatalf("expected the closed circuit to allow calls without probing")
+	}
+}
@@ -0,0 +1,313 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// transfer is a decoded log of a transfer profile event.
+type transfer struct {
+	from, to    common.Address
+	value       *big.Int
+	blockNumber uint64
+	logIndex    uint32
+	args        map[string]string
+}
+
+// balanceLedger folds transfers into per-account balances for contracts
+// indexed with a transfer profile. Each transfer is stored as two weight
+// events: the sender at log index 2i and the receiver at 2i+1, where i is the
+// index of the log in its block. Mints and burns have no event for the zero
+// address.
+//
+// The balances reflect the stored events of every block before next. They are
+// moved to other blocks by replaying stored events forwards, using their new
+// weights, or backwards, using their previous weights.
+type balanceLedger struct {
+	store    store.Backend
+	chainID  uint64
+	contract common.Address
+	profile  store.EventProfile
+
+	loaded   bool
+	next     uint64
+	balances map[common.Address]*big.Int
+
+	// pending holds the balances derived by the last derive call until its
+	// events are stored and commit applies them.
+	pending     map[common.Address]*big.Int
+	pendingNext uint64
+	// changed is set when the derived range ends with other balances than
+	// the stored one, so the stored events after it are stale.
+	changed bool
+}
+
+func newBalanceLedger(backend store.Backend, chainID uint64, contract common.Address, profile store.EventProfile) *balanceLedger {
+	return &balanceLedger{store: backend, chainID: chainID, contract: contract, profile: profile}
+}
+
+// derive returns the weight events of the transfers of [from, to], which
+// must be sorted by block and log index.
+func (l *balanceLedger) derive(ctx context.Context, from, to uint64, transfers []transfer) ([]store.Event, error) {
+	if err := l.moveTo(ctx, from); err != nil {
+		return nil, err
+	}
+	derived := make(map[common.Address]*big.Int)
+	balance := func(account common.Address) *big.Int {
+		if value, ok := derived[account]; ok {
+			return value
+		}
+		if value, ok := l.balances[account]; ok {
+			return value
+		}
+		return new(big.Int)
+	}
+	contract := l.contract.Hex()
+	events := make([]store.Event, 0, 2*len(transfers))
+	for _, t := range transfers {
+		sides := []struct {
+			account common.Address
+			delta   *big.Int
+			offset  uint32
+		}{
+			{t.from, new(big.Int).Neg(t.value), 0},
+			{t.to, t.value, 1},
+		}
+		for _, side := range sides {
+			if side.account == (common.Address{}) {
+				continue
+			}
+			previous := balance(side.account)
+			next := new(big.Int).Add(previous, side.delta)
+			if next.Sign() < 0 {
+				log.Warnw("transfer exceeds the indexed balance; clamping it to zero",
+					"chainID", l.chainID,
+					"contract", contract,
+					"account", side.account.Hex(),
+					"block", t.blockNumber,
+					"balance", previous.String(),
+					"value", t.value.String(),
+				)
+				next.SetUint64(0)
+			}
+			derived[side.account] = next
+			events = append(events, store.Event{
+				ChainID:        l.chainID,
+				Contract:       contract,
+				Account:        side.account.Hex(),
+				PreviousWeight: previous.String(),
+				NewWeight:      next.String(),
+				BlockNumber:    t.blockNumber,
+				LogIndex:       2*t.logIndex + side.offset,
+				Args:           t.args,
+			})
+		}
+	}
+
+	// The stored events after the range are only valid if the range still
+	// ends with the balances they were derived from.
+	stored := make(map[common.Address]*big.Int)
+	if err := l.store.IterateEventsInRange(ctx, l.chainID, l.contract, from, to, func(event store.Event) error {
+		weight, err := parseBalance(event.NewWeight)
+		if err != nil {
+			return err
+		}
+		stored[common.HexToAddress(event.Account)] = weight
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("read stored balances: %w", err)
+	}
+	l.changed = false
+	for account, weight := range derived {
+		storedWeight, ok := stored[account]
+		if !ok {
+			storedWeight = l.balance(account)
+		}
+		if weight.Cmp(storedWeight) != 0 {
+			l.changed = true
+		}
+	}
+	for account, weight := range stored {
+		if _, ok := derived[account]; !ok && weight.Cmp(l.balance(account)) != 0 {
+			l.changed = true
+		}
+	}
+	l.pending = derived
+	l.pendingNext = to + 1
+	return events, nil
+}
+
+// commit applies the balances of the last derived range once its events are
+// stored. It reports whether the stored events after the range are stale.
+func (l *balanceLedger) commit() bool {
+	if l == nil || l.pending == nil {
+		return false
+	}
+	for account, weight := range l.pending {
+		l.setBalance(account, weight)
+	}
+	l.next = l.pendingNext
+	l.pending = nil
+	return l.changed
+}
+
+// moveTo brings the balances to the stored state before block next. Long
+// rewinds are replaced by a replay from the first block.
+func (l *balanceLedger) moveTo(ctx context.Context, next uint64) error {
+	if !l.loaded || (next < l.next && l.next-next > next) {
+		l.balances = make(map[common.Address]*big.Int)
+		l.next = 0
+		l.loaded = true
+	}
+	switch {
+	case next > l.next:
+		err := l.store.IterateEventsInRange(ctx, l.chainID, l.contract, l.next, next-1, func(event store.Event) error {
+			weight, err := parseBalance(event.NewWeight)
+			if err != nil {
+				return err
+			}
+			l.setBalance(common.HexToAddress(event.Account), weight)
+			return nil
+		})
+		if err != nil {
+			l.loaded = false
+			return fmt.Errorf("replay stored balances: %w", err)
+		}
+	case next < l.next:
+		var events []store.Event
+		if err := l.store.IterateEventsInRange(ctx, l.chainID, l.contract, next, l.next-1, func(event store.Event) error {
+			events = append(events, event)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("rewind stored balances: %w", err)
+		}
+		for idx := len(events) - 1; idx >= 0; idx-- {
+			weight, err := parseBalance(events[idx].PreviousWeight)
+			if err != nil {
+				l.loaded = false
+				return fmt.Errorf("rewind stored balances: %w", err)
+			}
+			l.setBalance(common.HexToAddress(events[idx].Account), weight)
+		}
+	}
+	l.next = next
+	return nil
+}
+
+func (l *balanceLedger) balance(account common.Address) *big.Int {
+	if value, ok := l.balances[account]; ok {
+		return value
+	}
+	return new(big.Int)
+}
+
+func (l *balanceLedger) setBalance(account common.Address, weight *big.Int) {
+	if weight.Sign() == 0 {
+		delete(l.balances, account)
+		return
+	}
+	l.balances[account] = weight
+}
+
+// storedTransfers rebuilds the transfers of stored events from their
+// arguments. Both events of a transfer share its arguments.
+func (l *balanceLedger) storedTransfers(events []store.Event) ([]transfer, error) {
+	var transfers []transfer
+	for _, event := range events {
+		logIndex := event.LogIndex / 2
+		if n := len(transfers); n > 0 && transfers[n-1].blockNumber == event.BlockNumber && transfers[n-1].logIndex == logIndex {
+			continue
+		}
+		from, to := event.Args[l.profile.From], event.Args[l.profile.To]
+		if !common.IsHexAddress(from) || !common.IsHexAddress(to) {
+			return nil, fmt.Errorf("event at block %d has no transfer arguments", event.BlockNumber)
+		}
+		value, err := parseBalance(event.Args[l.profile.Value])
+		if err != nil {
+			return nil, fmt.Errorf("event at block %d: %w", event.BlockNumber, err)
+		}
+		transfers = append(transfers, transfer{
+			from:        common.HexToAddress(from),
+			to:          common.HexToAddress(to),
+			value:       value,
+			blockNumber: event.BlockNumber,
+			logIndex:    logIndex,
+			args:        event.Args,
+		})
+	}
+	return transfers, nil
+}
+
+func parseBalance(raw string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(raw, 10)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid balance %q", raw)
+	}
+	return value, nil
+}
+
+func sortTransfers(transfers []transfer) {
+	sort.Slice(transfers, func(a, b int) bool {
+		if transfers[a].blockNumber == transfers[b].blockNumber {
+			return transfers[a].logIndex < transfers[b].logIndex
+		}
+		return transfers[a].blockNumber < transfers[b].blockNumber
+	})
+}
+
+// repairBalances re-derives the stored events from block from onwards out of
+// their transfer arguments, after a replaced range changed the balances they
+// start from. It stops once a range ends with its stored balances again.
+func (i *Indexer) repairBalances(ctx context.Context, from uint64) error {
+	indexedUntil, ok, err := i.store.LastIndexedBlock(ctx, i.chainID, i.contract)
+	if err != nil || !ok {
+		return err
+	}
+	for from <= indexedUntil {
+		to := indexedUntil
+		if i.batchSize-1 < indexedUntil-from {
+			to = from + i.batchSize - 1
+		}
+		var stored []store.Event
+		if err := i.store.IterateEventsInRange(ctx, i.chainID, i.contract, from, to, func(event store.Event) error {
+			stored = append(stored, event)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("read stored transfers: %w", err)
+		}
+		transfers, err := i.ledger.storedTransfers(stored)
+		if err != nil {
+			return err
+		}
+		events, err := i.ledger.derive(ctx, from, to, transfers)
+		if err != nil {
+			return err
+		}
+		if err := i.store.ReplaceEventsInRange(ctx, i.chainID, i.contract, from, to, events, store.ReplaceOptions{}); err != nil {
+			return fmt.Errorf("store repaired balances: %w", err)
+		}
+		log.Infow("repaired stale balances", "chainID", i.chainID, "contract", i.contract.Hex(), "from", from, "to", to)
+		if !i.ledger.commit() || to == math.MaxUint64 {
+			return nil
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// commitBalances applies the balances of a stored range and repairs the
+// events after it when they became stale. It is a no-op for weight profiles.
+func (i *Indexer) commitBalances(ctx context.Context) error {
+	if i.ledger == nil || !i.ledger.commit() {
+		return nil
+	}
+	return i.repairBalances(ctx, i.ledger.next)
+}
@@ -0,0 +1,176 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func testTransfer(from, to common.Address, value int64, block uint64, logIndex uint32) transfer {
+	t := transfer{from: from, to: to, blockNumber: block, logIndex: logIndex}
+	t.value = big.NewInt(value)
+	t.args = map[string]string{"from": from.Hex(), "to": to.Hex(), "value": t.value.String()}
+	return t
+}
+
+func TestBalanceLedgerMintTransferBurn(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x5656565656565656565656565656565656565656")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	ledger := newBalanceLedger(eventStore, 1, contract, ERC20TransferProfile)
+
+	events, err := ledger.derive(ctx, 1, 2, []transfer{
+		testTransfer(common.Address{}, alice, 100, 1, 0),
+		testTransfer(alice, bob, 30, 2, 3),
+		testTransfer(bob, common.Address{}, 10, 2, 4),
+	})
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	expected := []struct {
+		account           common.Address
+		previous, current string
+		logIndex          uint32
+	}{
+		{alice, "0", "100", 1},
+		{alice, "100", "70", 6},
+		{bob, "0", "30", 7},
+		{bob, "30", "20", 8},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, want := range expected {
+		got := events[i]
+		if got.Account != want.account.Hex() || got.PreviousWeight != want.previous || got.NewWeight != want.current || got.LogIndex != want.logIndex {
+			t.Fatalf("expected event %d %s %s->%s at log %d, got %+v", i, want.account.Hex(), want.previous, want.current, want.logIndex, got)
+		}
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, events, 2); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if !ledger.commit() {
+		t.Fatalf("expected new balances to be reported as changed")
+	}
+	if got := ledger.balance(bob).String(); got != "20" {
+		t.Fatalf("expected bob balance 20, got %s", got)
+	}
+
+	// Rewinding to block 2 restores the balances stored before it.
+	if _, err := ledger.derive(ctx, 2, 2, nil); err != nil {
+		t.Fatalf("derive rewind: %v", err)
+	}
+	if got := ledger.balance(alice).String(); got != "100" {
+		t.Fatalf("expected alice balance 100 before block 2, got %s", got)
+	}
+	if _, ok := ledger.balances[bob]; ok {
+		t.Fatalf("expected no bob balance before block 2")
+	}
+	if !ledger.changed {
+		t.Fatalf("expected an empty block 2 to change the stored balances")
+	}
+
+	// A transfer larger than the balance is clamped to zero.
+	events, err = ledger.derive(ctx, 3, 3, []transfer{testTransfer(bob, alice, 50, 3, 0)})
+	if err != nil {
+		t.Fatalf("derive overdraft: %v", err)
+	}
+	if events[0].NewWeight != "0" || events[1].NewWeight != "120" {
+		t.Fatalf("expected clamped balances 0 and 120, got %+v", events)
+	}
+}
+
+func TestSyncOnceRepairsBalancesAfterRecoveredTransfer(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x7878787878787878787878787878787878787878")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	idx := &Indexer{
+		store:           eventStore,
+		chainID:         1,
+		contract:        contract,
+		startBlock:      1,
+		batchSize:       3,
+		verifyBatchSize: 1,
+		ledger:          newBalanceLedger(eventStore, 1, contract, ERC20TransferProfile),
+	}
+	transfers := []transfer{
+		testTransfer(common.Address{}, alice, 100, 1, 0),
+		testTransfer(common.Address{}, alice, 50, 2, 0),
+		testTransfer(alice, bob, 30, 3, 0),
+	}
+	firstPass := true
+	idx.headFunc = func(context.Context) (uint64, error) {
+		return 3, nil
+	}
+	idx.eventsFunc = func(ctx context.Context, from, to uint64) ([]store.Event, error) {
+		var selected []transfer
+		for _, tr := range transfers {
+			// The first pass misses the second mint.
+			if tr.blockNumber < from || tr.blockNumber > to || (firstPass && tr.blockNumber == 2) {
+				continue
+			}
+			selected = append(selected, tr)
+		}
+		firstPass = false
+		return idx.ledger.derive(ctx, from, to, selected)
+	}
+
+	state, err := idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	for range 4 {
+		if err := idx.syncOnce(ctx, &state); err != nil {
+			t.Fatalf("sync once: %v", err)
+		}
+	}
+
+	events, err := eventStore.ListEvents(ctx, store.ListOptions{ChainID: 1, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	last := events[2]
+	if last.BlockNumber != 3 || last.Account != alice.Hex() || last.PreviousWeight != "150" || last.NewWeight != "120" {
+		t.Fatalf("expected repaired alice transfer 150->120 at block 3, got %+v", last)
+	}
+	verifiedUntil, ok, err := eventStore.LastVerifiedBlock(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("last verified block: %v", err)
+	}
+	if !ok || verifiedUntil != 3 {
+		t.Fatalf("expected verified block 3, got %d (ok=%t)", verifiedUntil, ok)
+	}
+}
@@ -0,0 +1,248 @@
+package indexer
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// DefaultEventProfile indexes the WeightChanged event of the census validator
+// interface. It is used by contracts registered without a profile.
+var DefaultEventProfile = store.EventProfile{
+	ABI: `[{"type":"event","name":"WeightChanged","anonymous":false,"inputs":[` +
+		`{"name":"account","type":"address","indexed":true},` +
+		`{"name":"previousWeight","type":"uint88","indexed":false},` +
+		`{"name":"newWeight","type":"uint88","indexed":false}]}]`,
+	Account:        "account",
+	PreviousWeight: "previousWeight",
+	NewWeight:      "newWeight",
+}
+
+// ERC20TransferProfile indexes the Transfer event of ERC20 tokens, using the
+// token balances as weights.
+var ERC20TransferProfile = store.EventProfile{
+	ABI: `[{"type":"event","name":"Transfer","anonymous":false,"inputs":[` +
+		`{"name":"from","type":"address","indexed":true},` +
+		`{"name":"to","type":"address","indexed":true},` +
+		`{"name":"value","type":"uint256","indexed":false}]}]`,
+	From:  "from",
+	To:    "to",
+	Value: "value",
+}
+
+// SourceERC20 is the census source of ERC20 token balances.
+const SourceERC20 = "erc20"
+
+// SourceProfile returns the event profile of a census source shorthand. The
+// empty source stands for the default WeightChanged profile and returns nil.
+func SourceProfile(source string) (*store.EventProfile, error) {
+	switch strings.ToLower(strings.TrimSpace(source)) {
+	case "":
+		return nil, nil
+	case SourceERC20:
+		profile := ERC20TransferProfile
+		return &profile, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+}
+
+// ProfileEvent returns the event indexed with profile, or WeightChanged when
+// profile is nil.
+func ProfileEvent(profile *store.EventProfile) (abi.Event, error) {
+	decoder, err := newLogDecoder(profile)
+	if err != nil {
+		return abi.Event{}, err
+	}
+	return decoder.event, nil
+}
+
+// SameEventProfile reports whether a and b index the same event with the same
+// mapping. A nil profile stands for DefaultEventProfile.
+func SameEventProfile(a, b *store.EventProfile) bool {
+	if a == nil {
+		a = &DefaultEventProfile
+	}
+	if b == nil {
+		b = &DefaultEventProfile
+	}
+	return *a == *b
+}
+
+// logDecoder turns the logs of the event described by a profile into weight
+// change events.
+type logDecoder struct {
+	event   abi.Event
+	profile store.EventProfile
+	// keepArgs is set for custom profiles, whose decoded arguments are stored
+	// with the events.
+	keepArgs bool
+}
+
+// newLogDecoder returns the decoder of profile, or of DefaultEventProfile when
+// profile is nil.
+func newLogDecoder(profile *store.EventProfile) (*logDecoder, error) {
+	decoder := &logDecoder{profile: DefaultEventProfile}
+	if profile != nil && *profile != DefaultEventProfile {
+		decoder.profile = *profile
+		decoder.keepArgs = true
+	}
+	event, err := decoder.profile.Event()
+	if err != nil {
+		return nil, fmt.Errorf("invalid event profile: %w", err)
+	}
+	decoder.event = event
+	return decoder, nil
+}
+
+// unpack returns the decoded arguments of a log of the profile event.
+func (d *logDecoder) unpack(entry gethtypes.Log) (map[string]any, error) {
+	if len(entry.Topics) == 0 || entry.Topics[0] != d.event.ID {
+		return nil, fmt.Errorf("log %d of block %d is not a %s event", entry.Index, entry.BlockNumber, d.event.Name)
+	}
+	values := make(map[string]any, len(d.event.Inputs))
+	if err := d.event.Inputs.UnpackIntoMap(values, entry.Data); err != nil {
+		return nil, fmt.Errorf("unpack %s data at block %d: %w", d.event.Name, entry.BlockNumber, err)
+	}
+	var indexed abi.Arguments
+	for _, arg := range d.event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(values, indexed, entry.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("unpack %s topics at block %d: %w", d.event.Name, entry.BlockNumber, err)
+	}
+	return values, nil
+}
+
+// args formats decoded arguments for storage, or returns nil for the default
+// profile.
+func (d *logDecoder) args(values map[string]any) map[string]string {
+	if !d.keepArgs {
+		return nil
+	}
+	args := make(map[string]string, len(values))
+	for name, value := range values {
+		args[name] = formatArg(value)
+	}
+	return args
+}
+
+// decode returns the weight change of a log of a weight profile event.
+func (d *logDecoder) decode(chainID uint64, entry gethtypes.Log) (store.Event, error) {
+	if entry.Index > math.MaxUint32 {
+		return store.Event{}, fmt.Errorf("log index overflows uint32")
+	}
+	values, err := d.unpack(entry)
+	if err != nil {
+		return store.Event{}, err
+	}
+	account, ok := values[d.profile.Account].(common.Address)
+	if !ok {
+		return store.Event{}, fmt.Errorf("%s argument %q is not an address", d.event.Name, d.profile.Account)
+	}
+	newWeight, err := weightArg(values, d.profile.NewWeight)
+	if err != nil {
+		return store.Event{}, fmt.Errorf("%s at block %d: %w", d.event.Name, entry.BlockNumber, err)
+	}
+	previousWeight := new(big.Int)
+	if d.profile.PreviousWeight != "" {
+		if previousWeight, err = weightArg(values, d.profile.PreviousWeight); err != nil {
+			return store.Event{}, fmt.Errorf("%s at block %d: %w", d.event.Name, entry.BlockNumber, err)
+		}
+	}
+	return store.Event{
+		ChainID:        chainID,
+		Contract:       entry.Address.Hex(),
+		Account:        account.Hex(),
+		PreviousWeight: previousWeight.String(),
+		NewWeight:      newWeight.String(),
+		BlockNumber:    entry.BlockNumber,
+		LogIndex:       uint32(entry.Index),
+		Args:           d.args(values),
+	}, nil
+}
+
+// decodeTransfer returns the transfer of a log of a transfer profile event.
+func (d *logDecoder) decodeTransfer(entry gethtypes.Log) (transfer, error) {
+	if entry.Index > math.MaxUint32/2 {
+		return transfer{}, fmt.Errorf("log index overflows uint32")
+	}
+	values, err := d.unpack(entry)
+	if err != nil {
+		return transfer{}, err
+	}
+	from, fromOK := values[d.profile.From].(common.Address)
+	to, toOK := values[d.profile.To].(common.Address)
+	if !fromOK || !toOK {
+		return transfer{}, fmt.Errorf("%s arguments %q and %q must be addresses", d.event.Name, d.profile.From, d.profile.To)
+	}
+	value, err := weightArg(values, d.profile.Value)
+	if err != nil {
+		return transfer{}, fmt.Errorf("%s at block %d: %w", d.event.Name, entry.BlockNumber, err)
+	}
+	return transfer{
+		from:        from,
+		to:          to,
+		value:       value,
+		blockNumber: entry.BlockNumber,
+		logIndex:    uint32(entry.Index),
+		args:        d.args(values),
+	}, nil
+}
+
+// weightArg returns the integer argument name as a non-negative weight.
+func weightArg(values map[string]any, name string) (*big.Int, error) {
+	weight := new(big.Int)
+	switch value := reflect.ValueOf(values[name]); {
+	case !value.IsValid():
+		return nil, fmt.Errorf("missing argument %q", name)
+	case value.CanInt():
+		weight.SetInt64(value.Int())
+	case value.CanUint():
+		weight.SetUint64(value.Uint())
+	default:
+		n, ok := values[name].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("argument %q is not an integer", name)
+		}
+		weight.Set(n)
+	}
+	if weight.Sign() < 0 {
+		return nil, fmt.Errorf("argument %q holds negative weight %s", name, weight)
+	}
+	return weight, nil
+}
+
+// formatArg formats a decoded argument: addresses and hashes in hex, byte
+// strings and arrays as 0x-prefixed hex and other values in their default
+// format.
+func formatArg(value any) string {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		raw := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(raw), rv)
+		return hexutil.Encode(raw)
+	}
+	return fmt.Sprint(value)
+}
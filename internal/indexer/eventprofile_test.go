@@ -0,0 +1,129 @@
+package indexer
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+var transferProfile = store.EventProfile{
+	ABI: `[{"type":"event","name":"Transfer","inputs":[` +
+		`{"name":"from","type":"address","indexed":true},` +
+		`{"name":"to","type":"address","indexed":true},` +
+		`{"name":"value","type":"uint256","indexed":false}]}]`,
+	Account:   "to",
+	NewWeight: "value",
+}
+
+func TestLogDecoderDefaultProfile(t *testing.T) {
+	decoder, err := newLogDecoder(nil)
+	if err != nil {
+		t.Fatalf("create decoder: %v", err)
+	}
+	if decoder.event.ID != WeightChangedTopic {
+		t.Fatalf("expected the WeightChanged topic %s, got %s", WeightChangedTopic.Hex(), decoder.event.ID.Hex())
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aB")
+	data, err := decoder.event.Inputs.NonIndexed().Pack(big.NewInt(3), big.NewInt(10))
+	if err != nil {
+		t.Fatalf("pack event data: %v", err)
+	}
+	event, err := decoder.decode(5, gethtypes.Log{
+		Address:     contract,
+		Topics:      []common.Hash{WeightChangedTopic, common.BytesToHash(account.Bytes())},
+		Data:        data,
+		BlockNumber: 42,
+		Index:       7,
+	})
+	if err != nil {
+		t.Fatalf("decode log: %v", err)
+	}
+	want := store.Event{
+		ChainID:        5,
+		Contract:       contract.Hex(),
+		Account:        account.Hex(),
+		PreviousWeight: "3",
+		NewWeight:      "10",
+		BlockNumber:    42,
+		LogIndex:       7,
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Fatalf("expected %+v, got %+v", want, event)
+	}
+}
+
+func TestLogDecoderCustomProfileKeepsArgs(t *testing.T) {
+	decoder, err := newLogDecoder(&transferProfile)
+	if err != nil {
+		t.Fatalf("create decoder: %v", err)
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	from := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	to := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	data, err := decoder.event.Inputs.NonIndexed().Pack(big.NewInt(1000))
+	if err != nil {
+		t.Fatalf("pack event data: %v", err)
+	}
+	entry := gethtypes.Log{
+		Address:     contract,
+		Topics:      []common.Hash{decoder.event.ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        data,
+		BlockNumber: 9,
+		Index:       1,
+	}
+	event, err := decoder.decode(1, entry)
+	if err != nil {
+		t.Fatalf("decode log: %v", err)
+	}
+	wantArgs := map[string]string{"from": from.Hex(), "to": to.Hex(), "value": "1000"}
+	if event.Account != to.Hex() || event.PreviousWeight != "0" || event.NewWeight != "1000" || !reflect.DeepEqual(event.Args, wantArgs) {
+		t.Fatalf("unexpected decoded transfer: %+v", event)
+	}
+
+	entry.Topics = entry.Topics[:2]
+	if _, err := decoder.decode(1, entry); err == nil {
+		t.Fatalf("expected an error for a log missing an indexed argument")
+	}
+	entry.Topics = []common.Hash{WeightChangedTopic}
+	if _, err := decoder.decode(1, entry); err == nil {
+		t.Fatalf("expected an error for a log of another event")
+	}
+}
+
+func TestSameEventProfile(t *testing.T) {
+	defaultProfile := DefaultEventProfile
+	if !SameEventProfile(nil, &defaultProfile) || !SameEventProfile(&transferProfile, &transferProfile) {
+		t.Fatalf("expected equal profiles to match")
+	}
+	if SameEventProfile(nil, &transferProfile) {
+		t.Fatalf("expected the default and transfer profiles to differ")
+	}
+	if decoder, err := newLogDecoder(&defaultProfile); err != nil || decoder.keepArgs {
+		t.Fatalf("expected an explicit default profile not to keep arguments (err=%v)", err)
+	}
+}
+
+func TestSourceProfile(t *testing.T) {
+	profile, err := SourceProfile("ERC20")
+	if err != nil {
+		t.Fatalf("erc20 source: %v", err)
+	}
+	if profile == nil || !profile.Transfers() || *profile != ERC20TransferProfile {
+		t.Fatalf("expected the ERC20 transfer profile, got %+v", profile)
+	}
+	if err := profile.Validate(); err != nil {
+		t.Fatalf("validate ERC20 profile: %v", err)
+	}
+	if profile, err := SourceProfile(""); err != nil || profile != nil {
+		t.Fatalf("expected no profile for the default source, got %+v (%v)", profile, err)
+	}
+	if _, err := SourceProfile("erc721"); err == nil {
+		t.Fatalf("expected unknown source to fail")
+	}
+}
@@ -0,0 +1,201 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// ChainHead is the cached head of a chain. Safe and Finalized are unset on
+// chains whose endpoints do not serve those block tags.
+type ChainHead struct {
+	ChainID   uint64    `json:"chainId"`
+	Head      uint64    `json:"head"`
+	Safe      *uint64   `json:"safe,omitempty"`
+	Finalized *uint64   `json:"finalized,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// LastError is the error of the last failed refresh, if any.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// headFetchTimeout bounds a head refresh, which runs detached from the
+// context of the caller that started it.
+const headFetchTimeout = 30 * time.Second
+
+// HeadTracker caches the head of each chain for the indexers of the chain and
+// the API. A cached head is refreshed by the first caller that finds it older
+// than the poll interval of the chain; concurrent callers wait for that
+// refresh instead of sending their own request.
+type HeadTracker struct {
+	mu       sync.Mutex
+	chains   map[uint64]*trackedHead
+	fetch    func(ctx context.Context, chainID uint64) (ChainHead, error)
+	interval func(chainID uint64) time.Duration
+	now      func() time.Time
+}
+
+type trackedHead struct {
+	mu      sync.Mutex
+	head    ChainHead
+	ok      bool
+	lastErr string
+	// refresh is the refresh in flight, if any.
+	refresh *headRefresh
+}
+
+// headRefresh is a refresh of a chain head shared by the callers waiting for
+// it. done is closed once head and err are set.
+type headRefresh struct {
+	done chan struct{}
+	head ChainHead
+	err  error
+}
+
+func newHeadTracker(fetch func(context.Context, uint64) (ChainHead, error), interval func(uint64) time.Duration) *HeadTracker {
+	return &HeadTracker{
+		chains:   make(map[uint64]*trackedHead),
+		fetch:    fetch,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// HeadBlock returns the head block of the chain.
+func (t *HeadTracker) HeadBlock(ctx context.Context, chainID uint64) (uint64, error) {
+	head, err := t.Head(ctx, chainID)
+	if err != nil {
+		return 0, err
+	}
+	return head.Head, nil
+}
+
+// Head returns the cached head of the chain, refreshing it when it is older
+// than the poll interval of the chain. The refresh is not cancelled when ctx
+// is: Head returns early and the refresh completes for the other callers.
+func (t *HeadTracker) Head(ctx context.Context, chainID uint64) (ChainHead, error) {
+	entry := t.entry(chainID)
+	if head, ok := entry.fresh(t.now(), t.interval(chainID)); ok {
+		return head, nil
+	}
+	entry.mu.Lock()
+	refresh := entry.refresh
+	if refresh == nil {
+		refresh = &headRefresh{done: make(chan struct{})}
+		entry.refresh = refresh
+		go t.runRefresh(context.WithoutCancel(ctx), chainID, entry, refresh)
+	}
+	entry.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ChainHead{}, ctx.Err()
+	case <-refresh.done:
+		return refresh.head, refresh.err
+	}
+}
+
+// runRefresh fetches the head of the chain into entry and reports it to the
+// callers waiting for refresh.
+func (t *HeadTracker) runRefresh(ctx context.Context, chainID uint64, entry *trackedHead, refresh *headRefresh) {
+	ctx, cancel := context.WithTimeout(ctx, headFetchTimeout)
+	defer cancel()
+	head, err := t.fetch(ctx, chainID)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if err != nil {
+		entry.lastErr = err.Error()
+		refresh.err = err
+	} else {
+		head.ChainID = chainID
+		head.UpdatedAt = t.now().UTC()
+		entry.head = head
+		entry.ok = true
+		entry.lastErr = ""
+		refresh.head = head
+	}
+	entry.refresh = nil
+	close(refresh.done)
+}
+
+// Status returns the cached heads of the chains requested so far.
+func (t *HeadTracker) Status() []ChainHead {
+	t.mu.Lock()
+	entries := make([]*trackedHead, 0, len(t.chains))
+	for _, entry := range t.chains {
+		entries = append(entries, entry)
+	}
+	t.mu.Unlock()
+	out := make([]ChainHead, 0, len(entries))
+	for _, entry := range entries {
+		entry.mu.Lock()
+		if entry.ok || entry.lastErr != "" {
+			head := entry.head
+			head.LastError = entry.lastErr
+			out = append(out, head)
+		}
+		entry.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ChainID < out[j].ChainID })
+	return out
+}
+
+func (t *HeadTracker) entry(chainID uint64) *trackedHead {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.chains[chainID]
+	if !ok {
+		entry = &trackedHead{head: ChainHead{ChainID: chainID}}
+		t.chains[chainID] = entry
+	}
+	return entry
+}
+
+func (e *trackedHead) fresh(now time.Time, interval time.Duration) (ChainHead, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.ok || now.Sub(e.head.UpdatedAt) >= interval {
+		return ChainHead{}, false
+	}
+	return e.head, true
+}
+
+// readChainHead reads the head, safe and finalized blocks in a single batch
+// request. When the batch fails, only the head is read through the client,
+// which retries on the other endpoints of the chain.
+func readChainHead(ctx context.Context, client *RPCClient) (ChainHead, error) {
+	if ethClient, err := client.EthClient(); err == nil {
+		var (
+			head      hexutil.Uint64
+			safe      *blockNumberResult
+			finalized *blockNumberResult
+		)
+		batch := []gethrpc.BatchElem{
+			{Method: "eth_blockNumber", Result: &head},
+			{Method: "eth_getBlockByNumber", Args: []any{"safe", false}, Result: &safe},
+			{Method: "eth_getBlockByNumber", Args: []any{"finalized", false}, Result: &finalized},
+		}
+		if err := ethClient.Client().BatchCallContext(ctx, batch); err == nil && batch[0].Error == nil {
+			result := ChainHead{Head: uint64(head)}
+			if batch[1].Error == nil && safe != nil {
+				result.Safe = (*uint64)(&safe.Number)
+			}
+			if batch[2].Error == nil && finalized != nil {
+				result.Finalized = (*uint64)(&finalized.Number)
+			}
+			return result, nil
+		}
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return ChainHead{}, fmt.Errorf("fetch head block: %w", err)
+	}
+	return ChainHead{Head: head}, nil
+}
+
+type blockNumberResult struct {
+	Number hexutil.Uint64 `json:"number"`
+}
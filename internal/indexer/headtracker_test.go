@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHeadTrackerCachesHeadsPerInterval(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newHeadTracker(func(_ context.Context, chainID uint64) (ChainHead, error) {
+		if failing.Load() {
+			return ChainHead{}, errors.New("rpc unavailable")
+		}
+		n := calls.Add(1)
+		// Concurrent callers wait for the refresh in flight.
+		time.Sleep(time.Millisecond)
+		return ChainHead{Head: chainID*100 + uint64(n)}, nil
+	}, func(uint64) time.Duration { return time.Second })
+	var mu sync.Mutex
+	tracker.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if head, err := tracker.HeadBlock(ctx, 1); err != nil || head != 101 {
+				t.Errorf("expected head 101, got %d %v", head, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single refresh, got %d", calls.Load())
+	}
+
+	advance(time.Second)
+	if head, err := tracker.HeadBlock(ctx, 1); err != nil || head != 102 {
+		t.Fatalf("expected the head to be refreshed after the interval, got %d %v", head, err)
+	}
+	if head, err := tracker.HeadBlock(ctx, 2); err != nil || head != 203 {
+		t.Fatalf("expected chains to be cached separately, got %d %v", head, err)
+	}
+
+	advance(time.Second)
+	failing.Store(true)
+	if _, err := tracker.HeadBlock(ctx, 1); err == nil {
+		t.Fatalf("expected the failed refresh to return an error")
+	}
+	status := tracker.Status()
+	if len(status) != 2 || status[0].ChainID != 1 || status[0].Head != 102 || status[0].LastError == "" {
+		t.Fatalf("expected chain 1 with its last head and the refresh error, got %+v", status)
+	}
+	if status[1].ChainID != 2 || status[1].Head != 203 || status[1].LastError != "" {
+		t.Fatalf("expected chain 2 with its head, got %+v", status)
+	}
+}
+
+func TestHeadTrackerDetachesRefreshFromCaller(t *testing.T) {
+	release := make(chan struct{})
+	fetchErr := make(chan error, 1)
+	tracker := newHeadTracker(func(ctx context.Context, _ uint64) (ChainHead, error) {
+		select {
+		case <-release:
+			fetchErr <- ctx.Err()
+			return ChainHead{Head: 7}, nil
+		case <-ctx.Done():
+			fetchErr <- ctx.Err()
+			return ChainHead{}, ctx.Err()
+		}
+	}, func(uint64) time.Duration { return time.Minute })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := tracker.HeadBlock(ctx, 1)
+		done <- err
+	}()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to return, got %v", err)
+	}
+
+	waiting := make(chan uint64, 1)
+	go func() {
+		head, _ := tracker.HeadBlock(context.Background(), 1)
+		waiting <- head
+	}()
+	close(release)
+	if err := <-fetchErr; err != nil {
+		t.Fatalf("expected the refresh to outlive the cancelled caller, got %v", err)
+	}
+	if head := <-waiting; head != 7 {
+		t.Fatalf("expected head 7, got %d", head)
+	}
+}
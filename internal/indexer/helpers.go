@@ -2,14 +2,19 @@ package indexer
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"slices"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/vocdoni/davinci-node/web3/rpc"
 )
 
+// CodeReader reads contract bytecode at a given block.
+type CodeReader interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
 var definitiveErrors = []string{
 	"No state available for block",
 	"missing trie node",
@@ -24,11 +29,41 @@ func isDefinitiveError(err error) bool {
 	})
 }
 
+// rangeLimitErrors are the messages RPC providers use to reject eth_getLogs
+// queries spanning too many blocks or returning too many logs.
+var rangeLimitErrors = []string{
+	"block range",
+	"range too large",
+	"range is too large",
+	"is limited to",
+	"query returned more than",
+	"response size exceeded",
+	"too many results",
+}
+
+// isRangeLimitError reports whether err rejects the range of an eth_getLogs
+// query, which a smaller range may avoid.
+func isRangeLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return slices.ContainsFunc(rangeLimitErrors, func(e string) bool {
+		return strings.Contains(msg, e)
+	})
+}
+
+// CreationBlock returns the first block, up to head, at which the contract
+// has bytecode.
+func CreationBlock(ctx context.Context, client CodeReader, addr common.Address, head uint64) (uint64, error) {
+	return creationBlockInRange(ctx, client, addr, 0, head)
+}
+
 // creationBlockInRange function finds the block number of a contract between
 // the bounds provided as start and end blocks.
 func creationBlockInRange(
 	ctx context.Context,
-	client *rpc.Client,
+	client CodeReader,
 	addr common.Address,
 	start, end uint64,
 ) (uint64, error) {
@@ -40,9 +75,12 @@ func creationBlockInRange(
 	// code at this block
 	midBlock := (start + end) / 2
 	codeLen, err := sourceCodeLenAt(ctx, client, addr, midBlock)
-	if isDefinitiveError(err) {
+	if isDefinitiveError(err) || errors.Is(err, ErrDailyCapReached) {
 		return 0, err
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
 	// if any code is found, keep trying with the lower half of blocks until
 	// find the first. if not, keep trying with the upper half
 	if codeLen > 2 {
@@ -56,7 +94,7 @@ func creationBlockInRange(
 // at the block number provided.
 func sourceCodeLenAt(
 	ctx context.Context,
-	client *rpc.Client,
+	client CodeReader,
 	addr common.Address,
 	atBlockNumber uint64,
 ) (int, error) {
@@ -4,15 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math"
+	"math/big"
 	"sort"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	contracts "github.com/vocdoni/davinci-contracts/golang-types"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/vocdoni/davinci-node/log"
-	"github.com/vocdoni/davinci-node/web3/rpc"
 
 	"github.com/vocdoni/onchain-census-indexer/internal/store"
 )
@@ -21,8 +20,8 @@ var errRetryable = errors.New("retryable error")
 
 // Config configures the indexer.
 type Config struct {
-	Client          *rpc.Client
-	Store           *store.Store
+	Client          *RPCClient
+	Store           store.Backend
 	ChainID         uint64
 	Contract        common.Address
 	StartBlock      uint64
@@ -31,29 +30,74 @@ type Config struct {
 	VerifyBatchSize uint64
 	Confirmations   uint64
 	TailRescanDepth uint64
+	// MaxBackoff caps the delay between retries after RPC errors.
+	MaxBackoff time.Duration
+	// Breaker is the circuit breaker shared by the indexers of the chain.
+	Breaker *CircuitBreaker
+	// EventProfile selects the indexed event; nil indexes WeightChanged.
+	EventProfile *store.EventProfile
+	// AuditInterval is how often the balances of transfer profile contracts
+	// are compared with balanceOf at the verified block. Zero disables audits.
+	AuditInterval time.Duration
+	// AuditSample caps the accounts compared per audit; zero compares them all.
+	AuditSample int
+	// AuditReindex schedules a re-index of the mismatched accounts' history.
+	AuditReindex bool
+	// PauseOnUpgrade pauses indexing when the EIP-1967 implementation of the
+	// contract changes, until the upgrade is acknowledged.
+	PauseOnUpgrade bool
+	// BackfillWorkers is the number of segments backfilled concurrently when
+	// the contract is far behind the safe head. Zero or one indexes
+	// sequentially.
+	BackfillWorkers int
+	// BackfillSegmentSize is the number of blocks per backfill segment.
+	BackfillSegmentSize uint64
+	// BackfillLimiter bounds the backfill requests of the chain in flight.
+	BackfillLimiter *ChainLimiter
+	// RateLimiter limits the RPC requests of the chain; nil does not limit
+	// them.
+	RateLimiter *RateLimiter
+	// Heads is the head tracker shared by the indexers of the service; the
+	// head is read from the RPC on every poll when nil.
+	Heads *HeadTracker
 }
 
-// Indexer indexes WeightChanged events into the database.
+// Indexer indexes the weight changes of a contract event into the database.
 type Indexer struct {
-	client          *rpc.Client
-	store           *store.Store
-	chainID         uint64
-	contract        common.Address
-	filterer        *contracts.ICensusValidatorFilterer
+	client   *RPCClient
+	store    store.Backend
+	chainID  uint64
+	contract common.Address
+	decoder  *logDecoder
+	// ledger tracks balances for transfer profiles; nil for weight profiles.
+	ledger          *balanceLedger
+	auditor         *balanceAuditor
+	upgrades        *upgradeTracker
 	startBlock      uint64
 	pollInterval    time.Duration
 	batchSize       uint64
 	verifyBatchSize uint64
 	confirmations   uint64
 	tailRescanDepth uint64
-	headFunc        func(context.Context) (uint64, error)
-	eventsFunc      func(context.Context, uint64, uint64) ([]store.Event, error)
+	retry           *retryBackoff
+	breaker         *CircuitBreaker
+	// backfillWorkers, backfillSegmentSize and backfillLimiter configure the
+	// parallel backfill; see Config.
+	backfillWorkers     int
+	backfillSegmentSize uint64
+	backfillLimiter     *ChainLimiter
+	limiter             *RateLimiter
+	headFunc            func(context.Context) (uint64, error)
+	eventsFunc          func(context.Context, uint64, uint64) ([]store.Event, error)
 }
 
 type progressState struct {
 	indexedUntil   uint64
 	verifiedUntil  uint64
 	tailRescanFrom uint64
+	// upgradeFoundAt holds back parallel backfills until the verified cursor
+	// reaches it, after a segment found a proxy upgrade at that block.
+	upgradeFoundAt uint64
 }
 
 // New returns a new Indexer with the provided configuration.
@@ -67,9 +111,9 @@ func New(cfg Config) (*Indexer, error) {
 	if cfg.ChainID == 0 {
 		return nil, fmt.Errorf("chainID is required")
 	}
-	filterer, err := contracts.NewICensusValidatorFilterer(cfg.Contract, cfg.Client)
+	decoder, err := newLogDecoder(cfg.EventProfile)
 	if err != nil {
-		return nil, fmt.Errorf("create contract filterer: %w", err)
+		return nil, err
 	}
 	pollInterval := cfg.PollInterval
 	if pollInterval <= 0 {
@@ -87,21 +131,57 @@ func New(cfg Config) (*Indexer, error) {
 	if tailRescanDepth == 0 {
 		tailRescanDepth = verifyBatchSize
 	}
+	maxBackoff := cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	backfillSegmentSize := cfg.BackfillSegmentSize
+	if backfillSegmentSize == 0 {
+		backfillSegmentSize = 10 * batchSize
+	}
 	idx := &Indexer{
 		client:          cfg.Client,
 		store:           cfg.Store,
 		chainID:         cfg.ChainID,
 		contract:        cfg.Contract,
-		filterer:        filterer,
+		decoder:         decoder,
 		startBlock:      cfg.StartBlock,
 		pollInterval:    pollInterval,
 		batchSize:       batchSize,
 		verifyBatchSize: verifyBatchSize,
 		confirmations:   cfg.Confirmations,
 		tailRescanDepth: tailRescanDepth,
+		retry:           &retryBackoff{base: pollInterval, max: max(maxBackoff, pollInterval)},
+		breaker:         cfg.Breaker,
+		upgrades:        &upgradeTracker{pause: cfg.PauseOnUpgrade},
+
+		backfillWorkers:     cfg.BackfillWorkers,
+		backfillSegmentSize: backfillSegmentSize,
+		backfillLimiter:     cfg.BackfillLimiter,
+		limiter:             cfg.RateLimiter,
+	}
+	idx.headFunc = idx.fetchHead
+	if cfg.Heads != nil {
+		idx.headFunc = func(ctx context.Context) (uint64, error) {
+			return cfg.Heads.HeadBlock(ctx, cfg.ChainID)
+		}
 	}
-	idx.headFunc = idx.client.BlockNumber
+	idx.upgrades.readFunc = idx.readImplementation
 	idx.eventsFunc = idx.fetchEventsFromRPC
+	if decoder.profile.Transfers() {
+		idx.ledger = newBalanceLedger(cfg.Store, cfg.ChainID, cfg.Contract, decoder.profile)
+		idx.eventsFunc = idx.fetchTransfersFromRPC
+		// Only ERC20 tokens are known to have a balanceOf getter matching the
+		// folded balances.
+		if cfg.AuditInterval > 0 && decoder.profile == ERC20TransferProfile {
+			idx.auditor = &balanceAuditor{
+				interval:    cfg.AuditInterval,
+				sample:      cfg.AuditSample,
+				reindex:     cfg.AuditReindex,
+				balanceFunc: idx.balanceOf,
+			}
+		}
+	}
 	return idx, nil
 }
 
@@ -125,26 +205,75 @@ func (i *Indexer) Run(ctx context.Context) error {
 		"tailRescanDepth", i.tailRescanDepth,
 	)
 
+	probing := false
+	defer func() {
+		if probing {
+			i.breaker.release()
+		}
+	}()
 	for {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		err = i.syncOnce(ctx, &state)
-		if err != nil {
-			if errors.Is(err, errRetryable) {
-				log.Warnf("indexer retryable error: %v", err)
-			} else {
+		delay := i.pollInterval
+		allowed, probe, circuitRetryAt := i.breaker.allow(time.Now())
+		if allowed {
+			probing = probe
+			err = i.syncOnce(ctx, &state)
+			switch {
+			case err == nil:
+				i.breaker.success()
+				if failures := i.retry.success(); failures > 0 {
+					log.Infow("indexer recovered", "chainID", i.chainID, "contract", i.contract.Hex(), "failures", failures)
+				}
+			case errors.Is(err, errRetryable):
+				now := time.Now()
+				i.breaker.failure(now)
+				delay = i.retry.failure(err, now)
+				if status := i.retry.status(); status.Failures == 1 {
+					log.Warnw("indexer retryable error; backing off",
+						"chainID", i.chainID,
+						"contract", i.contract.Hex(),
+						"err", err,
+						"retryIn", delay.String(),
+					)
+				} else {
+					log.Debugw("indexer retryable error",
+						"chainID", i.chainID,
+						"contract", i.contract.Hex(),
+						"err", err,
+						"failures", status.Failures,
+						"retryIn", delay.String(),
+					)
+				}
+			default:
 				return err
 			}
+			probing = false
+		} else if !circuitRetryAt.IsZero() {
+			delay = max(time.Until(circuitRetryAt), 0)
 		}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(i.pollInterval):
+		case <-time.After(delay):
 		}
 	}
 }
 
+// RetryStatus returns the retry backoff of the indexer and the circuit breaker
+// state of its chain.
+func (i *Indexer) RetryStatus() RetryStatus {
+	status := i.retry.status()
+	state, retryAt := i.breaker.status()
+	status.Circuit = state
+	if !retryAt.IsZero() {
+		retryAt = retryAt.UTC()
+		status.CircuitRetryAt = &retryAt
+	}
+	return status
+}
+
 func (i *Indexer) loadProgress(ctx context.Context) (progressState, error) {
 	indexedUntil, indexedOK, err := i.store.LastIndexedBlock(ctx, i.chainID, i.contract)
 	if err != nil {
@@ -174,7 +303,10 @@ func (i *Indexer) loadProgress(ctx context.Context) (progressState, error) {
 }
 
 func (i *Indexer) syncOnce(ctx context.Context, state *progressState) error {
-	head, err := i.headFunc(ctx)
+	if paused, err := i.pausedByUpgrade(ctx); err != nil || paused {
+		return err
+	}
+	head, err := i.headFunc(WithRequestPriority(ctx, PriorityHead))
 	if err != nil {
 		return fmt.Errorf("%w: fetch head block: %v", errRetryable, err)
 	}
@@ -190,22 +322,56 @@ func (i *Indexer) syncOnce(ctx context.Context, state *progressState) error {
 		return nil
 	}
 
+	if err := i.backfill(ctx, state, safeHead); err != nil {
+		return err
+	}
+	if i.upgradePaused() {
+		return nil
+	}
 	for state.verifiedUntil < safeHead {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
 		verifyFrom := state.verifiedUntil + 1
 		verifyTo := min(verifyFrom+i.verifyBatchSize-1, safeHead)
-		if err := i.indexRange(ctx, state, verifyTo); err != nil {
+		// Batches more than one verification batch behind the safe head are
+		// historical and yield to head polling and tip indexing.
+		fetchCtx := ctx
+		if safeHead-verifyTo >= i.verifyBatchSize {
+			fetchCtx = WithRequestPriority(ctx, PriorityBackfill)
+		}
+		if err := i.indexRange(fetchCtx, state, verifyTo); err != nil {
 			return err
 		}
-		if err := i.verifyRange(ctx, state, verifyFrom, verifyTo); err != nil {
+		if err := i.verifyRange(fetchCtx, state, verifyFrom, verifyTo); err != nil {
 			return err
 		}
+		if err := i.checkImplementation(ctx, verifyTo); err != nil {
+			return err
+		}
+		if i.upgradePaused() {
+			return nil
+		}
 	}
 	if err := i.rescanTail(ctx, state, safeHead); err != nil {
 		return err
 	}
+	if err := i.processReindexJobs(ctx); err != nil {
+		return err
+	}
+	if err := i.auditBalances(ctx, state.verifiedUntil); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// Audits only report on the indexed data, so a failed audit does not
+		// fail the sync; it is retried after the audit interval.
+		log.Warnw("balance audit failed",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"block", state.verifiedUntil,
+			"err", err,
+		)
+	}
 	return nil
 }
 
@@ -226,6 +392,9 @@ func (i *Indexer) indexRange(ctx context.Context, state *progressState, targetTo
 		}); err != nil {
 			return fmt.Errorf("store first-pass events: %w", err)
 		}
+		if err := i.commitBalances(ctx); err != nil {
+			return err
+		}
 		state.indexedUntil = to
 		if len(events) > 0 {
 			log.Infow("stored first-pass batch", "from", from, "to", to, "count", len(events))
@@ -247,6 +416,9 @@ func (i *Indexer) verifyRange(ctx context.Context, state *progressState, from, t
 	}); err != nil {
 		return fmt.Errorf("store verified events: %w", err)
 	}
+	if err := i.commitBalances(ctx); err != nil {
+		return err
+	}
 	state.verifiedUntil = to
 	if len(events) > 0 {
 		log.Infow("verified events batch", "from", from, "to", to, "count", len(events))
@@ -278,6 +450,9 @@ func (i *Indexer) rescanTail(ctx context.Context, state *progressState, safeHead
 	if err := i.store.ReplaceEventsInRange(ctx, i.chainID, i.contract, from, to, events, store.ReplaceOptions{}); err != nil {
 		return fmt.Errorf("store tail rescan events: %w", err)
 	}
+	if err := i.commitBalances(ctx); err != nil {
+		return err
+	}
 	if len(events) > 0 {
 		log.Debugw("tail rescan stored", "from", from, "to", to, "count", len(events))
 	} else {
@@ -291,6 +466,78 @@ func (i *Indexer) rescanTail(ctx context.Context, state *progressState, safeHead
 	return nil
 }
 
+// processReindexJobs advances scheduled re-index jobs after the tip has been
+// handled. Each call works for at most one poll interval, so long ranges are
+// spread across polls instead of holding back progress at the tip.
+func (i *Indexer) processReindexJobs(ctx context.Context) error {
+	jobs, err := i.store.ListReindexJobs(ctx, i.chainID, i.contract)
+	if err != nil {
+		return fmt.Errorf("list reindex jobs: %w", err)
+	}
+	deadline := time.Now().Add(i.pollInterval)
+	for _, job := range jobs {
+		for !job.Done() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := i.reindexStep(ctx, &job); err != nil {
+				return err
+			}
+			if !time.Now().Before(deadline) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// reindexStep runs one first-pass batch of the job, or one verification batch
+// once the whole range has been indexed again.
+func (i *Indexer) reindexStep(ctx context.Context, job *store.ReindexJob) error {
+	next := *job
+	var from, to uint64
+	if next.IndexedUntil < next.To {
+		from = next.IndexedUntil + 1
+		to = min(from+i.batchSize-1, next.To)
+		next.Status = store.ReindexIndexing
+		next.IndexedUntil = to
+	} else {
+		from = next.VerifiedUntil + 1
+		to = min(from+i.verifyBatchSize-1, next.To)
+		next.Status = store.ReindexVerifying
+		next.VerifiedUntil = to
+		if to == next.To {
+			completedAt := time.Now().UTC()
+			next.Status = store.ReindexCompleted
+			next.CompletedAt = &completedAt
+		}
+	}
+	log.Debugw("reindex fetch", "job", next.ID, "status", next.Status, "from", from, "to", to)
+	events, err := i.eventsFunc(WithRequestPriority(ctx, PriorityBackfill), from, to)
+	if err != nil {
+		return err
+	}
+	if err := i.store.ReplaceEventsInRange(ctx, i.chainID, i.contract, from, to, events, store.ReplaceOptions{
+		ReindexJob: &next,
+	}); err != nil {
+		return fmt.Errorf("store reindex events: %w", err)
+	}
+	if err := i.commitBalances(ctx); err != nil {
+		return err
+	}
+	*job = next
+	if job.Done() {
+		log.Infow("reindex job completed",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"job", job.ID,
+			"from", job.From,
+			"to", job.To,
+		)
+	}
+	return nil
+}
+
 func (i *Indexer) safeHead(head uint64) (uint64, bool) {
 	if i.confirmations == 0 {
 		return head, true
@@ -322,42 +569,17 @@ func (i *Indexer) tailWindowStart(windowEnd uint64) (uint64, bool) {
 }
 
 func (i *Indexer) fetchEventsFromRPC(ctx context.Context, from, to uint64) ([]store.Event, error) {
-	opts := &bind.FilterOpts{
-		Start:   from,
-		End:     &to,
-		Context: ctx,
-	}
-	iter, err := i.filterer.FilterWeightChanged(opts, nil)
+	logs, err := i.fetchLogs(ctx, from, to)
 	if err != nil {
-		return nil, fmt.Errorf("%w: filter logs from %d to %d: %v", errRetryable, from, to, err)
+		return nil, err
 	}
-	defer func() {
-		if err := iter.Close(); err != nil {
-			log.Warnw("close logs iterator error", "err", err)
-		}
-	}()
-
-	results := make([]store.Event, 0)
-	for iter.Next() {
-		event := iter.Event
-		if event == nil {
-			continue
-		}
-		if event.Raw.Index > math.MaxUint32 {
-			return nil, fmt.Errorf("log index overflows uint32")
+	results := make([]store.Event, 0, len(logs))
+	for _, entry := range logs {
+		event, err := i.decoder.decode(i.chainID, entry)
+		if err != nil {
+			return nil, err
 		}
-		results = append(results, store.Event{
-			ChainID:        i.chainID,
-			Contract:       i.contract.Hex(),
-			Account:        event.Account.Hex(),
-			PreviousWeight: event.PreviousWeight.String(),
-			NewWeight:      event.NewWeight.String(),
-			BlockNumber:    event.Raw.BlockNumber,
-			LogIndex:       uint32(event.Raw.Index),
-		})
-	}
-	if err := iter.Error(); err != nil {
-		return nil, fmt.Errorf("%w: filter logs from %d to %d: %v", errRetryable, from, to, err)
+		results = append(results, event)
 	}
 	sort.Slice(results, func(a, b int) bool {
 		if results[a].BlockNumber == results[b].BlockNumber {
@@ -368,3 +590,59 @@ func (i *Indexer) fetchEventsFromRPC(ctx context.Context, from, to uint64) ([]st
 	log.Debugw("filter logs completed", "from", from, "to", to, "events", len(results))
 	return results, nil
 }
+
+// fetchTransfersFromRPC returns the balance changes of the transfers in
+// [from, to]. The balances are committed once the events are stored.
+func (i *Indexer) fetchTransfersFromRPC(ctx context.Context, from, to uint64) ([]store.Event, error) {
+	logs, err := i.fetchLogs(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	transfers := make([]transfer, 0, len(logs))
+	for _, entry := range logs {
+		t, err := i.decoder.decodeTransfer(entry)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	sortTransfers(transfers)
+	events, err := i.ledger.derive(ctx, from, to, transfers)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugw("filter transfer logs completed", "from", from, "to", to, "transfers", len(transfers), "events", len(events))
+	return events, nil
+}
+
+// fetchHead returns the head block of the chain.
+func (i *Indexer) fetchHead(ctx context.Context) (uint64, error) {
+	if err := i.limiter.Wait(ctx, i.chainID); err != nil {
+		return 0, err
+	}
+	return i.client.BlockNumber(ctx)
+}
+
+// fetchLogs returns the logs of the profile event emitted by the contract in
+// [from, to], without the ones removed by a reorg.
+func (i *Indexer) fetchLogs(ctx context.Context, from, to uint64) ([]gethtypes.Log, error) {
+	if err := i.limiter.Wait(ctx, i.chainID); err != nil {
+		return nil, fmt.Errorf("%w: filter logs from %d to %d: %v", errRetryable, from, to, err)
+	}
+	logs, err := i.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{i.contract},
+		Topics:    [][]common.Hash{{i.decoder.event.ID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: filter logs from %d to %d: %v", errRetryable, from, to, err)
+	}
+	kept := logs[:0]
+	for _, entry := range logs {
+		if !entry.Removed {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
@@ -3,6 +3,7 @@ package indexer
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/davinci-node/db"
@@ -147,3 +148,86 @@ func TestSyncOnceTailRescanRecoversLateEvent(t *testing.T) {
 		t.Fatalf("expected recovered tail event at block 9, got %+v", events[1])
 	}
 }
+
+func TestSyncOnceProcessesReindexJob(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x5656565656565656565656565656565656565656")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "1", BlockNumber: 1, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xbad", PreviousWeight: "0", NewWeight: "9", BlockNumber: 2, LogIndex: 0},
+	}, 4); err != nil {
+		t.Fatalf("save initial events: %v", err)
+	}
+	job, err := eventStore.ScheduleReindex(ctx, 1, contract, 2, 3)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+
+	canonical := []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xaaa", PreviousWeight: "0", NewWeight: "1", BlockNumber: 1, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xbbb", PreviousWeight: "0", NewWeight: "2", BlockNumber: 3, LogIndex: 0},
+	}
+	idx := &Indexer{
+		store:           eventStore,
+		chainID:         1,
+		contract:        contract,
+		startBlock:      1,
+		pollInterval:    time.Hour,
+		batchSize:       1,
+		verifyBatchSize: 2,
+	}
+	idx.headFunc = func(context.Context) (uint64, error) {
+		return 4, nil
+	}
+	calls := 0
+	idx.eventsFunc = func(_ context.Context, from, to uint64) ([]store.Event, error) {
+		calls++
+		var events []store.Event
+		for _, event := range canonical {
+			if event.BlockNumber >= from && event.BlockNumber <= to {
+				events = append(events, event)
+			}
+		}
+		return events, nil
+	}
+
+	state, err := idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 2 first-pass and 1 verification fetches, got %d", calls)
+	}
+
+	events, err := eventStore.ListEvents(ctx, store.ListOptions{ChainID: 1, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 || events[1].Account != "0xbbb" {
+		t.Fatalf("expected bad event replaced by canonical data, got %+v", events)
+	}
+	stored, ok, err := eventStore.GetReindexJob(ctx, 1, contract, job.ID)
+	if err != nil || !ok {
+		t.Fatalf("expected reindex job (ok=%t, err=%v)", ok, err)
+	}
+	if !stored.Done() || stored.VerifiedUntil != 3 || stored.CompletedAt == nil {
+		t.Fatalf("expected completed reindex job, got %+v", stored)
+	}
+}
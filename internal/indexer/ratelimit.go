@@ -0,0 +1,398 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// RequestPriority orders the RPC requests competing for a rate limit.
+type RequestPriority int
+
+const (
+	// PriorityHead is used to poll the chain head.
+	PriorityHead RequestPriority = iota
+	// PriorityTip is used to index blocks close to the head, and by requests
+	// without a priority.
+	PriorityTip
+	// PriorityBackfill is used for historical ranges: backfills, re-index jobs
+	// and start block searches.
+	PriorityBackfill
+)
+
+var requestPriorityNames = [...]string{"head", "tip", "backfill"}
+
+func (p RequestPriority) String() string {
+	if p < 0 || int(p) >= len(requestPriorityNames) {
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+	return requestPriorityNames[p]
+}
+
+// backfillReserve is the share of the burst and of the daily cap of a limit
+// that backfill requests leave to head polling and tip indexing.
+const backfillReserve = 0.25
+
+// ErrDailyCapReached is returned when the daily request cap of a chain or an
+// endpoint is used up. The cap resets at midnight UTC.
+var ErrDailyCapReached = errors.New("rpc daily request cap reached")
+
+type requestPriorityKey struct{}
+
+// WithRequestPriority returns a context whose RPC requests are rate limited
+// with priority p.
+func WithRequestPriority(ctx context.Context, p RequestPriority) context.Context {
+	return context.WithValue(ctx, requestPriorityKey{}, p)
+}
+
+func requestPriority(ctx context.Context) RequestPriority {
+	if p, ok := ctx.Value(requestPriorityKey{}).(RequestPriority); ok {
+		return p
+	}
+	return PriorityTip
+}
+
+// RateLimit bounds the RPC requests of a chain or an endpoint. Zero fields do
+// not limit requests.
+type RateLimit struct {
+	RequestsPerSecond float64
+	// Burst is the number of requests allowed at once; it defaults to one
+	// second of requests.
+	Burst    int
+	DailyCap uint64
+}
+
+func (l RateLimit) enabled() bool {
+	return l.RequestsPerSecond > 0 || l.DailyCap > 0
+}
+
+// RateLimitConfig configures a RateLimiter.
+type RateLimitConfig struct {
+	// Chain limits every chain without an entry in Chains.
+	Chain  RateLimit
+	Chains map[uint64]RateLimit
+	// Endpoints limits the HTTP requests sent to each RPC endpoint URI.
+	Endpoints map[string]RateLimit
+}
+
+// RateLimitStatus reports the usage of a chain or endpoint limit. The URI is
+// redacted like in EndpointStatus.
+type RateLimitStatus struct {
+	ChainID           uint64  `json:"chainId,omitempty"`
+	URI               string  `json:"uri,omitempty"`
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	DailyCap          uint64  `json:"dailyCap,omitempty"`
+	// UsedToday counts the requests let through since midnight UTC.
+	UsedToday uint64 `json:"usedToday"`
+	// Throttled counts the requests delayed by the limit, per priority.
+	Throttled map[string]uint64 `json:"throttled"`
+	// Rejected counts the requests refused by the daily cap.
+	Rejected uint64 `json:"rejected"`
+}
+
+// RateLimiter applies token bucket limits with daily caps to the RPC requests
+// of each chain, through Wait, and of each endpoint, through Transport.
+// Backfill requests only use the tokens and daily requests left above a
+// reserve, so they cannot starve head polling and tip indexing.
+type RateLimiter struct {
+	mu        sync.Mutex
+	cfg       RateLimitConfig
+	chains    map[uint64]*tokenBucket
+	endpoints map[string]*tokenBucket
+	now       func() time.Time
+}
+
+// NewRateLimiter returns a limiter applying cfg.
+func NewRateLimiter(cfg RateLimitConfig) (*RateLimiter, error) {
+	l := &RateLimiter{
+		chains:    make(map[uint64]*tokenBucket),
+		endpoints: make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+	if err := l.SetLimits(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLimits replaces the limits. Requests already counted today are kept.
+func (l *RateLimiter) SetLimits(cfg RateLimitConfig) error {
+	endpoints := make(map[string]RateLimit, len(cfg.Endpoints))
+	for uri, limit := range cfg.Endpoints {
+		key, err := rateLimitEndpointKey(uri)
+		if err != nil {
+			return err
+		}
+		endpoints[key] = limit
+	}
+	cfg.Endpoints = endpoints
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	for chainID, bucket := range l.chains {
+		bucket.setLimit(l.chainLimit(chainID))
+	}
+	for key, bucket := range l.endpoints {
+		bucket.setLimit(cfg.Endpoints[key])
+	}
+	return nil
+}
+
+func (l *RateLimiter) chainLimit(chainID uint64) RateLimit {
+	if limit, ok := l.cfg.Chains[chainID]; ok {
+		return limit
+	}
+	return l.cfg.Chain
+}
+
+// Wait blocks until a request on the chain is allowed by its limit, with the
+// priority of ctx. It fails with ErrDailyCapReached once the daily cap is used
+// up. A nil limiter allows every request.
+func (l *RateLimiter) Wait(ctx context.Context, chainID uint64) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	bucket, ok := l.chains[chainID]
+	if !ok {
+		limit := l.chainLimit(chainID)
+		if !limit.enabled() {
+			l.mu.Unlock()
+			return nil
+		}
+		bucket = newTokenBucket(limit, l.now)
+		l.chains[chainID] = bucket
+	}
+	l.mu.Unlock()
+	if err := bucket.wait(ctx, requestPriority(ctx)); err != nil {
+		return fmt.Errorf("chainID %d: %w", chainID, err)
+	}
+	return nil
+}
+
+// Transport returns next limited by the endpoint limits, for the RPCPool to
+// dial its endpoints with.
+func (l *RateLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	return &rateLimitedTransport{limiter: l, next: next}
+}
+
+func (l *RateLimiter) endpointBucket(u *url.URL) *tokenBucket {
+	key := endpointKey(u)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.endpoints[key]
+	if !ok {
+		limit, configured := l.cfg.Endpoints[key]
+		if !configured {
+			return nil
+		}
+		bucket = newTokenBucket(limit, l.now)
+		l.endpoints[key] = bucket
+	}
+	return bucket
+}
+
+// Status returns the usage of the chain and endpoint limits seen so far.
+func (l *RateLimiter) Status() []RateLimitStatus {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]RateLimitStatus, 0, len(l.chains)+len(l.endpoints))
+	for chainID, bucket := range l.chains {
+		status := bucket.status()
+		status.ChainID = chainID
+		out = append(out, status)
+	}
+	for key, bucket := range l.endpoints {
+		status := bucket.status()
+		status.URI = redactURI(key)
+		out = append(out, status)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].ChainID != out[b].ChainID {
+			return out[a].ChainID < out[b].ChainID
+		}
+		return out[a].URI < out[b].URI
+	})
+	return out
+}
+
+func rateLimitEndpointKey(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid rate limited rpc endpoint %q", uri)
+	}
+	return endpointKey(u), nil
+}
+
+// endpointKey identifies an endpoint by its URI without the query.
+func endpointKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// rateLimitedClient waits for the limit of its chain before each request used
+// to find start blocks.
+type rateLimitedClient struct {
+	StartBlockClient
+	limiter *RateLimiter
+	chainID uint64
+}
+
+func (c *rateLimitedClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := c.limiter.Wait(ctx, c.chainID); err != nil {
+		return nil, err
+	}
+	return c.StartBlockClient.CodeAt(ctx, account, blockNumber)
+}
+
+func (c *rateLimitedClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*gethtypes.Receipt, error) {
+	if err := c.limiter.Wait(ctx, c.chainID); err != nil {
+		return nil, err
+	}
+	return c.StartBlockClient.TransactionReceipt(ctx, txHash)
+}
+
+func (c *rateLimitedClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	if err := c.limiter.Wait(ctx, c.chainID); err != nil {
+		return nil, err
+	}
+	return c.StartBlockClient.FilterLogs(ctx, query)
+}
+
+type rateLimitedTransport struct {
+	limiter *RateLimiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if bucket := t.limiter.endpointBucket(req.URL); bucket != nil {
+		if err := bucket.wait(req.Context(), requestPriority(req.Context())); err != nil {
+			return nil, fmt.Errorf("%s: %w", redactURI(req.URL.String()), err)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// tokenBucket is a token bucket with a daily request cap.
+type tokenBucket struct {
+	mu        sync.Mutex
+	limit     RateLimit
+	tokens    float64
+	updated   time.Time
+	day       time.Time
+	used      uint64
+	throttled [len(requestPriorityNames)]uint64
+	rejected  uint64
+	now       func() time.Time
+}
+
+func newTokenBucket(limit RateLimit, now func() time.Time) *tokenBucket {
+	b := &tokenBucket{limit: limit, now: now, updated: now()}
+	b.tokens = b.burst()
+	return b
+}
+
+func (b *tokenBucket) setLimit(limit RateLimit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+	b.tokens = min(b.tokens, b.burst())
+}
+
+func (b *tokenBucket) burst() float64 {
+	if b.limit.Burst > 0 {
+		return float64(b.limit.Burst)
+	}
+	return max(1, math.Ceil(b.limit.RequestsPerSecond))
+}
+
+func (b *tokenBucket) wait(ctx context.Context, priority RequestPriority) error {
+	throttled := false
+	for {
+		delay, err := b.take(priority)
+		if err != nil || delay == 0 {
+			return err
+		}
+		if !throttled {
+			throttled = true
+			b.mu.Lock()
+			b.throttled[priority]++
+			b.mu.Unlock()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// take uses a request of the daily cap and a token when the priority allows
+// it, or returns how long to wait for the next token.
+func (b *tokenBucket) take(priority RequestPriority) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(b.day) {
+		b.day = day
+		b.used = 0
+	}
+	if dailyCap := b.limit.DailyCap; dailyCap > 0 {
+		allowed := dailyCap
+		if priority == PriorityBackfill {
+			allowed -= uint64(float64(dailyCap) * backfillReserve)
+		}
+		if b.used >= allowed {
+			b.rejected++
+			return 0, ErrDailyCapReached
+		}
+	}
+	if rate := b.limit.RequestsPerSecond; rate > 0 {
+		burst := b.burst()
+		b.tokens = min(burst, b.tokens+now.Sub(b.updated).Seconds()*rate)
+		b.updated = now
+		need := 1.0
+		if priority == PriorityBackfill {
+			need += math.Floor(burst * backfillReserve)
+		}
+		if b.tokens < need {
+			return max(time.Duration((need-b.tokens)/rate*float64(time.Second)), time.Millisecond), nil
+		}
+		b.tokens--
+	}
+	b.used++
+	return 0, nil
+}
+
+func (b *tokenBucket) status() RateLimitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := RateLimitStatus{
+		RequestsPerSecond: b.limit.RequestsPerSecond,
+		DailyCap:          b.limit.DailyCap,
+		Rejected:          b.rejected,
+		Throttled:         make(map[string]uint64, len(b.throttled)),
+	}
+	if b.day.Equal(b.now().UTC().Truncate(24 * time.Hour)) {
+		status.UsedToday = b.used
+	}
+	for p, count := range b.throttled {
+		status.Throttled[RequestPriority(p).String()] = count
+	}
+	return status
+}
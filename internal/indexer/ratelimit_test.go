@@ -0,0 +1,135 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReservesTokensForTipRequests(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(RateLimit{RequestsPerSecond: 4}, func() time.Time { return now })
+
+	// Backfill requests leave a quarter of the burst of 4 tokens.
+	for n := range 3 {
+		if delay, err := bucket.take(PriorityBackfill); err != nil || delay != 0 {
+			t.Fatalf("expected backfill request %d to pass, got delay %s err %v", n, delay, err)
+		}
+	}
+	if delay, _ := bucket.take(PriorityBackfill); delay == 0 {
+		t.Fatalf("expected the backfill request to wait for the reserve")
+	}
+	if delay, err := bucket.take(PriorityTip); err != nil || delay != 0 {
+		t.Fatalf("expected the tip request to use the reserve, got delay %s err %v", delay, err)
+	}
+	delay, _ := bucket.take(PriorityHead)
+	if delay != 250*time.Millisecond {
+		t.Fatalf("expected the head request to wait for the next token, got %s", delay)
+	}
+	now = now.Add(delay)
+	if delay, err := bucket.take(PriorityHead); err != nil || delay != 0 {
+		t.Fatalf("expected the head request to pass after the refill, got delay %s err %v", delay, err)
+	}
+}
+
+func TestTokenBucketDailyCap(t *testing.T) {
+	now := time.Date(2026, 1, 1, 23, 59, 0, 0, time.UTC)
+	bucket := newTokenBucket(RateLimit{DailyCap: 4}, func() time.Time { return now })
+	for range 3 {
+		if _, err := bucket.take(PriorityBackfill); err != nil {
+			t.Fatalf("take: %v", err)
+		}
+	}
+	if _, err := bucket.take(PriorityBackfill); !errors.Is(err, ErrDailyCapReached) {
+		t.Fatalf("expected backfill to leave the reserved requests, got %v", err)
+	}
+	if _, err := bucket.take(PriorityTip); err != nil {
+		t.Fatalf("expected the tip request to use the reserve: %v", err)
+	}
+	if _, err := bucket.take(PriorityHead); !errors.Is(err, ErrDailyCapReached) {
+		t.Fatalf("expected the daily cap to be reached, got %v", err)
+	}
+	status := bucket.status()
+	if status.UsedToday != 4 || status.Rejected != 2 {
+		t.Fatalf("expected 4 requests used and 2 rejected, got %+v", status)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := bucket.take(PriorityHead); err != nil {
+		t.Fatalf("expected the cap to reset at midnight UTC: %v", err)
+	}
+}
+
+func TestRateLimiterWaitsPerChain(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Chains: map[uint64]RateLimit{1: {RequestsPerSecond: 1000, Burst: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create rate limiter: %v", err)
+	}
+	ctx := context.Background()
+	for range 3 {
+		if err := limiter.Wait(ctx, 1); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+		if err := limiter.Wait(ctx, 2); err != nil {
+			t.Fatalf("wait on an unlimited chain: %v", err)
+		}
+	}
+	status := limiter.Status()
+	if len(status) != 1 || status[0].ChainID != 1 || status[0].UsedToday != 3 || status[0].Throttled["tip"] != 2 {
+		t.Fatalf("expected 3 requests and 2 throttled tip requests on chain 1, got %+v", status)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.Wait(canceled, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled wait, got %v", err)
+	}
+	var nilLimiter *RateLimiter
+	if err := nilLimiter.Wait(ctx, 1); err != nil {
+		t.Fatalf("expected a nil limiter to allow requests: %v", err)
+	}
+}
+
+func TestRateLimiterTransportLimitsEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Endpoints: map[string]RateLimit{server.URL + "/rpc": {DailyCap: 1}},
+	})
+	if err != nil {
+		t.Fatalf("create rate limiter: %v", err)
+	}
+	client := &http.Client{Transport: limiter.Transport(http.DefaultTransport)}
+	get := func(path string) error {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get("/rpc"); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := get("/rpc"); !errors.Is(err, ErrDailyCapReached) {
+		t.Fatalf("expected the endpoint cap to be reached, got %v", err)
+	}
+	if err := get("/other"); err != nil {
+		t.Fatalf("expected unlimited endpoints to pass: %v", err)
+	}
+	status := limiter.Status()
+	if len(status) != 1 || status[0].URI != redactURI(server.URL+"/rpc") || status[0].Rejected != 1 {
+		t.Fatalf("expected one rejection on the limited endpoint, got %+v", status)
+	}
+
+	if _, err := NewRateLimiter(RateLimitConfig{Endpoints: map[string]RateLimit{"not a url": {}}}); err == nil {
+		t.Fatalf("expected an error for an invalid endpoint")
+	}
+}
@@ -0,0 +1,558 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vocdoni/davinci-node/log"
+	"github.com/vocdoni/davinci-node/web3/rpc/chainlist"
+)
+
+// EndpointState classifies an RPC endpoint by its recent probes.
+type EndpointState string
+
+const (
+	// EndpointHealthy endpoints stay in the pool rotation.
+	EndpointHealthy EndpointState = "healthy"
+	// EndpointDegraded endpoints lag behind the chain head or fail often; they
+	// are taken out of the rotation until they recover.
+	EndpointDegraded EndpointState = "degraded"
+	// EndpointQuarantined endpoints failed repeatedly or serve another chain;
+	// they are kept out of the rotation for the quarantine period.
+	EndpointQuarantined EndpointState = "quarantined"
+)
+
+const (
+	// Endpoint sources reported in EndpointStatus.
+	EndpointSourceConfig    = "config"
+	EndpointSourceChainlist = "chainlist"
+
+	// healthSmoothing weights the latest probe in the error rate and latency averages.
+	healthSmoothing = 0.2
+	// healthLatencyBudget is the probe latency at which the score penalty is maximal.
+	healthLatencyBudget = 2 * time.Second
+)
+
+// HealthConfig configures the RPC endpoint health tracker.
+type HealthConfig struct {
+	Pool *RPCPool
+	// ProbeInterval is the time between endpoint probes.
+	ProbeInterval time.Duration
+	// ProbeTimeout bounds each endpoint probe.
+	ProbeTimeout time.Duration
+	// MaxLag is how many blocks an endpoint may trail the highest head seen on
+	// its chain before it is demoted.
+	MaxLag uint64
+	// MaxErrorRate is the smoothed probe error rate above which an endpoint is
+	// demoted.
+	MaxErrorRate float64
+	// QuarantineAfter is the number of consecutive failed probes that
+	// quarantines an endpoint for QuarantinePeriod.
+	QuarantineAfter  int
+	QuarantinePeriod time.Duration
+	// MinHealthy is the number of healthy endpoints per chain below which
+	// chainlist endpoints are fetched again, at most once per RefreshInterval.
+	MinHealthy          int
+	RefreshInterval     time.Duration
+	AutoRPC             bool
+	AutoRPCMaxEndpoints int
+}
+
+// EndpointStatus is the health report of an RPC endpoint. The URI is redacted
+// to its scheme and host since paths and queries often embed API keys.
+type EndpointStatus struct {
+	ChainID             uint64        `json:"chainId"`
+	URI                 string        `json:"uri"`
+	Source              string        `json:"source"`
+	State               EndpointState `json:"state"`
+	Score               float64       `json:"score"`
+	Head                uint64        `json:"head"`
+	Lag                 uint64        `json:"lag"`
+	LatencyMs           int64         `json:"latencyMs"`
+	ErrorRate           float64       `json:"errorRate"`
+	Probes              uint64        `json:"probes"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	LastError           string        `json:"lastError,omitempty"`
+	LastProbeAt         *time.Time    `json:"lastProbeAt,omitempty"`
+	QuarantinedUntil    *time.Time    `json:"quarantinedUntil,omitempty"`
+}
+
+// endpointProber is the subset of the RPC API used to probe an endpoint.
+type endpointProber interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error)
+	Close()
+}
+
+type endpointHealth struct {
+	chainID          uint64
+	uri              string
+	source           string
+	client           endpointProber
+	state            EndpointState
+	head             uint64
+	lag              uint64
+	latency          time.Duration
+	errorRate        float64
+	probes           uint64
+	failures         int
+	lastErr          string
+	lastProbe        time.Time
+	quarantinedUntil time.Time
+}
+
+type probeResult struct {
+	head    uint64
+	latency time.Duration
+	err     error
+	// wrongChain is set when the endpoint answered with another chain ID.
+	wrongChain bool
+}
+
+// HealthTracker verifies the chain of RPC endpoints before adding them to the
+// pool, probes them periodically and takes lagging or failing endpoints out of
+// the pool rotation.
+type HealthTracker struct {
+	cfg       HealthConfig
+	dial      func(ctx context.Context, uri string) (endpointProber, error)
+	chainlist func(chainID uint64, maxEndpoints int) ([]string, error)
+	addPool   func(uri string) (uint64, error)
+	disable   func(chainID uint64, uri string)
+	// addMu serializes endpoint additions; the pool is not safe for
+	// concurrent additions.
+	addMu       sync.Mutex
+	mu          sync.Mutex
+	endpoints   map[string]*endpointHealth
+	refreshedAt map[uint64]time.Time
+}
+
+// NewHealthTracker returns a tracker for the endpoints of cfg.Pool.
+func NewHealthTracker(cfg HealthConfig) (*HealthTracker, error) {
+	if cfg.Pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 30 * time.Second
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = 10 * time.Second
+	}
+	if cfg.MaxLag == 0 {
+		cfg.MaxLag = 50
+	}
+	if cfg.MaxErrorRate <= 0 {
+		cfg.MaxErrorRate = 0.5
+	}
+	if cfg.QuarantineAfter <= 0 {
+		cfg.QuarantineAfter = 3
+	}
+	if cfg.QuarantinePeriod <= 0 {
+		cfg.QuarantinePeriod = 10 * time.Minute
+	}
+	if cfg.MinHealthy <= 0 {
+		cfg.MinHealthy = 1
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 10 * time.Minute
+	}
+	if cfg.AutoRPCMaxEndpoints <= 0 {
+		cfg.AutoRPCMaxEndpoints = 3
+	}
+	return &HealthTracker{
+		cfg: cfg,
+		dial: func(ctx context.Context, uri string) (endpointProber, error) {
+			client, err := cfg.Pool.dial(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+		chainlist:   chainlistEndpoints,
+		addPool:     cfg.Pool.AddEndpoint,
+		disable:     cfg.Pool.DisableEndpoint,
+		endpoints:   make(map[string]*endpointHealth),
+		refreshedAt: make(map[uint64]time.Time),
+	}, nil
+}
+
+// AddEndpoint checks the chain ID served by uri and adds it to the pool. A
+// non-zero chainID must match the served one. Endpoints already tracked are
+// not added twice. It returns the chain ID served by the endpoint.
+func (h *HealthTracker) AddEndpoint(ctx context.Context, uri string, chainID uint64) (uint64, error) {
+	return h.addEndpoint(ctx, uri, chainID, EndpointSourceConfig)
+}
+
+func (h *HealthTracker) addEndpoint(ctx context.Context, uri string, chainID uint64, source string) (uint64, error) {
+	h.addMu.Lock()
+	defer h.addMu.Unlock()
+	h.mu.Lock()
+	if known, ok := h.endpoints[uri]; ok {
+		h.mu.Unlock()
+		if chainID != 0 && known.chainID != chainID {
+			return 0, fmt.Errorf("RPC endpoint %s serves chainID %d, configured for chainID %d", uri, known.chainID, chainID)
+		}
+		return known.chainID, nil
+	}
+	h.mu.Unlock()
+
+	probeCtx, cancel := context.WithTimeout(ctx, h.cfg.ProbeTimeout)
+	defer cancel()
+	client, err := h.dial(probeCtx, uri)
+	if err != nil {
+		return 0, fmt.Errorf("dial RPC endpoint %s: %w", uri, err)
+	}
+	served, err := client.ChainID(probeCtx)
+	if err != nil {
+		client.Close()
+		return 0, fmt.Errorf("get chainID of RPC endpoint %s: %w", uri, err)
+	}
+	if chainID != 0 && served.Uint64() != chainID {
+		client.Close()
+		return 0, fmt.Errorf("RPC endpoint %s serves chainID %d, configured for chainID %d", uri, served.Uint64(), chainID)
+	}
+	chainID = served.Uint64()
+
+	poolChainID, err := h.addPool(uri)
+	if err != nil {
+		client.Close()
+		return 0, fmt.Errorf("add RPC endpoint %s: %w", uri, err)
+	}
+	if poolChainID != chainID {
+		client.Close()
+		h.disable(poolChainID, uri)
+		return 0, fmt.Errorf("RPC endpoint %s changed chainID from %d to %d", uri, chainID, poolChainID)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endpoints[uri] = &endpointHealth{
+		chainID: chainID,
+		uri:     uri,
+		source:  source,
+		client:  client,
+		state:   EndpointHealthy,
+	}
+	return chainID, nil
+}
+
+// AddChainlistEndpoints adds up to AutoRPCMaxEndpoints chainlist endpoints
+// serving chainID and returns how many were added.
+func (h *HealthTracker) AddChainlistEndpoints(ctx context.Context, chainID uint64) (int, error) {
+	h.mu.Lock()
+	h.refreshedAt[chainID] = time.Now()
+	h.mu.Unlock()
+	uris, err := h.chainlist(chainID, h.cfg.AutoRPCMaxEndpoints)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, uri := range uris {
+		h.mu.Lock()
+		_, known := h.endpoints[uri]
+		h.mu.Unlock()
+		if known {
+			continue
+		}
+		if _, err := h.addEndpoint(ctx, uri, chainID, EndpointSourceChainlist); err != nil {
+			log.Debugw("skipping chainlist endpoint", "chainID", chainID, "err", err)
+			continue
+		}
+		added++
+	}
+	if added == 0 && h.HealthyEndpoints(chainID) == 0 {
+		return 0, fmt.Errorf("failed to add endpoints for chainID %d", chainID)
+	}
+	return added, nil
+}
+
+// HealthyEndpoints returns the number of healthy endpoints tracked for chainID.
+func (h *HealthTracker) HealthyEndpoints(chainID uint64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy := 0
+	for _, endpoint := range h.endpoints {
+		if endpoint.chainID == chainID && endpoint.state == EndpointHealthy {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// Run probes the tracked endpoints every ProbeInterval until the context is
+// canceled.
+func (h *HealthTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.ProbeInterval)
+	defer ticker.Stop()
+	defer h.close()
+	for {
+		h.Probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthTracker) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, endpoint := range h.endpoints {
+		endpoint.client.Close()
+	}
+}
+
+// Probe checks every tracked endpoint once, updates their state and, with
+// AutoRPC, fetches chainlist endpoints for chains short of healthy ones.
+func (h *HealthTracker) Probe(ctx context.Context) {
+	h.mu.Lock()
+	targets := make([]*endpointHealth, 0, len(h.endpoints))
+	for _, endpoint := range h.endpoints {
+		targets = append(targets, endpoint)
+	}
+	h.mu.Unlock()
+
+	results := make([]probeResult, len(targets))
+	var wg sync.WaitGroup
+	for i, endpoint := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.probe(ctx, endpoint.client, endpoint.chainID)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	var disabled []*endpointHealth
+	h.mu.Lock()
+	heads := make(map[uint64]uint64)
+	for i, endpoint := range targets {
+		if results[i].err == nil {
+			heads[endpoint.chainID] = max(heads[endpoint.chainID], results[i].head)
+		}
+	}
+	chains := make(map[uint64]struct{})
+	for i, endpoint := range targets {
+		chains[endpoint.chainID] = struct{}{}
+		previous := endpoint.state
+		h.record(endpoint, results[i], heads[endpoint.chainID], now)
+		if endpoint.state != previous {
+			log.Infow("rpc endpoint state changed",
+				"chainID", endpoint.chainID,
+				"uri", redactURI(endpoint.uri),
+				"from", previous,
+				"to", endpoint.state,
+				"lag", endpoint.lag,
+				"errorRate", endpoint.errorRate,
+				"err", endpoint.lastErr,
+			)
+		}
+		if endpoint.state != EndpointHealthy {
+			disabled = append(disabled, endpoint)
+		}
+	}
+	h.mu.Unlock()
+
+	// The pool re-enables disabled endpoints after a cooldown, so unhealthy
+	// ones are disabled again on every probe.
+	for _, endpoint := range disabled {
+		h.disable(endpoint.chainID, endpoint.uri)
+	}
+	if !h.cfg.AutoRPC {
+		return
+	}
+	for chainID := range chains {
+		if h.HealthyEndpoints(chainID) >= h.cfg.MinHealthy {
+			continue
+		}
+		h.mu.Lock()
+		due := now.Sub(h.refreshedAt[chainID]) >= h.cfg.RefreshInterval
+		h.mu.Unlock()
+		if !due {
+			continue
+		}
+		added, err := h.AddChainlistEndpoints(ctx, chainID)
+		if err != nil {
+			log.Warnw("refresh chainlist endpoints", "chainID", chainID, "err", err)
+			continue
+		}
+		log.Infow("refreshed chainlist endpoints", "chainID", chainID, "added", added)
+	}
+}
+
+// probe checks that the endpoint serves chainID, fetches its head and runs a
+// single-block eth_getLogs query.
+func (h *HealthTracker) probe(ctx context.Context, client endpointProber, chainID uint64) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.ProbeTimeout)
+	defer cancel()
+	served, err := client.ChainID(ctx)
+	if err != nil {
+		return probeResult{err: fmt.Errorf("eth_chainId: %w", err)}
+	}
+	if served.Uint64() != chainID {
+		return probeResult{err: fmt.Errorf("serves chainID %d", served.Uint64()), wrongChain: true}
+	}
+	start := time.Now()
+	head, err := client.BlockNumber(ctx)
+	latency := time.Since(start)
+	if err != nil {
+		return probeResult{latency: latency, err: fmt.Errorf("eth_blockNumber: %w", err)}
+	}
+	block := new(big.Int).SetUint64(head)
+	if _, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: block,
+		ToBlock:   block,
+		Addresses: []common.Address{{}},
+	}); err != nil {
+		return probeResult{head: head, latency: latency, err: fmt.Errorf("eth_getLogs: %w", err)}
+	}
+	return probeResult{head: head, latency: latency}
+}
+
+// record applies a probe result to the endpoint. chainHead is the highest
+// head reported on the chain in this round. Must be called with mu held.
+func (h *HealthTracker) record(endpoint *endpointHealth, result probeResult, chainHead uint64, now time.Time) {
+	endpoint.probes++
+	endpoint.lastProbe = now
+	failed := 0.0
+	if result.err != nil {
+		failed = 1
+		endpoint.failures++
+		endpoint.lastErr = result.err.Error()
+	} else {
+		endpoint.failures = 0
+		endpoint.head = result.head
+		endpoint.lag = chainHead - result.head
+	}
+	if endpoint.probes == 1 {
+		endpoint.errorRate = failed
+	} else {
+		endpoint.errorRate = (1-healthSmoothing)*endpoint.errorRate + healthSmoothing*failed
+	}
+	if result.latency > 0 {
+		if endpoint.latency == 0 {
+			endpoint.latency = result.latency
+		} else {
+			endpoint.latency = time.Duration((1-healthSmoothing)*float64(endpoint.latency) + healthSmoothing*float64(result.latency))
+		}
+	}
+
+	switch {
+	case result.wrongChain || endpoint.failures >= h.cfg.QuarantineAfter:
+		endpoint.state = EndpointQuarantined
+		endpoint.quarantinedUntil = now.Add(h.cfg.QuarantinePeriod)
+	case endpoint.state == EndpointQuarantined && now.Before(endpoint.quarantinedUntil):
+	case endpoint.lag > h.cfg.MaxLag || endpoint.errorRate > h.cfg.MaxErrorRate:
+		endpoint.state = EndpointDegraded
+		endpoint.quarantinedUntil = time.Time{}
+	default:
+		endpoint.state = EndpointHealthy
+		endpoint.quarantinedUntil = time.Time{}
+	}
+}
+
+// score rates an endpoint from 0 to 100 by error rate, lag and latency.
+func (h *HealthTracker) score(endpoint *endpointHealth) float64 {
+	if endpoint.state == EndpointQuarantined {
+		return 0
+	}
+	score := 100 * (1 - endpoint.errorRate)
+	score -= 30 * math.Min(float64(endpoint.lag)/float64(h.cfg.MaxLag), 1)
+	score -= 20 * math.Min(float64(endpoint.latency)/float64(healthLatencyBudget), 1)
+	return math.Round(math.Max(score, 0)*10) / 10
+}
+
+// Status returns the health of every tracked endpoint ordered by chain ID and
+// score.
+func (h *HealthTracker) Status() []EndpointStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]EndpointStatus, 0, len(h.endpoints))
+	for _, endpoint := range h.endpoints {
+		status := EndpointStatus{
+			ChainID:             endpoint.chainID,
+			URI:                 redactURI(endpoint.uri),
+			Source:              endpoint.source,
+			State:               endpoint.state,
+			Score:               h.score(endpoint),
+			Head:                endpoint.head,
+			Lag:                 endpoint.lag,
+			LatencyMs:           endpoint.latency.Milliseconds(),
+			ErrorRate:           math.Round(endpoint.errorRate*1000) / 1000,
+			Probes:              endpoint.probes,
+			ConsecutiveFailures: endpoint.failures,
+			LastError:           endpoint.lastErr,
+		}
+		if !endpoint.lastProbe.IsZero() {
+			lastProbe := endpoint.lastProbe.UTC()
+			status.LastProbeAt = &lastProbe
+		}
+		if endpoint.state == EndpointQuarantined {
+			until := endpoint.quarantinedUntil.UTC()
+			status.QuarantinedUntil = &until
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ChainID != out[j].ChainID {
+			return out[i].ChainID < out[j].ChainID
+		}
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].URI < out[j].URI
+	})
+	return out
+}
+
+// redactURI keeps the scheme and host of an endpoint URI.
+func redactURI(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" {
+		return "redacted"
+	}
+	redacted := parsed.Scheme + "://" + parsed.Host
+	if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" {
+		redacted += "/..."
+	}
+	return redacted
+}
+
+func chainlistEndpoints(chainID uint64, maxEndpoints int) ([]string, error) {
+	chainMap, err := chainlist.ChainList()
+	if err != nil {
+		return nil, fmt.Errorf("load chainlist: %w", err)
+	}
+	var shortName string
+	for name, id := range chainMap {
+		if id == chainID {
+			shortName = name
+			break
+		}
+	}
+	if shortName == "" {
+		return nil, fmt.Errorf("chainID %d not found in chainlist", chainID)
+	}
+	endpoints, err := chainlist.EndpointList(shortName, maxEndpoints)
+	if err != nil {
+		return nil, fmt.Errorf("chainlist endpoints: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no healthy endpoints found for chainID %d", chainID)
+	}
+	return endpoints, nil
+}
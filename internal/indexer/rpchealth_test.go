@@ -0,0 +1,214 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+type fakeEndpoint struct {
+	mu      sync.Mutex
+	chainID uint64
+	head    uint64
+	err     error
+}
+
+func (f *fakeEndpoint) set(head uint64, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.head, f.err = head, err
+}
+
+func (f *fakeEndpoint) ChainID(context.Context) (*big.Int, error) {
+	return new(big.Int).SetUint64(f.chainID), nil
+}
+
+func (f *fakeEndpoint) BlockNumber(context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head, f.err
+}
+
+func (f *fakeEndpoint) FilterLogs(context.Context, ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	return nil, nil
+}
+
+func (f *fakeEndpoint) Close() {}
+
+type fakeHealthPool struct {
+	mu       sync.Mutex
+	added    []string
+	disabled map[string]int
+}
+
+func newTestHealthTracker(t *testing.T, cfg HealthConfig, endpoints map[string]*fakeEndpoint) (*HealthTracker, *fakeHealthPool) {
+	t.Helper()
+	cfg.Pool = NewRPCPool(nil)
+	tracker, err := NewHealthTracker(cfg)
+	if err != nil {
+		t.Fatalf("create health tracker: %v", err)
+	}
+	pool := &fakeHealthPool{disabled: make(map[string]int)}
+	tracker.dial = func(_ context.Context, uri string) (endpointProber, error) {
+		endpoint, ok := endpoints[uri]
+		if !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return endpoint, nil
+	}
+	tracker.addPool = func(uri string) (uint64, error) {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		pool.added = append(pool.added, uri)
+		return endpoints[uri].chainID, nil
+	}
+	tracker.disable = func(_ uint64, uri string) {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		pool.disabled[uri]++
+	}
+	return tracker, pool
+}
+
+func statusByURI(tracker *HealthTracker) map[string]EndpointStatus {
+	out := make(map[string]EndpointStatus)
+	for _, status := range tracker.Status() {
+		out[status.URI] = status
+	}
+	return out
+}
+
+func TestHealthTrackerVerifiesChainID(t *testing.T) {
+	ctx := context.Background()
+	endpoints := map[string]*fakeEndpoint{
+		"https://mainnet.example.org": {chainID: 1},
+		"https://sepolia.example.org": {chainID: 11155111},
+	}
+	tracker, pool := newTestHealthTracker(t, HealthConfig{}, endpoints)
+
+	if chainID, err := tracker.AddEndpoint(ctx, "https://mainnet.example.org", 1); err != nil || chainID != 1 {
+		t.Fatalf("expected endpoint for chainID 1, got %d (err=%v)", chainID, err)
+	}
+	if _, err := tracker.AddEndpoint(ctx, "https://sepolia.example.org", 1); err == nil || !strings.Contains(err.Error(), "serves chainID 11155111") {
+		t.Fatalf("expected a chainID mismatch error, got %v", err)
+	}
+	if chainID, err := tracker.AddEndpoint(ctx, "https://sepolia.example.org", 0); err != nil || chainID != 11155111 {
+		t.Fatalf("expected a global endpoint to detect chainID 11155111, got %d (err=%v)", chainID, err)
+	}
+	if _, err := tracker.AddEndpoint(ctx, "https://mainnet.example.org", 0); err != nil {
+		t.Fatalf("add known endpoint: %v", err)
+	}
+	if len(pool.added) != 2 {
+		t.Fatalf("expected 2 endpoints added to the pool, got %v", pool.added)
+	}
+}
+
+func TestHealthTrackerDemotesAndQuarantines(t *testing.T) {
+	ctx := context.Background()
+	fast := &fakeEndpoint{chainID: 1, head: 1000}
+	lagging := &fakeEndpoint{chainID: 1, head: 900}
+	failing := &fakeEndpoint{chainID: 1, head: 1000}
+	endpoints := map[string]*fakeEndpoint{
+		"https://fast.example.org":    fast,
+		"https://lagging.example.org": lagging,
+		"https://failing.example.org": failing,
+	}
+	tracker, pool := newTestHealthTracker(t, HealthConfig{MaxLag: 10, QuarantineAfter: 2, QuarantinePeriod: time.Hour}, endpoints)
+	for uri := range endpoints {
+		if _, err := tracker.AddEndpoint(ctx, uri, 1); err != nil {
+			t.Fatalf("add endpoint %s: %v", uri, err)
+		}
+	}
+
+	failing.set(0, fmt.Errorf("timeout"))
+	tracker.Probe(ctx)
+	statuses := statusByURI(tracker)
+	if status := statuses["https://fast.example.org"]; status.State != EndpointHealthy || status.Head != 1000 || status.Score <= 0 {
+		t.Fatalf("expected the fast endpoint to be healthy, got %+v", status)
+	}
+	if status := statuses["https://lagging.example.org"]; status.State != EndpointDegraded || status.Lag != 100 {
+		t.Fatalf("expected the lagging endpoint to be degraded with lag 100, got %+v", status)
+	}
+	if pool.disabled["https://lagging.example.org"] != 1 {
+		t.Fatalf("expected the lagging endpoint to be disabled, got %v", pool.disabled)
+	}
+
+	tracker.Probe(ctx)
+	status := statusByURI(tracker)["https://failing.example.org"]
+	if status.State != EndpointQuarantined || status.ConsecutiveFailures != 2 || status.QuarantinedUntil == nil || status.Score != 0 {
+		t.Fatalf("expected the failing endpoint to be quarantined, got %+v", status)
+	}
+
+	// A recovered endpoint stays quarantined until the period ends.
+	failing.set(1000, nil)
+	lagging.set(1000, nil)
+	tracker.Probe(ctx)
+	statuses = statusByURI(tracker)
+	if statuses["https://failing.example.org"].State != EndpointQuarantined {
+		t.Fatalf("expected the endpoint to stay quarantined, got %+v", statuses["https://failing.example.org"])
+	}
+	if statuses["https://lagging.example.org"].State != EndpointHealthy {
+		t.Fatalf("expected the caught up endpoint to be healthy, got %+v", statuses["https://lagging.example.org"])
+	}
+	if tracker.HealthyEndpoints(1) != 2 {
+		t.Fatalf("expected 2 healthy endpoints, got %d", tracker.HealthyEndpoints(1))
+	}
+}
+
+func TestHealthTrackerRefreshesChainlistEndpoints(t *testing.T) {
+	ctx := context.Background()
+	configured := &fakeEndpoint{chainID: 1, head: 1000}
+	endpoints := map[string]*fakeEndpoint{
+		"https://configured.example.org":  configured,
+		"https://public.example.org":      {chainID: 1, head: 1000},
+		"https://other-chain.example.org": {chainID: 5, head: 1000},
+	}
+	tracker, _ := newTestHealthTracker(t, HealthConfig{AutoRPC: true, MinHealthy: 2, QuarantineAfter: 1}, endpoints)
+	var lookups int
+	tracker.chainlist = func(chainID uint64, _ int) ([]string, error) {
+		lookups++
+		return []string{"https://other-chain.example.org", "https://public.example.org"}, nil
+	}
+	if _, err := tracker.AddEndpoint(ctx, "https://configured.example.org", 1); err != nil {
+		t.Fatalf("add endpoint: %v", err)
+	}
+
+	configured.set(0, fmt.Errorf("timeout"))
+	tracker.Probe(ctx)
+	if lookups != 1 {
+		t.Fatalf("expected 1 chainlist lookup, got %d", lookups)
+	}
+	statuses := statusByURI(tracker)
+	if status, ok := statuses["https://public.example.org"]; !ok || status.Source != EndpointSourceChainlist {
+		t.Fatalf("expected the chainlist endpoint to be tracked, got %+v", statuses)
+	}
+	if _, ok := statuses["https://other-chain.example.org"]; ok {
+		t.Fatalf("expected the endpoint serving another chain to be rejected")
+	}
+
+	// Refreshes are rate limited per chain.
+	tracker.Probe(ctx)
+	if lookups != 1 {
+		t.Fatalf("expected no new chainlist lookup, got %d", lookups)
+	}
+}
+
+func TestRedactURI(t *testing.T) {
+	for uri, want := range map[string]string{
+		"https://mainnet.infura.io/v3/secret": "https://mainnet.infura.io/...",
+		"wss://rpc.example.org":               "wss://rpc.example.org",
+		"https://rpc.example.org/?key=secret": "https://rpc.example.org/...",
+		"not a url":                           "redacted",
+	} {
+		if got := redactURI(uri); got != want {
+			t.Fatalf("expected %q for %q, got %q", want, uri, got)
+		}
+	}
+}
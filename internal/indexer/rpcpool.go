@@ -0,0 +1,255 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/vocdoni/davinci-node/log"
+	"github.com/vocdoni/davinci-node/web3/rpc"
+)
+
+const (
+	// rpcDialTimeout bounds the chain ID request sent when an endpoint is added.
+	rpcDialTimeout = 10 * time.Second
+	// rpcCallTimeout and rpcFilterLogsTimeout bound each RPC call attempt.
+	rpcCallTimeout       = 3 * time.Second
+	rpcFilterLogsTimeout = 5 * time.Second
+	// rpcRetries is the number of attempts of a call on an endpoint before the
+	// endpoint is disabled and the call moves to the next one.
+	rpcRetries    = 2
+	rpcRetrySleep = 200 * time.Millisecond
+)
+
+// RPCPool balances the RPC calls of each chain between its endpoints. Calls
+// rotate over the available endpoints, and failing endpoints are disabled for
+// a cooldown. Endpoints are dialed with the HTTP transport of the pool.
+type RPCPool struct {
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	endpoints map[uint64]*rpc.Web3Iterator
+	clients   map[string]*ethclient.Client
+}
+
+// NewRPCPool returns an empty pool whose endpoints send their requests with
+// transport, or with http.DefaultTransport when transport is nil.
+func NewRPCPool(transport http.RoundTripper) *RPCPool {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &RPCPool{
+		httpClient: &http.Client{Transport: transport},
+		endpoints:  make(map[uint64]*rpc.Web3Iterator),
+		clients:    make(map[string]*ethclient.Client),
+	}
+}
+
+// dial returns a client for uri that sends its requests with the transport of
+// the pool.
+func (p *RPCPool) dial(ctx context.Context, uri string) (*ethclient.Client, error) {
+	client, err := gethrpc.DialOptions(ctx, uri, gethrpc.WithHTTPClient(p.httpClient))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// AddEndpoint dials uri and adds it to the endpoints of the chain it serves,
+// which is returned.
+func (p *RPCPool) AddEndpoint(uri string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcDialTimeout)
+	defer cancel()
+	client, err := p.dial(ctx, uri)
+	if err != nil {
+		return 0, fmt.Errorf("dial RPC endpoint %s: %w", uri, err)
+	}
+	served, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return 0, fmt.Errorf("get chainID of RPC endpoint %s: %w", uri, err)
+	}
+	chainID := served.Uint64()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if previous, ok := p.clients[uri]; ok {
+		previous.Close()
+	} else if endpoints, ok := p.endpoints[chainID]; ok {
+		endpoints.Add(&rpc.Web3Endpoint{ChainID: chainID, URI: uri})
+	} else {
+		p.endpoints[chainID] = rpc.NewWeb3Iterator(&rpc.Web3Endpoint{ChainID: chainID, URI: uri})
+	}
+	p.clients[uri] = client
+	return chainID, nil
+}
+
+// DisableEndpoint disables uri on the chain until its cooldown expires. When
+// every endpoint of the chain is disabled, they are all enabled again.
+func (p *RPCPool) DisableEndpoint(chainID uint64, uri string) {
+	endpoints := p.iterator(chainID)
+	if endpoints == nil {
+		return
+	}
+	before := endpoints.Available()
+	endpoints.Disable(uri)
+	if after := endpoints.Available(); after < before {
+		log.Warnw("endpoint disabled", "chainID", chainID, "uri", uri,
+			"availableEndpoints", after, "disabledEndpoints", endpoints.Disabled())
+	} else if after > before {
+		log.Infow("all endpoints were disabled, reset to available", "chainID", chainID, "resetEndpoints", after)
+	}
+}
+
+// NumberOfEndpoints returns the number of endpoints of the chain, only the
+// available ones when onlyAvailable is set.
+func (p *RPCPool) NumberOfEndpoints(chainID uint64, onlyAvailable bool) int {
+	endpoints := p.iterator(chainID)
+	if endpoints == nil {
+		return 0
+	}
+	n := endpoints.Available()
+	if !onlyAvailable {
+		n += endpoints.Disabled()
+	}
+	return n
+}
+
+// Client returns a client of the chain that balances its calls between the
+// endpoints of the chain.
+func (p *RPCPool) Client(chainID uint64) (*RPCClient, error) {
+	if p.NumberOfEndpoints(chainID, false) == 0 {
+		return nil, fmt.Errorf("no endpoint found for chainID %d", chainID)
+	}
+	return &RPCClient{pool: p, chainID: chainID}, nil
+}
+
+func (p *RPCPool) iterator(chainID uint64) *rpc.Web3Iterator {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.endpoints[chainID]
+}
+
+// next returns the next endpoint of the chain with its client.
+func (p *RPCPool) next(chainID uint64) (string, *ethclient.Client, error) {
+	endpoints := p.iterator(chainID)
+	if endpoints == nil {
+		return "", nil, fmt.Errorf("no endpoint found for chainID %d", chainID)
+	}
+	endpoint, err := endpoints.Next()
+	if err != nil {
+		return "", nil, fmt.Errorf("get endpoint for chainID %d: %w", chainID, err)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return endpoint.URI, p.clients[endpoint.URI], nil
+}
+
+// RPCClient sends the RPC calls of a chain to the endpoints of an RPCPool.
+// Failed calls are retried on the same endpoint and then on the next ones.
+type RPCClient struct {
+	pool    *RPCPool
+	chainID uint64
+}
+
+// EthClient returns the client of the next endpoint of the chain, for calls
+// the RPCClient does not wrap.
+func (c *RPCClient) EthClient() (*ethclient.Client, error) {
+	_, client, err := c.pool.next(c.chainID)
+	return client, err
+}
+
+// BlockNumber returns the head block of the chain.
+func (c *RPCClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return callSwitchingEndpoints(ctx, c, rpcCallTimeout, func(ctx context.Context, client *ethclient.Client) (uint64, error) {
+		return client.BlockNumber(ctx)
+	})
+}
+
+// CodeAt returns the bytecode of account at blockNumber.
+func (c *RPCClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return callSwitchingEndpoints(ctx, c, rpcCallTimeout, func(ctx context.Context, client *ethclient.Client) ([]byte, error) {
+		return client.CodeAt(ctx, account, blockNumber)
+	})
+}
+
+// CallContract executes call at blockNumber.
+func (c *RPCClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return callSwitchingEndpoints(ctx, c, rpcCallTimeout, func(ctx context.Context, client *ethclient.Client) ([]byte, error) {
+		return client.CallContract(ctx, call, blockNumber)
+	})
+}
+
+// FilterLogs returns the logs matching query.
+func (c *RPCClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	return callSwitchingEndpoints(ctx, c, rpcFilterLogsTimeout, func(ctx context.Context, client *ethclient.Client) ([]gethtypes.Log, error) {
+		return client.FilterLogs(ctx, query)
+	})
+}
+
+// TransactionReceipt returns the receipt of txHash.
+func (c *RPCClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*gethtypes.Receipt, error) {
+	return callSwitchingEndpoints(ctx, c, rpcCallTimeout, func(ctx context.Context, client *ethclient.Client) (*gethtypes.Receipt, error) {
+		return client.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// callSwitchingEndpoints runs fn on the endpoints of the chain in turn until
+// it succeeds. Each endpoint is tried rpcRetries times before it is disabled.
+// Reverted calls and cancelled contexts are not retried.
+func callSwitchingEndpoints[T any](ctx context.Context, c *RPCClient, timeout time.Duration,
+	fn func(context.Context, *ethclient.Client) (T, error),
+) (T, error) {
+	var zero T
+	total := c.pool.NumberOfEndpoints(c.chainID, false)
+	if total == 0 {
+		return zero, fmt.Errorf("no endpoints available for chainID %d", c.chainID)
+	}
+	tried := make(map[string]bool, total)
+	var lastErr error
+	for range total {
+		uri, client, err := c.pool.next(c.chainID)
+		if err != nil {
+			return zero, err
+		}
+		if tried[uri] {
+			break
+		}
+		tried[uri] = true
+		for attempt := range rpcRetries {
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			res, err := fn(callCtx, client)
+			cancel()
+			if err == nil {
+				return res, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				return zero, ctx.Err()
+			}
+			if rpc.IsPermanentTxError(err) {
+				return zero, err
+			}
+			if rpc.IsPermanentRPCError(err) || errors.Is(err, ErrDailyCapReached) || attempt == rpcRetries-1 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return zero, ctx.Err()
+			case <-time.After(rpcRetrySleep):
+			}
+		}
+		log.Warnw("endpoint failed, switching to next", "chainID", c.chainID, "uri", uri, "error", lastErr)
+		c.pool.DisableEndpoint(c.chainID, uri)
+	}
+	return zero, fmt.Errorf("all endpoints failed for chainID %d: %w", c.chainID, lastErr)
+}
@@ -0,0 +1,80 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newJSONRPCServer(t *testing.T, failing *atomic.Bool, head string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result := head
+		if req.Method == "eth_chainId" {
+			result = "0x1"
+		} else if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRPCPoolSwitchesEndpoints(t *testing.T) {
+	var failing, healthy atomic.Bool
+	first := newJSONRPCServer(t, &failing, "0x10")
+	second := newJSONRPCServer(t, &healthy, "0x20")
+	transport := &countingTransport{}
+	pool := NewRPCPool(transport)
+	for _, uri := range []string{first.URL, second.URL} {
+		if chainID, err := pool.AddEndpoint(uri); err != nil || chainID != 1 {
+			t.Fatalf("expected chainID 1, got %d %v", chainID, err)
+		}
+	}
+	if transport.requests.Load() != 2 {
+		t.Fatalf("expected the endpoints to be dialed with the pool transport, got %d requests", transport.requests.Load())
+	}
+	client, err := pool.Client(1)
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	if head, err := client.BlockNumber(context.Background()); err != nil || head != 0x10 {
+		t.Fatalf("expected head 16 from the first endpoint, got %d %v", head, err)
+	}
+
+	failing.Store(true)
+	for range 2 {
+		if head, err := client.BlockNumber(context.Background()); err != nil || head != 0x20 {
+			t.Fatalf("expected head 32 from the second endpoint, got %d %v", head, err)
+		}
+	}
+	if available := pool.NumberOfEndpoints(1, true); available != 1 {
+		t.Fatalf("expected the failing endpoint to be disabled, got %d available", available)
+	}
+	if _, err := pool.Client(2); err == nil {
+		t.Fatalf("expected an error for a chain without endpoints")
+	}
+}
@@ -11,16 +11,14 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/davinci-node/log"
-	"github.com/vocdoni/davinci-node/web3/rpc"
-	"github.com/vocdoni/davinci-node/web3/rpc/chainlist"
 
 	"github.com/vocdoni/onchain-census-indexer/internal/store"
 )
 
 // ServiceConfig configures the indexer service.
 type ServiceConfig struct {
-	Pool                 *rpc.Web3Pool
-	Store                *store.Store
+	Pool                 *RPCPool
+	Store                store.Backend
 	PollInterval         time.Duration
 	BatchSize            uint64
 	VerifyBatchSize      uint64
@@ -29,6 +27,54 @@ type ServiceConfig struct {
 	ContractSyncInterval time.Duration
 	AutoRPC              bool
 	AutoRPCMaxEndpoints  int
+	// ArchiveGracePeriod is how long expired contracts stay archived before
+	// they are deleted. Zero deletes them as soon as they expire.
+	ArchiveGracePeriod time.Duration
+	// ExpiryWarning is how long before expiresAt a contract is reported as
+	// expiring soon and its expiry policy is evaluated.
+	ExpiryWarning time.Duration
+	// Chains overrides indexing settings per chain ID.
+	Chains map[uint64]ChainSettings
+	// MaxBackoff caps the delay between indexer retries after RPC errors.
+	MaxBackoff time.Duration
+	// BreakerThreshold is the number of consecutive RPC failures on a chain,
+	// across its indexers, that opens its circuit for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RestartThreshold is the number of consecutive crashes after which an
+	// indexer is marked failed. Crashed indexers are restarted after a delay
+	// that doubles from ContractSyncInterval up to MaxRestartDelay.
+	RestartThreshold int
+	MaxRestartDelay  time.Duration
+	// AuditInterval, AuditSample and AuditReindex configure the balance
+	// audits of transfer profile contracts; see Config.
+	AuditInterval time.Duration
+	AuditSample   int
+	AuditReindex  bool
+	// PauseOnUpgrade pauses indexers when the EIP-1967 implementation of
+	// their contract changes, until the upgrade is acknowledged.
+	PauseOnUpgrade bool
+	// BackfillWorkers and BackfillSegmentSize configure the parallel backfill
+	// of contracts far behind the safe head; see Config. BackfillChainLimit
+	// caps the backfill requests in flight per chain across its indexers;
+	// zero does not limit them.
+	BackfillWorkers     int
+	BackfillSegmentSize uint64
+	BackfillChainLimit  int
+	// RateLimiter limits the RPC requests of indexers and start block
+	// searches; nil does not limit them.
+	RateLimiter *RateLimiter
+	// Health adds chainlist endpoints when AutoRPC is set. A tracker for Pool
+	// is created when nil.
+	Health *HealthTracker
+}
+
+// ChainSettings overrides the service-wide indexing settings for one chain.
+// Zero values keep the service defaults.
+type ChainSettings struct {
+	PollInterval  time.Duration
+	BatchSize     uint64
+	Confirmations *uint64
 }
 
 // ContractInfo defines a contract indexing target.
@@ -37,7 +83,40 @@ type ContractInfo struct {
 	Address    common.Address `json:"address"`
 	StartBlock uint64         `json:"startBlock"`
 	ExpiresAt  time.Time      `json:"expiresAt"`
+	Label      string         `json:"label,omitempty"`
 	Synced     bool           `json:"synced"`
+	// ExpiringSoon is set when the contract expires within the warning window.
+	ExpiringSoon bool `json:"expiringSoon"`
+	// Policy optionally extends or bounds ExpiresAt while the contract is indexed.
+	Policy *store.ExpiryPolicy `json:"policy,omitempty"`
+	// DeploymentTx optionally identifies the transaction that deployed the
+	// contract, so its start block is read from the receipt.
+	DeploymentTx *common.Hash `json:"deploymentTx,omitempty"`
+	// StartBlockMethod records how a detected start block was found.
+	StartBlockMethod string `json:"startBlockMethod,omitempty"`
+	// EventProfile optionally indexes another event than WeightChanged.
+	EventProfile *store.EventProfile `json:"eventProfile,omitempty"`
+	// Upgrades is the EIP-1967 implementation history of proxy contracts.
+	Upgrades []store.ProxyUpgrade `json:"upgrades,omitempty"`
+	// UpgradePending is set while indexing is paused by an upgrade that has
+	// not been acknowledged.
+	UpgradePending bool `json:"upgradePending,omitempty"`
+	// Archive is set for expired contracts kept read-only until their grace period ends.
+	Archive *store.ContractArchive `json:"archive,omitempty"`
+	// ReindexJobs lists scheduled and completed re-index jobs of the contract.
+	ReindexJobs []store.ReindexJob `json:"reindexJobs,omitempty"`
+	// Unverified is set while a re-index job re-verifies a range the verified
+	// cursor already covers. Synced is not set until the job completes.
+	Unverified bool `json:"unverified,omitempty"`
+	// Supervisor is set once the indexer crashed with a non-retryable error,
+	// until it recovers or is restarted by an operator.
+	Supervisor *SupervisorStatus `json:"supervisor,omitempty"`
+	// Retry is set while the indexer backs off after RPC errors or its chain
+	// circuit breaker is not closed.
+	Retry *RetryStatus `json:"retry,omitempty"`
+	// Audit is the last comparison of the indexed balances with the
+	// contract state.
+	Audit *AuditReport `json:"audit,omitempty"`
 }
 
 // Key returns a unique key for the contract config.
@@ -46,10 +125,15 @@ func (c ContractInfo) Key() string {
 }
 
 type contractInfoJSON struct {
-	ChainID    uint64     `json:"chainId"`
-	Address    string     `json:"address"`
-	StartBlock uint64     `json:"startBlock"`
-	ExpiresAt  *time.Time `json:"expiresAt"`
+	ChainID      uint64              `json:"chainId"`
+	Address      string              `json:"address"`
+	StartBlock   uint64              `json:"startBlock"`
+	ExpiresAt    *time.Time          `json:"expiresAt"`
+	Label        string              `json:"label"`
+	Policy       *store.ExpiryPolicy `json:"policy"`
+	DeploymentTx string              `json:"deploymentTx"`
+	EventProfile *store.EventProfile `json:"eventProfile"`
+	Source       string              `json:"source"`
 }
 
 // UnmarshalJSON parses contract config from JSON with hex address string.
@@ -67,27 +151,57 @@ func (c *ContractInfo) UnmarshalJSON(data []byte) error {
 	if tmp.ExpiresAt == nil {
 		return fmt.Errorf("expiresAt is required")
 	}
+	if err := tmp.Policy.Validate(*tmp.ExpiresAt); err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+	deploymentTx, err := ParseDeploymentTx(tmp.DeploymentTx)
+	if err != nil {
+		return err
+	}
+	if err := tmp.EventProfile.Validate(); err != nil {
+		return fmt.Errorf("invalid eventProfile: %w", err)
+	}
+	if tmp.Source != "" {
+		if tmp.EventProfile != nil {
+			return fmt.Errorf("source and eventProfile are mutually exclusive")
+		}
+		if tmp.EventProfile, err = SourceProfile(tmp.Source); err != nil {
+			return err
+		}
+	}
 	c.ChainID = tmp.ChainID
 	c.Address = common.HexToAddress(tmp.Address)
 	c.StartBlock = tmp.StartBlock
 	c.ExpiresAt = tmp.ExpiresAt.UTC()
+	c.Label = strings.TrimSpace(tmp.Label)
+	c.Policy = tmp.Policy
+	c.DeploymentTx = deploymentTx
+	c.EventProfile = tmp.EventProfile
 	return nil
 }
 
-// IsExpiredAt reports whether the contract should be purged at the provided time.
+// IsExpiredAt reports whether the contract should stop being indexed at the provided time.
 func (c ContractInfo) IsExpiredAt(now time.Time) bool {
 	return !c.ExpiresAt.After(now)
 }
 
+// IsExpiringSoonAt reports whether the contract is still indexed at the provided
+// time but expires within window.
+func (c ContractInfo) IsExpiringSoonAt(now time.Time, window time.Duration) bool {
+	return !c.IsExpiredAt(now) && !c.ExpiresAt.After(now.Add(window))
+}
+
 type managedIndexer struct {
-	cancel context.CancelFunc
-	done   chan struct{}
+	indexer   *Indexer
+	startedAt time.Time
+	cancel    context.CancelFunc
+	done      chan struct{}
 }
 
 // Service manages multiple indexers.
 type Service struct {
-	pool                 *rpc.Web3Pool
-	store                *store.Store
+	pool                 *RPCPool
+	store                store.Backend
 	pollInterval         time.Duration
 	batchSize            uint64
 	verifyBatchSize      uint64
@@ -96,8 +210,30 @@ type Service struct {
 	contractSyncInterval time.Duration
 	autoRPC              bool
 	autoRPCMaxEndpoints  int
+	health               *HealthTracker
+	maxBackoff           time.Duration
+	breakerThreshold     int
+	breakerCooldown      time.Duration
+	restartThreshold     int
+	maxRestartDelay      time.Duration
+	auditInterval        time.Duration
+	auditSample          int
+	auditReindex         bool
+	pauseOnUpgrade       bool
+	backfillWorkers      int
+	backfillSegmentSize  uint64
+	backfillChainLimit   int
+	limiter              *RateLimiter
+	archiveGracePeriod   time.Duration
+	expiryWarning        time.Duration
+	expiryWarned         map[string]time.Time // last expiresAt warned about, per contract key
 	mu                   sync.Mutex
+	chains               map[uint64]ChainSettings
 	indexers             map[string]*managedIndexer
+	breakers             map[uint64]*CircuitBreaker
+	limiters             map[uint64]*ChainLimiter
+	heads                *HeadTracker
+	crashes              map[string]*crashRecord
 }
 
 // NewService creates a new indexer service.
@@ -126,7 +262,33 @@ func NewService(cfg ServiceConfig) (*Service, error) {
 	if cfg.AutoRPCMaxEndpoints <= 0 {
 		cfg.AutoRPCMaxEndpoints = 3
 	}
-	return &Service{
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = defaultBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
+	}
+	if cfg.RestartThreshold <= 0 {
+		cfg.RestartThreshold = defaultRestartThreshold
+	}
+	if cfg.MaxRestartDelay <= 0 {
+		cfg.MaxRestartDelay = defaultMaxRestartDelay
+	}
+	if cfg.Health == nil {
+		health, err := NewHealthTracker(HealthConfig{
+			Pool:                cfg.Pool,
+			AutoRPC:             cfg.AutoRPC,
+			AutoRPCMaxEndpoints: cfg.AutoRPCMaxEndpoints,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg.Health = health
+	}
+	s := &Service{
 		pool:                 cfg.Pool,
 		store:                cfg.Store,
 		pollInterval:         cfg.PollInterval,
@@ -137,8 +299,134 @@ func NewService(cfg ServiceConfig) (*Service, error) {
 		contractSyncInterval: cfg.ContractSyncInterval,
 		autoRPC:              cfg.AutoRPC,
 		autoRPCMaxEndpoints:  cfg.AutoRPCMaxEndpoints,
+		health:               cfg.Health,
+		maxBackoff:           cfg.MaxBackoff,
+		breakerThreshold:     cfg.BreakerThreshold,
+		breakerCooldown:      cfg.BreakerCooldown,
+		restartThreshold:     cfg.RestartThreshold,
+		maxRestartDelay:      cfg.MaxRestartDelay,
+		auditInterval:        cfg.AuditInterval,
+		auditSample:          cfg.AuditSample,
+		auditReindex:         cfg.AuditReindex,
+		pauseOnUpgrade:       cfg.PauseOnUpgrade,
+		backfillWorkers:      cfg.BackfillWorkers,
+		backfillSegmentSize:  cfg.BackfillSegmentSize,
+		backfillChainLimit:   cfg.BackfillChainLimit,
+		limiter:              cfg.RateLimiter,
+		archiveGracePeriod:   cfg.ArchiveGracePeriod,
+		expiryWarning:        cfg.ExpiryWarning,
+		expiryWarned:         make(map[string]time.Time),
+		chains:               cfg.Chains,
 		indexers:             make(map[string]*managedIndexer),
-	}, nil
+		breakers:             make(map[uint64]*CircuitBreaker),
+		limiters:             make(map[uint64]*ChainLimiter),
+		crashes:              make(map[string]*crashRecord),
+	}
+	s.heads = newHeadTracker(s.fetchHead, func(chainID uint64) time.Duration {
+		pollInterval, _, _ := s.chainConfig(chainID)
+		return pollInterval
+	})
+	return s, nil
+}
+
+// Heads returns the head tracker shared by the indexers, refreshed at most
+// once per poll interval of each chain.
+func (s *Service) Heads() *HeadTracker {
+	return s.heads
+}
+
+func (s *Service) fetchHead(ctx context.Context, chainID uint64) (ChainHead, error) {
+	ctx = WithRequestPriority(ctx, PriorityHead)
+	if err := s.limiter.Wait(ctx, chainID); err != nil {
+		return ChainHead{}, err
+	}
+	client, err := s.pool.Client(chainID)
+	if err != nil {
+		return ChainHead{}, fmt.Errorf("create web3 client for chainID %d: %w", chainID, err)
+	}
+	return readChainHead(ctx, client)
+}
+
+// SetChainSettings replaces the per-chain setting overrides. Indexers already
+// running keep their settings; the new ones apply to indexers started afterwards.
+func (s *Service) SetChainSettings(chains map[uint64]ChainSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chains = chains
+}
+
+// chainConfig returns the indexer settings for a chain with its overrides applied.
+func (s *Service) chainConfig(chainID uint64) (pollInterval time.Duration, batchSize, confirmations uint64) {
+	pollInterval, batchSize, confirmations = s.pollInterval, s.batchSize, s.confirmations
+	s.mu.Lock()
+	settings, ok := s.chains[chainID]
+	s.mu.Unlock()
+	if !ok {
+		return pollInterval, batchSize, confirmations
+	}
+	if settings.PollInterval > 0 {
+		pollInterval = settings.PollInterval
+	}
+	if settings.BatchSize > 0 {
+		batchSize = settings.BatchSize
+	}
+	if settings.Confirmations != nil {
+		confirmations = *settings.Confirmations
+	}
+	return pollInterval, batchSize, confirmations
+}
+
+// breaker returns the circuit breaker shared by the indexers of a chain.
+func (s *Service) breaker(chainID uint64) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	breaker, ok := s.breakers[chainID]
+	if !ok {
+		breaker = newCircuitBreaker(chainID, s.breakerThreshold, s.breakerCooldown)
+		s.breakers[chainID] = breaker
+	}
+	return breaker
+}
+
+// backfillLimiter returns the backfill limiter shared by the indexers of a chain.
+func (s *Service) backfillLimiter(chainID uint64) *ChainLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limiter, ok := s.limiters[chainID]
+	if !ok {
+		limiter = newChainLimiter(s.backfillChainLimit)
+		s.limiters[chainID] = limiter
+	}
+	return limiter
+}
+
+// RetryStatus returns the retry state of a running indexer. It reports false
+// when the contract is not being indexed or is neither backing off nor paused
+// by its chain circuit breaker.
+func (s *Service) RetryStatus(chainID uint64, contract common.Address) (RetryStatus, bool) {
+	s.mu.Lock()
+	entry, ok := s.indexers[contractKey(chainID, contract)]
+	s.mu.Unlock()
+	if !ok || entry.indexer == nil {
+		return RetryStatus{}, false
+	}
+	status := entry.indexer.RetryStatus()
+	if status.Failures == 0 && status.Circuit == CircuitClosed {
+		return RetryStatus{}, false
+	}
+	return status, true
+}
+
+// AuditStatus returns the last balance audit of a running indexer. It reports
+// false when the contract is not being indexed or has not been audited.
+func (s *Service) AuditStatus(chainID uint64, contract common.Address) (AuditReport, bool) {
+	s.mu.Lock()
+	entry, ok := s.indexers[contractKey(chainID, contract)]
+	s.mu.Unlock()
+	if !ok || entry.indexer == nil {
+		return AuditReport{}, false
+	}
+	return entry.indexer.AuditStatus()
 }
 
 // Start launches all indexers and returns a channel with their errors.
@@ -181,20 +469,56 @@ func (s *Service) syncContracts(ctx context.Context, errCh chan<- error) {
 			Address:    common.HexToAddress(record.Contract),
 			StartBlock: record.StartBlock,
 			ExpiresAt:  record.ExpiresAt,
+			Label:      record.Label,
+			Policy:     record.Policy,
+			Archive:    record.Archive,
 		}
-		if cfg.IsExpiredAt(now) {
-			if err := s.purgeContract(ctx, cfg); err != nil {
+		cfg.EventProfile = record.EventProfile
+		cfg.DeploymentTx, _ = ParseDeploymentTx(record.DeploymentTx)
+		// Expiry policies are evaluated once the contract enters the warning
+		// window, and before an expired contract is archived.
+		if cfg.Archive == nil && cfg.Policy != nil && !cfg.ExpiresAt.After(now.Add(s.expiryWarning)) {
+			if err := s.applyExpiryPolicy(ctx, &cfg, now); err != nil {
 				s.sendErr(errCh, err)
-			} else {
-				purgedAny = true
 			}
+		}
+		if cfg.Archive == nil && !cfg.IsExpiredAt(now) {
+			activeKeys[cfg.Key()] = struct{}{}
+			s.warnExpiringSoon(cfg, now)
+			if err := s.ensureRegistered(ctx, cfg, errCh); err != nil {
+				s.sendErr(errCh, err)
+			}
+			continue
+		}
+		// Expired contracts are archived first and deleted once the grace period ends.
+		if cfg.Archive == nil && s.archiveGracePeriod > 0 {
+			if err := s.archiveContract(ctx, cfg); err != nil {
+				s.sendErr(errCh, err)
+			}
+			continue
+		}
+		if cfg.Archive != nil && now.Before(cfg.Archive.ArchivedAt.Add(s.archiveGracePeriod)) {
 			continue
 		}
-		activeKeys[cfg.Key()] = struct{}{}
-		if err := s.ensureRegistered(ctx, cfg, errCh); err != nil {
+		if err := s.purgeContract(ctx, cfg); err != nil {
 			s.sendErr(errCh, err)
+		} else {
+			purgedAny = true
+		}
+	}
+	for key := range s.expiryWarned {
+		if _, ok := activeKeys[key]; !ok {
+			delete(s.expiryWarned, key)
 		}
 	}
+	s.mu.Lock()
+	for key := range s.crashes {
+		if _, ok := activeKeys[key]; !ok {
+			delete(s.crashes, key)
+		}
+	}
+	s.clearRecovered(now)
+	s.mu.Unlock()
 	if err := s.stopInactiveIndexers(ctx, activeKeys); err != nil {
 		s.sendErr(errCh, err)
 	}
@@ -215,7 +539,7 @@ func (s *Service) ensureRegistered(ctx context.Context, cfg ContractInfo, errCh
 	key := contractKey(cfg.ChainID, cfg.Address)
 
 	s.mu.Lock()
-	if _, exists := s.indexers[key]; exists {
+	if _, exists := s.indexers[key]; exists || !s.canRestart(key, time.Now()) {
 		s.mu.Unlock()
 		return nil
 	}
@@ -229,23 +553,38 @@ func (s *Service) ensureRegistered(ctx context.Context, cfg ContractInfo, errCh
 	if err != nil {
 		return fmt.Errorf("create web3 client for chainID %d: %w", cfg.ChainID, err)
 	}
+	pollInterval, batchSize, confirmations := s.chainConfig(cfg.ChainID)
+	decoder, err := newLogDecoder(cfg.EventProfile)
+	if err != nil {
+		return fmt.Errorf("chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
 	if cfg.StartBlock == 0 {
-		head, err := client.BlockNumber(ctx)
+		head, err := s.heads.HeadBlock(ctx, cfg.ChainID)
 		if err != nil {
 			return fmt.Errorf("fetch head block for chainID %d: %w", cfg.ChainID, err)
 		}
-		startBlock, err := creationBlockInRange(ctx, client, cfg.Address, 0, head)
+		var deploymentTx common.Hash
+		if cfg.DeploymentTx != nil {
+			deploymentTx = *cfg.DeploymentTx
+		}
+		finder := &startBlockFinder{
+			client: &rateLimitedClient{StartBlockClient: client, limiter: s.limiter, chainID: cfg.ChainID},
+			topic:  decoder.event.ID,
+			window: batchSize,
+		}
+		startBlock, method, err := finder.find(WithRequestPriority(ctx, PriorityBackfill), cfg.Address, deploymentTx, head)
 		if err != nil {
-			return fmt.Errorf("find creation block for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+			return fmt.Errorf("find start block for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
 		}
 		cfg.StartBlock = startBlock
-		if err := s.store.SetContractStartBlock(ctx, cfg.ChainID, cfg.Address, cfg.StartBlock); err != nil {
+		if err := s.store.SetContractStartBlock(ctx, cfg.ChainID, cfg.Address, cfg.StartBlock, method); err != nil {
 			return fmt.Errorf("persist start block for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
 		}
 		log.Infow("calculated and persisted contract start block",
 			"chainID", cfg.ChainID,
 			"contract", cfg.Address.Hex(),
 			"startBlock", cfg.StartBlock,
+			"method", method,
 		)
 	}
 	idx, err := New(Config{
@@ -254,19 +593,34 @@ func (s *Service) ensureRegistered(ctx context.Context, cfg ContractInfo, errCh
 		ChainID:         cfg.ChainID,
 		Contract:        cfg.Address,
 		StartBlock:      cfg.StartBlock,
-		PollInterval:    s.pollInterval,
-		BatchSize:       s.batchSize,
+		PollInterval:    pollInterval,
+		BatchSize:       batchSize,
 		VerifyBatchSize: s.verifyBatchSize,
-		Confirmations:   s.confirmations,
+		Confirmations:   confirmations,
 		TailRescanDepth: s.tailRescanDepth,
+		MaxBackoff:      s.maxBackoff,
+		Breaker:         s.breaker(cfg.ChainID),
+		EventProfile:    cfg.EventProfile,
+		AuditInterval:   s.auditInterval,
+		AuditSample:     s.auditSample,
+		AuditReindex:    s.auditReindex,
+		PauseOnUpgrade:  s.pauseOnUpgrade,
+
+		BackfillWorkers:     s.backfillWorkers,
+		BackfillSegmentSize: s.backfillSegmentSize,
+		BackfillLimiter:     s.backfillLimiter(cfg.ChainID),
+		RateLimiter:         s.limiter,
+		Heads:               s.heads,
 	})
 	if err != nil {
 		return fmt.Errorf("create indexer: %w", err)
 	}
 	runCtx, cancel := context.WithCancel(ctx)
 	entry := &managedIndexer{
-		cancel: cancel,
-		done:   make(chan struct{}),
+		indexer:   idx,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
 	}
 
 	s.mu.Lock()
@@ -282,13 +636,17 @@ func (s *Service) ensureRegistered(ctx context.Context, cfg ContractInfo, errCh
 	go func(indexerInstance *Indexer, runEntry *managedIndexer, runKey string) {
 		defer close(runEntry.done)
 		err := indexerInstance.Run(runCtx)
-		if err != nil && !errors.Is(err, context.Canceled) {
+		crashed := err != nil && !errors.Is(err, context.Canceled)
+		if crashed {
 			s.sendErr(errCh, err)
 		}
 		s.mu.Lock()
 		current, exists := s.indexers[runKey]
 		if exists && current == runEntry {
 			delete(s.indexers, runKey)
+			if crashed {
+				s.recordCrash(runKey, cfg.ChainID, cfg.Address, err, time.Now())
+			}
 		}
 		s.mu.Unlock()
 	}(idx, entry, key)
@@ -296,6 +654,102 @@ func (s *Service) ensureRegistered(ctx context.Context, cfg ContractInfo, errCh
 	return nil
 }
 
+// applyExpiryPolicy extends the expiration of a contract according to its
+// policy when new events were indexed since the last extension.
+func (s *Service) applyExpiryPolicy(ctx context.Context, cfg *ContractInfo, now time.Time) error {
+	expiresAt, ok := cfg.Policy.Extend(cfg.ExpiresAt, now)
+	if !ok {
+		return nil
+	}
+	latest, err := s.store.ListEvents(ctx, store.ListOptions{
+		ChainID:        cfg.ChainID,
+		Contract:       cfg.Address,
+		First:          1,
+		OrderDirection: "desc",
+	})
+	if err != nil {
+		return fmt.Errorf("latest event for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
+	if len(latest) == 0 || latest[0].BlockNumber <= cfg.Policy.LastEventBlock {
+		return nil
+	}
+	policy := *cfg.Policy
+	policy.LastEventBlock = latest[0].BlockNumber
+	if err := s.store.SetContractExpiry(ctx, cfg.ChainID, cfg.Address, expiresAt, &policy); err != nil {
+		return fmt.Errorf("extend contract for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
+	log.Infow("extended contract expiration",
+		"chainID", cfg.ChainID,
+		"contract", cfg.Address.Hex(),
+		"previousExpiresAt", cfg.ExpiresAt,
+		"expiresAt", expiresAt,
+		"lastEventBlock", policy.LastEventBlock,
+	)
+	cfg.ExpiresAt = expiresAt
+	cfg.Policy = &policy
+	return nil
+}
+
+// warnExpiringSoon logs once per expiration when a contract enters the
+// warning window.
+func (s *Service) warnExpiringSoon(cfg ContractInfo, now time.Time) {
+	if !cfg.IsExpiringSoonAt(now, s.expiryWarning) {
+		return
+	}
+	key := cfg.Key()
+	if warned, ok := s.expiryWarned[key]; ok && warned.Equal(cfg.ExpiresAt) {
+		return
+	}
+	s.expiryWarned[key] = cfg.ExpiresAt
+	log.Warnw("contract expiring soon",
+		"chainID", cfg.ChainID,
+		"contract", cfg.Address.Hex(),
+		"expiresAt", cfg.ExpiresAt,
+		"remaining", cfg.ExpiresAt.Sub(now).Round(time.Second),
+	)
+}
+
+// archiveContract stops indexing an expired contract and records its final
+// census snapshot. The data is kept read-only until the grace period ends.
+func (s *Service) archiveContract(ctx context.Context, cfg ContractInfo) error {
+	key := contractKey(cfg.ChainID, cfg.Address)
+	if err := s.stopIndexer(ctx, key); err != nil {
+		return fmt.Errorf("stop indexer for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
+	snapshot, err := store.BuildCensusSnapshot(ctx, s.store, cfg.ChainID, cfg.Address)
+	if err != nil {
+		return fmt.Errorf("snapshot census for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
+	indexedUntil, _, err := s.store.LastIndexedBlock(ctx, cfg.ChainID, cfg.Address)
+	if err != nil {
+		return err
+	}
+	verifiedUntil, _, err := s.store.LastVerifiedBlock(ctx, cfg.ChainID, cfg.Address)
+	if err != nil {
+		return err
+	}
+	archive := store.ContractArchive{
+		ArchivedAt:    time.Now().UTC(),
+		IndexedUntil:  indexedUntil,
+		VerifiedUntil: verifiedUntil,
+		Accounts:      len(snapshot.Accounts),
+		TotalWeight:   snapshot.TotalWeight,
+		Root:          snapshot.Root,
+	}
+	if err := s.store.ArchiveContract(ctx, cfg.ChainID, cfg.Address, archive); err != nil {
+		return fmt.Errorf("archive contract for chainID %d contract %s: %w", cfg.ChainID, cfg.Address.Hex(), err)
+	}
+	log.Infow("archived expired contract",
+		"chainID", cfg.ChainID,
+		"contract", cfg.Address.Hex(),
+		"expiresAt", cfg.ExpiresAt,
+		"accounts", archive.Accounts,
+		"root", archive.Root.Hex(),
+		"deleteAfter", archive.ArchivedAt.Add(s.archiveGracePeriod),
+	)
+	return nil
+}
+
 func (s *Service) purgeContract(ctx context.Context, cfg ContractInfo) error {
 	key := contractKey(cfg.ChainID, cfg.Address)
 	if err := s.stopIndexer(ctx, key); err != nil {
@@ -358,7 +812,7 @@ func (s *Service) ensureEndpoints(ctx context.Context, chainID uint64) error {
 	if !s.autoRPC {
 		return fmt.Errorf("no RPC endpoints configured for chainID %d", chainID)
 	}
-	count, err := addChainlistEndpoints(chainID, s.autoRPCMaxEndpoints, s.pool)
+	count, err := s.health.AddChainlistEndpoints(ctx, chainID)
 	if err != nil {
 		return err
 	}
@@ -376,41 +830,6 @@ func (s *Service) sendErr(errCh chan<- error, err error) {
 	}
 }
 
-func addChainlistEndpoints(chainID uint64, maxEndpoints int, pool *rpc.Web3Pool) (int, error) {
-	chainMap, err := chainlist.ChainList()
-	if err != nil {
-		return 0, fmt.Errorf("load chainlist: %w", err)
-	}
-	var shortName string
-	for name, id := range chainMap {
-		if id == chainID {
-			shortName = name
-			break
-		}
-	}
-	if shortName == "" {
-		return 0, fmt.Errorf("chainID %d not found in chainlist", chainID)
-	}
-	endpoints, err := chainlist.EndpointList(shortName, maxEndpoints)
-	if err != nil {
-		return 0, fmt.Errorf("chainlist endpoints: %w", err)
-	}
-	if len(endpoints) == 0 {
-		return 0, fmt.Errorf("no healthy endpoints found for chainID %d", chainID)
-	}
-	added := 0
-	for _, endpoint := range endpoints {
-		if _, err := pool.AddEndpoint(endpoint); err != nil {
-			continue
-		}
-		added++
-	}
-	if added == 0 {
-		return 0, fmt.Errorf("failed to add endpoints for chainID %d", chainID)
-	}
-	return added, nil
-}
-
 func contractKey(chainID uint64, contract common.Address) string {
 	return fmt.Sprintf("%d:%s", chainID, strings.ToLower(contract.Hex()))
 }
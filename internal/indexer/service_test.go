@@ -8,7 +8,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vocdoni/davinci-node/db"
 	"github.com/vocdoni/davinci-node/db/metadb"
-	"github.com/vocdoni/davinci-node/web3/rpc"
 
 	"github.com/vocdoni/onchain-census-indexer/internal/store"
 )
@@ -50,7 +49,7 @@ func TestSyncContractsPurgesExpiredContractsAndCompactsStore(t *testing.T) {
 	}
 
 	svc, err := NewService(ServiceConfig{
-		Pool:  rpc.NewWeb3Pool(),
+		Pool:  NewRPCPool(nil),
 		Store: eventStore,
 	})
 	if err != nil {
@@ -95,3 +94,215 @@ func TestSyncContractsPurgesExpiredContractsAndCompactsStore(t *testing.T) {
 		t.Fatalf("expected exactly one compaction after purge, got %d", database.compactCalls)
 	}
 }
+
+func TestSyncContractsArchivesExpiredContractsBeforeDeletion(t *testing.T) {
+	ctx := context.Background()
+	baseDB, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := baseDB.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+
+	database := &compactingDB{Database: baseDB}
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x9999999999999999999999999999999999999999")
+	account := common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	expiresAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	if err := eventStore.SaveContract(ctx, 1, contract, 100, expiresAt); err != nil {
+		t.Fatalf("save expired contract: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: account.Hex(), PreviousWeight: "1", NewWeight: "2", BlockNumber: 101, LogIndex: 0},
+	}, 101); err != nil {
+		t.Fatalf("save contract events: %v", err)
+	}
+
+	svc, err := NewService(ServiceConfig{
+		Pool:               NewRPCPool(nil),
+		Store:              eventStore,
+		ArchiveGracePeriod: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	svc.syncContracts(ctx, errCh)
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected sync error: %v", err)
+	default:
+	}
+
+	record, ok, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok || record.Archive == nil {
+		t.Fatalf("expected expired contract to be archived, got %+v (ok=%t, err=%v)", record, ok, err)
+	}
+	if record.Archive.IndexedUntil != 101 || record.Archive.Accounts != 1 || record.Archive.TotalWeight != "2" {
+		t.Fatalf("unexpected archive summary: %+v", record.Archive)
+	}
+	snapshot, err := store.BuildCensusSnapshot(ctx, eventStore, 1, contract)
+	if err != nil {
+		t.Fatalf("build snapshot: %v", err)
+	}
+	if record.Archive.Root != snapshot.Root {
+		t.Fatalf("expected archive root %s, got %s", snapshot.Root, record.Archive.Root)
+	}
+	if database.compactCalls != 0 {
+		t.Fatalf("expected no compaction while the contract is archived, got %d", database.compactCalls)
+	}
+
+	// A second pass within the grace period keeps the archive untouched.
+	svc.syncContracts(ctx, errCh)
+	if _, ok, _ := eventStore.GetContract(ctx, 1, contract); !ok {
+		t.Fatalf("expected archived contract to be kept during the grace period")
+	}
+
+	svc.archiveGracePeriod = time.Nanosecond
+	svc.syncContracts(ctx, errCh)
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected sync error: %v", err)
+	default:
+	}
+	if _, ok, _ := eventStore.GetContract(ctx, 1, contract); ok {
+		t.Fatalf("expected archived contract to be deleted after the grace period")
+	}
+	if database.compactCalls != 1 {
+		t.Fatalf("expected exactly one compaction after deletion, got %d", database.compactCalls)
+	}
+}
+
+func TestSyncContractsAppliesExpiryPolicy(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x9999999999999999999999999999999999999999")
+	account := common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	expiresAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	policy := &store.ExpiryPolicy{AutoExtend: store.Duration(time.Hour)}
+	if err := eventStore.SaveContract(ctx, 1, contract, 100, expiresAt); err != nil {
+		t.Fatalf("save expired contract: %v", err)
+	}
+	if err := eventStore.SetContractExpiry(ctx, 1, contract, expiresAt, policy); err != nil {
+		t.Fatalf("set contract policy: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, []store.Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: account.Hex(), PreviousWeight: "0", NewWeight: "2", BlockNumber: 101, LogIndex: 0},
+	}, 101); err != nil {
+		t.Fatalf("save contract events: %v", err)
+	}
+
+	svc, err := NewService(ServiceConfig{
+		Pool:               NewRPCPool(nil),
+		Store:              eventStore,
+		ArchiveGracePeriod: time.Hour,
+		ExpiryWarning:      2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+	// Pretend the contract is already being indexed so no RPC client is needed.
+	done := make(chan struct{})
+	close(done)
+	key := contractKey(1, contract)
+	svc.indexers[key] = &managedIndexer{cancel: func() {}, done: done}
+
+	errCh := make(chan error, 1)
+	svc.syncContracts(ctx, errCh)
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected sync error: %v", err)
+	default:
+	}
+	record, ok, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok || record.Archive != nil {
+		t.Fatalf("expected an active contract, got %+v (ok=%t, err=%v)", record, ok, err)
+	}
+	if !record.ExpiresAt.After(time.Now().Add(50 * time.Minute)) {
+		t.Fatalf("expected expiresAt to be extended by about an hour, got %s", record.ExpiresAt)
+	}
+	if record.Policy == nil || record.Policy.LastEventBlock != 101 {
+		t.Fatalf("expected the policy to record event block 101, got %+v", record.Policy)
+	}
+	if warned, ok := svc.expiryWarned[key]; !ok || !warned.Equal(record.ExpiresAt) {
+		t.Fatalf("expected an expiring soon warning for %s, got %s (ok=%t)", record.ExpiresAt, warned, ok)
+	}
+
+	// Without new events the contract is not extended again and gets archived.
+	if err := eventStore.SetContractExpiry(ctx, 1, contract, expiresAt, record.Policy); err != nil {
+		t.Fatalf("reset contract expiry: %v", err)
+	}
+	svc.syncContracts(ctx, errCh)
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected sync error: %v", err)
+	default:
+	}
+	record, ok, err = eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok || record.Archive == nil {
+		t.Fatalf("expected the idle contract to be archived, got %+v (ok=%t, err=%v)", record, ok, err)
+	}
+	if _, ok := svc.expiryWarned[key]; ok {
+		t.Fatalf("expected the warning state to be dropped for the archived contract")
+	}
+}
+
+func TestChainConfigAppliesOverrides(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := NewService(ServiceConfig{
+		Pool:          NewRPCPool(nil),
+		Store:         store.New(database),
+		PollInterval:  5 * time.Second,
+		BatchSize:     50,
+		Confirmations: 12,
+	})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+	zero := uint64(0)
+	svc.SetChainSettings(map[uint64]ChainSettings{
+		10: {PollInterval: time.Second, Confirmations: &zero},
+		11: {BatchSize: 500},
+	})
+
+	tests := []struct {
+		chainID       uint64
+		pollInterval  time.Duration
+		batchSize     uint64
+		confirmations uint64
+	}{
+		{1, 5 * time.Second, 50, 12},
+		{10, time.Second, 50, 0},
+		{11, 5 * time.Second, 500, 12},
+	}
+	for _, tt := range tests {
+		pollInterval, batchSize, confirmations := svc.chainConfig(tt.chainID)
+		if pollInterval != tt.pollInterval || batchSize != tt.batchSize || confirmations != tt.confirmations {
+			t.Fatalf("chain %d: expected (%s, %d, %d), got (%s, %d, %d)", tt.chainID,
+				tt.pollInterval, tt.batchSize, tt.confirmations, pollInterval, batchSize, confirmations)
+		}
+	}
+}
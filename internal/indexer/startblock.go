@@ -0,0 +1,255 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	contracts "github.com/vocdoni/davinci-contracts/golang-types"
+	"github.com/vocdoni/davinci-node/log"
+)
+
+// Methods used to find the start block of a contract, recorded with it.
+const (
+	// StartBlockReceipt uses the receipt of the deployment transaction.
+	StartBlockReceipt = "receipt"
+	// StartBlockLogs uses the block of the earliest indexed event.
+	StartBlockLogs = "logs"
+	// StartBlockBytecode bisects eth_getCode for the creation block, which
+	// needs an archive node.
+	StartBlockBytecode = "bytecode"
+)
+
+// WeightChangedTopic is the topic of the WeightChanged event.
+var WeightChangedTopic = func() common.Hash {
+	parsed, err := contracts.ICensusValidatorMetaData.GetAbi()
+	if err != nil {
+		panic(fmt.Sprintf("parse census validator ABI: %v", err))
+	}
+	return parsed.Events["WeightChanged"].ID
+}()
+
+// ParseDeploymentTx parses an optional deployment transaction hash. An empty
+// string returns nil.
+func ParseDeploymentTx(raw string) (*common.Hash, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	decoded, err := hexutil.Decode(raw)
+	if err != nil || len(decoded) != common.HashLength {
+		return nil, fmt.Errorf("invalid deploymentTx %q", raw)
+	}
+	txHash := common.BytesToHash(decoded)
+	return &txHash, nil
+}
+
+// StartBlockClient is the subset of the RPC API used to find start blocks.
+type StartBlockClient interface {
+	CodeReader
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*gethtypes.Receipt, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error)
+}
+
+const (
+	// maxStartBlockLogQueries bounds the eth_getLogs calls of the event
+	// search, retries included, before the bytecode bisection takes over.
+	maxStartBlockLogQueries = 256
+	// startBlockLogRetries is the number of retries of a failed eth_getLogs
+	// call that the RPC did not reject for its range.
+	startBlockLogRetries = 3
+	// defaultStartBlockRetryDelay is the delay before the first retry, doubled
+	// after each one.
+	defaultStartBlockRetryDelay = time.Second
+)
+
+// errStartBlockNotFound is returned by a strategy that does not apply to the
+// contract, so the next one is tried.
+var errStartBlockNotFound = errors.New("start block not found")
+
+// startBlockFinder finds the block from which a contract must be indexed by
+// trying, in order, the deployment transaction receipt, the earliest indexed
+// event and the bytecode bisection.
+type startBlockFinder struct {
+	client StartBlockClient
+	// topic is the topic of the indexed event; WeightChangedTopic if unset.
+	topic common.Hash
+	// window is the first eth_getLogs range of the event search.
+	window uint64
+	// retryDelay is the delay before the first retry of a failed eth_getLogs
+	// call; defaultStartBlockRetryDelay if unset.
+	retryDelay time.Duration
+}
+
+// find returns the start block of the contract up to head and the method that
+// found it. deploymentTx is optional.
+func (f *startBlockFinder) find(ctx context.Context, addr common.Address, deploymentTx common.Hash, head uint64) (uint64, string, error) {
+	strategies := []struct {
+		method string
+		find   func() (uint64, error)
+	}{
+		{StartBlockReceipt, func() (uint64, error) { return f.fromReceipt(ctx, addr, deploymentTx) }},
+		{StartBlockLogs, func() (uint64, error) { return f.fromLogs(ctx, addr, head) }},
+		{StartBlockBytecode, func() (uint64, error) { return creationBlockInRange(ctx, f.client, addr, 0, head) }},
+	}
+	var errs []error
+	for _, strategy := range strategies {
+		block, err := strategy.find()
+		if err == nil {
+			return block, strategy.method, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, "", ctxErr
+		}
+		if !errors.Is(err, errStartBlockNotFound) {
+			log.Debugw("start block strategy failed",
+				"contract", addr.Hex(),
+				"method", strategy.method,
+				"err", err,
+			)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", strategy.method, err))
+	}
+	return 0, "", errors.Join(errs...)
+}
+
+// fromReceipt returns the block of the deployment transaction. Contracts
+// created by a factory have no contract address in the receipt, so for them
+// the contract must have bytecode at the receipt block and none before it.
+func (f *startBlockFinder) fromReceipt(ctx context.Context, addr common.Address, deploymentTx common.Hash) (uint64, error) {
+	if deploymentTx == (common.Hash{}) {
+		return 0, errStartBlockNotFound
+	}
+	receipt, err := f.client.TransactionReceipt(ctx, deploymentTx)
+	if err != nil {
+		return 0, fmt.Errorf("fetch receipt of %s: %w", deploymentTx.Hex(), err)
+	}
+	if receipt.Status != gethtypes.ReceiptStatusSuccessful {
+		return 0, fmt.Errorf("deployment transaction %s reverted", deploymentTx.Hex())
+	}
+	if receipt.ContractAddress != (common.Address{}) && receipt.ContractAddress != addr {
+		return 0, fmt.Errorf("transaction %s deployed %s", deploymentTx.Hex(), receipt.ContractAddress.Hex())
+	}
+	if receipt.BlockNumber == nil {
+		return 0, fmt.Errorf("receipt of %s has no block number", deploymentTx.Hex())
+	}
+	block := receipt.BlockNumber.Uint64()
+	if receipt.ContractAddress == (common.Address{}) {
+		if err := f.checkCreatedAt(ctx, addr, block); err != nil {
+			return 0, fmt.Errorf("transaction %s: %w", deploymentTx.Hex(), err)
+		}
+	}
+	return block, nil
+}
+
+// checkCreatedAt checks that the contract has bytecode at block and none at
+// the block before.
+func (f *startBlockFinder) checkCreatedAt(ctx context.Context, addr common.Address, block uint64) error {
+	codeLen, err := sourceCodeLenAt(ctx, f.client, addr, block)
+	if err != nil {
+		return fmt.Errorf("get code at %d: %w", block, err)
+	}
+	if codeLen == 0 {
+		return fmt.Errorf("contract %s has no code at block %d", addr.Hex(), block)
+	}
+	if block == 0 {
+		return nil
+	}
+	codeLen, err = sourceCodeLenAt(ctx, f.client, addr, block-1)
+	if err != nil {
+		return fmt.Errorf("get code at %d: %w", block-1, err)
+	}
+	if codeLen > 0 {
+		return fmt.Errorf("contract %s already has code at block %d", addr.Hex(), block-1)
+	}
+	return nil
+}
+
+// fromLogs returns the block of the earliest indexed event of the contract.
+// It scans forward from block 0 with windows that double after each empty
+// range and halve when the RPC rejects a range, so old contracts are found
+// with a logarithmic number of calls. Other errors are retried a few times,
+// and the scan gives up after maxStartBlockLogQueries calls.
+func (f *startBlockFinder) fromLogs(ctx context.Context, addr common.Address, head uint64) (uint64, error) {
+	window := max(f.window, 1)
+	topic := f.topic
+	if topic == (common.Hash{}) {
+		topic = WeightChangedTopic
+	}
+	// maxWindow is lowered to the largest range below one the RPC rejected.
+	maxWindow := uint64(0)
+	queries, retries := 0, 0
+	for from := uint64(0); from <= head; {
+		if queries == maxStartBlockLogQueries {
+			return 0, fmt.Errorf("%w: no event up to block %d after %d eth_getLogs calls", errStartBlockNotFound, from, queries)
+		}
+		queries++
+		to := head
+		if window-1 < head-from {
+			to = from + window - 1
+		}
+		logs, err := f.client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{addr},
+			Topics:    [][]common.Hash{{topic}},
+		})
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrDailyCapReached) {
+				return 0, fmt.Errorf("filter logs from %d to %d: %w", from, to, err)
+			}
+			if isRangeLimitError(err) && window > 1 {
+				window /= 2
+				maxWindow = window
+				continue
+			}
+			if retries == startBlockLogRetries {
+				return 0, fmt.Errorf("filter logs from %d to %d: %w", from, to, err)
+			}
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(f.retryDelayAfter(retries)):
+			}
+			retries++
+			continue
+		}
+		retries = 0
+		if len(logs) > 0 {
+			first := logs[0].BlockNumber
+			for _, entry := range logs[1:] {
+				first = min(first, entry.BlockNumber)
+			}
+			return first, nil
+		}
+		if to == head {
+			break
+		}
+		from = to + 1
+		if window <= math.MaxUint64/2 {
+			window *= 2
+		}
+		if maxWindow > 0 {
+			window = min(window, maxWindow)
+		}
+	}
+	return 0, errStartBlockNotFound
+}
+
+// retryDelayAfter returns the delay before the retry following the given
+// number of retries.
+func (f *startBlockFinder) retryDelayAfter(retries int) time.Duration {
+	delay := f.retryDelay
+	if delay <= 0 {
+		delay = defaultStartBlockRetryDelay
+	}
+	return delay << retries
+}
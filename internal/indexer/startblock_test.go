@@ -0,0 +1,194 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeStartBlockChain serves a contract created at createdAt whose
+// WeightChanged events are emitted at the events blocks.
+type fakeStartBlockChain struct {
+	contract  common.Address
+	createdAt uint64
+	events    []uint64
+	receipts  map[common.Hash]*gethtypes.Receipt
+	// maxRange rejects eth_getLogs ranges wider than it when set.
+	maxRange uint64
+	// flaky fails this many eth_getLogs calls with a transient error.
+	flaky      int
+	logQueries int
+	codeCalls  int
+}
+
+func (f *fakeStartBlockChain) CodeAt(_ context.Context, account common.Address, block *big.Int) ([]byte, error) {
+	f.codeCalls++
+	if account != f.contract || block.Uint64() < f.createdAt {
+		return nil, nil
+	}
+	return []byte{0x60, 0x80, 0x60, 0x40}, nil
+}
+
+func (f *fakeStartBlockChain) TransactionReceipt(_ context.Context, txHash common.Hash) (*gethtypes.Receipt, error) {
+	receipt, ok := f.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (f *fakeStartBlockChain) FilterLogs(_ context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	f.logQueries++
+	if f.flaky > 0 {
+		f.flaky--
+		return nil, fmt.Errorf("connection reset by peer")
+	}
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if f.maxRange > 0 && to-from+1 > f.maxRange {
+		return nil, fmt.Errorf("block range is too wide")
+	}
+	var logs []gethtypes.Log
+	for _, block := range f.events {
+		if block >= from && block <= to {
+			logs = append(logs, gethtypes.Log{Address: f.contract, BlockNumber: block, Topics: []common.Hash{WeightChangedTopic}})
+		}
+	}
+	return logs, nil
+}
+
+func TestStartBlockFinderUsesDeploymentReceipt(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	deployment := common.HexToHash("0xaa")
+	factoryCall := common.HexToHash("0xbb")
+	otherDeployment := common.HexToHash("0xcc")
+	unrelatedCall := common.HexToHash("0xdd")
+	chain := &fakeStartBlockChain{
+		contract:  contract,
+		createdAt: 1200,
+		events:    []uint64{1500},
+		receipts: map[common.Hash]*gethtypes.Receipt{
+			deployment:      {Status: gethtypes.ReceiptStatusSuccessful, ContractAddress: contract, BlockNumber: big.NewInt(1200)},
+			factoryCall:     {Status: gethtypes.ReceiptStatusSuccessful, BlockNumber: big.NewInt(1200)},
+			otherDeployment: {Status: gethtypes.ReceiptStatusSuccessful, ContractAddress: common.HexToAddress("0x2222"), BlockNumber: big.NewInt(900)},
+			unrelatedCall:   {Status: gethtypes.ReceiptStatusSuccessful, BlockNumber: big.NewInt(1300)},
+		},
+	}
+	finder := &startBlockFinder{client: chain, window: 100}
+
+	for txHash, want := range map[common.Hash]struct {
+		block  uint64
+		method string
+	}{
+		deployment:      {1200, StartBlockReceipt},
+		factoryCall:     {1200, StartBlockReceipt},
+		otherDeployment: {1500, StartBlockLogs},
+		unrelatedCall:   {1500, StartBlockLogs},
+		{}:              {1500, StartBlockLogs},
+	} {
+		block, method, err := finder.find(context.Background(), contract, txHash, 100000)
+		if err != nil {
+			t.Fatalf("find start block with tx %s: %v", txHash.Hex(), err)
+		}
+		if block != want.block || method != want.method {
+			t.Fatalf("expected block %d by %s with tx %s, got %d by %s", want.block, want.method, txHash.Hex(), block, method)
+		}
+	}
+	// Only the receipts without a contract address are checked against the
+	// bytecode, at their block and the one before.
+	if chain.codeCalls != 4 {
+		t.Fatalf("expected 4 eth_getCode calls, got %d", chain.codeCalls)
+	}
+}
+
+func TestStartBlockFinderWidensAndShrinksLogWindows(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	chain := &fakeStartBlockChain{
+		contract:  contract,
+		createdAt: 5_000_000,
+		events:    []uint64{5_123_456, 5_200_000},
+		maxRange:  500_000,
+	}
+	finder := &startBlockFinder{client: chain, window: 1000}
+	block, method, err := finder.find(context.Background(), contract, common.Hash{}, 10_000_000)
+	if err != nil {
+		t.Fatalf("find start block: %v", err)
+	}
+	if block != 5_123_456 || method != StartBlockLogs {
+		t.Fatalf("expected block 5123456 by logs, got %d by %s", block, method)
+	}
+	if chain.logQueries > 30 {
+		t.Fatalf("expected a logarithmic number of eth_getLogs calls, got %d", chain.logQueries)
+	}
+}
+
+func TestStartBlockFinderRetriesTransientLogErrors(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	chain := &fakeStartBlockChain{contract: contract, createdAt: 40_000, events: []uint64{50_000}, flaky: 2}
+	finder := &startBlockFinder{client: chain, window: 1000, retryDelay: time.Millisecond}
+	block, err := finder.fromLogs(context.Background(), contract, 100_000)
+	if err != nil || block != 50_000 {
+		t.Fatalf("expected block 50000 after the retries, got %d (err=%v)", block, err)
+	}
+	// Transient errors must not shrink the window: 1000, 2000, ... 32000 end
+	// at block 62999, past the event.
+	if chain.logQueries != 2+6 {
+		t.Fatalf("expected 8 eth_getLogs calls, got %d", chain.logQueries)
+	}
+
+	chain = &fakeStartBlockChain{contract: contract, createdAt: 40_000, events: []uint64{50_000}, flaky: startBlockLogRetries + 1}
+	finder = &startBlockFinder{client: chain, window: 1000, retryDelay: time.Millisecond}
+	if _, err := finder.fromLogs(context.Background(), contract, 100_000); err == nil || errors.Is(err, errStartBlockNotFound) {
+		t.Fatalf("expected the log error after the retries, got %v", err)
+	}
+}
+
+func TestStartBlockFinderBoundsLogScan(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	chain := &fakeStartBlockChain{contract: contract, createdAt: 9_000_000, events: []uint64{9_500_000}, maxRange: 1000}
+	finder := &startBlockFinder{client: chain, window: 1000}
+	block, method, err := finder.find(context.Background(), contract, common.Hash{}, 10_000_000)
+	if err != nil {
+		t.Fatalf("find start block: %v", err)
+	}
+	if block != 9_000_000 || method != StartBlockBytecode {
+		t.Fatalf("expected block 9000000 by bytecode, got %d by %s", block, method)
+	}
+	if chain.logQueries != maxStartBlockLogQueries {
+		t.Fatalf("expected %d eth_getLogs calls, got %d", maxStartBlockLogQueries, chain.logQueries)
+	}
+}
+
+func TestStartBlockFinderFallsBackToBytecode(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	chain := &fakeStartBlockChain{contract: contract, createdAt: 777}
+	finder := &startBlockFinder{client: chain, window: 100}
+	block, method, err := finder.find(context.Background(), contract, common.Hash{}, 10_000)
+	if err != nil {
+		t.Fatalf("find start block: %v", err)
+	}
+	if block != 777 || method != StartBlockBytecode {
+		t.Fatalf("expected block 777 by bytecode, got %d by %s", block, method)
+	}
+}
+
+func TestParseDeploymentTx(t *testing.T) {
+	if txHash, err := ParseDeploymentTx(" "); err != nil || txHash != nil {
+		t.Fatalf("expected no hash for an empty value, got %v (err=%v)", txHash, err)
+	}
+	raw := "0x" + fmt.Sprintf("%064x", 42)
+	if txHash, err := ParseDeploymentTx(raw); err != nil || txHash == nil || *txHash != common.BigToHash(big.NewInt(42)) {
+		t.Fatalf("expected hash %s, got %v (err=%v)", raw, txHash, err)
+	}
+	for _, raw := range []string{"0x1234", "not a hash", "0x" + fmt.Sprintf("%066x", 1)} {
+		if _, err := ParseDeploymentTx(raw); err == nil {
+			t.Fatalf("expected an error for %q", raw)
+		}
+	}
+}
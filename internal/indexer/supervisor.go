@@ -0,0 +1,145 @@
+package indexer
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/log"
+)
+
+// IndexerState is the supervision state of an indexer that crashed with a
+// non-retryable error.
+type IndexerState string
+
+const (
+	// IndexerRestarting indexers are restarted once their restart delay ends.
+	IndexerRestarting IndexerState = "restarting"
+	// IndexerRecovering indexers were restarted and are considered recovered
+	// once they run for the maximum restart delay without crashing.
+	IndexerRecovering IndexerState = "recovering"
+	// IndexerFailed indexers crashed too many times in a row and are no longer
+	// restarted until RestartIndexer is called.
+	IndexerFailed IndexerState = "failed"
+)
+
+const (
+	defaultRestartThreshold = 5
+	defaultMaxRestartDelay  = 10 * time.Minute
+)
+
+// SupervisorStatus reports the crashes of an indexer.
+type SupervisorStatus struct {
+	State         IndexerState `json:"state"`
+	Failures      int          `json:"failures"`
+	LastError     string       `json:"lastError"`
+	LastFailureAt time.Time    `json:"lastFailureAt"`
+	RestartAt     *time.Time   `json:"restartAt,omitempty"`
+}
+
+// crashRecord tracks the consecutive crashes of an indexer.
+type crashRecord struct {
+	failures      int
+	lastError     string
+	lastFailureAt time.Time
+	restartAt     time.Time
+	failed        bool
+}
+
+// recordCrash registers a non-retryable error returned by the indexer of key.
+// It must be called with s.mu held.
+func (s *Service) recordCrash(key string, chainID uint64, contract common.Address, err error, now time.Time) {
+	crash, ok := s.crashes[key]
+	if !ok {
+		crash = &crashRecord{}
+		s.crashes[key] = crash
+	}
+	crash.failures++
+	crash.lastError = err.Error()
+	crash.lastFailureAt = now
+	if crash.failures >= s.restartThreshold {
+		crash.failed = true
+		crash.restartAt = time.Time{}
+		log.Warnw("indexer failed; not restarting it",
+			"chainID", chainID,
+			"contract", contract.Hex(),
+			"failures", crash.failures,
+			"err", err,
+		)
+		return
+	}
+	delay := s.maxRestartDelay
+	if shift := crash.failures - 1; shift < 32 && s.contractSyncInterval<<shift > 0 && s.contractSyncInterval<<shift < delay {
+		delay = s.contractSyncInterval << shift
+	}
+	crash.restartAt = now.Add(delay)
+	log.Warnw("indexer crashed; restarting it",
+		"chainID", chainID,
+		"contract", contract.Hex(),
+		"failures", crash.failures,
+		"restartIn", delay.String(),
+		"err", err,
+	)
+}
+
+// clearRecovered forgets the crashes of indexers that have been running for
+// the maximum restart delay since their last restart. It must be called with
+// s.mu held.
+func (s *Service) clearRecovered(now time.Time) {
+	for key, crash := range s.crashes {
+		entry, running := s.indexers[key]
+		if !running || now.Sub(entry.startedAt) < s.maxRestartDelay {
+			continue
+		}
+		delete(s.crashes, key)
+		log.Infow("indexer recovered from crashes", "key", key, "failures", crash.failures)
+	}
+}
+
+// canRestart reports whether the indexer of key may be started at now. It must
+// be called with s.mu held.
+func (s *Service) canRestart(key string, now time.Time) bool {
+	crash, ok := s.crashes[key]
+	return !ok || (!crash.failed && !now.Before(crash.restartAt))
+}
+
+// SupervisorStatus returns the crash state of a contract indexer. It reports
+// false when the indexer has not crashed since it last recovered.
+func (s *Service) SupervisorStatus(chainID uint64, contract common.Address) (SupervisorStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	crash, ok := s.crashes[contractKey(chainID, contract)]
+	if !ok {
+		return SupervisorStatus{}, false
+	}
+	status := SupervisorStatus{
+		State:         IndexerRestarting,
+		Failures:      crash.failures,
+		LastError:     crash.lastError,
+		LastFailureAt: crash.lastFailureAt.UTC(),
+	}
+	_, running := s.indexers[contractKey(chainID, contract)]
+	switch {
+	case crash.failed:
+		status.State = IndexerFailed
+	case running:
+		status.State = IndexerRecovering
+	default:
+		restartAt := crash.restartAt.UTC()
+		status.RestartAt = &restartAt
+	}
+	return status, true
+}
+
+// RestartIndexer clears the crash history of a contract indexer so it is
+// started again on the next contract sync. It reports false when the indexer
+// has not crashed.
+func (s *Service) RestartIndexer(chainID uint64, contract common.Address) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := contractKey(chainID, contract)
+	if _, ok := s.crashes[key]; !ok {
+		return false
+	}
+	delete(s.crashes, key)
+	return true
+}
@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestSupervisorRestartsWithDelayAndMarksFailed(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := NewService(ServiceConfig{
+		Pool:                 NewRPCPool(nil),
+		Store:                store.New(database),
+		ContractSyncInterval: time.Second,
+		RestartThreshold:     3,
+		MaxRestartDelay:      time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	key := contractKey(1, contract)
+	now := time.Now()
+
+	svc.mu.Lock()
+	svc.recordCrash(key, 1, contract, fmt.Errorf("store closed"), now)
+	svc.mu.Unlock()
+	status, ok := svc.SupervisorStatus(1, contract)
+	if !ok || status.State != IndexerRestarting || status.Failures != 1 || status.LastError != "store closed" {
+		t.Fatalf("expected a restarting indexer after 1 failure, got %+v", status)
+	}
+	if status.RestartAt == nil || !status.RestartAt.Equal(now.Add(time.Second).UTC()) {
+		t.Fatalf("expected a restart after 1s, got %v", status.RestartAt)
+	}
+
+	svc.mu.Lock()
+	if svc.canRestart(key, now) || !svc.canRestart(key, now.Add(time.Second)) {
+		t.Fatalf("expected the indexer to wait for its restart delay")
+	}
+	svc.recordCrash(key, 1, contract, fmt.Errorf("store closed"), now)
+	if svc.canRestart(key, now.Add(time.Second)) || !svc.canRestart(key, now.Add(2*time.Second)) {
+		t.Fatalf("expected the restart delay to double")
+	}
+	svc.recordCrash(key, 1, contract, fmt.Errorf("log index overflow"), now)
+	if svc.canRestart(key, now.Add(time.Hour)) {
+		t.Fatalf("expected a failed indexer not to be restarted")
+	}
+	svc.mu.Unlock()
+	status, _ = svc.SupervisorStatus(1, contract)
+	if status.State != IndexerFailed || status.Failures != 3 || status.LastError != "log index overflow" || status.RestartAt != nil {
+		t.Fatalf("expected a failed indexer after 3 failures, got %+v", status)
+	}
+
+	if !svc.RestartIndexer(1, contract) {
+		t.Fatalf("expected the failed indexer to be restarted")
+	}
+	if _, ok := svc.SupervisorStatus(1, contract); ok {
+		t.Fatalf("expected no crash state after a restart")
+	}
+	if svc.RestartIndexer(1, contract) {
+		t.Fatalf("expected no restart of an indexer that has not crashed")
+	}
+}
+
+func TestSupervisorForgetsRecoveredIndexers(t *testing.T) {
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	svc, err := NewService(ServiceConfig{
+		Pool:            NewRPCPool(nil),
+		Store:           store.New(database),
+		MaxRestartDelay: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("create indexer service: %v", err)
+	}
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	key := contractKey(1, contract)
+	now := time.Now()
+
+	svc.mu.Lock()
+	svc.recordCrash(key, 1, contract, fmt.Errorf("store closed"), now)
+	svc.indexers[key] = &managedIndexer{startedAt: now}
+	svc.clearRecovered(now.Add(30 * time.Second))
+	svc.mu.Unlock()
+	if status, ok := svc.SupervisorStatus(1, contract); !ok || status.State != IndexerRecovering {
+		t.Fatalf("expected a recovering indexer, got %+v", status)
+	}
+
+	svc.mu.Lock()
+	svc.clearRecovered(now.Add(time.Minute))
+	svc.mu.Unlock()
+	if _, ok := svc.SupervisorStatus(1, contract); ok {
+		t.Fatalf("expected the crashes of a recovered indexer to be forgotten")
+	}
+}
@@ -0,0 +1,158 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/log"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+// EIP1967ImplementationSlot is the storage slot holding the implementation of
+// an EIP-1967 proxy: keccak256("eip1967.proxy.implementation") - 1.
+var EIP1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// upgradeTracker follows the EIP-1967 implementation of the indexed contract
+// at verified batch boundaries.
+type upgradeTracker struct {
+	// pause stops indexing after an upgrade until it is acknowledged.
+	pause bool
+	// loaded is set once the known implementation was read from the store.
+	loaded         bool
+	implementation common.Address
+	paused         bool
+	readFunc       func(ctx context.Context, block uint64) (common.Address, error)
+}
+
+// loadUpgrades reads the last recorded implementation and pause state of the
+// contract.
+func (i *Indexer) loadUpgrades(ctx context.Context) error {
+	record, ok, err := i.store.GetContract(ctx, i.chainID, i.contract)
+	if err != nil {
+		return fmt.Errorf("load contract upgrades: %w", err)
+	}
+	u := i.upgrades
+	u.implementation = common.Address{}
+	u.paused = false
+	if ok {
+		if n := len(record.Upgrades); n > 0 {
+			u.implementation = common.HexToAddress(record.Upgrades[n-1].Implementation)
+		}
+		u.paused = record.UpgradePending
+	}
+	u.loaded = true
+	return nil
+}
+
+// pausedByUpgrade reports whether indexing is paused by an upgrade that has not
+// been acknowledged yet.
+func (i *Indexer) pausedByUpgrade(ctx context.Context) (bool, error) {
+	u := i.upgrades
+	if u == nil || u.readFunc == nil {
+		return false, nil
+	}
+	if !u.loaded || u.paused {
+		if err := i.loadUpgrades(ctx); err != nil {
+			return false, err
+		}
+	}
+	if u.paused {
+		log.Debugw("indexing paused until the proxy upgrade is acknowledged",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"implementation", u.implementation.Hex(),
+		)
+	}
+	return u.paused, nil
+}
+
+// upgradePaused reports whether the last check paused indexing.
+func (i *Indexer) upgradePaused() bool {
+	return i.upgrades != nil && i.upgrades.paused
+}
+
+// checkImplementation reads the proxy implementation at a verified block and
+// records it when it changed. Read errors only skip the check, since full
+// nodes cannot serve the storage of old blocks during a backfill.
+func (i *Indexer) checkImplementation(ctx context.Context, block uint64) error {
+	u := i.upgrades
+	if u == nil || u.readFunc == nil {
+		return nil
+	}
+	if !u.loaded {
+		if err := i.loadUpgrades(ctx); err != nil {
+			return err
+		}
+	}
+	implementation, err := u.readFunc(ctx, block)
+	if err != nil {
+		log.Debugw("cannot read proxy implementation", "chainID", i.chainID, "contract", i.contract.Hex(), "block", block, "err", err)
+		return nil
+	}
+	if implementation == u.implementation {
+		return nil
+	}
+	// The first implementation of a proxy is recorded without pausing.
+	upgraded := u.implementation != (common.Address{})
+	pause := upgraded && u.pause
+	if err := i.store.RecordContractUpgrade(ctx, i.chainID, i.contract, store.ProxyUpgrade{
+		Implementation: implementation.Hex(),
+		Block:          block,
+		DetectedAt:     time.Now(),
+	}, pause); err != nil {
+		return fmt.Errorf("record contract upgrade: %w", err)
+	}
+	if upgraded {
+		log.Warnw("proxy implementation upgraded",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"previous", u.implementation.Hex(),
+			"implementation", implementation.Hex(),
+			"block", block,
+			"paused", pause,
+		)
+	} else {
+		log.Infow("proxy implementation detected",
+			"chainID", i.chainID,
+			"contract", i.contract.Hex(),
+			"implementation", implementation.Hex(),
+			"block", block,
+		)
+	}
+	u.implementation = implementation
+	u.paused = u.paused || pause
+	return nil
+}
+
+// implementationChanged reports whether the proxy implementation at block
+// differs from the recorded one. As in checkImplementation, blocks whose
+// storage cannot be read count as unchanged, and so does the first
+// implementation of a proxy, which is not an upgrade.
+func (i *Indexer) implementationChanged(ctx context.Context, block uint64) bool {
+	u := i.upgrades
+	if u == nil || u.readFunc == nil || u.implementation == (common.Address{}) {
+		return false
+	}
+	implementation, err := u.readFunc(ctx, block)
+	return err == nil && implementation != u.implementation
+}
+
+// readImplementation reads the EIP-1967 implementation slot of the contract.
+func (i *Indexer) readImplementation(ctx context.Context, block uint64) (common.Address, error) {
+	if err := i.limiter.Wait(ctx, i.chainID); err != nil {
+		return common.Address{}, err
+	}
+	client, err := i.client.EthClient()
+	if err != nil {
+		return common.Address{}, err
+	}
+	value, err := client.StorageAt(ctx, i.contract, EIP1967ImplementationSlot, new(big.Int).SetUint64(block))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(value), nil
+}
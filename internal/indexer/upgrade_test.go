@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+func TestSyncOncePausesOnProxyUpgrade(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := store.New(database)
+
+	contract := common.HexToAddress("0x5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a")
+	if err := eventStore.SaveContract(ctx, 1, contract, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	first := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	second := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	var reads []uint64
+	idx := &Indexer{
+		store:           eventStore,
+		chainID:         1,
+		contract:        contract,
+		startBlock:      1,
+		batchSize:       2,
+		verifyBatchSize: 2,
+		tailRescanDepth: 2,
+		upgrades: &upgradeTracker{
+			pause: true,
+			readFunc: func(_ context.Context, block uint64) (common.Address, error) {
+				reads = append(reads, block)
+				switch {
+				case block == 4:
+					return common.Address{}, errors.New("missing trie node")
+				case block < 5:
+					return first, nil
+				default:
+					return second, nil
+				}
+			},
+		},
+	}
+	head := uint64(6)
+	idx.headFunc = func(context.Context) (uint64, error) {
+		return head, nil
+	}
+	idx.eventsFunc = func(context.Context, uint64, uint64) ([]store.Event, error) {
+		return nil, nil
+	}
+
+	state, err := idx.loadProgress(ctx)
+	if err != nil {
+		t.Fatalf("load progress: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once: %v", err)
+	}
+	// The failed read at block 4 is skipped and the upgrade found at block 6.
+	if len(reads) != 3 || reads[2] != 6 {
+		t.Fatalf("expected reads at blocks 2, 4 and 6, got %v", reads)
+	}
+	record, _, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("get contract: %v", err)
+	}
+	if len(record.Upgrades) != 2 || record.Upgrades[0].Implementation != first.Hex() || record.Upgrades[0].Block != 2 ||
+		record.Upgrades[1].Implementation != second.Hex() || record.Upgrades[1].Block != 6 {
+		t.Fatalf("expected implementations %s at block 2 and %s at block 6, got %+v", first.Hex(), second.Hex(), record.Upgrades)
+	}
+	if !record.UpgradePending {
+		t.Fatalf("expected the upgrade to pause indexing")
+	}
+
+	head = 8
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once while paused: %v", err)
+	}
+	if state.verifiedUntil != 6 {
+		t.Fatalf("expected indexing to stay paused at block 6, got %d", state.verifiedUntil)
+	}
+
+	if _, err := eventStore.AcknowledgeContractUpgrade(ctx, 1, contract); err != nil {
+		t.Fatalf("acknowledge upgrade: %v", err)
+	}
+	if err := idx.syncOnce(ctx, &state); err != nil {
+		t.Fatalf("sync once after acknowledgement: %v", err)
+	}
+	if state.verifiedUntil != 8 {
+		t.Fatalf("expected indexing to resume up to block 8, got %d", state.verifiedUntil)
+	}
+	record, _, err = eventStore.GetContract(ctx, 1, contract)
+	if err != nil || len(record.Upgrades) != 2 {
+		t.Fatalf("expected no new upgrade, got %+v (err=%v)", record.Upgrades, err)
+	}
+}
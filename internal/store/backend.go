@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Backend is the storage used by the indexer and the HTTP and GraphQL APIs.
+// Store, backed by Pebble, is the default implementation; PostgresStore keeps
+// the same data in SQL tables shared by several processes.
+type Backend interface {
+	LastIndexedBlock(ctx context.Context, chainID uint64, contract common.Address) (uint64, bool, error)
+	LastVerifiedBlock(ctx context.Context, chainID uint64, contract common.Address) (uint64, bool, error)
+	SaveEvents(ctx context.Context, chainID uint64, contract common.Address, events []Event, lastIndexedBlock uint64) error
+	ReplaceEventsInRange(ctx context.Context, chainID uint64, contract common.Address, from, to uint64, events []Event, opts ReplaceOptions) error
+	IterateEvents(ctx context.Context, chainID uint64, contract common.Address, fn func(Event) error) error
+	IterateEventsInRange(ctx context.Context, chainID uint64, contract common.Address, from, to uint64, fn func(Event) error) error
+	ListEvents(ctx context.Context, opts ListOptions) ([]Event, error)
+
+	GetContract(ctx context.Context, chainID uint64, contract common.Address) (ContractRecord, bool, error)
+	SaveContract(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, expiresAt time.Time) error
+	SetContractStartBlock(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, method string) error
+	SetContractDeploymentTx(ctx context.Context, chainID uint64, contract common.Address, txHash common.Hash) error
+	SetContractEventProfile(ctx context.Context, chainID uint64, contract common.Address, profile *EventProfile) error
+	RecordContractUpgrade(ctx context.Context, chainID uint64, contract common.Address, upgrade ProxyUpgrade, pause bool) error
+	AcknowledgeContractUpgrade(ctx context.Context, chainID uint64, contract common.Address) (bool, error)
+	SetContractLabel(ctx context.Context, chainID uint64, contract common.Address, label string) error
+	ListContracts(ctx context.Context) ([]ContractRecord, error)
+	DeleteContractData(ctx context.Context, chainID uint64, contract common.Address) error
+	ArchiveContract(ctx context.Context, chainID uint64, contract common.Address, archive ContractArchive) error
+	UnarchiveContract(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time) error
+	SetContractExpiry(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time, policy *ExpiryPolicy) error
+
+	ScheduleReindex(ctx context.Context, chainID uint64, contract common.Address, from, to uint64) (ReindexJob, error)
+	ListReindexJobs(ctx context.Context, chainID uint64, contract common.Address) ([]ReindexJob, error)
+	GetReindexJob(ctx context.Context, chainID uint64, contract common.Address, id uint64) (ReindexJob, bool, error)
+
+	ListBackfillSegments(ctx context.Context, chainID uint64, contract common.Address) ([]BackfillSegment, error)
+	MergeBackfillSegments(ctx context.Context, chainID uint64, contract common.Address, verifiedUntil uint64) (uint64, error)
+
+	Migrate(ctx context.Context, opts MigrateOptions) (MigrationReport, error)
+	Compact(ctx context.Context) error
+}
+
+var (
+	_ Backend = (*Store)(nil)
+	_ Backend = (*PostgresStore)(nil)
+)
@@ -0,0 +1,31 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+	"github.com/vocdoni/onchain-census-indexer/internal/store/storetest"
+)
+
+func TestPebbleBackend(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		database, err := metadb.New(db.TypeInMem, "")
+		if err != nil {
+			t.Fatalf("create in-memory db: %v", err)
+		}
+		t.Cleanup(func() {
+			if cerr := database.Close(); cerr != nil {
+				t.Fatalf("close db: %v", cerr)
+			}
+		})
+		backend := store.New(database)
+		if _, err := backend.Migrate(context.Background(), store.MigrateOptions{}); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return backend
+	})
+}
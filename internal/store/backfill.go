@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+)
+
+const backfillKeyPrefix = "meta:backfill:"
+
+// BackfillSegment is a range of blocks indexed and verified by a backfill
+// worker ahead of the progress cursors. Segments are merged into the cursors
+// once every block before them is verified.
+type BackfillSegment struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// Contains reports whether block is part of the segment.
+func (s BackfillSegment) Contains(block uint64) bool {
+	return s.From <= block && block <= s.To
+}
+
+// ListBackfillSegments returns the completed backfill segments of a contract
+// ordered by their first block.
+func (s *Store) ListBackfillSegments(ctx context.Context, chainID uint64, contract common.Address) ([]BackfillSegment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var (
+		results []BackfillSegment
+		iterErr error
+	)
+	err := s.db.Iterate(backfillPrefix(chainID, contract), func(_, value []byte) bool {
+		var segment BackfillSegment
+		if err := json.Unmarshal(value, &segment); err != nil {
+			iterErr = fmt.Errorf("decode backfill segment: %w", err)
+			return false
+		}
+		results = append(results, segment)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterate backfill segments: %w", err)
+	}
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	return results, nil
+}
+
+// MergeBackfillSegments advances the indexed and verified cursors through the
+// segments contiguous with verifiedUntil and deletes them, together with the
+// segments already behind it. It returns the new verified cursor.
+func (s *Store) MergeBackfillSegments(ctx context.Context, chainID uint64, contract common.Address, verifiedUntil uint64) (uint64, error) {
+	segments, err := s.ListBackfillSegments(ctx, chainID, contract)
+	if err != nil {
+		return 0, err
+	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	merged := verifiedUntil
+	removed := 0
+	for _, segment := range segments {
+		if segment.From > merged+1 {
+			break
+		}
+		merged = max(merged, segment.To)
+		if err := tx.Delete(backfillKey(chainID, contract, segment.From)); err != nil {
+			return 0, fmt.Errorf("delete backfill segment: %w", err)
+		}
+		removed++
+	}
+	if removed == 0 {
+		return verifiedUntil, nil
+	}
+	if merged > verifiedUntil {
+		indexedUntil, ok, err := s.LastIndexedBlock(ctx, chainID, contract)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || indexedUntil < merged {
+			indexedUntil = merged
+		}
+		if err := s.setProgressBlocks(tx, chainID, contract, ReplaceOptions{
+			IndexedUntil:  &indexedUntil,
+			VerifiedUntil: &merged,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit backfill merge: %w", err)
+	}
+	return merged, nil
+}
+
+func setBackfillSegment(tx db.WriteTx, chainID uint64, contract common.Address, segment BackfillSegment) error {
+	payload, err := json.Marshal(segment)
+	if err != nil {
+		return fmt.Errorf("marshal backfill segment: %w", err)
+	}
+	if err := tx.Set(backfillKey(chainID, contract, segment.From), payload); err != nil {
+		return fmt.Errorf("store backfill segment: %w", err)
+	}
+	return nil
+}
+
+func backfillPrefix(chainID uint64, contract common.Address) []byte {
+	key := make([]byte, len(backfillKeyPrefix)+8+contractAddressBytes)
+	copy(key, backfillKeyPrefix)
+	offset := len(backfillKeyPrefix)
+	binary.BigEndian.PutUint64(key[offset:], chainID)
+	offset += 8
+	copy(key[offset:], contract.Bytes())
+	return key
+}
+
+func backfillKey(chainID uint64, contract common.Address, from uint64) []byte {
+	return binary.BigEndian.AppendUint64(backfillPrefix(chainID, contract), from)
+}
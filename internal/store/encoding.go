@@ -0,0 +1,259 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Event values are stored either as legacy JSON objects or in a versioned
+// binary layout. The binary layout only holds what the key does not:
+//
+//	version (1) | account (20) | previousWeight (11, uint88 BE) | newWeight (11, uint88 BE)
+//
+// Chain ID, contract, block number and log index are decoded from the key.
+const (
+	eventEncodingV1 byte = 0x01
+	weightBytes          = 11
+	eventValueV1Len      = 1 + common.AddressLength + 2*weightBytes
+
+	// migrationBlockGroupBits sets how many blocks are rewritten per migration
+	// transaction (1<<bits).
+	migrationBlockGroupBits = 16
+)
+
+// encodeEvent returns the binary value of event. Events that the binary layout
+// cannot represent losslessly (non-checksummed accounts, weights that are not
+// canonical uint88 decimals) and events with decoded arguments keep the legacy
+// JSON encoding.
+func encodeEvent(event Event) ([]byte, error) {
+	value, ok := encodeEventV1(event)
+	if ok {
+		return value, nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+	return payload, nil
+}
+
+func encodeEventV1(event Event) ([]byte, bool) {
+	if len(event.Args) > 0 || !common.IsHexAddress(event.Account) {
+		return nil, false
+	}
+	account := common.HexToAddress(event.Account)
+	if account.Hex() != event.Account {
+		return nil, false
+	}
+	value := make([]byte, eventValueV1Len)
+	value[0] = eventEncodingV1
+	copy(value[1:], account.Bytes())
+	offset := 1 + common.AddressLength
+	for _, weight := range []string{event.PreviousWeight, event.NewWeight} {
+		n, ok := new(big.Int).SetString(weight, 10)
+		if !ok || n.Sign() < 0 || n.BitLen() > 8*weightBytes || n.String() != weight {
+			return nil, false
+		}
+		n.FillBytes(value[offset : offset+weightBytes])
+		offset += weightBytes
+	}
+	return value, true
+}
+
+// decodeEvent decodes an event value in any supported encoding. key must be
+// the full event key.
+func decodeEvent(key, value []byte) (Event, error) {
+	var decoder eventDecoder
+	return decoder.decode(key, value)
+}
+
+// eventDecoder decodes event values while reusing the state shared by
+// consecutive events of a contract: its checksummed address and the keccak
+// state used to checksum accounts. The zero value is ready to use.
+type eventDecoder struct {
+	contract    common.Address
+	contractHex string
+	hasher      crypto.KeccakState
+	hexBuf      [2 + 2*common.AddressLength]byte
+	hashBuf     [32]byte
+}
+
+func (d *eventDecoder) decode(key, value []byte) (Event, error) {
+	if isLegacyEventValue(value) {
+		var event Event
+		if err := json.Unmarshal(value, &event); err != nil {
+			return Event{}, fmt.Errorf("decode event: %w", err)
+		}
+		return event, nil
+	}
+	if len(value) == 0 {
+		return Event{}, fmt.Errorf("decode event: empty value")
+	}
+	if value[0] != eventEncodingV1 {
+		return Event{}, fmt.Errorf("decode event: unsupported encoding version %d", value[0])
+	}
+	if len(value) != eventValueV1Len {
+		return Event{}, fmt.Errorf("decode event: invalid value length %d", len(value))
+	}
+	blockNumber, err := eventBlockNumber(key)
+	if err != nil {
+		return Event{}, fmt.Errorf("decode event: %w", err)
+	}
+	chainID, contract, logIndex := parseEventKey(key)
+	if d.contractHex == "" || contract != d.contract {
+		d.contract = contract
+		d.contractHex = d.checksumHex(contract)
+	}
+	offset := 1 + common.AddressLength
+	return Event{
+		ChainID:        chainID,
+		Contract:       d.contractHex,
+		Account:        d.checksumHex(common.BytesToAddress(value[1:offset])),
+		PreviousWeight: formatWeight(value[offset : offset+weightBytes]),
+		NewWeight:      formatWeight(value[offset+weightBytes:]),
+		BlockNumber:    blockNumber,
+		LogIndex:       logIndex,
+	}, nil
+}
+
+// checksumHex returns the EIP-55 form of addr, matching common.Address.Hex
+// without allocating a new hasher per call.
+func (d *eventDecoder) checksumHex(addr common.Address) string {
+	if d.hasher == nil {
+		d.hasher = crypto.NewKeccakState()
+	}
+	buf := d.hexBuf[:]
+	copy(buf, "0x")
+	hex.Encode(buf[2:], addr[:])
+	d.hasher.Reset()
+	_, _ = d.hasher.Write(buf[2:])
+	_, _ = d.hasher.Read(d.hashBuf[:])
+	for i := 2; i < len(buf); i++ {
+		nibble := d.hashBuf[(i-2)/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		} else {
+			nibble &= 0xf
+		}
+		if buf[i] > '9' && nibble > 7 {
+			buf[i] -= 32
+		}
+	}
+	return string(buf)
+}
+
+// formatWeight renders a big-endian uint88 as a decimal string.
+func formatWeight(value []byte) string {
+	if value[0] == 0 && value[1] == 0 && value[2] == 0 {
+		return strconv.FormatUint(binary.BigEndian.Uint64(value[3:]), 10)
+	}
+	return new(big.Int).SetBytes(value).String()
+}
+
+func isLegacyEventValue(value []byte) bool {
+	return len(value) > 0 && value[0] == '{'
+}
+
+// MigrateEventEncoding rewrites legacy JSON event values of every stored
+// contract into the binary encoding. It works through one block group at a
+// time so it can run in the background while indexing continues, and it is
+// safe to interrupt and run again. It returns the number of rewritten events.
+func (s *Store) MigrateEventEncoding(ctx context.Context) (uint64, error) {
+	records, err := s.ListContracts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var migrated uint64
+	for _, record := range records {
+		if !common.IsHexAddress(record.Contract) {
+			continue
+		}
+		contract := common.HexToAddress(record.Contract)
+		lastBlock, ok, err := s.LastIndexedBlock(ctx, record.ChainID, contract)
+		if err != nil {
+			return migrated, err
+		}
+		if !ok {
+			continue
+		}
+		for group := record.StartBlock >> migrationBlockGroupBits; group <= lastBlock>>migrationBlockGroupBits; group++ {
+			count, err := s.migrateEventGroup(ctx, record.ChainID, contract, group)
+			migrated += count
+			if err != nil {
+				return migrated, err
+			}
+		}
+	}
+	return migrated, nil
+}
+
+func (s *Store) migrateEventGroup(ctx context.Context, chainID uint64, contract common.Address, group uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	var groupKey [8]byte
+	binary.BigEndian.PutUint64(groupKey[:], group<<migrationBlockGroupBits)
+	prefix := append(eventPrefix(chainID, contract), groupKey[:8-migrationBlockGroupBits/8]...)
+
+	type rewrite struct {
+		key, value []byte
+	}
+	var (
+		rewrites []rewrite
+		iterErr  error
+	)
+	err := s.db.Iterate(prefix, func(key, value []byte) bool {
+		if !isLegacyEventValue(value) {
+			return true
+		}
+		fullKey := fullIteratedKey(prefix, key)
+		event, err := decodeEvent(fullKey, value)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		// Legacy payloads that disagree with their key are left for fsck.
+		blockNumber, _ := eventBlockNumber(fullKey)
+		_, _, logIndex := parseEventKey(fullKey)
+		if event.ChainID != chainID || !common.IsHexAddress(event.Contract) || common.HexToAddress(event.Contract) != contract ||
+			event.BlockNumber != blockNumber || event.LogIndex != logIndex {
+			return true
+		}
+		if encoded, ok := encodeEventV1(event); ok {
+			rewrites = append(rewrites, rewrite{key: fullKey, value: encoded})
+		}
+		return true
+	})
+	if iterErr != nil {
+		return 0, iterErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("iterate events: %w", err)
+	}
+	if len(rewrites) == 0 {
+		return 0, nil
+	}
+
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	for _, r := range rewrites {
+		if err := tx.Set(r.key, r.value); err != nil {
+			return 0, fmt.Errorf("store migrated event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit migrated events: %w", err)
+	}
+	return uint64(len(rewrites)), nil
+}
@@ -0,0 +1,527 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Finding kinds reported by Fsck.
+const (
+	FindingInvalidEventKey        = "invalid_event_key"
+	FindingInvalidEventPayload    = "invalid_event_payload"
+	FindingEventKeyMismatch       = "event_key_mismatch"
+	FindingOrphanedEvents         = "orphaned_events"
+	FindingEventsBelowStartBlock  = "events_below_start_block"
+	FindingEventsBeyondIndexed    = "events_beyond_indexed_cursor"
+	FindingInvalidContract        = "invalid_contract_record"
+	FindingInvalidCursor          = "invalid_cursor"
+	FindingOrphanedCursor         = "orphaned_cursor"
+	FindingCursorBelowStartBlock  = "cursor_below_start_block"
+	FindingVerifiedAheadIndexed   = "verified_ahead_of_indexed"
+	FindingInvalidReindexJob      = "invalid_reindex_job"
+	FindingOrphanedReindexJobs    = "orphaned_reindex_jobs"
+	FindingInvalidBackfillSegment = "invalid_backfill_segment"
+	FindingUnknownKey             = "unknown_key"
+)
+
+const fsckRepairBatchSize = 1000
+
+// FsckOptions controls Fsck.
+type FsckOptions struct {
+	// Repair deletes orphaned and corrupt entries and resets progress cursors
+	// so affected ranges are indexed and verified again.
+	Repair bool
+}
+
+// FsckFinding describes an inconsistency found in the store.
+type FsckFinding struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key,omitempty"`
+	ChainID  uint64 `json:"chainId,omitempty"`
+	Contract string `json:"contract,omitempty"`
+	Count    uint64 `json:"count,omitempty"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+// FsckReport summarizes a store integrity check.
+type FsckReport struct {
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Repair     bool          `json:"repair"`
+	Contracts  int           `json:"contracts"`
+	Events     uint64        `json:"events"`
+	Findings   []FsckFinding `json:"findings"`
+}
+
+// Clean reports whether every finding has been repaired.
+func (r FsckReport) Clean() bool {
+	for _, finding := range r.Findings {
+		if !finding.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+type fsckContract struct {
+	chainID      uint64
+	contract     common.Address
+	record       *ContractRecord
+	corrupt      bool
+	indexed      *uint64
+	verified     *uint64
+	belowStart   uint64
+	beyondCursor uint64
+	orphaned     uint64
+	jobs         uint64
+	segments     []BackfillSegment
+	resetFrom    *uint64
+}
+
+type fsckState struct {
+	contracts  map[string]*fsckContract
+	deleteKeys [][]byte
+	findings   []FsckFinding
+}
+
+// Fsck walks every evt: and meta: key, validating key layout, payload decoding,
+// progress cursor invariants and orphaned data. With opts.Repair set, orphaned
+// and corrupt entries are deleted and cursors are reset so the indexer
+// re-processes the affected ranges.
+func (s *Store) Fsck(ctx context.Context, opts FsckOptions) (FsckReport, error) {
+	report := FsckReport{StartedAt: time.Now().UTC(), Repair: opts.Repair}
+	state := &fsckState{contracts: make(map[string]*fsckContract)}
+
+	if err := s.fsckContracts(ctx, state); err != nil {
+		return FsckReport{}, err
+	}
+	for _, cursor := range []struct {
+		prefix   string
+		verified bool
+	}{
+		{prefix: lastBlockKeyPrefix},
+		{prefix: verifiedBlockKeyPref, verified: true},
+	} {
+		if err := s.fsckCursors(ctx, state, cursor.prefix, cursor.verified); err != nil {
+			return FsckReport{}, err
+		}
+	}
+	if err := s.fsckReindexJobs(ctx, state); err != nil {
+		return FsckReport{}, err
+	}
+	if err := s.fsckBackfillSegments(ctx, state); err != nil {
+		return FsckReport{}, err
+	}
+	if err := s.fsckUnknownMeta(ctx, state); err != nil {
+		return FsckReport{}, err
+	}
+	events, err := s.fsckEvents(ctx, state)
+	if err != nil {
+		return FsckReport{}, err
+	}
+	cursorUpdates := state.checkContracts()
+
+	for _, c := range state.contracts {
+		if c.record != nil {
+			report.Contracts++
+		}
+	}
+	report.Events = events
+	if opts.Repair {
+		if err := s.fsckRepair(ctx, state, cursorUpdates); err != nil {
+			return FsckReport{}, err
+		}
+		for i := range state.findings {
+			if state.findings[i].Kind != FindingInvalidContract && state.findings[i].Kind != FindingUnknownKey {
+				state.findings[i].Repaired = true
+			}
+		}
+	}
+	report.Findings = state.findings
+	if report.Findings == nil {
+		report.Findings = []FsckFinding{}
+	}
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+func (st *fsckState) contract(chainID uint64, contract common.Address) *fsckContract {
+	key := fmt.Sprintf("%d:%s", chainID, strings.ToLower(contract.Hex()))
+	c, ok := st.contracts[key]
+	if !ok {
+		c = &fsckContract{chainID: chainID, contract: contract}
+		st.contracts[key] = c
+	}
+	return c
+}
+
+func (st *fsckState) add(finding FsckFinding) {
+	st.findings = append(st.findings, finding)
+}
+
+func (s *Store) fsckContracts(ctx context.Context, state *fsckState) error {
+	prefix := []byte(contractKeyPrefix)
+	return s.fsckIterate(ctx, prefix, func(key, value []byte) {
+		chainID, contract, ok := parseContractScopedKey(key, contractKeyPrefix)
+		if !ok {
+			state.add(FsckFinding{Kind: FindingInvalidContract, Key: hex.EncodeToString(key), Detail: "invalid contract key length"})
+			return
+		}
+		var record ContractRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			state.add(FsckFinding{Kind: FindingInvalidContract, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: fmt.Sprintf("decode contract: %v", err)})
+			state.contract(chainID, contract).corrupt = true
+			return
+		}
+		if record.ChainID != chainID || !common.IsHexAddress(record.Contract) || common.HexToAddress(record.Contract) != contract {
+			state.add(FsckFinding{Kind: FindingInvalidContract, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: "contract record does not match its key"})
+			state.contract(chainID, contract).corrupt = true
+			return
+		}
+		state.contract(chainID, contract).record = &record
+	})
+}
+
+func (s *Store) fsckCursors(ctx context.Context, state *fsckState, prefix string, verified bool) error {
+	return s.fsckIterate(ctx, []byte(prefix), func(key, value []byte) {
+		chainID, contract, ok := parseContractScopedKey(key, prefix)
+		if !ok {
+			state.add(FsckFinding{Kind: FindingInvalidCursor, Key: hex.EncodeToString(key), Detail: "invalid cursor key length"})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		block, err := decodeUint64(value)
+		if err != nil {
+			state.add(FsckFinding{Kind: FindingInvalidCursor, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: err.Error()})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		c := state.contract(chainID, contract)
+		if !validContractIdentity(chainID, contract) {
+			state.deleteKeys = append(state.deleteKeys, key)
+		}
+		if verified {
+			c.verified = &block
+		} else {
+			c.indexed = &block
+		}
+	})
+}
+
+func (s *Store) fsckReindexJobs(ctx context.Context, state *fsckState) error {
+	return s.fsckIterate(ctx, []byte(reindexKeyPrefix), func(key, value []byte) {
+		var job ReindexJob
+		if len(key) != len(reindexKeyPrefix)+8+contractAddressBytes+8 {
+			state.add(FsckFinding{Kind: FindingInvalidReindexJob, Key: hex.EncodeToString(key), Detail: "invalid reindex job key length"})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		chainID, contract, _ := parseContractScopedKey(key[:len(key)-8], reindexKeyPrefix)
+		if err := json.Unmarshal(value, &job); err != nil {
+			state.add(FsckFinding{Kind: FindingInvalidReindexJob, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: fmt.Sprintf("decode reindex job: %v", err)})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		c := state.contract(chainID, contract)
+		if !validContractIdentity(chainID, contract) {
+			state.deleteKeys = append(state.deleteKeys, key)
+		}
+		c.jobs++
+	})
+}
+
+func (s *Store) fsckBackfillSegments(ctx context.Context, state *fsckState) error {
+	return s.fsckIterate(ctx, []byte(backfillKeyPrefix), func(key, value []byte) {
+		var segment BackfillSegment
+		if len(key) != len(backfillKeyPrefix)+8+contractAddressBytes+8 {
+			state.add(FsckFinding{Kind: FindingInvalidBackfillSegment, Key: hex.EncodeToString(key), Detail: "invalid backfill segment key length"})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		chainID, contract, _ := parseContractScopedKey(key[:len(key)-8], backfillKeyPrefix)
+		if err := json.Unmarshal(value, &segment); err != nil || segment.From > segment.To ||
+			segment.From != binary.BigEndian.Uint64(key[len(key)-8:]) {
+			state.add(FsckFinding{Kind: FindingInvalidBackfillSegment, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: "backfill segment does not match its key"})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		c := state.contract(chainID, contract)
+		c.segments = append(c.segments, segment)
+	})
+}
+
+func (s *Store) fsckUnknownMeta(ctx context.Context, state *fsckState) error {
+	return s.fsckIterate(ctx, []byte(metaKeyPrefix), func(key, _ []byte) {
+		for _, known := range knownMetaKeyPrefixes {
+			if bytes.HasPrefix(key, []byte(known)) {
+				return
+			}
+		}
+		state.add(FsckFinding{Kind: FindingUnknownKey, Key: hex.EncodeToString(key), Detail: fmt.Sprintf("unknown meta key %q", key)})
+	})
+}
+
+func (s *Store) fsckEvents(ctx context.Context, state *fsckState) (uint64, error) {
+	var events uint64
+	err := s.fsckIterate(ctx, []byte(eventKeyPrefix), func(key, value []byte) {
+		events++
+		blockNumber, err := eventBlockNumber(key)
+		if err != nil {
+			state.add(FsckFinding{Kind: FindingInvalidEventKey, Key: hex.EncodeToString(key), Detail: err.Error()})
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		chainID, contract, logIndex := parseEventKey(key)
+		c := state.contract(chainID, contract)
+		if c.record == nil {
+			// Data of a contract whose record cannot be decoded is left alone
+			// until the record is fixed by hand.
+			if c.corrupt {
+				return
+			}
+			c.orphaned++
+			if !validContractIdentity(chainID, contract) {
+				state.deleteKeys = append(state.deleteKeys, key)
+			}
+			return
+		}
+		detail := ""
+		event, err := decodeEvent(key, value)
+		if err != nil {
+			detail = err.Error()
+		} else if event.ChainID != chainID || !common.IsHexAddress(event.Contract) || common.HexToAddress(event.Contract) != contract ||
+			event.BlockNumber != blockNumber || event.LogIndex != logIndex {
+			detail = "event payload does not match its key"
+		}
+		if detail != "" {
+			kind := FindingInvalidEventPayload
+			if !strings.HasPrefix(detail, "decode") {
+				kind = FindingEventKeyMismatch
+			}
+			state.add(FsckFinding{Kind: kind, Key: hex.EncodeToString(key), ChainID: chainID, Contract: contract.Hex(), Detail: detail})
+			state.deleteKeys = append(state.deleteKeys, key)
+			c.resetBefore(blockNumber)
+			// A segment holding a corrupt event is backfilled again.
+			for _, segment := range c.segments {
+				if segment.Contains(blockNumber) {
+					state.deleteKeys = append(state.deleteKeys, backfillKey(chainID, contract, segment.From))
+				}
+			}
+			return
+		}
+		if c.record.StartBlock > 0 && blockNumber < c.record.StartBlock {
+			c.belowStart++
+			state.deleteKeys = append(state.deleteKeys, key)
+			return
+		}
+		if (c.indexed == nil || blockNumber > *c.indexed) && !c.backfilled(blockNumber) {
+			c.beyondCursor++
+			state.deleteKeys = append(state.deleteKeys, key)
+		}
+	})
+	return events, err
+}
+
+// backfilled reports whether block is covered by a completed backfill segment.
+func (c *fsckContract) backfilled(block uint64) bool {
+	for _, segment := range c.segments {
+		if segment.Contains(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetBefore records that progress must be rewound so block is processed again.
+func (c *fsckContract) resetBefore(block uint64) {
+	cursor := uint64(0)
+	if block > 0 {
+		cursor = block - 1
+	}
+	if c.resetFrom == nil || cursor < *c.resetFrom {
+		c.resetFrom = &cursor
+	}
+}
+
+type fsckCursorUpdate struct {
+	chainID  uint64
+	contract common.Address
+	opts     ReplaceOptions
+	orphan   bool
+}
+
+// checkContracts validates per-contract invariants once all keys were visited
+// and returns the cursor changes a repair must apply.
+func (st *fsckState) checkContracts() []fsckCursorUpdate {
+	keys := make([]string, 0, len(st.contracts))
+	for key := range st.contracts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var updates []fsckCursorUpdate
+	for _, key := range keys {
+		c := st.contracts[key]
+		if c.corrupt {
+			continue
+		}
+		if c.record == nil {
+			if c.orphaned > 0 {
+				st.add(FsckFinding{Kind: FindingOrphanedEvents, ChainID: c.chainID, Contract: c.contract.Hex(), Count: c.orphaned, Detail: "events stored for a contract without a meta:contract: record"})
+			}
+			if c.jobs > 0 {
+				st.add(FsckFinding{Kind: FindingOrphanedReindexJobs, ChainID: c.chainID, Contract: c.contract.Hex(), Count: c.jobs, Detail: "reindex jobs stored for a contract without a meta:contract: record"})
+			}
+			if c.indexed != nil || c.verified != nil {
+				st.add(FsckFinding{Kind: FindingOrphanedCursor, ChainID: c.chainID, Contract: c.contract.Hex(), Detail: "progress cursors stored for a contract without a meta:contract: record"})
+			}
+			if validContractIdentity(c.chainID, c.contract) && (c.orphaned > 0 || c.jobs > 0 || c.indexed != nil || c.verified != nil) {
+				updates = append(updates, fsckCursorUpdate{chainID: c.chainID, contract: c.contract, orphan: true})
+			}
+			continue
+		}
+		if c.belowStart > 0 {
+			st.add(FsckFinding{Kind: FindingEventsBelowStartBlock, ChainID: c.chainID, Contract: c.contract.Hex(), Count: c.belowStart, Detail: fmt.Sprintf("events stored before start block %d", c.record.StartBlock)})
+		}
+		if c.beyondCursor > 0 {
+			st.add(FsckFinding{Kind: FindingEventsBeyondIndexed, ChainID: c.chainID, Contract: c.contract.Hex(), Count: c.beyondCursor, Detail: "events stored beyond the indexed cursor"})
+		}
+
+		startCursor := uint64(0)
+		if c.record.StartBlock > 0 {
+			startCursor = c.record.StartBlock - 1
+		}
+		indexed, verified := c.indexed, c.verified
+		var update ReplaceOptions
+		if indexed != nil && c.record.StartBlock > 0 && *indexed < startCursor {
+			st.add(FsckFinding{Kind: FindingCursorBelowStartBlock, ChainID: c.chainID, Contract: c.contract.Hex(), Detail: fmt.Sprintf("indexed cursor %d is below start block %d", *indexed, c.record.StartBlock)})
+			indexed = &startCursor
+			update.IndexedUntil = indexed
+		}
+		if verified != nil && c.record.StartBlock > 0 && *verified < startCursor {
+			st.add(FsckFinding{Kind: FindingCursorBelowStartBlock, ChainID: c.chainID, Contract: c.contract.Hex(), Detail: fmt.Sprintf("verified cursor %d is below start block %d", *verified, c.record.StartBlock)})
+			verified = &startCursor
+			update.VerifiedUntil = verified
+		}
+		if verified != nil && (indexed == nil || *verified > *indexed) {
+			st.add(FsckFinding{Kind: FindingVerifiedAheadIndexed, ChainID: c.chainID, Contract: c.contract.Hex(), Detail: "verified cursor is ahead of the indexed cursor"})
+			reset := startCursor
+			if indexed != nil {
+				reset = *indexed
+			}
+			verified = &reset
+			update.VerifiedUntil = verified
+		}
+		if c.resetFrom != nil && verified != nil && *verified > max(*c.resetFrom, startCursor) {
+			reset := max(*c.resetFrom, startCursor)
+			update.VerifiedUntil = &reset
+		}
+		if update.IndexedUntil != nil || update.VerifiedUntil != nil {
+			updates = append(updates, fsckCursorUpdate{chainID: c.chainID, contract: c.contract, opts: update})
+		}
+	}
+	return updates
+}
+
+func (s *Store) fsckRepair(ctx context.Context, state *fsckState, updates []fsckCursorUpdate) error {
+	if err := s.deleteKeys(ctx, state.deleteKeys); err != nil {
+		return err
+	}
+	for _, update := range updates {
+		if update.orphan {
+			if err := s.DeleteContractData(ctx, update.chainID, update.contract); err != nil {
+				return fmt.Errorf("delete orphaned data: %w", err)
+			}
+			continue
+		}
+		tx := s.db.WriteTx()
+		if err := s.setProgressBlocks(tx, update.chainID, update.contract, update.opts); err != nil {
+			tx.Discard()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			tx.Discard()
+			return fmt.Errorf("commit cursor reset: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) deleteKeys(ctx context.Context, keys [][]byte) error {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for start := 0; start < len(keys); start += fsckRepairBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+fsckRepairBatchSize, len(keys))
+		tx := s.db.WriteTx()
+		for _, key := range keys[start:end] {
+			if err := tx.Delete(key); err != nil {
+				tx.Discard()
+				return fmt.Errorf("delete corrupt key: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			tx.Discard()
+			return fmt.Errorf("commit fsck repair: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) fsckIterate(ctx context.Context, prefix []byte, fn func(key, value []byte)) error {
+	var iterErr error
+	err := s.db.Iterate(prefix, func(key, value []byte) bool {
+		if err := ctx.Err(); err != nil {
+			iterErr = err
+			return false
+		}
+		fn(fullIteratedKey(prefix, key), bytes.Clone(value))
+		return true
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+	if err != nil {
+		return fmt.Errorf("iterate %s: %w", prefix, err)
+	}
+	return nil
+}
+
+// validContractIdentity reports whether DeleteContractData accepts the pair;
+// data stored under other identities is deleted key by key.
+func validContractIdentity(chainID uint64, contract common.Address) bool {
+	return chainID != 0 && contract != (common.Address{})
+}
+
+// parseContractScopedKey decodes keys made of prefix + chainID + contract.
+func parseContractScopedKey(key []byte, prefix string) (uint64, common.Address, bool) {
+	if len(key) != len(prefix)+8+contractAddressBytes {
+		return 0, common.Address{}, false
+	}
+	offset := len(prefix)
+	chainID := binary.BigEndian.Uint64(key[offset:])
+	offset += 8
+	return chainID, common.BytesToAddress(key[offset : offset+contractAddressBytes]), true
+}
+
+// parseEventKey decodes a key already validated by eventBlockNumber.
+func parseEventKey(key []byte) (uint64, common.Address, uint32) {
+	offset := len(eventKeyPrefix)
+	chainID := binary.BigEndian.Uint64(key[offset:])
+	offset += 8
+	contract := common.BytesToAddress(key[offset : offset+contractAddressBytes])
+	offset += contractAddressBytes + 8
+	return chainID, contract, binary.BigEndian.Uint32(key[offset:])
+}
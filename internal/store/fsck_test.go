@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+)
+
+func TestFsckReportsAndRepairs(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+
+	healthy := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	orphan := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	ahead := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	if err := store.SaveContract(ctx, 1, healthy, 5, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	events := []Event{
+		{ChainID: 1, Contract: healthy.Hex(), Account: "0xabc", PreviousWeight: "0", NewWeight: "1", BlockNumber: 5, LogIndex: 0},
+		{ChainID: 1, Contract: healthy.Hex(), Account: "0xdef", PreviousWeight: "0", NewWeight: "2", BlockNumber: 6, LogIndex: 0},
+		{ChainID: 1, Contract: healthy.Hex(), Account: "0x123", PreviousWeight: "0", NewWeight: "3", BlockNumber: 7, LogIndex: 0},
+	}
+	if err := store.SaveEvents(ctx, 1, healthy, events, 7); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	orphanEvents := []Event{{ChainID: 1, Contract: orphan.Hex(), Account: "0x999", PreviousWeight: "0", NewWeight: "1", BlockNumber: 1, LogIndex: 0}}
+	if err := store.SaveEvents(ctx, 1, orphan, orphanEvents, 1); err != nil {
+		t.Fatalf("save orphan events: %v", err)
+	}
+	if err := store.SaveContract(ctx, 1, ahead, 0, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+
+	tx := database.WriteTx()
+	if err := tx.Set(eventKey(1, healthy, 6, 0), []byte("{broken")); err != nil {
+		t.Fatalf("corrupt event: %v", err)
+	}
+	if err := tx.Set(lastBlockKey(1, ahead), encodeUint64(10)); err != nil {
+		t.Fatalf("set indexed cursor: %v", err)
+	}
+	if err := tx.Set(verifiedBlockKey(1, ahead), encodeUint64(20)); err != nil {
+		t.Fatalf("set verified cursor: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	report, err := store.Fsck(ctx, FsckOptions{})
+	if err != nil {
+		t.Fatalf("fsck: %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, finding := range report.Findings {
+		kinds[finding.Kind]++
+		if finding.Repaired {
+			t.Fatalf("expected no repairs without the repair option, got %+v", finding)
+		}
+	}
+	for _, kind := range []string{FindingInvalidEventPayload, FindingOrphanedEvents, FindingOrphanedCursor, FindingVerifiedAheadIndexed} {
+		if kinds[kind] != 1 {
+			t.Fatalf("expected one %s finding, got %+v", kind, report.Findings)
+		}
+	}
+	if len(report.Findings) != 4 {
+		t.Fatalf("expected 4 findings, got %+v", report.Findings)
+	}
+	if report.Contracts != 2 || report.Events != 4 {
+		t.Fatalf("expected 2 contracts and 4 events, got %d and %d", report.Contracts, report.Events)
+	}
+	if report.Clean() {
+		t.Fatalf("expected report with findings not to be clean")
+	}
+
+	report, err = store.Fsck(ctx, FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("fsck repair: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("expected every finding to be repaired, got %+v", report.Findings)
+	}
+
+	verified, ok, err := store.LastVerifiedBlock(ctx, 1, healthy)
+	if err != nil || !ok {
+		t.Fatalf("expected verified cursor (ok=%t, err=%v)", ok, err)
+	}
+	if verified != 5 {
+		t.Fatalf("expected verified cursor rewound to 5, got %d", verified)
+	}
+	verified, _, err = store.LastVerifiedBlock(ctx, 1, ahead)
+	if err != nil {
+		t.Fatalf("last verified block: %v", err)
+	}
+	if verified != 10 {
+		t.Fatalf("expected verified cursor reset to 10, got %d", verified)
+	}
+	if _, ok, err := store.LastIndexedBlock(ctx, 1, orphan); err != nil || ok {
+		t.Fatalf("expected orphaned cursor to be deleted (ok=%t, err=%v)", ok, err)
+	}
+
+	report, err = store.Fsck(ctx, FsckOptions{})
+	if err != nil {
+		t.Fatalf("fsck after repair: %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Fatalf("expected no findings after repair, got %+v", report.Findings)
+	}
+	if report.Events != 2 {
+		t.Fatalf("expected 2 events after repair, got %d", report.Events)
+	}
+}
+
+func TestFsckKeepsBackfilledEvents(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+
+	contract := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	if err := store.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := store.SaveEvents(ctx, 1, contract, nil, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	segment := BackfillSegment{From: 21, To: 30}
+	if err := store.ReplaceEventsInRange(ctx, 1, contract, 21, 30, []Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xabc", PreviousWeight: "0", NewWeight: "1", BlockNumber: 22, LogIndex: 0},
+		{ChainID: 1, Contract: contract.Hex(), Account: "0xdef", PreviousWeight: "0", NewWeight: "2", BlockNumber: 25, LogIndex: 0},
+	}, ReplaceOptions{BackfillSegment: &segment}); err != nil {
+		t.Fatalf("store segment: %v", err)
+	}
+	// An event of an unfinished segment is beyond the cursor.
+	if err := store.ReplaceEventsInRange(ctx, 1, contract, 31, 40, []Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: "0x123", PreviousWeight: "0", NewWeight: "3", BlockNumber: 35, LogIndex: 0},
+	}, ReplaceOptions{}); err != nil {
+		t.Fatalf("store unfinished segment: %v", err)
+	}
+
+	report, err := store.Fsck(ctx, FsckOptions{})
+	if err != nil {
+		t.Fatalf("fsck: %v", err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Kind != FindingEventsBeyondIndexed || report.Findings[0].Count != 1 {
+		t.Fatalf("expected one event beyond the cursor, got %+v", report.Findings)
+	}
+
+	tx := database.WriteTx()
+	if err := tx.Set(eventKey(1, contract, 25, 0), []byte("{broken")); err != nil {
+		t.Fatalf("corrupt event: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := store.Fsck(ctx, FsckOptions{Repair: true}); err != nil {
+		t.Fatalf("fsck repair: %v", err)
+	}
+	segments, err := store.ListBackfillSegments(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("list backfill segments: %v", err)
+	}
+	if len(segments) != 0 {
+		t.Fatalf("expected the segment with a corrupt event to be dropped, got %+v", segments)
+	}
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vocdoni/davinci-node/log"
+)
+
+const schemaVersionKey = "meta:schema_version"
+
+// ErrSchemaTooNew is returned when the database was written by a newer binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration upgrades the on-disk layout to Version. Run must be idempotent: an
+// interrupted migration runs again from the start on the next startup.
+type Migration struct {
+	Version     uint64
+	Description string
+	Run         func(ctx context.Context, s *Store) error
+}
+
+// migrations is the ordered registry of layout changes. Databases created
+// before the schema version was recorded are at version 0. Append new entries
+// with the next version whenever eventKey, ContractRecord or a meta: key
+// changes in a way older code would misread.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "record the schema version of the original layout",
+		Run:         stampVersion,
+	},
+	{
+		Version:     2,
+		Description: "store event values in the binary layout (legacy JSON values are rewritten in the background)",
+		Run:         stampVersion,
+	},
+	{
+		Version:     3,
+		Description: "archive expired contracts in their contract record",
+		Run:         stampVersion,
+	},
+	{
+		Version:     4,
+		Description: "record per-contract expiry policies",
+		Run:         stampVersion,
+	},
+	{
+		Version:     5,
+		Description: "record contract labels",
+		Run:         stampVersion,
+	},
+	{
+		Version:     6,
+		Description: "record start block methods and deployment transactions",
+		Run:         stampVersion,
+	},
+	{
+		Version:     7,
+		Description: "record event profiles and decoded event arguments",
+		Run:         stampVersion,
+	},
+	{
+		Version:     8,
+		Description: "record proxy upgrades and paused contracts",
+		Run:         stampVersion,
+	},
+	{
+		Version:     9,
+		Description: "record completed backfill segments under meta:backfill: keys",
+		Run:         stampVersion,
+	},
+}
+
+// stampVersion is the Run of migrations whose new layout is written by the
+// code itself: the version bump only keeps older binaries from opening the
+// database.
+func stampVersion(context.Context, *Store) error {
+	return nil
+}
+
+// CurrentSchemaVersion returns the schema version written by this binary.
+func CurrentSchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrateOptions controls Migrate.
+type MigrateOptions struct {
+	// DryRun reports the pending migrations without running them.
+	DryRun bool
+}
+
+// MigrationInfo describes a migration in a MigrationReport.
+type MigrationInfo struct {
+	Version     uint64 `json:"version"`
+	Description string `json:"description"`
+}
+
+// MigrationReport summarizes a Migrate call.
+type MigrationReport struct {
+	From    uint64          `json:"from"`
+	To      uint64          `json:"to"`
+	DryRun  bool            `json:"dryRun"`
+	Pending []MigrationInfo `json:"pending"`
+}
+
+// SchemaVersion returns the recorded schema version if present.
+func (s *Store) SchemaVersion(ctx context.Context) (uint64, bool, error) {
+	return s.progressBlock(ctx, []byte(schemaVersionKey), "schema version")
+}
+
+// Migrate brings the database to CurrentSchemaVersion by running every pending
+// migration in order, recording the version after each one so an interrupted
+// run resumes where it stopped. It returns ErrSchemaTooNew if the database was
+// written by a newer binary. Empty databases are stamped with the current
+// version directly.
+func (s *Store) Migrate(ctx context.Context, opts MigrateOptions) (MigrationReport, error) {
+	return s.migrate(ctx, migrations, opts)
+}
+
+func (s *Store) migrate(ctx context.Context, registry []Migration, opts MigrateOptions) (MigrationReport, error) {
+	var target uint64
+	for _, m := range registry {
+		if m.Version <= target {
+			return MigrationReport{}, fmt.Errorf("migration registry must have strictly increasing versions starting above 0")
+		}
+		target = m.Version
+	}
+	current, ok, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	if !ok {
+		empty, err := s.isEmpty(ctx)
+		if err != nil {
+			return MigrationReport{}, err
+		}
+		if empty {
+			report := MigrationReport{From: target, To: target, DryRun: opts.DryRun, Pending: []MigrationInfo{}}
+			if opts.DryRun {
+				return report, nil
+			}
+			return report, s.setSchemaVersion(target)
+		}
+	}
+	if current > target {
+		return MigrationReport{}, fmt.Errorf("%w: database is at version %d, this binary supports up to %d", ErrSchemaTooNew, current, target)
+	}
+
+	report := MigrationReport{From: current, To: target, DryRun: opts.DryRun, Pending: []MigrationInfo{}}
+	for _, m := range registry {
+		if m.Version <= current {
+			continue
+		}
+		report.Pending = append(report.Pending, MigrationInfo{Version: m.Version, Description: m.Description})
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	for _, m := range registry {
+		if m.Version <= current {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		log.Infow("running store migration", "version", m.Version, "description", m.Description)
+		if err := m.Run(ctx, s); err != nil {
+			return report, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := s.setSchemaVersion(m.Version); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (s *Store) setSchemaVersion(version uint64) error {
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	if err := tx.Set([]byte(schemaVersionKey), encodeUint64(version)); err != nil {
+		return fmt.Errorf("store schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit schema version: %w", err)
+	}
+	return nil
+}
+
+// isEmpty reports whether the database holds no events or metadata.
+func (s *Store) isEmpty(ctx context.Context) (bool, error) {
+	for _, prefix := range []string{eventKeyPrefix, metaKeyPrefix} {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		found := false
+		if err := s.db.Iterate([]byte(prefix), func(_, _ []byte) bool {
+			found = true
+			return false
+		}); err != nil {
+			return false, fmt.Errorf("iterate %s: %w", prefix, err)
+		}
+		if found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if err := store.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+
+	var ran []uint64
+	failAt := uint64(2)
+	registry := []Migration{
+		{Version: 1, Description: "one", Run: func(context.Context, *Store) error {
+			ran = append(ran, 1)
+			return nil
+		}},
+		{Version: 2, Description: "two", Run: func(context.Context, *Store) error {
+			ran = append(ran, 2)
+			if failAt == 2 {
+				return errors.New("interrupted")
+			}
+			return nil
+		}},
+		{Version: 3, Description: "three", Run: func(context.Context, *Store) error {
+			ran = append(ran, 3)
+			return nil
+		}},
+	}
+
+	report, err := store.migrate(ctx, registry, MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.From != 0 || report.To != 3 || len(report.Pending) != 3 || len(ran) != 0 {
+		t.Fatalf("expected 3 pending migrations and none run, got %+v (ran %v)", report, ran)
+	}
+	if _, ok, err := store.SchemaVersion(ctx); err != nil || ok {
+		t.Fatalf("expected dry run not to record a version (ok=%t, err=%v)", ok, err)
+	}
+
+	if _, err := store.migrate(ctx, registry, MigrateOptions{}); err == nil {
+		t.Fatalf("expected interrupted migration to fail")
+	}
+	version, _, err := store.SchemaVersion(ctx)
+	if err != nil || version != 1 {
+		t.Fatalf("expected version 1 after interruption, got %d (err=%v)", version, err)
+	}
+
+	failAt = 0
+	ran = nil
+	report, err = store.migrate(ctx, registry, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("resume migrations: %v", err)
+	}
+	if len(ran) != 2 || ran[0] != 2 || ran[1] != 3 || report.From != 1 {
+		t.Fatalf("expected migrations 2 and 3 to resume from version 1, got %v (%+v)", ran, report)
+	}
+
+	if _, err := store.migrate(ctx, registry[:2], MigrateOptions{}); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrateStampsEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+
+	report, err := store.Migrate(ctx, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if len(report.Pending) != 0 {
+		t.Fatalf("expected no migrations for an empty database, got %+v", report.Pending)
+	}
+	version, ok, err := store.SchemaVersion(ctx)
+	if err != nil || !ok || version != CurrentSchemaVersion() {
+		t.Fatalf("expected version %d, got %d (ok=%t, err=%v)", CurrentSchemaVersion(), version, ok, err)
+	}
+}
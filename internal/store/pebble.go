@@ -0,0 +1,226 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/vocdoni/davinci-node/db"
+)
+
+// ErrUnsupported is returned when the backing database does not provide an
+// optional capability such as checkpoints.
+var ErrUnsupported = errors.New("operation not supported by the backing database")
+
+// PebbleDB is a Pebble-backed db.Database with the same on-disk layout as
+// davinci-node's pebbledb package. It additionally exposes the Pebble features
+// the store relies on, such as consistent checkpoints.
+type PebbleDB struct {
+	db *pebble.DB
+}
+
+var _ db.Database = (*PebbleDB)(nil)
+
+// PebbleOptions configures OpenPebble.
+type PebbleOptions struct {
+	// ReadOnly opens the database without acquiring write access.
+	ReadOnly bool
+	// MustExist fails when no database is present at the path.
+	MustExist bool
+}
+
+// OpenPebble opens (or creates) a Pebble database at path.
+func OpenPebble(path string, opts PebbleOptions) (*PebbleDB, error) {
+	if !opts.MustExist && !opts.ReadOnly {
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	database, err := pebble.Open(path, &pebble.Options{
+		Levels: []pebble.LevelOptions{
+			{Compression: pebble.SnappyCompression},
+		},
+		ReadOnly:         opts.ReadOnly,
+		ErrorIfNotExists: opts.MustExist,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PebbleDB{db: database}, nil
+}
+
+// Get implements db.Reader.
+func (p *PebbleDB) Get(key []byte) ([]byte, error) {
+	return pebbleGet(p.db, key)
+}
+
+// Iterate implements db.Reader.
+func (p *PebbleDB) Iterate(prefix []byte, callback func(key, value []byte) bool) error {
+	return pebbleIterate(p.db, prefix, callback)
+}
+
+// IterateRange calls callback for every key in [start, end) in key order. Keys
+// are passed in full, unlike Iterate which strips the prefix. A nil end
+// iterates to the end of the keyspace.
+func (p *PebbleDB) IterateRange(start, end []byte, callback func(key, value []byte) bool) error {
+	return pebbleIterateRange(p.db, start, end, 0, false, callback)
+}
+
+// IterateRangeReverse is IterateRange in reverse key order.
+func (p *PebbleDB) IterateRangeReverse(start, end []byte, callback func(key, value []byte) bool) error {
+	return pebbleIterateRange(p.db, start, end, 0, true, callback)
+}
+
+// WriteTx implements db.Database.
+func (p *PebbleDB) WriteTx() db.WriteTx {
+	return &pebbleWriteTx{batch: p.db.NewIndexedBatch()}
+}
+
+// Compact implements db.Database.
+func (p *PebbleDB) Compact() error {
+	iter, err := p.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	var first, last []byte
+	if iter.First() {
+		first = append(first, iter.Key()...)
+	}
+	if iter.Last() {
+		last = append(last, iter.Key()...)
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if first == nil {
+		return nil
+	}
+	// Compact treats the end key as exclusive, so extend it past the last key.
+	return p.db.Compact(first, append(last, 0), true)
+}
+
+// Checkpoint writes a consistent point-in-time copy of the database into dir,
+// which must not exist yet. Writers are not blocked while the checkpoint is taken.
+func (p *PebbleDB) Checkpoint(dir string) error {
+	return p.db.Checkpoint(dir, pebble.WithFlushedWAL())
+}
+
+// Close implements io.Closer.
+func (p *PebbleDB) Close() error {
+	return p.db.Close()
+}
+
+type pebbleWriteTx struct {
+	batch *pebble.Batch
+}
+
+var _ db.WriteTx = (*pebbleWriteTx)(nil)
+
+func (tx *pebbleWriteTx) Get(key []byte) ([]byte, error) {
+	return pebbleGet(tx.batch, key)
+}
+
+func (tx *pebbleWriteTx) Iterate(prefix []byte, callback func(key, value []byte) bool) error {
+	return pebbleIterate(tx.batch, prefix, callback)
+}
+
+func (tx *pebbleWriteTx) Set(key, value []byte) error {
+	return tx.batch.Set(key, value, nil)
+}
+
+func (tx *pebbleWriteTx) Delete(key []byte) error {
+	return tx.batch.Delete(key, nil)
+}
+
+// DeleteRange deletes every key in [start, end) with a single range tombstone,
+// so the cost does not depend on how many keys the range holds. Writes added
+// to the transaction afterwards are not affected.
+func (tx *pebbleWriteTx) DeleteRange(start, end []byte) error {
+	return tx.batch.DeleteRange(start, end, nil)
+}
+
+func (tx *pebbleWriteTx) Apply(other db.WriteTx) error {
+	otherTx, ok := db.UnwrapWriteTx(other).(*pebbleWriteTx)
+	if !ok {
+		return fmt.Errorf("cannot apply %T to a pebble transaction", other)
+	}
+	return tx.batch.Apply(otherTx.batch, nil)
+}
+
+func (tx *pebbleWriteTx) Commit() error {
+	if tx.batch == nil {
+		return fmt.Errorf("cannot commit pebble tx: already committed or discarded")
+	}
+	err := tx.batch.Commit(nil)
+	tx.batch = nil
+	return err
+}
+
+func (tx *pebbleWriteTx) Discard() {
+	if tx.batch == nil {
+		return
+	}
+	_ = tx.batch.Close()
+	tx.batch = nil
+}
+
+func pebbleGet(reader pebble.Reader, key []byte) ([]byte, error) {
+	value, closer, err := reader.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, db.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.Clone(value)
+	if err := closer.Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func pebbleIterate(reader pebble.Reader, prefix []byte, callback func(key, value []byte) bool) error {
+	return pebbleIterateRange(reader, prefix, prefixUpperBound(prefix), len(prefix), false, callback)
+}
+
+// pebbleIterateRange iterates [start, end), in reverse key order with reverse,
+// trimming the first trim bytes of every key before handing it to callback.
+func pebbleIterateRange(reader pebble.Reader, start, end []byte, trim int, reverse bool, callback func(key, value []byte) bool) (err error) {
+	iter, err := reader.NewIter(&pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := iter.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	valid, next := iter.First, iter.Next
+	if reverse {
+		valid, next = iter.Last, iter.Prev
+	}
+	for ok := valid(); ok; ok = next() {
+		if !callback(iter.Key()[trim:], iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+// prefixUpperBound returns the smallest key greater than every key starting
+// with prefix, or nil when no such key exists.
+func prefixUpperBound(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
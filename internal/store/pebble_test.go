@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+)
+
+func TestOpenPebbleReadsExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	legacy, err := metadb.New(db.TypePebble, dir)
+	if err != nil {
+		t.Fatalf("create pebble db: %v", err)
+	}
+	if err := New(legacy).SaveContract(ctx, 1, contract, 7, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("close pebble db: %v", err)
+	}
+
+	database, err := OpenPebble(dir, PebbleOptions{MustExist: true})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	record, ok, err := New(database).GetContract(ctx, 1, contract)
+	if err != nil || !ok {
+		t.Fatalf("expected existing contract (ok=%t, err=%v)", ok, err)
+	}
+	if record.StartBlock != 7 {
+		t.Fatalf("expected start block 7, got %d", record.StartBlock)
+	}
+
+	if err := New(database).Checkpoint(ctx, filepath.Join(t.TempDir(), "checkpoint")); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if _, err := OpenPebble(filepath.Join(t.TempDir(), "missing"), PebbleOptions{MustExist: true}); err == nil {
+		t.Fatalf("expected MustExist to fail on a missing database")
+	}
+}
+
+func TestPebbleRangeDeletes(t *testing.T) {
+	ctx := context.Background()
+	database, err := OpenPebble(t.TempDir(), PebbleOptions{})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := New(database)
+
+	// Adjacent addresses make the contract event prefixes neighbours in the keyspace.
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	neighbour := common.HexToAddress("0x1111111111111111111111111111111111111112")
+	if err := eventStore.SaveEvents(ctx, 1, contract, benchmarkEvents(contract, 100, 1), 100); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := eventStore.SaveEvents(ctx, 1, neighbour, benchmarkEvents(neighbour, 10, 1), 10); err != nil {
+		t.Fatalf("save neighbour events: %v", err)
+	}
+
+	replacement := benchmarkEvents(contract, 30, 1)[20:21]
+	if err := eventStore.ReplaceEventsInRange(ctx, 1, contract, 20, 29, replacement, ReplaceOptions{}); err != nil {
+		t.Fatalf("replace events in range: %v", err)
+	}
+	blocks := eventBlocks(t, eventStore, contract)
+	if len(blocks) != 91 || blocks[18] != 19 || blocks[19] != 21 || blocks[20] != 30 {
+		t.Fatalf("expected only blocks 20-29 but the replacement to be dropped, got %v", blocks)
+	}
+
+	if err := eventStore.ReplaceEventsInRange(ctx, 1, contract, 90, math.MaxUint64, nil, ReplaceOptions{}); err != nil {
+		t.Fatalf("replace open-ended range: %v", err)
+	}
+	if blocks := eventBlocks(t, eventStore, contract); blocks[len(blocks)-1] != 89 {
+		t.Fatalf("expected events up to block 89, got %v", blocks)
+	}
+
+	if err := eventStore.DeleteContractData(ctx, 1, contract); err != nil {
+		t.Fatalf("delete contract data: %v", err)
+	}
+	if blocks := eventBlocks(t, eventStore, contract); len(blocks) != 0 {
+		t.Fatalf("expected purged contract to have no events, got %v", blocks)
+	}
+	if blocks := eventBlocks(t, eventStore, neighbour); len(blocks) != 10 {
+		t.Fatalf("expected neighbour events to be kept, got %v", blocks)
+	}
+}
+
+func eventBlocks(t *testing.T, eventStore *Store, contract common.Address) []uint64 {
+	t.Helper()
+	var blocks []uint64
+	if err := eventStore.IterateEvents(context.Background(), 1, contract, func(event Event) error {
+		blocks = append(blocks, event.BlockNumber)
+		return nil
+	}); err != nil {
+		t.Fatalf("iterate events: %v", err)
+	}
+	return blocks
+}
+
+// scanOnlyDB hides the Pebble range capabilities so the store falls back to
+// scanning and deleting key by key.
+type scanOnlyDB struct{ db.Database }
+
+func (d scanOnlyDB) WriteTx() db.WriteTx {
+	return struct{ db.WriteTx }{d.Database.WriteTx()}
+}
+
+// BenchmarkPebbleRangeDeletes replaces a 2000-block window and purges a
+// contract for growing histories. With range deletes the cost follows the
+// affected range; the scan variant grows with the whole contract history.
+func BenchmarkPebbleRangeDeletes(b *testing.B) {
+	const window = 2000
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	for _, history := range []int{10_000, 100_000, 1_000_000} {
+		events := benchmarkEvents(contract, history, 1)
+		for _, mode := range []string{"range", "scan"} {
+			open := func(b *testing.B) (*Store, func()) {
+				database, err := OpenPebble(b.TempDir(), PebbleOptions{})
+				if err != nil {
+					b.Fatalf("open pebble: %v", err)
+				}
+				eventStore := New(database)
+				if mode == "scan" {
+					eventStore = New(scanOnlyDB{database})
+				}
+				return eventStore, func() {
+					if err := database.Close(); err != nil {
+						b.Fatalf("close db: %v", err)
+					}
+				}
+			}
+			b.Run(fmt.Sprintf("replace/history=%d/%s", history, mode), func(b *testing.B) {
+				eventStore, closeDB := open(b)
+				b.ReportAllocs()
+				defer closeDB()
+				if err := eventStore.SaveEvents(context.Background(), 1, contract, events, uint64(history)); err != nil {
+					b.Fatalf("save events: %v", err)
+				}
+				if err := eventStore.Compact(context.Background()); err != nil {
+					b.Fatalf("compact: %v", err)
+				}
+				from := uint64(history - window + 1)
+				replacement := events[history-window:]
+				b.ResetTimer()
+				for range b.N {
+					if err := eventStore.ReplaceEventsInRange(context.Background(), 1, contract, from, uint64(history), replacement, ReplaceOptions{}); err != nil {
+						b.Fatalf("replace events in range: %v", err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("purge/history=%d/%s", history, mode), func(b *testing.B) {
+				eventStore, closeDB := open(b)
+				b.ReportAllocs()
+				defer closeDB()
+				for range b.N {
+					b.StopTimer()
+					if err := eventStore.SaveEvents(context.Background(), 1, contract, events, uint64(history)); err != nil {
+						b.Fatalf("save events: %v", err)
+					}
+					if err := eventStore.Compact(context.Background()); err != nil {
+						b.Fatalf("compact: %v", err)
+					}
+					b.StartTimer()
+					if err := eventStore.DeleteContractData(context.Background(), 1, contract); err != nil {
+						b.Fatalf("delete contract data: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPebbleListEventsDesc(t *testing.T) {
+	ctx := context.Background()
+	database, err := OpenPebble(t.TempDir(), PebbleOptions{})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	pebbleStore := New(database)
+	memory, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := memory.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	memoryStore := New(memory)
+
+	contract := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	other := common.HexToAddress("0x1313131313131313131313131313131313131313")
+	events := make([]Event, 0, 10)
+	for block := uint64(1); block <= 10; block++ {
+		events = append(events, Event{
+			ChainID: 1, Contract: contract.Hex(), Account: common.BigToAddress(new(big.Int).SetUint64(block)).Hex(),
+			PreviousWeight: "0", NewWeight: fmt.Sprint(block), BlockNumber: block,
+		})
+	}
+	for _, s := range []*Store{pebbleStore, memoryStore} {
+		if err := s.SaveEvents(ctx, 1, contract, events, 10); err != nil {
+			t.Fatalf("save events: %v", err)
+		}
+		if err := s.SaveEvents(ctx, 1, other, []Event{{
+			ChainID: 1, Contract: other.Hex(), Account: contract.Hex(),
+			PreviousWeight: "0", NewWeight: "1", BlockNumber: 11,
+		}}, 11); err != nil {
+			t.Fatalf("save events: %v", err)
+		}
+	}
+
+	for _, opts := range []ListOptions{
+		{First: 1},
+		{First: 3, Skip: 2},
+		{First: 4, Skip: 8},
+		{Skip: 8},
+		{Skip: 20},
+		{},
+	} {
+		opts.ChainID, opts.Contract, opts.OrderDirection = 1, contract, "desc"
+		got, err := pebbleStore.ListEvents(ctx, opts)
+		if err != nil {
+			t.Fatalf("list pebble events: %v", err)
+		}
+		want, err := memoryStore.ListEvents(ctx, opts)
+		if err != nil {
+			t.Fatalf("list in-memory events: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d events for %+v, got %d", len(want), opts, len(got))
+		}
+		for i := range got {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Fatalf("expected %+v for %+v, got %+v", want[i], opts, got[i])
+			}
+		}
+	}
+	latest, err := pebbleStore.ListEvents(ctx, ListOptions{ChainID: 1, Contract: contract, First: 1, OrderDirection: "desc"})
+	if err != nil || len(latest) != 1 || latest[0].BlockNumber != 10 {
+		t.Fatalf("expected the latest event at block 10, got %+v %v", latest, err)
+	}
+}
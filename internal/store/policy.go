@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Duration is a time.Duration encoded in JSON as a Go duration string such as
+// "168h".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", raw, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ExpiryPolicy adjusts the expiration of a contract while it is being indexed.
+type ExpiryPolicy struct {
+	// AutoExtend pushes ExpiresAt forward by this duration when the contract
+	// is about to expire and new events were indexed since the last extension.
+	AutoExtend Duration `json:"autoExtend,omitempty"`
+	// MaxExpiresAt bounds the lifetime of the contract: ExpiresAt is never
+	// extended past it.
+	MaxExpiresAt *time.Time `json:"maxExpiresAt,omitempty"`
+	// LastEventBlock is the latest event block seen by the last automatic
+	// extension. It is maintained by the indexer.
+	LastEventBlock uint64 `json:"lastEventBlock,omitempty"`
+}
+
+// IsZero reports whether the policy has no effect.
+func (p *ExpiryPolicy) IsZero() bool {
+	return p == nil || (p.AutoExtend <= 0 && p.MaxExpiresAt == nil)
+}
+
+// Validate checks the policy against the expiration it applies to.
+func (p *ExpiryPolicy) Validate(expiresAt time.Time) error {
+	if p == nil {
+		return nil
+	}
+	if p.AutoExtend < 0 {
+		return fmt.Errorf("autoExtend must not be negative")
+	}
+	if p.MaxExpiresAt != nil && expiresAt.After(*p.MaxExpiresAt) {
+		return fmt.Errorf("expiresAt must not be after maxExpiresAt")
+	}
+	return nil
+}
+
+// Extend returns the expiration after one automatic extension, counted from
+// expiresAt or now when the contract has already expired, and capped at
+// MaxExpiresAt. It reports false when the policy cannot move expiresAt later.
+func (p *ExpiryPolicy) Extend(expiresAt, now time.Time) (time.Time, bool) {
+	if p == nil || p.AutoExtend <= 0 {
+		return time.Time{}, false
+	}
+	base := expiresAt
+	if base.Before(now) {
+		base = now
+	}
+	next := base.Add(time.Duration(p.AutoExtend))
+	if p.MaxExpiresAt != nil && next.After(*p.MaxExpiresAt) {
+		next = *p.MaxExpiresAt
+	}
+	if !next.After(expiresAt) {
+		return time.Time{}, false
+	}
+	return next.UTC(), true
+}
+
+// normalizeExpiryPolicy drops policies without effect and stores times in UTC.
+func normalizeExpiryPolicy(policy *ExpiryPolicy) *ExpiryPolicy {
+	if policy.IsZero() {
+		return nil
+	}
+	normalized := *policy
+	if normalized.AutoExtend < 0 {
+		normalized.AutoExtend = 0
+	}
+	if normalized.MaxExpiresAt != nil {
+		maxExpiresAt := normalized.MaxExpiresAt.UTC()
+		normalized.MaxExpiresAt = &maxExpiresAt
+	}
+	return &normalized
+}
+
+// SetContractExpiry replaces the expiration and expiry policy of an existing
+// contract. A nil or zero policy removes it. Archived contracts are rejected
+// with ErrContractArchived.
+func (s *Store) SetContractExpiry(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time, policy *ExpiryPolicy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt is required")
+	}
+	if err := policy.Validate(expiresAt); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	if record.Archive != nil {
+		return ErrContractArchived
+	}
+	record.ExpiresAt = expiresAt.UTC()
+	record.Policy = normalizeExpiryPolicy(policy)
+	return s.putContract(record)
+}
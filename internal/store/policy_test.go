@@ -0,0 +1,56 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExpiryPolicyExtend(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxExpiresAt := now.Add(10 * time.Hour)
+	policy := &ExpiryPolicy{AutoExtend: Duration(4 * time.Hour), MaxExpiresAt: &maxExpiresAt}
+
+	next, ok := policy.Extend(now.Add(time.Hour), now)
+	if !ok || !next.Equal(now.Add(5*time.Hour)) {
+		t.Fatalf("expected extension to %s, got %s (ok=%t)", now.Add(5*time.Hour), next, ok)
+	}
+	// Expired contracts are extended from now.
+	next, ok = policy.Extend(now.Add(-time.Hour), now)
+	if !ok || !next.Equal(now.Add(4*time.Hour)) {
+		t.Fatalf("expected extension to %s, got %s (ok=%t)", now.Add(4*time.Hour), next, ok)
+	}
+	next, ok = policy.Extend(now.Add(8*time.Hour), now)
+	if !ok || !next.Equal(maxExpiresAt) {
+		t.Fatalf("expected extension capped at %s, got %s (ok=%t)", maxExpiresAt, next, ok)
+	}
+	if _, ok := policy.Extend(maxExpiresAt, now); ok {
+		t.Fatalf("expected no extension past maxExpiresAt")
+	}
+	if _, ok := (&ExpiryPolicy{MaxExpiresAt: &maxExpiresAt}).Extend(now, now); ok {
+		t.Fatalf("expected no extension without autoExtend")
+	}
+}
+
+func TestExpiryPolicyJSON(t *testing.T) {
+	var policy ExpiryPolicy
+	if err := json.Unmarshal([]byte(`{"autoExtend":"168h"}`), &policy); err != nil {
+		t.Fatalf("decode policy: %v", err)
+	}
+	if time.Duration(policy.AutoExtend) != 168*time.Hour {
+		t.Fatalf("expected autoExtend 168h, got %s", time.Duration(policy.AutoExtend))
+	}
+	payload, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatalf("encode policy: %v", err)
+	}
+	if string(payload) != `{"autoExtend":"168h0m0s"}` {
+		t.Fatalf("expected {\"autoExtend\":\"168h0m0s\"}, got %s", payload)
+	}
+	if err := json.Unmarshal([]byte(`{"autoExtend":604800}`), &policy); err == nil {
+		t.Fatalf("expected error for a numeric duration")
+	}
+	if err := json.Unmarshal([]byte(`{"autoExtend":"soon"}`), &policy); err == nil {
+		t.Fatalf("expected error for an invalid duration")
+	}
+}
@@ -0,0 +1,1330 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	// Registers the "pgx" database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/vocdoni/davinci-node/log"
+)
+
+// postgresMigrationLock is the advisory lock key that serializes schema
+// migrations when several processes start against the same database.
+const postgresMigrationLock int64 = 0x63656e737573
+
+// postgresMigration upgrades the PostgreSQL schema to Version. Its statements
+// run in one transaction together with the version update.
+type postgresMigration struct {
+	Version     uint64
+	Description string
+	Statements  []string
+}
+
+// postgresMigrations is the ordered registry of PostgreSQL schema changes. Block
+// numbers and chain IDs are BIGINT, addresses are 20-byte BYTEA and weights are
+// NUMERIC so the tables can be joined and aggregated directly.
+var postgresMigrations = []postgresMigration{
+	{
+		Version:     1,
+		Description: "create census tables",
+		Statements: []string{
+			`CREATE TABLE census_contracts (
+				chain_id    BIGINT      NOT NULL,
+				contract    BYTEA       NOT NULL,
+				start_block BIGINT      NOT NULL,
+				expires_at  TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (chain_id, contract)
+			)`,
+			`CREATE TABLE census_cursors (
+				chain_id       BIGINT NOT NULL,
+				contract       BYTEA  NOT NULL,
+				indexed_block  BIGINT,
+				verified_block BIGINT,
+				PRIMARY KEY (chain_id, contract)
+			)`,
+			`CREATE TABLE census_events (
+				chain_id        BIGINT         NOT NULL,
+				contract        BYTEA          NOT NULL,
+				block_number    BIGINT         NOT NULL,
+				log_index       BIGINT         NOT NULL,
+				account         BYTEA          NOT NULL,
+				previous_weight NUMERIC(78, 0) NOT NULL,
+				new_weight      NUMERIC(78, 0) NOT NULL,
+				PRIMARY KEY (chain_id, contract, block_number, log_index)
+			)`,
+			`CREATE INDEX census_events_account_idx ON census_events (chain_id, contract, account)`,
+			`CREATE TABLE census_reindex_jobs (
+				chain_id       BIGINT      NOT NULL,
+				contract       BYTEA       NOT NULL,
+				id             BIGINT      NOT NULL,
+				from_block     BIGINT      NOT NULL,
+				to_block       BIGINT      NOT NULL,
+				status         TEXT        NOT NULL,
+				indexed_until  BIGINT      NOT NULL,
+				verified_until BIGINT      NOT NULL,
+				created_at     TIMESTAMPTZ NOT NULL,
+				completed_at   TIMESTAMPTZ,
+				PRIMARY KEY (chain_id, contract, id)
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add contract archive columns",
+		Statements: []string{
+			`ALTER TABLE census_contracts
+				ADD COLUMN archived_at            TIMESTAMPTZ,
+				ADD COLUMN archive_indexed_until  BIGINT,
+				ADD COLUMN archive_verified_until BIGINT,
+				ADD COLUMN archive_accounts       BIGINT,
+				ADD COLUMN archive_total_weight   NUMERIC(78, 0),
+				ADD COLUMN archive_root           BYTEA`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add contract expiry policy columns",
+		Statements: []string{
+			// policy_auto_extend holds a duration in nanoseconds.
+			`ALTER TABLE census_contracts
+				ADD COLUMN policy_auto_extend       BIGINT,
+				ADD COLUMN policy_max_expires_at    TIMESTAMPTZ,
+				ADD COLUMN policy_last_event_block  BIGINT`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add contract labels",
+		Statements: []string{
+			`ALTER TABLE census_contracts ADD COLUMN label TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "add contract deployment transaction and start block method",
+		Statements: []string{
+			`ALTER TABLE census_contracts
+				ADD COLUMN deployment_tx      BYTEA,
+				ADD COLUMN start_block_method TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add contract event profiles and decoded event arguments",
+		Statements: []string{
+			`ALTER TABLE census_contracts ADD COLUMN event_profile JSONB`,
+			`ALTER TABLE census_events ADD COLUMN args JSONB`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "add proxy upgrade history to contracts",
+		Statements: []string{
+			`ALTER TABLE census_contracts ADD COLUMN upgrades JSONB`,
+			`ALTER TABLE census_contracts ADD COLUMN upgrade_pending BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "create backfill segments table",
+		Statements: []string{
+			`CREATE TABLE census_backfill_segments (
+				chain_id   BIGINT NOT NULL,
+				contract   BYTEA  NOT NULL,
+				from_block BIGINT NOT NULL,
+				to_block   BIGINT NOT NULL,
+				PRIMARY KEY (chain_id, contract, from_block)
+			)`,
+		},
+	},
+}
+
+const postgresEventColumns = `chain_id, contract, block_number, log_index, account, previous_weight::text, new_weight::text, args`
+
+const postgresContractColumns = `chain_id, contract, start_block, expires_at, archived_at, archive_indexed_until,
+	archive_verified_until, archive_accounts, archive_total_weight::text, archive_root,
+	policy_auto_extend, policy_max_expires_at, policy_last_event_block, label, deployment_tx, start_block_method, event_profile,
+	upgrades, upgrade_pending`
+
+const postgresReindexJobColumns = `chain_id, contract, id, from_block, to_block, status, indexed_until, verified_until, created_at, completed_at`
+
+// postgresQuerier is implemented by both *sql.DB and *sql.Tx.
+type postgresQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PostgresStore is a Backend that keeps events, progress cursors, contracts and
+// re-index jobs in PostgreSQL, so several processes can share them and other
+// tables can join against census data.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the PostgreSQL database described by dsn. Migrate
+// must run before the store is used to create or upgrade its tables.
+func OpenPostgres(ctx context.Context, dsn string) (*PostgresStore, error) {
+	database, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	if err := database.PingContext(ctx); err != nil {
+		_ = database.Close()
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	return &PostgresStore{db: database}, nil
+}
+
+// Close closes the connection pool.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate brings the PostgreSQL schema to the latest version. Each migration
+// runs in its own transaction under an advisory lock, so concurrent replicas
+// apply it once. It returns ErrSchemaTooNew if the schema was written by a
+// newer binary.
+func (s *PostgresStore) Migrate(ctx context.Context, opts MigrateOptions) (MigrationReport, error) {
+	target := postgresMigrations[len(postgresMigrations)-1].Version
+	current, err := postgresSchemaVersion(ctx, s.db)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	if current > target {
+		return MigrationReport{}, fmt.Errorf("%w: database is at version %d, this binary supports up to %d", ErrSchemaTooNew, current, target)
+	}
+	report := MigrationReport{From: current, To: target, DryRun: opts.DryRun, Pending: []MigrationInfo{}}
+	for _, m := range postgresMigrations {
+		if m.Version > current {
+			report.Pending = append(report.Pending, MigrationInfo{Version: m.Version, Description: m.Description})
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	for _, m := range postgresMigrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := s.runMigration(ctx, m); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (s *PostgresStore) runMigration(ctx context.Context, m postgresMigration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migration %d: %w", m.Version, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("lock schema: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS census_schema (version BIGINT NOT NULL)`); err != nil {
+		return fmt.Errorf("create schema version table: %w", err)
+	}
+	// Another process may have applied the migration while we waited for the lock.
+	current, err := postgresSchemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if current >= m.Version {
+		return tx.Commit()
+	}
+	log.Infow("running store migration", "backend", "postgres", "version", m.Version, "description", m.Description)
+	for _, statement := range m.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM census_schema`); err != nil {
+		return fmt.Errorf("clear schema version: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO census_schema (version) VALUES ($1)`, m.Version); err != nil {
+		return fmt.Errorf("store schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// postgresSchemaVersion returns the recorded schema version, or 0 when the
+// census tables have not been created yet.
+func postgresSchemaVersion(ctx context.Context, q postgresQuerier) (uint64, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT to_regclass('census_schema') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("check schema version table: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version uint64
+	err := q.QueryRowContext(ctx, `SELECT version FROM census_schema`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// LastIndexedBlock returns the last indexed block number if present.
+func (s *PostgresStore) LastIndexedBlock(ctx context.Context, chainID uint64, contract common.Address) (uint64, bool, error) {
+	return postgresProgressBlock(ctx, s.db, chainID, contract, "indexed_block")
+}
+
+// LastVerifiedBlock returns the last verified block number if present.
+func (s *PostgresStore) LastVerifiedBlock(ctx context.Context, chainID uint64, contract common.Address) (uint64, bool, error) {
+	return postgresProgressBlock(ctx, s.db, chainID, contract, "verified_block")
+}
+
+func postgresProgressBlock(ctx context.Context, q postgresQuerier, chainID uint64, contract common.Address, column string) (uint64, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	var block sql.Null[uint64]
+	err := q.QueryRowContext(ctx,
+		`SELECT `+column+` FROM census_cursors WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract.Bytes(),
+	).Scan(&block)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get %s: %w", column, err)
+	}
+	return block.V, block.Valid, nil
+}
+
+// SaveEvents persists the provided events and updates the last indexed block for the contract.
+func (s *PostgresStore) SaveEvents(ctx context.Context, chainID uint64, contract common.Address, events []Event, lastIndexedBlock uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	for _, event := range events {
+		if event.ChainID == 0 {
+			return fmt.Errorf("event chainID is required")
+		}
+		if !common.IsHexAddress(event.Contract) {
+			return fmt.Errorf("event contract is invalid")
+		}
+	}
+	return s.withTx(ctx, "commit events", func(tx *sql.Tx) error {
+		if err := postgresInsertEvents(ctx, tx, events); err != nil {
+			return err
+		}
+		return postgresSetProgressBlocks(ctx, tx, chainID, contract, ReplaceOptions{
+			IndexedUntil:  &lastIndexedBlock,
+			VerifiedUntil: &lastIndexedBlock,
+		})
+	})
+}
+
+// ReplaceEventsInRange atomically rewrites all events in the inclusive block range and
+// optionally updates indexed and/or verified progress cursors.
+func (s *PostgresStore) ReplaceEventsInRange(
+	ctx context.Context,
+	chainID uint64,
+	contract common.Address,
+	from, to uint64,
+	events []Event,
+	opts ReplaceOptions,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	if from > to {
+		return fmt.Errorf("from block must be less than or equal to to block")
+	}
+	for _, event := range events {
+		if err := validateEventForRange(event, chainID, contract, from, to); err != nil {
+			return err
+		}
+	}
+	return s.withTx(ctx, "commit range replacement", func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM census_events WHERE chain_id = $1 AND contract = $2 AND block_number BETWEEN $3 AND $4`,
+			chainID, contract.Bytes(), from, to,
+		); err != nil {
+			return fmt.Errorf("delete events in range: %w", err)
+		}
+		if err := postgresInsertEvents(ctx, tx, events); err != nil {
+			return err
+		}
+		return postgresSetProgressBlocks(ctx, tx, chainID, contract, opts)
+	})
+}
+
+// IterateEvents calls fn for every stored event of a contract in ascending
+// block and log index order. Iteration stops at the first error returned by fn.
+func (s *PostgresStore) IterateEvents(ctx context.Context, chainID uint64, contract common.Address, fn func(Event) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 || contract == (common.Address{}) {
+		return fmt.Errorf("both chainID and contract are required")
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresEventColumns+` FROM census_events
+		WHERE chain_id = $1 AND contract = $2
+		ORDER BY block_number, log_index`,
+		chainID, contract.Bytes(),
+	)
+	if err != nil {
+		return fmt.Errorf("query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var decoder eventDecoder
+	for rows.Next() {
+		event, err := decoder.scanPostgresEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate events: %w", err)
+	}
+	return nil
+}
+
+// IterateEventsInRange calls fn for every event of the contract in the
+// inclusive block range [from, to], in block and log index order.
+func (s *PostgresStore) IterateEventsInRange(ctx context.Context, chainID uint64, contract common.Address, from, to uint64, fn func(Event) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 || contract == (common.Address{}) {
+		return fmt.Errorf("both chainID and contract are required")
+	}
+	if from > to {
+		return nil
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresEventColumns+` FROM census_events
+		WHERE chain_id = $1 AND contract = $2 AND block_number BETWEEN $3 AND $4
+		ORDER BY block_number, log_index`,
+		chainID, contract.Bytes(), from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var decoder eventDecoder
+	for rows.Next() {
+		event, err := decoder.scanPostgresEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate events: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns events matching the provided options, ordered like the
+// Pebble store: by chain, contract, block number and log index.
+func (s *PostgresStore) ListEvents(ctx context.Context, opts ListOptions) ([]Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts, err := validateListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	direction := "ASC"
+	if opts.OrderDirection == "desc" {
+		direction = "DESC"
+	}
+	query := `SELECT ` + postgresEventColumns + ` FROM census_events`
+	var args []any
+	if opts.ChainID != 0 {
+		query += ` WHERE chain_id = $1 AND contract = $2`
+		args = append(args, opts.ChainID, opts.Contract.Bytes())
+	}
+	query += fmt.Sprintf(` ORDER BY chain_id %[1]s, contract %[1]s, block_number %[1]s, log_index %[1]s`, direction)
+	if opts.First > 0 {
+		args = append(args, opts.First)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	args = append(args, opts.Skip)
+	query += fmt.Sprintf(` OFFSET $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		decoder eventDecoder
+		results []Event
+	)
+	for rows.Next() {
+		event, err := decoder.scanPostgresEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events: %w", err)
+	}
+	return results, nil
+}
+
+// GetContract returns the stored configuration of a contract if present.
+func (s *PostgresStore) GetContract(ctx context.Context, chainID uint64, contract common.Address) (ContractRecord, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ContractRecord{}, false, err
+	}
+	return postgresGetContract(ctx, s.db, chainID, contract, "")
+}
+
+func postgresGetContract(ctx context.Context, q postgresQuerier, chainID uint64, contract common.Address, lock string) (ContractRecord, bool, error) {
+	record, err := scanPostgresContract(q.QueryRowContext(ctx,
+		`SELECT `+postgresContractColumns+` FROM census_contracts WHERE chain_id = $1 AND contract = $2`+lock,
+		chainID, contract.Bytes(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ContractRecord{}, false, nil
+	}
+	if err != nil {
+		return ContractRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+// SaveContract stores a contract configuration.
+// If the contract already exists, startBlock is preserved and expiresAt is updated.
+// Archived contracts are rejected with ErrContractArchived.
+func (s *PostgresStore) SaveContract(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt is required")
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO census_contracts (chain_id, contract, start_block, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chain_id, contract) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE census_contracts.archived_at IS NULL`,
+		chainID, contract.Bytes(), startBlock, expiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	// The conflicting row is left untouched when the contract is archived.
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	} else if updated == 0 {
+		return ErrContractArchived
+	}
+	return nil
+}
+
+// SetContractStartBlock updates the start block for an existing contract, and
+// the method used to find it, only when the current stored value is zero.
+func (s *PostgresStore) SetContractStartBlock(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, method string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	if _, ok, err := s.GetContract(ctx, chainID, contract); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE census_contracts SET start_block = $3, start_block_method = $4
+		WHERE chain_id = $1 AND contract = $2 AND start_block = 0`,
+		chainID, contract.Bytes(), startBlock, method,
+	); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	return nil
+}
+
+// ListContracts returns all stored contracts.
+func (s *PostgresStore) ListContracts(ctx context.Context) ([]ContractRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresContractColumns+` FROM census_contracts ORDER BY chain_id, contract`)
+	if err != nil {
+		return nil, fmt.Errorf("query contracts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var results []ContractRecord
+	for rows.Next() {
+		record, err := scanPostgresContract(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contracts: %w", err)
+	}
+	return results, nil
+}
+
+// DeleteContractData removes contract metadata and all indexed events for that contract.
+func (s *PostgresStore) DeleteContractData(ctx context.Context, chainID uint64, contract common.Address) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	return s.withTx(ctx, "commit contract purge", func(tx *sql.Tx) error {
+		for _, table := range []string{"census_events", "census_reindex_jobs", "census_backfill_segments", "census_cursors", "census_contracts"} {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM `+table+` WHERE chain_id = $1 AND contract = $2`,
+				chainID, contract.Bytes(),
+			); err != nil {
+				return fmt.Errorf("delete from %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ArchiveContract marks a contract as archived with the given snapshot summary.
+// A zero ArchivedAt is set to the current time.
+func (s *PostgresStore) ArchiveContract(ctx context.Context, chainID uint64, contract common.Address, archive ContractArchive) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if archive.ArchivedAt.IsZero() {
+		archive.ArchivedAt = time.Now()
+	}
+	totalWeight, err := postgresWeight(archive.TotalWeight)
+	if err != nil {
+		return fmt.Errorf("archive total weight: %w", err)
+	}
+	return s.withTx(ctx, "commit contract archive", func(tx *sql.Tx) error {
+		record, ok, err := postgresGetContract(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("contract not found")
+		}
+		if record.Archive != nil {
+			return ErrContractArchived
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE census_contracts SET archived_at = $3, archive_indexed_until = $4, archive_verified_until = $5,
+			archive_accounts = $6, archive_total_weight = $7, archive_root = $8
+			WHERE chain_id = $1 AND contract = $2`,
+			chainID, contract.Bytes(), archive.ArchivedAt.UTC(), archive.IndexedUntil, archive.VerifiedUntil,
+			archive.Accounts, totalWeight, archive.Root.Bytes(),
+		); err != nil {
+			return fmt.Errorf("store contract archive: %w", err)
+		}
+		return nil
+	})
+}
+
+// UnarchiveContract makes an archived contract active again with a new
+// expiration time. Indexing resumes from its retained progress cursors.
+func (s *PostgresStore) UnarchiveContract(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt is required")
+	}
+	return s.withTx(ctx, "commit contract restore", func(tx *sql.Tx) error {
+		record, ok, err := postgresGetContract(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("contract not found")
+		}
+		if record.Archive == nil {
+			return fmt.Errorf("contract is not archived")
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE census_contracts SET expires_at = $3, archived_at = NULL, archive_indexed_until = NULL,
+			archive_verified_until = NULL, archive_accounts = NULL, archive_total_weight = NULL, archive_root = NULL
+			WHERE chain_id = $1 AND contract = $2`,
+			chainID, contract.Bytes(), expiresAt.UTC(),
+		); err != nil {
+			return fmt.Errorf("store contract: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetContractEventProfile sets the event profile of an existing contract. A
+// nil profile restores the default WeightChanged event.
+func (s *PostgresStore) SetContractEventProfile(ctx context.Context, chainID uint64, contract common.Address, profile *EventProfile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	var payload []byte
+	if profile != nil {
+		var err error
+		if payload, err = json.Marshal(profile); err != nil {
+			return fmt.Errorf("marshal contract event profile: %w", err)
+		}
+	}
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE census_contracts SET event_profile = $3 WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract.Bytes(), payload,
+	)
+	if err != nil {
+		return fmt.Errorf("store contract event profile: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("store contract event profile: %w", err)
+	} else if updated == 0 {
+		return fmt.Errorf("contract not found")
+	}
+	return nil
+}
+
+// RecordContractUpgrade appends an implementation to the upgrade history of an
+// existing contract. With pause, indexing of the contract stays paused until
+// AcknowledgeContractUpgrade is called.
+func (s *PostgresStore) RecordContractUpgrade(ctx context.Context, chainID uint64, contract common.Address, upgrade ProxyUpgrade, pause bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	upgrade.DetectedAt = upgrade.DetectedAt.UTC()
+	payload, err := json.Marshal([]ProxyUpgrade{upgrade})
+	if err != nil {
+		return fmt.Errorf("marshal contract upgrade: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE census_contracts SET upgrades = COALESCE(upgrades, '[]'::jsonb) || $3::jsonb,
+		upgrade_pending = upgrade_pending OR $4
+		WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract.Bytes(), payload, pause,
+	)
+	if err != nil {
+		return fmt.Errorf("store contract upgrade: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("store contract upgrade: %w", err)
+	} else if updated == 0 {
+		return fmt.Errorf("contract not found")
+	}
+	return nil
+}
+
+// AcknowledgeContractUpgrade resumes indexing of a contract paused by an
+// upgrade. It reports false when no upgrade was pending.
+func (s *PostgresStore) AcknowledgeContractUpgrade(ctx context.Context, chainID uint64, contract common.Address) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	acknowledged := false
+	err := s.withTx(ctx, "commit upgrade acknowledgement", func(tx *sql.Tx) error {
+		record, ok, err := postgresGetContract(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("contract not found")
+		}
+		if !record.UpgradePending {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE census_contracts SET upgrade_pending = FALSE WHERE chain_id = $1 AND contract = $2`,
+			chainID, contract.Bytes(),
+		); err != nil {
+			return fmt.Errorf("store upgrade acknowledgement: %w", err)
+		}
+		acknowledged = true
+		return nil
+	})
+	return acknowledged, err
+}
+
+// SetContractDeploymentTx sets the deployment transaction hash of an existing
+// contract. A zero hash removes it.
+func (s *PostgresStore) SetContractDeploymentTx(ctx context.Context, chainID uint64, contract common.Address, txHash common.Hash) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var hash []byte
+	if txHash != (common.Hash{}) {
+		hash = txHash.Bytes()
+	}
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE census_contracts SET deployment_tx = $3 WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract.Bytes(), hash,
+	)
+	if err != nil {
+		return fmt.Errorf("store contract deployment tx: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("store contract deployment tx: %w", err)
+	} else if updated == 0 {
+		return fmt.Errorf("contract not found")
+	}
+	return nil
+}
+
+// SetContractLabel sets the label of an existing contract. An empty label
+// removes it.
+func (s *PostgresStore) SetContractLabel(ctx context.Context, chainID uint64, contract common.Address, label string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE census_contracts SET label = $3 WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract.Bytes(), strings.TrimSpace(label),
+	)
+	if err != nil {
+		return fmt.Errorf("store contract label: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("store contract label: %w", err)
+	} else if updated == 0 {
+		return fmt.Errorf("contract not found")
+	}
+	return nil
+}
+
+// SetContractExpiry replaces the expiration and expiry policy of an existing
+// contract. A nil or zero policy removes it. Archived contracts are rejected
+// with ErrContractArchived.
+func (s *PostgresStore) SetContractExpiry(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time, policy *ExpiryPolicy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt is required")
+	}
+	if err := policy.Validate(expiresAt); err != nil {
+		return err
+	}
+	var (
+		autoExtend   sql.Null[int64]
+		maxExpiresAt sql.Null[time.Time]
+		lastEvent    sql.Null[uint64]
+	)
+	if policy = normalizeExpiryPolicy(policy); policy != nil {
+		autoExtend = sql.Null[int64]{V: int64(policy.AutoExtend), Valid: true}
+		lastEvent = sql.Null[uint64]{V: policy.LastEventBlock, Valid: true}
+		if policy.MaxExpiresAt != nil {
+			maxExpiresAt = sql.Null[time.Time]{V: *policy.MaxExpiresAt, Valid: true}
+		}
+	}
+	return s.withTx(ctx, "commit contract expiry", func(tx *sql.Tx) error {
+		record, ok, err := postgresGetContract(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("contract not found")
+		}
+		if record.Archive != nil {
+			return ErrContractArchived
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE census_contracts SET expires_at = $3, policy_auto_extend = $4, policy_max_expires_at = $5,
+			policy_last_event_block = $6
+			WHERE chain_id = $1 AND contract = $2`,
+			chainID, contract.Bytes(), expiresAt.UTC(), autoExtend, maxExpiresAt, lastEvent,
+		); err != nil {
+			return fmt.Errorf("store contract expiry: %w", err)
+		}
+		return nil
+	})
+}
+
+// ScheduleReindex stores a new re-index job for [from,to]. The range is clamped
+// to the contract start block and must already be covered by the indexed cursor.
+func (s *PostgresStore) ScheduleReindex(ctx context.Context, chainID uint64, contract common.Address, from, to uint64) (ReindexJob, error) {
+	if err := ctx.Err(); err != nil {
+		return ReindexJob{}, err
+	}
+	var job ReindexJob
+	err := s.withTx(ctx, "commit reindex job", func(tx *sql.Tx) error {
+		// Locking the contract row serializes job ID allocation across processes.
+		record, ok, err := postgresGetContract(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: contract not found", ErrInvalidReindexJob)
+		}
+		indexedUntil, indexed, err := postgresProgressBlock(ctx, tx, chainID, contract, "indexed_block")
+		if err != nil {
+			return err
+		}
+		var lastID uint64
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COALESCE(MAX(id), 0) FROM census_reindex_jobs WHERE chain_id = $1 AND contract = $2`,
+			chainID, contract.Bytes(),
+		).Scan(&lastID); err != nil {
+			return fmt.Errorf("get last reindex job: %w", err)
+		}
+		if job, err = newReindexJob(record, indexedUntil, indexed, from, to, lastID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM census_reindex_jobs WHERE chain_id = $1 AND contract = $2 AND status = $3 AND id NOT IN (
+				SELECT id FROM census_reindex_jobs WHERE chain_id = $1 AND contract = $2 AND status = $3
+				ORDER BY id DESC LIMIT $4)`,
+			chainID, contract.Bytes(), ReindexCompleted, keptCompletedReindexJobs,
+		); err != nil {
+			return fmt.Errorf("prune reindex jobs: %w", err)
+		}
+		return postgresSetReindexJob(ctx, tx, job)
+	})
+	if err != nil {
+		return ReindexJob{}, err
+	}
+	return job, nil
+}
+
+// ListReindexJobs returns the re-index jobs of a contract ordered by ID. A zero
+// chainID lists the jobs of every contract.
+func (s *PostgresStore) ListReindexJobs(ctx context.Context, chainID uint64, contract common.Address) ([]ReindexJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + postgresReindexJobColumns + ` FROM census_reindex_jobs`
+	var args []any
+	if chainID != 0 {
+		query += ` WHERE chain_id = $1 AND contract = $2`
+		args = append(args, chainID, contract.Bytes())
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY chain_id, contract, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query reindex jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var results []ReindexJob
+	for rows.Next() {
+		job, err := scanPostgresReindexJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reindex jobs: %w", err)
+	}
+	return results, nil
+}
+
+// ListBackfillSegments returns the completed backfill segments of a contract
+// ordered by their first block.
+func (s *PostgresStore) ListBackfillSegments(ctx context.Context, chainID uint64, contract common.Address) ([]BackfillSegment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return postgresBackfillSegments(ctx, s.db, chainID, contract, "")
+}
+
+// MergeBackfillSegments advances the indexed and verified cursors through the
+// segments contiguous with verifiedUntil and deletes them, together with the
+// segments already behind it. It returns the new verified cursor.
+func (s *PostgresStore) MergeBackfillSegments(ctx context.Context, chainID uint64, contract common.Address, verifiedUntil uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	merged := verifiedUntil
+	err := s.withTx(ctx, "commit backfill merge", func(tx *sql.Tx) error {
+		segments, err := postgresBackfillSegments(ctx, tx, chainID, contract, " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		removed := 0
+		for _, segment := range segments {
+			if segment.From > merged+1 {
+				break
+			}
+			merged = max(merged, segment.To)
+			removed++
+		}
+		if removed == 0 {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM census_backfill_segments WHERE chain_id = $1 AND contract = $2 AND to_block <= $3`,
+			chainID, contract.Bytes(), merged,
+		); err != nil {
+			return fmt.Errorf("delete backfill segments: %w", err)
+		}
+		if merged == verifiedUntil {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO census_cursors (chain_id, contract, indexed_block, verified_block) VALUES ($1, $2, $3, $3)
+			ON CONFLICT (chain_id, contract) DO UPDATE SET
+				indexed_block = GREATEST(COALESCE(census_cursors.indexed_block, 0), EXCLUDED.indexed_block),
+				verified_block = EXCLUDED.verified_block`,
+			chainID, contract.Bytes(), merged,
+		); err != nil {
+			return fmt.Errorf("store merged cursors: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return merged, nil
+}
+
+func postgresBackfillSegments(ctx context.Context, q postgresQuerier, chainID uint64, contract common.Address, lock string) ([]BackfillSegment, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT from_block, to_block FROM census_backfill_segments WHERE chain_id = $1 AND contract = $2 ORDER BY from_block`+lock,
+		chainID, contract.Bytes(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query backfill segments: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var results []BackfillSegment
+	for rows.Next() {
+		var segment BackfillSegment
+		if err := rows.Scan(&segment.From, &segment.To); err != nil {
+			return nil, fmt.Errorf("decode backfill segment: %w", err)
+		}
+		results = append(results, segment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate backfill segments: %w", err)
+	}
+	return results, nil
+}
+
+// GetReindexJob returns a re-index job by ID.
+func (s *PostgresStore) GetReindexJob(ctx context.Context, chainID uint64, contract common.Address, id uint64) (ReindexJob, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ReindexJob{}, false, err
+	}
+	job, err := scanPostgresReindexJob(s.db.QueryRowContext(ctx,
+		`SELECT `+postgresReindexJobColumns+` FROM census_reindex_jobs WHERE chain_id = $1 AND contract = $2 AND id = $3`,
+		chainID, contract.Bytes(), id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReindexJob{}, false, nil
+	}
+	if err != nil {
+		return ReindexJob{}, false, err
+	}
+	return job, true, nil
+}
+
+// Compact is a no-op: PostgreSQL reclaims deleted rows through autovacuum.
+func (s *PostgresStore) Compact(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *PostgresStore) withTx(ctx context.Context, label string, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	return nil
+}
+
+func postgresInsertEvents(ctx context.Context, tx *sql.Tx, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO census_events (chain_id, contract, block_number, log_index, account, previous_weight, new_weight, args)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (chain_id, contract, block_number, log_index) DO UPDATE SET
+			account = EXCLUDED.account,
+			previous_weight = EXCLUDED.previous_weight,
+			new_weight = EXCLUDED.new_weight,
+			args = EXCLUDED.args`)
+	if err != nil {
+		return fmt.Errorf("prepare event insert: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !common.IsHexAddress(event.Account) {
+			return fmt.Errorf("event account is invalid")
+		}
+		previousWeight, err := postgresWeight(event.PreviousWeight)
+		if err != nil {
+			return err
+		}
+		newWeight, err := postgresWeight(event.NewWeight)
+		if err != nil {
+			return err
+		}
+		var args []byte
+		if len(event.Args) > 0 {
+			if args, err = json.Marshal(event.Args); err != nil {
+				return fmt.Errorf("marshal event args: %w", err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx,
+			event.ChainID,
+			common.HexToAddress(event.Contract).Bytes(),
+			event.BlockNumber,
+			event.LogIndex,
+			common.HexToAddress(event.Account).Bytes(),
+			previousWeight,
+			newWeight,
+			args,
+		); err != nil {
+			return fmt.Errorf("store event: %w", err)
+		}
+	}
+	return nil
+}
+
+// postgresWeight validates a decimal weight and returns its canonical form.
+func postgresWeight(weight string) (string, error) {
+	n, ok := new(big.Int).SetString(weight, 10)
+	if !ok || n.Sign() < 0 {
+		return "", fmt.Errorf("event weight %q is not a non-negative integer", weight)
+	}
+	return n.String(), nil
+}
+
+func (d *eventDecoder) scanPostgresEvent(rows *sql.Rows) (Event, error) {
+	var (
+		event                   Event
+		contract, account, args []byte
+	)
+	if err := rows.Scan(
+		&event.ChainID,
+		&contract,
+		&event.BlockNumber,
+		&event.LogIndex,
+		&account,
+		&event.PreviousWeight,
+		&event.NewWeight,
+		&args,
+	); err != nil {
+		return Event{}, fmt.Errorf("decode event: %w", err)
+	}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &event.Args); err != nil {
+			return Event{}, fmt.Errorf("decode event args: %w", err)
+		}
+	}
+	if address := common.BytesToAddress(contract); d.contractHex == "" || address != d.contract {
+		d.contract = address
+		d.contractHex = d.checksumHex(address)
+	}
+	event.Contract = d.contractHex
+	event.Account = d.checksumHex(common.BytesToAddress(account))
+	return event, nil
+}
+
+func postgresSetProgressBlocks(ctx context.Context, tx *sql.Tx, chainID uint64, contract common.Address, opts ReplaceOptions) error {
+	for _, cursor := range []struct {
+		column string
+		block  *uint64
+	}{
+		{"indexed_block", opts.IndexedUntil},
+		{"verified_block", opts.VerifiedUntil},
+	} {
+		if cursor.block == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO census_cursors (chain_id, contract, `+cursor.column+`) VALUES ($1, $2, $3)
+			ON CONFLICT (chain_id, contract) DO UPDATE SET `+cursor.column+` = EXCLUDED.`+cursor.column,
+			chainID, contract.Bytes(), *cursor.block,
+		); err != nil {
+			return fmt.Errorf("store %s: %w", cursor.column, err)
+		}
+	}
+	if opts.ReindexJob != nil {
+		if err := postgresSetReindexJob(ctx, tx, *opts.ReindexJob); err != nil {
+			return err
+		}
+	}
+	if opts.BackfillSegment != nil {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO census_backfill_segments (chain_id, contract, from_block, to_block) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (chain_id, contract, from_block) DO UPDATE SET to_block = EXCLUDED.to_block`,
+			chainID, contract.Bytes(), opts.BackfillSegment.From, opts.BackfillSegment.To,
+		); err != nil {
+			return fmt.Errorf("store backfill segment: %w", err)
+		}
+	}
+	return nil
+}
+
+func postgresSetReindexJob(ctx context.Context, tx *sql.Tx, job ReindexJob) error {
+	if !common.IsHexAddress(job.Contract) {
+		return fmt.Errorf("reindex job contract is invalid")
+	}
+	var completedAt sql.Null[time.Time]
+	if job.CompletedAt != nil {
+		completedAt = sql.Null[time.Time]{V: job.CompletedAt.UTC(), Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO census_reindex_jobs (`+postgresReindexJobColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (chain_id, contract, id) DO UPDATE SET
+			from_block = EXCLUDED.from_block,
+			to_block = EXCLUDED.to_block,
+			status = EXCLUDED.status,
+			indexed_until = EXCLUDED.indexed_until,
+			verified_until = EXCLUDED.verified_until,
+			created_at = EXCLUDED.created_at,
+			completed_at = EXCLUDED.completed_at`,
+		job.ChainID, common.HexToAddress(job.Contract).Bytes(), job.ID, job.From, job.To, job.Status,
+		job.IndexedUntil, job.VerifiedUntil, job.CreatedAt.UTC(), completedAt,
+	); err != nil {
+		return fmt.Errorf("store reindex job: %w", err)
+	}
+	return nil
+}
+
+func scanPostgresContract(row interface{ Scan(...any) error }) (ContractRecord, error) {
+	var (
+		record        ContractRecord
+		contract      []byte
+		archivedAt    sql.Null[time.Time]
+		indexedUntil  sql.Null[uint64]
+		verifiedUntil sql.Null[uint64]
+		accounts      sql.Null[int]
+		totalWeight   sql.Null[string]
+		root          []byte
+		autoExtend    sql.Null[int64]
+		maxExpiresAt  sql.Null[time.Time]
+		lastEvent     sql.Null[uint64]
+		deploymentTx  []byte
+		eventProfile  []byte
+		upgrades      []byte
+	)
+	err := row.Scan(&record.ChainID, &contract, &record.StartBlock, &record.ExpiresAt,
+		&archivedAt, &indexedUntil, &verifiedUntil, &accounts, &totalWeight, &root,
+		&autoExtend, &maxExpiresAt, &lastEvent, &record.Label, &deploymentTx, &record.StartBlockMethod, &eventProfile,
+		&upgrades, &record.UpgradePending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ContractRecord{}, err
+	}
+	if err != nil {
+		return ContractRecord{}, fmt.Errorf("decode contract: %w", err)
+	}
+	record.Contract = common.BytesToAddress(contract).Hex()
+	record.ExpiresAt = record.ExpiresAt.UTC()
+	if len(deploymentTx) > 0 {
+		record.DeploymentTx = common.BytesToHash(deploymentTx).Hex()
+	}
+	if len(eventProfile) > 0 {
+		if err := json.Unmarshal(eventProfile, &record.EventProfile); err != nil {
+			return ContractRecord{}, fmt.Errorf("decode contract event profile: %w", err)
+		}
+	}
+	if len(upgrades) > 0 {
+		if err := json.Unmarshal(upgrades, &record.Upgrades); err != nil {
+			return ContractRecord{}, fmt.Errorf("decode contract upgrades: %w", err)
+		}
+	}
+	if archivedAt.Valid {
+		record.Archive = &ContractArchive{
+			ArchivedAt:    archivedAt.V.UTC(),
+			IndexedUntil:  indexedUntil.V,
+			VerifiedUntil: verifiedUntil.V,
+			Accounts:      accounts.V,
+			TotalWeight:   totalWeight.V,
+			Root:          common.BytesToHash(root),
+		}
+	}
+	if autoExtend.Valid || maxExpiresAt.Valid {
+		record.Policy = &ExpiryPolicy{
+			AutoExtend:     Duration(autoExtend.V),
+			LastEventBlock: lastEvent.V,
+		}
+		if maxExpiresAt.Valid {
+			maxExpires := maxExpiresAt.V.UTC()
+			record.Policy.MaxExpiresAt = &maxExpires
+		}
+	}
+	return record, nil
+}
+
+func scanPostgresReindexJob(row interface{ Scan(...any) error }) (ReindexJob, error) {
+	var (
+		job         ReindexJob
+		contract    []byte
+		completedAt sql.Null[time.Time]
+	)
+	err := row.Scan(&job.ChainID, &contract, &job.ID, &job.From, &job.To, &job.Status,
+		&job.IndexedUntil, &job.VerifiedUntil, &job.CreatedAt, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReindexJob{}, err
+	}
+	if err != nil {
+		return ReindexJob{}, fmt.Errorf("decode reindex job: %w", err)
+	}
+	job.Contract = common.BytesToAddress(contract).Hex()
+	job.CreatedAt = job.CreatedAt.UTC()
+	if completedAt.Valid {
+		completed := completedAt.V.UTC()
+		job.CompletedAt = &completed
+	}
+	return job, nil
+}
@@ -0,0 +1,109 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+	"github.com/vocdoni/onchain-census-indexer/internal/store/storetest"
+)
+
+// postgresTestDSNEnv points the Postgres suite at an existing server instead of
+// the embedded one. The DSN must be a URL; every subtest runs in its own schema.
+const postgresTestDSNEnv = "POSTGRES_TEST_DSN"
+
+func TestPostgresBackend(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping postgres backend in short mode")
+	}
+	dsn := os.Getenv(postgresTestDSNEnv)
+	if dsn == "" {
+		dsn = startEmbeddedPostgres(t)
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	defer func() {
+		if cerr := admin.Close(); cerr != nil {
+			t.Fatalf("close postgres: %v", cerr)
+		}
+	}()
+
+	schemas := 0
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		ctx := context.Background()
+		schemas++
+		schema := fmt.Sprintf("census_storetest_%d_%d", os.Getpid(), schemas)
+		if _, err := admin.ExecContext(ctx, `CREATE SCHEMA `+schema); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.ExecContext(context.Background(), `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+				t.Fatalf("drop schema: %v", err)
+			}
+		})
+		schemaDSN, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatalf("parse %s: %v", postgresTestDSNEnv, err)
+		}
+		query := schemaDSN.Query()
+		query.Set("search_path", schema)
+		schemaDSN.RawQuery = query.Encode()
+
+		backend, err := store.OpenPostgres(ctx, schemaDSN.String())
+		if err != nil {
+			t.Fatalf("open postgres store: %v", err)
+		}
+		t.Cleanup(func() {
+			if cerr := backend.Close(); cerr != nil {
+				t.Fatalf("close postgres store: %v", cerr)
+			}
+		})
+		report, err := backend.Migrate(ctx, store.MigrateOptions{})
+		if err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		if report.From != 0 || len(report.Pending) == 0 {
+			t.Fatalf("expected a fresh schema to run every migration, got %+v", report)
+		}
+		return backend
+	})
+}
+
+// startEmbeddedPostgres runs a throwaway PostgreSQL server for the test. The
+// binaries are downloaded on first use; the test is skipped when that is not
+// possible (offline sandboxes, running as root).
+func startEmbeddedPostgres(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	if err := listener.Close(); err != nil {
+		t.Fatalf("release port: %v", err)
+	}
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(t.TempDir()).
+		Logger(io.Discard)
+	server := embeddedpostgres.NewDatabase(cfg)
+	if err := server.Start(); err != nil {
+		t.Skipf("embedded postgres unavailable (set %s to use an existing server): %v", postgresTestDSNEnv, err)
+	}
+	t.Cleanup(func() {
+		if err := server.Stop(); err != nil {
+			t.Errorf("stop embedded postgres: %v", err)
+		}
+	})
+	return cfg.GetConnectionURL() + "?sslmode=disable"
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// EventProfile describes the event indexed for a contract and how its decoded
+// arguments produce weight changes. Contracts without a profile index the
+// WeightChanged event of the census validator interface.
+//
+// A profile maps either a weight event, where Account and NewWeight name the
+// account and its new weight, or a transfer event, where From, To and Value
+// move Value from the balance of From to the balance of To and balances are
+// the weights.
+type EventProfile struct {
+	// ABI is a JSON ABI fragment holding exactly one event.
+	ABI string `json:"abi"`
+	// Account names the address argument whose weight changes.
+	Account string `json:"account,omitempty"`
+	// NewWeight names the integer argument holding the new weight.
+	NewWeight string `json:"newWeight,omitempty"`
+	// PreviousWeight optionally names the integer argument holding the
+	// previous weight. Without it previous weights are reported as zero.
+	PreviousWeight string `json:"previousWeight,omitempty"`
+	// From names the address argument whose balance decreases. The zero
+	// address mints.
+	From string `json:"from,omitempty"`
+	// To names the address argument whose balance increases. The zero
+	// address burns.
+	To string `json:"to,omitempty"`
+	// Value names the integer argument holding the transferred amount.
+	Value string `json:"value,omitempty"`
+}
+
+// Transfers reports whether the profile maps a transfer event.
+func (p *EventProfile) Transfers() bool {
+	return p != nil && p.Value != ""
+}
+
+// Event parses the ABI fragment and checks that the mapped arguments exist
+// and have suitable types.
+func (p *EventProfile) Event() (abi.Event, error) {
+	if p == nil {
+		return abi.Event{}, fmt.Errorf("event profile is required")
+	}
+	parsed, err := abi.JSON(strings.NewReader(p.ABI))
+	if err != nil {
+		return abi.Event{}, fmt.Errorf("parse event ABI: %w", err)
+	}
+	if len(parsed.Events) != 1 {
+		return abi.Event{}, fmt.Errorf("event ABI must hold exactly one event, got %d", len(parsed.Events))
+	}
+	var event abi.Event
+	for _, e := range parsed.Events {
+		event = e
+	}
+	if event.Anonymous {
+		return abi.Event{}, fmt.Errorf("anonymous event %s cannot be filtered by topic", event.Name)
+	}
+	type argMapping struct {
+		field, arg string
+		types      []byte
+		required   bool
+	}
+	weightMapping := []argMapping{
+		{"account", p.Account, []byte{abi.AddressTy}, true},
+		{"newWeight", p.NewWeight, []byte{abi.UintTy, abi.IntTy}, true},
+		{"previousWeight", p.PreviousWeight, []byte{abi.UintTy, abi.IntTy}, false},
+	}
+	transferMapping := []argMapping{
+		{"from", p.From, []byte{abi.AddressTy}, true},
+		{"to", p.To, []byte{abi.AddressTy}, true},
+		{"value", p.Value, []byte{abi.UintTy}, true},
+	}
+	mapping, unused := weightMapping, transferMapping
+	if p.Transfers() {
+		mapping, unused = transferMapping, weightMapping
+	}
+	for _, m := range unused {
+		if m.arg != "" {
+			return abi.Event{}, fmt.Errorf("%s argument cannot be combined with %s", m.field, mapping[0].field)
+		}
+	}
+	for _, m := range mapping {
+		if m.arg == "" {
+			if m.required {
+				return abi.Event{}, fmt.Errorf("%s argument is required", m.field)
+			}
+			continue
+		}
+		arg, ok := eventArgument(event, m.arg)
+		if !ok {
+			return abi.Event{}, fmt.Errorf("%s argument %q is not an argument of %s", m.field, m.arg, event.Name)
+		}
+		if !slices.Contains(m.types, arg.Type.T) {
+			return abi.Event{}, fmt.Errorf("%s argument %q has unsupported type %s", m.field, m.arg, arg.Type.String())
+		}
+	}
+	// Indexed integers of transfer events are token IDs, as in ERC721 Transfer
+	// events, not amounts.
+	if arg, _ := eventArgument(event, p.Value); p.Transfers() && arg.Indexed {
+		return abi.Event{}, fmt.Errorf("value argument %q must not be indexed", p.Value)
+	}
+	return event, nil
+}
+
+// Validate checks the profile; a nil profile is valid.
+func (p *EventProfile) Validate() error {
+	if p == nil {
+		return nil
+	}
+	_, err := p.Event()
+	return err
+}
+
+func eventArgument(event abi.Event, name string) (abi.Argument, bool) {
+	for _, arg := range event.Inputs {
+		if arg.Name == name {
+			return arg, true
+		}
+	}
+	return abi.Argument{}, false
+}
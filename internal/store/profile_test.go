@@ -0,0 +1,53 @@
+package store
+
+import "testing"
+
+const transferABI = `[{"type":"event","name":"Transfer","inputs":[` +
+	`{"name":"from","type":"address","indexed":true},` +
+	`{"name":"to","type":"address","indexed":true},` +
+	`{"name":"value","type":"uint256","indexed":false}]}]`
+
+func TestEventProfileValidate(t *testing.T) {
+	var nilProfile *EventProfile
+	if err := nilProfile.Validate(); err != nil {
+		t.Fatalf("expected a nil profile to be valid, got %v", err)
+	}
+	event, err := (&EventProfile{ABI: transferABI, Account: "to", NewWeight: "value"}).Event()
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+	if event.Name != "Transfer" || event.Sig != "Transfer(address,address,uint256)" {
+		t.Fatalf("expected the Transfer event, got %s", event.Sig)
+	}
+
+	transfers := &EventProfile{ABI: transferABI, From: "from", To: "to", Value: "value"}
+	if err := transfers.Validate(); err != nil || !transfers.Transfers() {
+		t.Fatalf("expected a valid transfer profile (err=%v)", err)
+	}
+
+	erc721 := `[{"type":"event","name":"Transfer","inputs":[` +
+		`{"name":"from","type":"address","indexed":true},` +
+		`{"name":"to","type":"address","indexed":true},` +
+		`{"name":"tokenId","type":"uint256","indexed":true}]}]`
+	twoEvents := `[{"type":"event","name":"A","inputs":[]},{"type":"event","name":"B","inputs":[]}]`
+	anonymous := `[{"type":"event","name":"Transfer","anonymous":true,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}]`
+	for _, profile := range []EventProfile{
+		{ABI: "not json", Account: "to", NewWeight: "value"},
+		{ABI: twoEvents, Account: "to", NewWeight: "value"},
+		{ABI: anonymous, Account: "to", NewWeight: "value"},
+		{ABI: transferABI, NewWeight: "value"},
+		{ABI: transferABI, Account: "to"},
+		{ABI: transferABI, Account: "owner", NewWeight: "value"},
+		{ABI: transferABI, Account: "value", NewWeight: "value"},
+		{ABI: transferABI, Account: "to", NewWeight: "from"},
+		{ABI: transferABI, Account: "to", NewWeight: "value", PreviousWeight: "from"},
+		{ABI: transferABI, From: "from", Value: "value"},
+		{ABI: transferABI, From: "from", To: "value", Value: "value"},
+		{ABI: transferABI, From: "from", To: "to", Value: "value", Account: "to"},
+		{ABI: erc721, From: "from", To: "to", Value: "tokenId"},
+	} {
+		if err := profile.Validate(); err == nil {
+			t.Fatalf("expected an error for profile %+v", profile)
+		}
+	}
+}
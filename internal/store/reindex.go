@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+)
+
+const reindexKeyPrefix = "meta:reindex:"
+
+// keptCompletedReindexJobs is the number of completed jobs kept per contract
+// when a new job is scheduled; older completed jobs are pruned.
+const keptCompletedReindexJobs = 16
+
+// ErrInvalidReindexJob is returned when a re-index job cannot be scheduled for
+// the requested contract or range.
+var ErrInvalidReindexJob = errors.New("invalid reindex job")
+
+// ReindexJob status values.
+const (
+	ReindexPending   = "pending"
+	ReindexIndexing  = "indexing"
+	ReindexVerifying = "verifying"
+	ReindexCompleted = "completed"
+)
+
+// ReindexJob is a scheduled re-index and re-verification of [From,To] for a
+// contract. IndexedUntil and VerifiedUntil are the job's own progress cursors,
+// rewound to From-1 when the job is scheduled.
+type ReindexJob struct {
+	ID            uint64     `json:"id"`
+	ChainID       uint64     `json:"chainId"`
+	Contract      string     `json:"contract"`
+	From          uint64     `json:"from"`
+	To            uint64     `json:"to"`
+	Status        string     `json:"status"`
+	IndexedUntil  uint64     `json:"indexedUntil"`
+	VerifiedUntil uint64     `json:"verifiedUntil"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+}
+
+// Done reports whether the job has been completed.
+func (j ReindexJob) Done() bool {
+	return j.Status == ReindexCompleted
+}
+
+// ScheduleReindex stores a new re-index job for [from,to]. The range is clamped
+// to the contract start block and must already be covered by the indexed cursor.
+// Completed jobs beyond the most recent keptCompletedReindexJobs are pruned.
+func (s *Store) ScheduleReindex(ctx context.Context, chainID uint64, contract common.Address, from, to uint64) (ReindexJob, error) {
+	if err := ctx.Err(); err != nil {
+		return ReindexJob{}, err
+	}
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return ReindexJob{}, err
+	}
+	if !ok {
+		return ReindexJob{}, fmt.Errorf("%w: contract not found", ErrInvalidReindexJob)
+	}
+	indexedUntil, indexed, err := s.LastIndexedBlock(ctx, chainID, contract)
+	if err != nil {
+		return ReindexJob{}, err
+	}
+	// The next ID is read from the stored jobs, so concurrent schedules would
+	// otherwise allocate the same ID and overwrite each other.
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	jobs, err := s.ListReindexJobs(ctx, chainID, contract)
+	if err != nil {
+		return ReindexJob{}, err
+	}
+	var lastID uint64
+	if len(jobs) > 0 {
+		lastID = jobs[len(jobs)-1].ID
+	}
+	job, err := newReindexJob(record, indexedUntil, indexed, from, to, lastID)
+	if err != nil {
+		return ReindexJob{}, err
+	}
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	for _, id := range prunedReindexJobs(jobs) {
+		if err := tx.Delete(reindexKey(chainID, contract, id)); err != nil {
+			return ReindexJob{}, fmt.Errorf("prune reindex job: %w", err)
+		}
+	}
+	if err := setReindexJob(tx, job); err != nil {
+		return ReindexJob{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ReindexJob{}, fmt.Errorf("commit reindex job: %w", err)
+	}
+	return job, nil
+}
+
+// ListReindexJobs returns the re-index jobs of a contract ordered by ID. A zero
+// chainID lists the jobs of every contract.
+func (s *Store) ListReindexJobs(ctx context.Context, chainID uint64, contract common.Address) ([]ReindexJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	prefix := []byte(reindexKeyPrefix)
+	if chainID != 0 {
+		prefix = reindexPrefix(chainID, contract)
+	}
+	var (
+		results []ReindexJob
+		iterErr error
+	)
+	err := s.db.Iterate(prefix, func(_, value []byte) bool {
+		if err := ctx.Err(); err != nil {
+			iterErr = err
+			return false
+		}
+		var job ReindexJob
+		if err := json.Unmarshal(value, &job); err != nil {
+			iterErr = fmt.Errorf("decode reindex job: %w", err)
+			return false
+		}
+		results = append(results, job)
+		return true
+	})
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("iterate reindex jobs: %w", err)
+	}
+	return results, nil
+}
+
+// GetReindexJob returns a re-index job by ID.
+func (s *Store) GetReindexJob(ctx context.Context, chainID uint64, contract common.Address, id uint64) (ReindexJob, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ReindexJob{}, false, err
+	}
+	payload, err := s.db.Get(reindexKey(chainID, contract, id))
+	if err != nil {
+		if errors.Is(err, db.ErrKeyNotFound) {
+			return ReindexJob{}, false, nil
+		}
+		return ReindexJob{}, false, fmt.Errorf("get reindex job: %w", err)
+	}
+	var job ReindexJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return ReindexJob{}, false, fmt.Errorf("decode reindex job: %w", err)
+	}
+	return job, true, nil
+}
+
+// newReindexJob validates [from,to] against the contract record and its indexed
+// cursor and returns the pending job that follows lastID.
+func newReindexJob(record ContractRecord, indexedUntil uint64, indexed bool, from, to, lastID uint64) (ReindexJob, error) {
+	if record.Archive != nil {
+		return ReindexJob{}, ErrContractArchived
+	}
+	if from > to {
+		return ReindexJob{}, fmt.Errorf("%w: from %d is greater than to %d", ErrInvalidReindexJob, from, to)
+	}
+	// Block 0 cannot hold contract logs and keeps the job cursors from underflowing.
+	from = max(from, record.StartBlock, 1)
+	if from > to {
+		return ReindexJob{}, fmt.Errorf("%w: range ends before contract start block %d", ErrInvalidReindexJob, record.StartBlock)
+	}
+	if !indexed || to > indexedUntil {
+		return ReindexJob{}, fmt.Errorf("%w: range has not been indexed yet (to %d is beyond the indexed cursor)", ErrInvalidReindexJob, to)
+	}
+	return ReindexJob{
+		ID:            lastID + 1,
+		ChainID:       record.ChainID,
+		Contract:      common.HexToAddress(record.Contract).Hex(),
+		From:          from,
+		To:            to,
+		Status:        ReindexPending,
+		IndexedUntil:  from - 1,
+		VerifiedUntil: from - 1,
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
+
+// prunedReindexJobs returns the IDs of the completed jobs, ordered by ID, that
+// are older than the most recent keptCompletedReindexJobs completed ones.
+func prunedReindexJobs(jobs []ReindexJob) []uint64 {
+	var completed []uint64
+	for _, job := range jobs {
+		if job.Done() {
+			completed = append(completed, job.ID)
+		}
+	}
+	if len(completed) <= keptCompletedReindexJobs {
+		return nil
+	}
+	return completed[:len(completed)-keptCompletedReindexJobs]
+}
+
+func setReindexJob(tx db.WriteTx, job ReindexJob) error {
+	if !common.IsHexAddress(job.Contract) {
+		return fmt.Errorf("reindex job contract is invalid")
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal reindex job: %w", err)
+	}
+	if err := tx.Set(reindexKey(job.ChainID, common.HexToAddress(job.Contract), job.ID), payload); err != nil {
+		return fmt.Errorf("store reindex job: %w", err)
+	}
+	return nil
+}
+
+func reindexPrefix(chainID uint64, contract common.Address) []byte {
+	key := make([]byte, len(reindexKeyPrefix)+8+contractAddressBytes)
+	copy(key, reindexKeyPrefix)
+	offset := len(reindexKeyPrefix)
+	binary.BigEndian.PutUint64(key[offset:], chainID)
+	offset += 8
+	copy(key[offset:], contract.Bytes())
+	return key
+}
+
+func reindexKey(chainID uint64, contract common.Address, id uint64) []byte {
+	return binary.BigEndian.AppendUint64(reindexPrefix(chainID, contract), id)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+)
+
+func TestScheduleReindex(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	if _, err := store.ScheduleReindex(ctx, 1, contract, 10, 20); !errors.Is(err, ErrInvalidReindexJob) {
+		t.Fatalf("expected ErrInvalidReindexJob for an unknown contract, got %v", err)
+	}
+	if err := store.SaveContract(ctx, 1, contract, 10, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := store.SaveEvents(ctx, 1, contract, nil, 100); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if _, err := store.ScheduleReindex(ctx, 1, contract, 50, 101); !errors.Is(err, ErrInvalidReindexJob) {
+		t.Fatalf("expected ErrInvalidReindexJob for a range beyond the indexed cursor, got %v", err)
+	}
+	if _, err := store.ScheduleReindex(ctx, 1, contract, 60, 50); !errors.Is(err, ErrInvalidReindexJob) {
+		t.Fatalf("expected ErrInvalidReindexJob for an inverted range, got %v", err)
+	}
+
+	first, err := store.ScheduleReindex(ctx, 1, contract, 0, 50)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	if first.From != 10 || first.IndexedUntil != 9 || first.VerifiedUntil != 9 || first.Status != ReindexPending {
+		t.Fatalf("expected job clamped to start block 10, got %+v", first)
+	}
+	second, err := store.ScheduleReindex(ctx, 1, contract, 60, 70)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	if second.ID != first.ID+1 {
+		t.Fatalf("expected sequential job IDs, got %d and %d", first.ID, second.ID)
+	}
+	jobs, err := store.ListReindexJobs(ctx, 0, common.Address{})
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != first.ID {
+		t.Fatalf("expected 2 ordered jobs, got %+v", jobs)
+	}
+
+	if err := store.DeleteContractData(ctx, 1, contract); err != nil {
+		t.Fatalf("delete contract data: %v", err)
+	}
+	jobs, err = store.ListReindexJobs(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("expected reindex jobs to be purged with the contract, got %d", len(jobs))
+	}
+}
+
+func TestScheduleReindexAllocatesUniqueIDs(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+	contract := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	if err := store.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := store.SaveEvents(ctx, 1, contract, nil, 100); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.ScheduleReindex(ctx, 1, contract, 10, 20); err != nil {
+				t.Errorf("schedule reindex: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	jobs, err := store.ListReindexJobs(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	if len(jobs) != 8 {
+		t.Fatalf("expected 8 jobs with distinct IDs, got %d", len(jobs))
+	}
+}
+
+func TestScheduleReindexPrunesCompletedJobs(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+	contract := common.HexToAddress("0x1313131313131313131313131313131313131313")
+	if err := store.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := store.SaveEvents(ctx, 1, contract, nil, 100); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	pending, err := store.ScheduleReindex(ctx, 1, contract, 10, 20)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	completedAt := time.Now().UTC()
+	for range 20 {
+		job, err := store.ScheduleReindex(ctx, 1, contract, 10, 20)
+		if err != nil {
+			t.Fatalf("schedule reindex: %v", err)
+		}
+		job.Status = ReindexCompleted
+		job.IndexedUntil, job.VerifiedUntil = job.To, job.To
+		job.CompletedAt = &completedAt
+		if err := store.ReplaceEventsInRange(ctx, 1, contract, 10, 20, nil, ReplaceOptions{ReindexJob: &job}); err != nil {
+			t.Fatalf("complete job: %v", err)
+		}
+	}
+	last, err := store.ScheduleReindex(ctx, 1, contract, 10, 20)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	jobs, err := store.ListReindexJobs(ctx, 1, contract)
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	// The pending jobs and the 16 latest completed ones are kept.
+	if len(jobs) != 18 || jobs[0].ID != pending.ID || jobs[1].ID != 6 || jobs[len(jobs)-1].ID != last.ID {
+		t.Fatalf("expected the pending jobs and the 16 latest completed ones, got %d jobs", len(jobs))
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrContractArchived is returned when a write targets an archived contract.
+var ErrContractArchived = errors.New("contract is archived")
+
+// ContractArchive marks an expired contract as archived. Archived contracts are
+// no longer indexed and their data is read-only until they are restored or
+// deleted once the retention grace period ends. The remaining fields describe
+// the final census snapshot taken when the contract was archived.
+type ContractArchive struct {
+	ArchivedAt    time.Time   `json:"archivedAt"`
+	IndexedUntil  uint64      `json:"indexedUntil"`
+	VerifiedUntil uint64      `json:"verifiedUntil"`
+	Accounts      int         `json:"accounts"`
+	TotalWeight   string      `json:"totalWeight"`
+	Root          common.Hash `json:"root"`
+}
+
+// SnapshotAccount is the final weight of an account in a census snapshot.
+type SnapshotAccount struct {
+	Account string `json:"account"`
+	Weight  string `json:"weight"`
+}
+
+// CensusSnapshot is the census of a contract after its last indexed event: every
+// account holding a non-zero weight, in account order.
+type CensusSnapshot struct {
+	Root        common.Hash       `json:"root"`
+	TotalWeight string            `json:"totalWeight"`
+	Accounts    []SnapshotAccount `json:"accounts"`
+}
+
+// BuildCensusSnapshot replays the events of a contract into its final census.
+// The root is the SHA-256 digest of one "account:weight\n" line per snapshot
+// account, with lowercase hex accounts and decimal weights.
+func BuildCensusSnapshot(ctx context.Context, backend Backend, chainID uint64, contract common.Address) (CensusSnapshot, error) {
+	weights := make(map[common.Address]*big.Int)
+	err := backend.IterateEvents(ctx, chainID, contract, func(event Event) error {
+		if !common.IsHexAddress(event.Account) {
+			return fmt.Errorf("event at block %d has an invalid account", event.BlockNumber)
+		}
+		weight, ok := new(big.Int).SetString(event.NewWeight, 10)
+		if !ok {
+			return fmt.Errorf("event at block %d has an invalid weight %q", event.BlockNumber, event.NewWeight)
+		}
+		weights[common.HexToAddress(event.Account)] = weight
+		return nil
+	})
+	if err != nil {
+		return CensusSnapshot{}, fmt.Errorf("replay census events: %w", err)
+	}
+	accounts := make([]common.Address, 0, len(weights))
+	for account, weight := range weights {
+		if weight.Sign() != 0 {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Cmp(accounts[j]) < 0
+	})
+	snapshot := CensusSnapshot{Accounts: make([]SnapshotAccount, 0, len(accounts))}
+	total := new(big.Int)
+	digest := sha256.New()
+	for _, account := range accounts {
+		weight := weights[account]
+		total.Add(total, weight)
+		snapshot.Accounts = append(snapshot.Accounts, SnapshotAccount{Account: account.Hex(), Weight: weight.String()})
+		fmt.Fprintf(digest, "%s:%s\n", strings.ToLower(account.Hex()), weight.String())
+	}
+	snapshot.TotalWeight = total.String()
+	snapshot.Root = common.BytesToHash(digest.Sum(nil))
+	return snapshot, nil
+}
+
+// ArchiveContract marks a contract as archived with the given snapshot summary.
+// A zero ArchivedAt is set to the current time.
+func (s *Store) ArchiveContract(ctx context.Context, chainID uint64, contract common.Address, archive ContractArchive) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	if record.Archive != nil {
+		return ErrContractArchived
+	}
+	if archive.ArchivedAt.IsZero() {
+		archive.ArchivedAt = time.Now()
+	}
+	archive.ArchivedAt = archive.ArchivedAt.UTC()
+	record.Archive = &archive
+	return s.putContract(record)
+}
+
+// UnarchiveContract makes an archived contract active again with a new
+// expiration time. Indexing resumes from its retained progress cursors.
+func (s *Store) UnarchiveContract(ctx context.Context, chainID uint64, contract common.Address, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt is required")
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	if record.Archive == nil {
+		return fmt.Errorf("contract is not archived")
+	}
+	record.Archive = nil
+	record.ExpiresAt = expiresAt.UTC()
+	return s.putContract(record)
+}
+
+func (s *Store) putContract(record ContractRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal contract: %w", err)
+	}
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	if err := tx.Set(contractKey(record.ChainID, common.HexToAddress(record.Contract)), payload); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit contract: %w", err)
+	}
+	return nil
+}
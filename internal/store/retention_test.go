@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/davinci-node/db"
+	"github.com/vocdoni/davinci-node/db/metadb"
+)
+
+func TestBuildCensusSnapshot(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := New(database)
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000Aa")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000bB")
+	carol := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	events := []Event{
+		{ChainID: 1, Contract: contract.Hex(), Account: bob.Hex(), PreviousWeight: "0", NewWeight: "5", BlockNumber: 10},
+		{ChainID: 1, Contract: contract.Hex(), Account: alice.Hex(), PreviousWeight: "0", NewWeight: "3", BlockNumber: 11},
+		{ChainID: 1, Contract: contract.Hex(), Account: carol.Hex(), PreviousWeight: "0", NewWeight: "9", BlockNumber: 11, LogIndex: 1},
+		{ChainID: 1, Contract: contract.Hex(), Account: bob.Hex(), PreviousWeight: "5", NewWeight: "7", BlockNumber: 12},
+		{ChainID: 1, Contract: contract.Hex(), Account: carol.Hex(), PreviousWeight: "9", NewWeight: "0", BlockNumber: 13},
+	}
+	if err := eventStore.SaveEvents(ctx, 1, contract, events, 13); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	snapshot, err := BuildCensusSnapshot(ctx, eventStore, 1, contract)
+	if err != nil {
+		t.Fatalf("build snapshot: %v", err)
+	}
+	want := []SnapshotAccount{{Account: alice.Hex(), Weight: "3"}, {Account: bob.Hex(), Weight: "7"}}
+	if len(snapshot.Accounts) != len(want) || snapshot.Accounts[0] != want[0] || snapshot.Accounts[1] != want[1] {
+		t.Fatalf("expected accounts %+v, got %+v", want, snapshot.Accounts)
+	}
+	if snapshot.TotalWeight != "10" {
+		t.Fatalf("expected total weight 10, got %s", snapshot.TotalWeight)
+	}
+	lines := strings.ToLower(alice.Hex()) + ":3\n" + strings.ToLower(bob.Hex()) + ":7\n"
+	if root := common.Hash(sha256.Sum256([]byte(lines))); snapshot.Root != root {
+		t.Fatalf("expected root %s, got %s", root, snapshot.Root)
+	}
+
+	empty, err := BuildCensusSnapshot(ctx, eventStore, 1, common.HexToAddress("0x2222222222222222222222222222222222222222"))
+	if err != nil {
+		t.Fatalf("build empty snapshot: %v", err)
+	}
+	if len(empty.Accounts) != 0 || empty.TotalWeight != "0" || empty.Root != common.Hash(sha256.Sum256(nil)) {
+		t.Fatalf("expected an empty snapshot, got %+v", empty)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -15,12 +18,23 @@ import (
 
 const (
 	eventKeyPrefix       = "evt:"
+	metaKeyPrefix        = "meta:"
 	lastBlockKeyPrefix   = "meta:last_block:"
 	verifiedBlockKeyPref = "meta:verified_block:"
 	contractKeyPrefix    = "meta:contract:"
 	contractAddressBytes = 20
 )
 
+// knownMetaKeyPrefixes lists every meta: key family written by the store.
+var knownMetaKeyPrefixes = []string{
+	lastBlockKeyPrefix,
+	verifiedBlockKeyPref,
+	contractKeyPrefix,
+	reindexKeyPrefix,
+	backfillKeyPrefix,
+	schemaVersionKey,
+}
+
 // Event represents a WeightChanged event stored in the database.
 type Event struct {
 	ChainID        uint64 `json:"chainId"`
@@ -30,17 +44,32 @@ type Event struct {
 	NewWeight      string `json:"newWeight"`
 	BlockNumber    uint64 `json:"blockNumber"`
 	LogIndex       uint32 `json:"logIndex"`
+	// Args holds the decoded arguments of events indexed with a custom
+	// EventProfile, formatted as strings.
+	Args map[string]string `json:"args,omitempty"`
 }
 
 // Store provides access to persisted WeightChanged events.
 type Store struct {
 	db db.Database
+	// eventsMu serializes event writers with the background encoding migration.
+	eventsMu sync.Mutex
+	// jobsMu serializes the allocation of re-index job IDs.
+	jobsMu sync.Mutex
+	// contractsMu serializes the read-modify-write updates of contract
+	// records, so concurrent updates do not drop each other's fields.
+	contractsMu sync.Mutex
 }
 
 // ReplaceOptions controls which progress cursors are updated when replacing a range.
 type ReplaceOptions struct {
 	IndexedUntil  *uint64
 	VerifiedUntil *uint64
+	// ReindexJob, when set, is stored in the same transaction as the events.
+	ReindexJob *ReindexJob
+	// BackfillSegment, when set, marks the segment as completed in the same
+	// transaction as the events.
+	BackfillSegment *BackfillSegment
 }
 
 // New returns a new Store backed by the provided database.
@@ -87,6 +116,8 @@ func (s *Store) SaveEvents(ctx context.Context, chainID uint64, contract common.
 	if contract == (common.Address{}) {
 		return fmt.Errorf("contract address is required")
 	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
 	tx := s.db.WriteTx()
 	defer tx.Discard()
 
@@ -102,9 +133,9 @@ func (s *Store) SaveEvents(ctx context.Context, chainID uint64, contract common.
 		}
 		contractAddr := common.HexToAddress(event.Contract)
 		key := eventKey(event.ChainID, contractAddr, event.BlockNumber, event.LogIndex)
-		payload, err := json.Marshal(event)
+		payload, err := encodeEvent(event)
 		if err != nil {
-			return fmt.Errorf("marshal event: %w", err)
+			return err
 		}
 		if err := tx.Set(key, payload); err != nil {
 			return fmt.Errorf("store event: %w", err)
@@ -169,22 +200,15 @@ func (s *Store) ReplaceEventsInRange(
 	if from > to {
 		return fmt.Errorf("from block must be less than or equal to to block")
 	}
-
-	keys, err := s.eventKeysInRange(ctx, chainID, contract, from, to)
-	if err != nil {
-		return err
-	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
 
 	tx := s.db.WriteTx()
 	defer tx.Discard()
 
-	for _, key := range keys {
-		if err := ctx.Err(); err != nil {
-			return err
-		}
-		if err := tx.Delete(key); err != nil {
-			return fmt.Errorf("delete event in range: %w", err)
-		}
+	start, end := eventRangeBounds(chainID, contract, from, to)
+	if err := s.deleteRange(ctx, tx, eventPrefix(chainID, contract), start, end); err != nil {
+		return fmt.Errorf("delete events in range: %w", err)
 	}
 	for _, event := range events {
 		if err := ctx.Err(); err != nil {
@@ -194,9 +218,9 @@ func (s *Store) ReplaceEventsInRange(
 			return err
 		}
 		key := eventKey(event.ChainID, contract, event.BlockNumber, event.LogIndex)
-		payload, err := json.Marshal(event)
+		payload, err := encodeEvent(event)
 		if err != nil {
-			return fmt.Errorf("marshal event: %w", err)
+			return err
 		}
 		if err := tx.Set(key, payload); err != nil {
 			return fmt.Errorf("store event in range: %w", err)
@@ -211,8 +235,185 @@ func (s *Store) ReplaceEventsInRange(
 	return nil
 }
 
+// PutEvents persists the provided events for a contract without touching its
+// progress cursors. Existing events with the same key are overwritten.
+func (s *Store) PutEvents(ctx context.Context, chainID uint64, contract common.Address, events []Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if contract == (common.Address{}) {
+		return fmt.Errorf("contract address is required")
+	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if event.ChainID != chainID {
+			return fmt.Errorf("event chainID mismatch")
+		}
+		if !common.IsHexAddress(event.Contract) || common.HexToAddress(event.Contract) != contract {
+			return fmt.Errorf("event contract mismatch")
+		}
+		payload, err := encodeEvent(event)
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(eventKey(chainID, contract, event.BlockNumber, event.LogIndex), payload); err != nil {
+			return fmt.Errorf("store event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit events: %w", err)
+	}
+	return nil
+}
+
+// IterateEvents calls fn for every stored event of a contract in ascending
+// block and log index order. Iteration stops at the first error returned by fn.
+func (s *Store) IterateEvents(ctx context.Context, chainID uint64, contract common.Address, fn func(Event) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 || contract == (common.Address{}) {
+		return fmt.Errorf("both chainID and contract are required")
+	}
+	var (
+		decoder eventDecoder
+		iterErr error
+	)
+	prefix := eventPrefix(chainID, contract)
+	err := s.db.Iterate(prefix, func(key, value []byte) bool {
+		if err := ctx.Err(); err != nil {
+			iterErr = err
+			return false
+		}
+		event, err := decoder.decode(fullIteratedKey(prefix, key), value)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		if err := fn(event); err != nil {
+			iterErr = err
+			return false
+		}
+		return true
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+	if err != nil {
+		return fmt.Errorf("iterate events: %w", err)
+	}
+	return nil
+}
+
+// IterateEventsInRange calls fn for every event of the contract in the
+// inclusive block range [from, to], in block and log index order.
+func (s *Store) IterateEventsInRange(ctx context.Context, chainID uint64, contract common.Address, from, to uint64, fn func(Event) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if chainID == 0 || contract == (common.Address{}) {
+		return fmt.Errorf("both chainID and contract are required")
+	}
+	if from > to {
+		return nil
+	}
+	var (
+		decoder eventDecoder
+		iterErr error
+	)
+	start, end := eventRangeBounds(chainID, contract, from, to)
+	err := s.iterateRange(eventPrefix(chainID, contract), start, end, func(key, value []byte) bool {
+		if err := ctx.Err(); err != nil {
+			iterErr = err
+			return false
+		}
+		event, err := decoder.decode(key, value)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		if err := fn(event); err != nil {
+			iterErr = err
+			return false
+		}
+		return true
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+	if err != nil {
+		return fmt.Errorf("iterate events: %w", err)
+	}
+	return nil
+}
+
+// GetContract returns the stored configuration of a contract if present.
+func (s *Store) GetContract(ctx context.Context, chainID uint64, contract common.Address) (ContractRecord, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ContractRecord{}, false, err
+	}
+	payload, err := s.db.Get(contractKey(chainID, contract))
+	if err != nil {
+		if errors.Is(err, db.ErrKeyNotFound) {
+			return ContractRecord{}, false, nil
+		}
+		return ContractRecord{}, false, fmt.Errorf("get contract: %w", err)
+	}
+	var record ContractRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return ContractRecord{}, false, fmt.Errorf("decode contract: %w", err)
+	}
+	return record, true, nil
+}
+
+// RestoreContract writes a contract record as-is, together with the provided
+// progress cursors, replacing any previously stored record.
+func (s *Store) RestoreContract(ctx context.Context, record ContractRecord, opts ReplaceOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if record.ChainID == 0 {
+		return fmt.Errorf("chainID is required")
+	}
+	if !common.IsHexAddress(record.Contract) {
+		return fmt.Errorf("contract address is invalid")
+	}
+	contract := common.HexToAddress(record.Contract)
+	record.Contract = contract.Hex()
+	record.ExpiresAt = record.ExpiresAt.UTC()
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal contract: %w", err)
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	if err := tx.Set(contractKey(record.ChainID, contract), payload); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	if err := s.setProgressBlocks(tx, record.ChainID, contract, opts); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit contract: %w", err)
+	}
+	return nil
+}
+
 // SaveContract stores a contract configuration.
 // If the contract already exists, startBlock is preserved and expiresAt is updated.
+// Archived contracts are rejected with ErrContractArchived.
 func (s *Store) SaveContract(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, expiresAt time.Time) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -228,6 +429,8 @@ func (s *Store) SaveContract(ctx context.Context, chainID uint64, contract commo
 	}
 	expiresAt = expiresAt.UTC()
 
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
 	key := contractKey(chainID, contract)
 	payload, err := s.db.Get(key)
 	if err == nil {
@@ -235,6 +438,9 @@ func (s *Store) SaveContract(ctx context.Context, chainID uint64, contract commo
 		if err := json.Unmarshal(payload, &record); err != nil {
 			return fmt.Errorf("decode contract: %w", err)
 		}
+		if record.Archive != nil {
+			return ErrContractArchived
+		}
 		if record.ExpiresAt.Equal(expiresAt) {
 			return nil
 		}
@@ -280,9 +486,9 @@ func (s *Store) SaveContract(ctx context.Context, chainID uint64, contract commo
 	return nil
 }
 
-// SetContractStartBlock updates the start block for an existing contract only
-// when the current stored value is zero.
-func (s *Store) SetContractStartBlock(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64) error {
+// SetContractStartBlock updates the start block for an existing contract, and
+// the method used to find it, only when the current stored value is zero.
+func (s *Store) SetContractStartBlock(ctx context.Context, chainID uint64, contract common.Address, startBlock uint64, method string) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -292,6 +498,8 @@ func (s *Store) SetContractStartBlock(ctx context.Context, chainID uint64, contr
 	if contract == (common.Address{}) {
 		return fmt.Errorf("contract address is required")
 	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
 	key := contractKey(chainID, contract)
 	payload, err := s.db.Get(key)
 	if err != nil {
@@ -308,6 +516,7 @@ func (s *Store) SetContractStartBlock(ctx context.Context, chainID uint64, contr
 		return nil
 	}
 	record.StartBlock = startBlock
+	record.StartBlockMethod = method
 	updated, err := json.Marshal(record)
 	if err != nil {
 		return fmt.Errorf("marshal contract: %w", err)
@@ -323,6 +532,97 @@ func (s *Store) SetContractStartBlock(ctx context.Context, chainID uint64, contr
 	return nil
 }
 
+// SetContractDeploymentTx sets the deployment transaction hash of an existing
+// contract. A zero hash removes it.
+func (s *Store) SetContractDeploymentTx(ctx context.Context, chainID uint64, contract common.Address, txHash common.Hash) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	record.DeploymentTx = ""
+	if txHash != (common.Hash{}) {
+		record.DeploymentTx = txHash.Hex()
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal contract: %w", err)
+	}
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	if err := tx.Set(contractKey(chainID, contract), payload); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit contract: %w", err)
+	}
+	return nil
+}
+
+// SetContractEventProfile sets the event profile of an existing contract. A
+// nil profile restores the default WeightChanged event.
+func (s *Store) SetContractEventProfile(ctx context.Context, chainID uint64, contract common.Address, profile *EventProfile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	record.EventProfile = profile
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal contract: %w", err)
+	}
+	tx := s.db.WriteTx()
+	defer tx.Discard()
+	if err := tx.Set(contractKey(chainID, contract), payload); err != nil {
+		return fmt.Errorf("store contract: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit contract: %w", err)
+	}
+	return nil
+}
+
+// SetContractLabel sets the label of an existing contract. An empty label
+// removes it.
+func (s *Store) SetContractLabel(ctx context.Context, chainID uint64, contract common.Address, label string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	label = strings.TrimSpace(label)
+	if record.Label == label {
+		return nil
+	}
+	record.Label = label
+	return s.putContract(record)
+}
+
 // ListContracts returns all stored contracts.
 func (s *Store) ListContracts(ctx context.Context) ([]ContractRecord, error) {
 	if err := ctx.Err(); err != nil {
@@ -365,36 +665,25 @@ func (s *Store) DeleteContractData(ctx context.Context, chainID uint64, contract
 	if contract == (common.Address{}) {
 		return fmt.Errorf("contract address is required")
 	}
-
-	prefix := eventPrefix(chainID, contract)
-	eventKeys := make([][]byte, 0)
-	var iterErr error
-	err := s.db.Iterate(prefix, func(key, _ []byte) bool {
-		if err := ctx.Err(); err != nil {
-			iterErr = err
-			return false
-		}
-		keyCopy := fullIteratedKey(prefix, key)
-		eventKeys = append(eventKeys, keyCopy)
-		return true
-	})
-	if iterErr != nil {
-		return iterErr
-	}
-	if err != nil {
-		return fmt.Errorf("iterate contract events: %w", err)
-	}
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
 
 	tx := s.db.WriteTx()
 	defer tx.Discard()
 
-	for _, key := range eventKeys {
-		if err := ctx.Err(); err != nil {
-			return err
-		}
-		if err := tx.Delete(key); err != nil {
-			return fmt.Errorf("delete event: %w", err)
-		}
+	prefix := eventPrefix(chainID, contract)
+	if err := s.deleteRange(ctx, tx, prefix, prefix, prefixUpperBound(prefix)); err != nil {
+		return fmt.Errorf("delete contract events: %w", err)
+	}
+	jobPrefix := reindexPrefix(chainID, contract)
+	if err := s.deleteRange(ctx, tx, jobPrefix, jobPrefix, prefixUpperBound(jobPrefix)); err != nil {
+		return fmt.Errorf("delete reindex jobs: %w", err)
+	}
+	segmentPrefix := backfillPrefix(chainID, contract)
+	if err := s.deleteRange(ctx, tx, segmentPrefix, segmentPrefix, prefixUpperBound(segmentPrefix)); err != nil {
+		return fmt.Errorf("delete backfill segments: %w", err)
 	}
 	if err := tx.Delete(lastBlockKey(chainID, contract)); err != nil {
 		return fmt.Errorf("delete last indexed block: %w", err)
@@ -422,6 +711,22 @@ func (s *Store) Compact(ctx context.Context) error {
 	return nil
 }
 
+// Checkpoint writes a consistent copy of the backing database into dir while
+// writes continue. It returns ErrUnsupported if the database cannot checkpoint.
+func (s *Store) Checkpoint(ctx context.Context, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	checkpointer, ok := s.db.(interface{ Checkpoint(dir string) error })
+	if !ok {
+		return ErrUnsupported
+	}
+	if err := checkpointer.Checkpoint(dir); err != nil {
+		return fmt.Errorf("checkpoint store: %w", err)
+	}
+	return nil
+}
+
 // ListOptions defines pagination and ordering options when listing events.
 type ListOptions struct {
 	First          int
@@ -437,46 +742,52 @@ func (s *Store) ListEvents(ctx context.Context, opts ListOptions) ([]Event, erro
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	if opts.First < 0 || opts.Skip < 0 {
-		return nil, fmt.Errorf("first and skip must be non-negative")
-	}
-	orderBy := opts.OrderBy
-	if orderBy == "" {
-		orderBy = "blockNumber"
-	}
-	if orderBy != "blockNumber" {
-		return nil, fmt.Errorf("unsupported orderBy: %s", orderBy)
-	}
-	orderDirection := opts.OrderDirection
-	if orderDirection == "" {
-		orderDirection = "asc"
+	opts, err := validateListOptions(opts)
+	if err != nil {
+		return nil, err
 	}
-
 	prefix := []byte(eventKeyPrefix)
-	if opts.ChainID != 0 || opts.Contract != (common.Address{}) {
-		if opts.ChainID == 0 || opts.Contract == (common.Address{}) {
-			return nil, fmt.Errorf("both chainID and contract are required for filtering")
-		}
+	if opts.ChainID != 0 {
 		prefix = eventPrefix(opts.ChainID, opts.Contract)
 	}
-
-	if orderDirection == "desc" {
+	if opts.OrderDirection == "desc" {
 		return s.listEventsDesc(ctx, opts, prefix)
 	}
-	if orderDirection != "asc" {
-		return nil, fmt.Errorf("unsupported orderDirection: %s", orderDirection)
-	}
-
 	return s.listEventsAsc(ctx, opts, prefix)
 }
 
+// validateListOptions fills in the default ordering of opts and rejects
+// unsupported values.
+func validateListOptions(opts ListOptions) (ListOptions, error) {
+	if opts.First < 0 || opts.Skip < 0 {
+		return ListOptions{}, fmt.Errorf("first and skip must be non-negative")
+	}
+	if opts.OrderBy == "" {
+		opts.OrderBy = "blockNumber"
+	}
+	if opts.OrderBy != "blockNumber" {
+		return ListOptions{}, fmt.Errorf("unsupported orderBy: %s", opts.OrderBy)
+	}
+	if opts.OrderDirection == "" {
+		opts.OrderDirection = "asc"
+	}
+	if opts.OrderDirection != "asc" && opts.OrderDirection != "desc" {
+		return ListOptions{}, fmt.Errorf("unsupported orderDirection: %s", opts.OrderDirection)
+	}
+	if (opts.ChainID != 0) != (opts.Contract != (common.Address{})) {
+		return ListOptions{}, fmt.Errorf("both chainID and contract are required for filtering")
+	}
+	return opts, nil
+}
+
 func (s *Store) listEventsAsc(ctx context.Context, opts ListOptions, prefix []byte) ([]Event, error) {
 	var (
+		decoder eventDecoder
 		results []Event
 		skipped int
 		iterErr error
 	)
-	err := s.db.Iterate(prefix, func(_, value []byte) bool {
+	err := s.db.Iterate(prefix, func(key, value []byte) bool {
 		if err := ctx.Err(); err != nil {
 			iterErr = err
 			return false
@@ -488,9 +799,9 @@ func (s *Store) listEventsAsc(ctx context.Context, opts ListOptions, prefix []by
 		if opts.First > 0 && len(results) >= opts.First {
 			return false
 		}
-		var event Event
-		if err := json.Unmarshal(value, &event); err != nil {
-			iterErr = fmt.Errorf("decode event: %w", err)
+		event, err := decoder.decode(fullIteratedKey(prefix, key), value)
+		if err != nil {
+			iterErr = err
 			return false
 		}
 		results = append(results, event)
@@ -505,23 +816,37 @@ func (s *Store) listEventsAsc(ctx context.Context, opts ListOptions, prefix []by
 	return results, nil
 }
 
+// listEventsDesc returns events from the newest one. Databases that iterate
+// backwards stop after the requested page; the others scan the whole prefix.
 func (s *Store) listEventsDesc(ctx context.Context, opts ListOptions, prefix []byte) ([]Event, error) {
+	reverser, ok := s.db.(interface {
+		IterateRangeReverse(start, end []byte, callback func(key, value []byte) bool) error
+	})
+	if !ok {
+		return s.scanEventsDesc(ctx, opts, prefix)
+	}
 	var (
-		all     []Event
+		decoder eventDecoder
+		results []Event
+		skipped int
 		iterErr error
 	)
-	err := s.db.Iterate(prefix, func(_, value []byte) bool {
+	err := reverser.IterateRangeReverse(prefix, prefixUpperBound(prefix), func(key, value []byte) bool {
 		if err := ctx.Err(); err != nil {
 			iterErr = err
 			return false
 		}
-		var event Event
-		if err := json.Unmarshal(value, &event); err != nil {
-			iterErr = fmt.Errorf("decode event: %w", err)
+		if skipped < opts.Skip {
+			skipped++
+			return true
+		}
+		event, err := decoder.decode(key, value)
+		if err != nil {
+			iterErr = err
 			return false
 		}
-		all = append(all, event)
-		return true
+		results = append(results, event)
+		return opts.First == 0 || len(results) < opts.First
 	})
 	if iterErr != nil {
 		return nil, iterErr
@@ -529,18 +854,61 @@ func (s *Store) listEventsDesc(ctx context.Context, opts ListOptions, prefix []b
 	if err != nil {
 		return nil, fmt.Errorf("iterate events: %w", err)
 	}
-	for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
-		all[i], all[j] = all[j], all[i]
+	return results, nil
+}
+
+// scanEventsDesc lists events in descending order on databases without
+// reverse iteration. With opts.First set, only the raw entries of the last
+// Skip+First events are kept during the scan, and only the page is decoded.
+func (s *Store) scanEventsDesc(ctx context.Context, opts ListOptions, prefix []byte) ([]Event, error) {
+	type rawEvent struct {
+		key, value []byte
+	}
+	window := 0
+	if opts.First > 0 {
+		window = opts.Skip + opts.First
+	}
+	var (
+		kept    []rawEvent
+		oldest  int
+		iterErr error
+	)
+	err := s.db.Iterate(prefix, func(key, value []byte) bool {
+		if err := ctx.Err(); err != nil {
+			iterErr = err
+			return false
+		}
+		entry := rawEvent{key: fullIteratedKey(prefix, key), value: bytes.Clone(value)}
+		if window == 0 || len(kept) < window {
+			kept = append(kept, entry)
+			return true
+		}
+		kept[oldest] = entry
+		oldest = (oldest + 1) % window
+		return true
+	})
+	if iterErr != nil {
+		return nil, iterErr
 	}
-	start := opts.Skip
-	if start > len(all) {
+	if err != nil {
+		return nil, fmt.Errorf("iterate events: %w", err)
+	}
+	if opts.Skip >= len(kept) {
 		return []Event{}, nil
 	}
-	end := len(all)
-	if opts.First > 0 && start+opts.First < end {
-		end = start + opts.First
+	var decoder eventDecoder
+	results := make([]Event, 0, len(kept)-opts.Skip)
+	for i := opts.Skip; i < len(kept); i++ {
+		// Entries are kept oldest first from oldest, so the i-th newest one
+		// sits i positions before it.
+		entry := kept[(oldest-1-i+2*len(kept))%len(kept)]
+		event, err := decoder.decode(entry.key, entry.value)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, event)
 	}
-	return all[start:end], nil
+	return results, nil
 }
 
 func eventKey(chainID uint64, contract common.Address, blockNumber uint64, logIndex uint32) []byte {
@@ -616,6 +984,26 @@ type ContractRecord struct {
 	Contract   string    `json:"contract"`
 	StartBlock uint64    `json:"startBlock"`
 	ExpiresAt  time.Time `json:"expiresAt"`
+	// Label is an optional human readable name for the contract.
+	Label string `json:"label,omitempty"`
+	// Policy optionally adjusts ExpiresAt while the contract is indexed.
+	Policy *ExpiryPolicy `json:"policy,omitempty"`
+	// Archive is set once the contract has expired and been archived.
+	Archive *ContractArchive `json:"archive,omitempty"`
+	// DeploymentTx optionally holds the hash of the transaction that deployed
+	// the contract, used to find its start block.
+	DeploymentTx string `json:"deploymentTx,omitempty"`
+	// StartBlockMethod records how a detected start block was found; it is
+	// empty for configured start blocks.
+	StartBlockMethod string `json:"startBlockMethod,omitempty"`
+	// EventProfile optionally replaces the default WeightChanged event.
+	EventProfile *EventProfile `json:"eventProfile,omitempty"`
+	// Upgrades is the implementation history of a contract deployed behind an
+	// EIP-1967 proxy; it is empty for other contracts.
+	Upgrades []ProxyUpgrade `json:"upgrades,omitempty"`
+	// UpgradePending pauses indexing after an upgrade until an operator
+	// acknowledges it.
+	UpgradePending bool `json:"upgradePending,omitempty"`
 }
 
 func contractKey(chainID uint64, contract common.Address) []byte {
@@ -628,34 +1016,63 @@ func contractKey(chainID uint64, contract common.Address) []byte {
 	return key
 }
 
-func (s *Store) eventKeysInRange(ctx context.Context, chainID uint64, contract common.Address, from, to uint64) ([][]byte, error) {
-	prefix := eventPrefix(chainID, contract)
+// eventRangeBounds returns the [start, end) key range holding the contract's
+// events for the inclusive block range [from, to].
+func eventRangeBounds(chainID uint64, contract common.Address, from, to uint64) ([]byte, []byte) {
+	start := eventKey(chainID, contract, from, 0)
+	if to == math.MaxUint64 {
+		return start, prefixUpperBound(eventPrefix(chainID, contract))
+	}
+	return start, eventKey(chainID, contract, to+1, 0)
+}
+
+// iterateRange calls fn with the full key of every entry in [start, end), all
+// of which share prefix. The key is only valid during the call. Databases
+// without bounded iteration fall back to filtering a scan of prefix.
+func (s *Store) iterateRange(prefix, start, end []byte, fn func(key, value []byte) bool) error {
+	if ranger, ok := s.db.(interface {
+		IterateRange(start, end []byte, callback func(key, value []byte) bool) error
+	}); ok {
+		return ranger.IterateRange(start, end, fn)
+	}
+	return s.db.Iterate(prefix, func(key, value []byte) bool {
+		fullKey := fullIteratedKey(prefix, key)
+		if bytes.Compare(fullKey, start) < 0 || (end != nil && bytes.Compare(fullKey, end) >= 0) {
+			return true
+		}
+		return fn(fullKey, value)
+	})
+}
+
+// deleteRange removes every key in [start, end) as part of tx. Pebble drops
+// the whole range with a single tombstone; other databases get one delete per
+// key, collected with a bounded scan.
+func (s *Store) deleteRange(ctx context.Context, tx db.WriteTx, prefix, start, end []byte) error {
+	if deleter, ok := tx.(interface{ DeleteRange(start, end []byte) error }); ok {
+		return deleter.DeleteRange(start, end)
+	}
 	keys := make([][]byte, 0)
 	var iterErr error
-	err := s.db.Iterate(prefix, func(key, _ []byte) bool {
+	err := s.iterateRange(prefix, start, end, func(key, _ []byte) bool {
 		if err := ctx.Err(); err != nil {
 			iterErr = err
 			return false
 		}
-		fullKey := fullIteratedKey(prefix, key)
-		blockNumber, err := eventBlockNumber(fullKey)
-		if err != nil {
-			iterErr = err
-			return false
-		}
-		if blockNumber < from || blockNumber > to {
-			return true
-		}
-		keys = append(keys, fullKey)
+		keys = append(keys, bytes.Clone(key))
 		return true
 	})
 	if iterErr != nil {
-		return nil, iterErr
+		return iterErr
 	}
 	if err != nil {
-		return nil, fmt.Errorf("iterate contract events in range: %w", err)
+		return fmt.Errorf("iterate key range: %w", err)
 	}
-	return keys, nil
+	for _, key := range keys {
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func eventBlockNumber(key []byte) (uint64, error) {
@@ -694,5 +1111,15 @@ func (s *Store) setProgressBlocks(tx db.WriteTx, chainID uint64, contract common
 			return fmt.Errorf("store last verified block: %w", err)
 		}
 	}
+	if opts.ReindexJob != nil {
+		if err := setReindexJob(tx, *opts.ReindexJob); err != nil {
+			return err
+		}
+	}
+	if opts.BackfillSegment != nil {
+		if err := setBackfillSegment(tx, chainID, contract, *opts.BackfillSegment); err != nil {
+			return err
+		}
+	}
 	return nil
 }
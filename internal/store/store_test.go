@@ -2,6 +2,11 @@ package store
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -125,10 +130,10 @@ func TestSetContractStartBlock(t *testing.T) {
 		t.Fatalf("save contract with fixed start block: %v", err)
 	}
 
-	if err := eventStore.SetContractStartBlock(ctx, 10, contractZero, 12345); err != nil {
+	if err := eventStore.SetContractStartBlock(ctx, 10, contractZero, 12345, "logs"); err != nil {
 		t.Fatalf("set start block: %v", err)
 	}
-	if err := eventStore.SetContractStartBlock(ctx, 11, contractFixed, 99999); err != nil {
+	if err := eventStore.SetContractStartBlock(ctx, 11, contractFixed, 99999, "logs"); err != nil {
 		t.Fatalf("set start block for fixed contract: %v", err)
 	}
 
@@ -144,6 +149,9 @@ func TestSetContractStartBlock(t *testing.T) {
 	if got := byChain[10].StartBlock; got != 12345 {
 		t.Fatalf("expected updated start block 12345 for chain 10, got %d", got)
 	}
+	if got := byChain[10].StartBlockMethod; got != "logs" {
+		t.Fatalf("expected start block method logs for chain 10, got %q", got)
+	}
 	if got := byChain[11].StartBlock; got != 42 {
 		t.Fatalf("expected unchanged start block 42 for chain 11, got %d", got)
 	}
@@ -388,3 +396,232 @@ func TestReplaceEventsInRange(t *testing.T) {
 		t.Fatalf("expected verified block 11, got %d (ok=%t)", gotVerified, ok)
 	}
 }
+
+func TestEventEncodingRoundTrip(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	event := Event{
+		ChainID:        10,
+		Contract:       contract.Hex(),
+		Account:        common.HexToAddress("0x00000000000000000000000000000000000000aB").Hex(),
+		PreviousWeight: "0",
+		NewWeight:      "309485009821345068724781055", // max uint88
+		BlockNumber:    123456,
+		LogIndex:       7,
+	}
+	key := eventKey(event.ChainID, contract, event.BlockNumber, event.LogIndex)
+	value, err := encodeEvent(event)
+	if err != nil {
+		t.Fatalf("encode event: %v", err)
+	}
+	if len(value) != eventValueV1Len || value[0] != eventEncodingV1 {
+		t.Fatalf("expected %d-byte binary value, got %d bytes", eventValueV1Len, len(value))
+	}
+	decoded, err := decodeEvent(key, value)
+	if err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Fatalf("expected %+v, got %+v", event, decoded)
+	}
+
+	legacy := event
+	legacy.Account = "0xabc"
+	value, err = encodeEvent(legacy)
+	if err != nil {
+		t.Fatalf("encode legacy event: %v", err)
+	}
+	if !isLegacyEventValue(value) {
+		t.Fatalf("expected non-address account to keep the JSON encoding")
+	}
+	if decoded, err = decodeEvent(key, value); err != nil || !reflect.DeepEqual(decoded, legacy) {
+		t.Fatalf("expected legacy event to decode (err=%v), got %+v", err, decoded)
+	}
+
+	withArgs := event
+	withArgs.Args = map[string]string{"value": event.NewWeight}
+	if value, err = encodeEvent(withArgs); err != nil || !isLegacyEventValue(value) {
+		t.Fatalf("expected an event with decoded arguments to keep the JSON encoding (err=%v)", err)
+	}
+	if decoded, err = decodeEvent(key, value); err != nil || !reflect.DeepEqual(decoded, withArgs) {
+		t.Fatalf("expected event with arguments to decode (err=%v), got %+v", err, decoded)
+	}
+
+	overflow := event
+	overflow.NewWeight = "309485009821345068724781056"
+	if value, err = encodeEvent(overflow); err != nil || !isLegacyEventValue(value) {
+		t.Fatalf("expected weights above uint88 to keep the JSON encoding (err=%v)", err)
+	}
+	if _, err := decodeEvent(key, []byte{0x7f}); err == nil {
+		t.Fatalf("expected unknown encoding version to fail")
+	}
+}
+
+func TestMigrateEventEncoding(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	store := New(database)
+
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if err := store.SaveContract(ctx, 1, contract, 1, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	events := benchmarkEvents(contract, 5, 1<<migrationBlockGroupBits)
+	events = append(events, Event{ChainID: 1, Contract: contract.Hex(), Account: "0xabc", PreviousWeight: "1", NewWeight: "2", BlockNumber: 2, LogIndex: 9})
+	if err := store.SaveEvents(ctx, 1, contract, nil, events[4].BlockNumber); err != nil {
+		t.Fatalf("save cursor: %v", err)
+	}
+	tx := database.WriteTx()
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("marshal event: %v", err)
+		}
+		if err := tx.Set(eventKey(1, contract, event.BlockNumber, event.LogIndex), payload); err != nil {
+			t.Fatalf("store legacy event: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit legacy events: %v", err)
+	}
+
+	migrated, err := store.MigrateEventEncoding(ctx)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if migrated != 5 {
+		t.Fatalf("expected 5 migrated events, got %d", migrated)
+	}
+	if migrated, err = store.MigrateEventEncoding(ctx); err != nil || migrated != 0 {
+		t.Fatalf("expected second run to be a no-op, got %d (err=%v)", migrated, err)
+	}
+	value, err := database.Get(eventKey(1, contract, events[4].BlockNumber, events[4].LogIndex))
+	if err != nil {
+		t.Fatalf("get event: %v", err)
+	}
+	if isLegacyEventValue(value) {
+		t.Fatalf("expected event in a later block group to be migrated")
+	}
+	listed, err := store.ListEvents(ctx, ListOptions{ChainID: 1, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(listed) != len(events) {
+		t.Fatalf("expected %d events, got %d", len(events), len(listed))
+	}
+}
+
+const benchmarkEventCount = 1_000_000
+
+func benchmarkEvents(contract common.Address, count int, blockStep uint64) []Event {
+	events := make([]Event, count)
+	for i := range events {
+		var account common.Address
+		binary.BigEndian.PutUint64(account[12:], uint64(i)+1)
+		events[i] = Event{
+			ChainID:        1,
+			Contract:       contract.Hex(),
+			Account:        account.Hex(),
+			PreviousWeight: strconv.Itoa(i),
+			NewWeight:      strconv.Itoa(i + 1),
+			BlockNumber:    1 + uint64(i)*blockStep,
+			LogIndex:       uint32(i % 4),
+		}
+	}
+	return events
+}
+
+// BenchmarkEventEncoding compares storing and decoding a million-event
+// contract with the legacy JSON values and the binary encoding. The
+// bytes/event metric reports the stored value size.
+func BenchmarkEventEncoding(b *testing.B) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	events := benchmarkEvents(contract, benchmarkEventCount, 1)
+	keys := make([][]byte, len(events))
+	for i, event := range events {
+		keys[i] = eventKey(event.ChainID, contract, event.BlockNumber, event.LogIndex)
+	}
+	encoders := []struct {
+		name   string
+		encode func(Event) ([]byte, error)
+	}{
+		{name: "json", encode: func(event Event) ([]byte, error) { return json.Marshal(event) }},
+		{name: "binary", encode: encodeEvent},
+	}
+	for _, encoder := range encoders {
+		values := make([][]byte, len(events))
+		size := 0
+		for i, event := range events {
+			value, err := encoder.encode(event)
+			if err != nil {
+				b.Fatalf("encode event: %v", err)
+			}
+			values[i] = value
+			size += len(value)
+		}
+		b.Run(encoder.name, func(b *testing.B) {
+			for range b.N {
+				var decoder eventDecoder
+				for i, value := range values {
+					if _, err := decoder.decode(keys[i], value); err != nil {
+						b.Fatalf("decode event: %v", err)
+					}
+				}
+			}
+			b.ReportMetric(float64(size)/float64(len(values)), "bytes/event")
+			b.ReportMetric(float64(size)/(1<<20), "MiB/contract")
+		})
+	}
+}
+
+func TestConcurrentContractUpdates(t *testing.T) {
+	ctx := context.Background()
+	database, err := metadb.New(db.TypeInMem, "")
+	if err != nil {
+		t.Fatalf("create in-memory db: %v", err)
+	}
+	defer func() {
+		if cerr := database.Close(); cerr != nil {
+			t.Fatalf("close db: %v", cerr)
+		}
+	}()
+	eventStore := New(database)
+
+	contract := common.HexToAddress("0x5656565656565656565656565656565656565656")
+	if err := eventStore.SaveContract(ctx, 1, contract, 0, futureTime(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := eventStore.ArchiveContract(ctx, 1, contract, ContractArchive{IndexedUntil: 10}); err != nil {
+			t.Errorf("archive contract: %v", err)
+		}
+	}()
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := eventStore.SetContractStartBlock(ctx, 1, contract, uint64(i+1), "logs"); err != nil {
+				t.Errorf("set start block: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	record, ok, err := eventStore.GetContract(ctx, 1, contract)
+	if err != nil || !ok {
+		t.Fatalf("get contract: %v %v", ok, err)
+	}
+	if record.Archive == nil || record.StartBlock == 0 {
+		t.Fatalf("expected the archive and the start block to be kept, got %+v", record)
+	}
+}
@@ -0,0 +1,860 @@
+// Package storetest is the contract test suite shared by every store.Backend
+// implementation.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/vocdoni/onchain-census-indexer/internal/store"
+)
+
+var (
+	contractA = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	contractB = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	accountA  = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	accountB  = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+)
+
+// Run runs the contract suite. open must return an empty, migrated backend
+// for every subtest and release it when the subtest ends.
+func Run(t *testing.T, open func(t *testing.T) store.Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, backend store.Backend)
+	}{
+		{"Cursors", testCursors},
+		{"ReplaceEventsInRange", testReplaceEventsInRange},
+		{"ReplaceEventsInRangeIsAtomic", testReplaceEventsInRangeIsAtomic},
+		{"ListEvents", testListEvents},
+		{"IterateEvents", testIterateEvents},
+		{"EventArgs", testEventArgs},
+		{"Contracts", testContracts},
+		{"DeleteContractData", testDeleteContractData},
+		{"ArchiveContract", testArchiveContract},
+		{"ContractExpiry", testContractExpiry},
+		{"ContractUpgrades", testContractUpgrades},
+		{"ReindexJobs", testReindexJobs},
+		{"BackfillSegments", testBackfillSegments},
+		{"Migrate", testMigrate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func event(chainID uint64, contract common.Address, block uint64, logIndex uint32, weight string) store.Event {
+	return store.Event{
+		ChainID:        chainID,
+		Contract:       contract.Hex(),
+		Account:        accountA.Hex(),
+		PreviousWeight: "0",
+		NewWeight:      weight,
+		BlockNumber:    block,
+		LogIndex:       logIndex,
+	}
+}
+
+func blocks(events []store.Event) []uint64 {
+	out := make([]uint64, 0, len(events))
+	for _, event := range events {
+		out = append(out, event.BlockNumber)
+	}
+	return out
+}
+
+func listAll(t *testing.T, backend store.Backend, chainID uint64, contract common.Address) []store.Event {
+	t.Helper()
+	events, err := backend.ListEvents(context.Background(), store.ListOptions{ChainID: chainID, Contract: contract})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	return events
+}
+
+func testCursors(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	if _, ok, err := backend.LastIndexedBlock(ctx, 1, contractA); err != nil || ok {
+		t.Fatalf("expected no indexed cursor, got ok=%t err=%v", ok, err)
+	}
+	if _, ok, err := backend.LastVerifiedBlock(ctx, 1, contractA); err != nil || ok {
+		t.Fatalf("expected no verified cursor, got ok=%t err=%v", ok, err)
+	}
+	if err := backend.SaveEvents(ctx, 1, contractA, []store.Event{event(1, contractA, 5, 0, "1")}, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	indexed, ok, err := backend.LastIndexedBlock(ctx, 1, contractA)
+	if err != nil || !ok || indexed != 10 {
+		t.Fatalf("expected indexed cursor 10, got %d (ok=%t, err=%v)", indexed, ok, err)
+	}
+	verified, ok, err := backend.LastVerifiedBlock(ctx, 1, contractA)
+	if err != nil || !ok || verified != 10 {
+		t.Fatalf("expected verified cursor 10, got %d (ok=%t, err=%v)", verified, ok, err)
+	}
+
+	// Cursors are updated independently.
+	indexedUntil := uint64(20)
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 11, 20, nil, store.ReplaceOptions{IndexedUntil: &indexedUntil}); err != nil {
+		t.Fatalf("replace range: %v", err)
+	}
+	indexed, _, _ = backend.LastIndexedBlock(ctx, 1, contractA)
+	verified, _, _ = backend.LastVerifiedBlock(ctx, 1, contractA)
+	if indexed != 20 || verified != 10 {
+		t.Fatalf("expected cursors 20/10, got %d/%d", indexed, verified)
+	}
+	if _, ok, _ := backend.LastIndexedBlock(ctx, 2, contractA); ok {
+		t.Fatalf("expected cursors to be scoped to the chain")
+	}
+}
+
+func testReplaceEventsInRange(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	initial := []store.Event{
+		event(1, contractA, 1, 0, "1"),
+		event(1, contractA, 2, 0, "2"),
+		event(1, contractA, 2, 1, "3"),
+		event(1, contractA, 3, 0, "4"),
+		event(1, contractA, 4, 0, "5"),
+	}
+	if err := backend.SaveEvents(ctx, 1, contractA, initial, 4); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := backend.SaveEvents(ctx, 1, contractB, []store.Event{event(1, contractB, 2, 0, "9")}, 4); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	replacement := event(1, contractA, 3, 7, "70")
+	replacement.Account = accountB.Hex()
+	replacement.PreviousWeight = "340282366920938463463374607431768211455"
+	verifiedUntil := uint64(3)
+	job := store.ReindexJob{
+		ID: 1, ChainID: 1, Contract: contractA.Hex(), From: 2, To: 3,
+		Status: store.ReindexVerifying, IndexedUntil: 3, VerifiedUntil: 3,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 2, 3, []store.Event{replacement}, store.ReplaceOptions{
+		VerifiedUntil: &verifiedUntil,
+		ReindexJob:    &job,
+	}); err != nil {
+		t.Fatalf("replace range: %v", err)
+	}
+
+	events := listAll(t, backend, 1, contractA)
+	if got := blocks(events); !reflect.DeepEqual(got, []uint64{1, 3, 4}) {
+		t.Fatalf("expected blocks [1 3 4], got %v", got)
+	}
+	if !reflect.DeepEqual(events[1], replacement) {
+		t.Fatalf("expected %+v, got %+v", replacement, events[1])
+	}
+	if other := listAll(t, backend, 1, contractB); len(other) != 1 {
+		t.Fatalf("expected other contract to keep its event, got %d", len(other))
+	}
+	if verified, _, _ := backend.LastVerifiedBlock(ctx, 1, contractA); verified != 3 {
+		t.Fatalf("expected verified cursor 3, got %d", verified)
+	}
+	if indexed, _, _ := backend.LastIndexedBlock(ctx, 1, contractA); indexed != 4 {
+		t.Fatalf("expected indexed cursor to stay at 4, got %d", indexed)
+	}
+	stored, ok, err := backend.GetReindexJob(ctx, 1, contractA, 1)
+	if err != nil || !ok {
+		t.Fatalf("expected reindex job stored with the range (ok=%t, err=%v)", ok, err)
+	}
+	if !reflect.DeepEqual(stored, job) {
+		t.Fatalf("expected job %+v, got %+v", job, stored)
+	}
+
+	// Clearing a range without events removes everything inside it.
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 1, 4, nil, store.ReplaceOptions{}); err != nil {
+		t.Fatalf("clear range: %v", err)
+	}
+	if events := listAll(t, backend, 1, contractA); len(events) != 0 {
+		t.Fatalf("expected empty contract, got %v", blocks(events))
+	}
+}
+
+func testReplaceEventsInRangeIsAtomic(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	if err := backend.SaveEvents(ctx, 1, contractA, []store.Event{event(1, contractA, 5, 0, "1")}, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	indexedUntil := uint64(20)
+	invalid := []store.Event{
+		event(1, contractA, 6, 0, "2"),
+		event(1, contractA, 30, 0, "3"),
+	}
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 1, 20, invalid, store.ReplaceOptions{IndexedUntil: &indexedUntil}); err == nil {
+		t.Fatalf("expected error for an event outside the range")
+	}
+	for name, events := range map[string][]store.Event{
+		"chain mismatch":    {event(2, contractA, 6, 0, "2")},
+		"contract mismatch": {event(1, contractB, 6, 0, "2")},
+	} {
+		if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 1, 20, events, store.ReplaceOptions{IndexedUntil: &indexedUntil}); err == nil {
+			t.Fatalf("expected error for %s", name)
+		}
+	}
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 5, 4, nil, store.ReplaceOptions{}); err == nil {
+		t.Fatalf("expected error for an inverted range")
+	}
+	if got := blocks(listAll(t, backend, 1, contractA)); !reflect.DeepEqual(got, []uint64{5}) {
+		t.Fatalf("expected failed replacements to leave block 5 untouched, got %v", got)
+	}
+	if indexed, _, _ := backend.LastIndexedBlock(ctx, 1, contractA); indexed != 10 {
+		t.Fatalf("expected indexed cursor to stay at 10, got %d", indexed)
+	}
+}
+
+func testListEvents(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	if err := backend.SaveEvents(ctx, 1, contractA, []store.Event{
+		event(1, contractA, 3, 0, "1"),
+		event(1, contractA, 1, 0, "2"),
+		event(1, contractA, 2, 1, "3"),
+		event(1, contractA, 2, 0, "4"),
+	}, 3); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := backend.SaveEvents(ctx, 2, contractB, []store.Event{event(2, contractB, 1, 0, "5")}, 1); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if err := backend.SaveEvents(ctx, 1, contractB, []store.Event{event(1, contractB, 9, 0, "6")}, 9); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       store.ListOptions
+		wantBlocks []uint64
+		wantLogs   []uint32
+	}{
+		{
+			name:       "contract asc",
+			opts:       store.ListOptions{ChainID: 1, Contract: contractA},
+			wantBlocks: []uint64{1, 2, 2, 3},
+			wantLogs:   []uint32{0, 0, 1, 0},
+		},
+		{
+			name:       "contract desc paged",
+			opts:       store.ListOptions{First: 2, Skip: 1, OrderDirection: "desc", ChainID: 1, Contract: contractA},
+			wantBlocks: []uint64{2, 2},
+			wantLogs:   []uint32{1, 0},
+		},
+		{
+			name:       "all contracts in key order",
+			opts:       store.ListOptions{},
+			wantBlocks: []uint64{1, 2, 2, 3, 9, 1},
+		},
+		{
+			name:       "all contracts desc",
+			opts:       store.ListOptions{First: 2, OrderBy: "blockNumber", OrderDirection: "desc"},
+			wantBlocks: []uint64{1, 9},
+		},
+		{
+			name:       "skip past end",
+			opts:       store.ListOptions{Skip: 10, ChainID: 1, Contract: contractA},
+			wantBlocks: []uint64{},
+		},
+	}
+	for _, tt := range tests {
+		events, err := backend.ListEvents(ctx, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: list events: %v", tt.name, err)
+		}
+		if got := blocks(events); !reflect.DeepEqual(got, tt.wantBlocks) {
+			t.Fatalf("%s: expected blocks %v, got %v", tt.name, tt.wantBlocks, got)
+		}
+		if tt.wantLogs == nil {
+			continue
+		}
+		for i, event := range events {
+			if event.LogIndex != tt.wantLogs[i] {
+				t.Fatalf("%s: expected log indexes %v, got %+v", tt.name, tt.wantLogs, events)
+			}
+		}
+	}
+
+	for name, opts := range map[string]store.ListOptions{
+		"negative first":    {First: -1},
+		"unknown orderBy":   {OrderBy: "weight"},
+		"unknown direction": {OrderDirection: "sideways"},
+		"partial filter":    {ChainID: 1},
+	} {
+		if _, err := backend.ListEvents(ctx, opts); err == nil {
+			t.Fatalf("expected error for %s", name)
+		}
+	}
+}
+
+func testIterateEvents(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	saved := []store.Event{
+		event(1, contractA, 2, 0, "1"),
+		event(1, contractA, 1, 3, "2"),
+		event(1, contractA, 1, 1, "3"),
+	}
+	if err := backend.SaveEvents(ctx, 1, contractA, saved, 2); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	var got []store.Event
+	if err := backend.IterateEvents(ctx, 1, contractA, func(event store.Event) error {
+		got = append(got, event)
+		return nil
+	}); err != nil {
+		t.Fatalf("iterate events: %v", err)
+	}
+	want := []store.Event{saved[2], saved[1], saved[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	got = nil
+	if err := backend.IterateEventsInRange(ctx, 1, contractA, 1, 1, func(event store.Event) error {
+		got = append(got, event)
+		return nil
+	}); err != nil {
+		t.Fatalf("iterate events in range: %v", err)
+	}
+	if want := []store.Event{saved[2], saved[1]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v in block 1, got %+v", want, got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := backend.IterateEvents(ctx, 1, contractA, func(store.Event) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected iteration to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func testEventArgs(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	withArgs := event(1, contractA, 1, 0, "5")
+	withArgs.Args = map[string]string{"from": accountB.Hex(), "to": accountA.Hex(), "value": "5"}
+	saved := []store.Event{withArgs, event(1, contractA, 2, 0, "7")}
+	if err := backend.SaveEvents(ctx, 1, contractA, saved, 2); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	if got := listAll(t, backend, 1, contractA); !reflect.DeepEqual(got, saved) {
+		t.Fatalf("expected %+v, got %+v", saved, got)
+	}
+}
+
+func testContracts(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	if _, ok, err := backend.GetContract(ctx, 1, contractA); err != nil || ok {
+		t.Fatalf("expected missing contract, got ok=%t err=%v", ok, err)
+	}
+	if err := backend.SaveContract(ctx, 1, contractB, 7, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := backend.SaveContract(ctx, 1, contractA, 0, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := backend.SaveContract(ctx, 1, contractA, 0, time.Time{}); err == nil {
+		t.Fatalf("expected error for a missing expiresAt")
+	}
+
+	if err := backend.SetContractStartBlock(ctx, 1, contractA, 100, "receipt"); err != nil {
+		t.Fatalf("set start block: %v", err)
+	}
+	if err := backend.SetContractStartBlock(ctx, 1, contractA, 200, "bytecode"); err != nil {
+		t.Fatalf("set start block: %v", err)
+	}
+	if err := backend.SetContractStartBlock(ctx, 5, contractA, 200, "receipt"); err == nil {
+		t.Fatalf("expected error for an unknown contract")
+	}
+	deploymentTx := common.HexToHash("0x1234")
+	if err := backend.SetContractDeploymentTx(ctx, 1, contractA, deploymentTx); err != nil {
+		t.Fatalf("set deployment tx: %v", err)
+	}
+	if err := backend.SetContractDeploymentTx(ctx, 5, contractA, deploymentTx); err == nil {
+		t.Fatalf("expected error when setting the deployment tx of an unknown contract")
+	}
+	if err := backend.SetContractLabel(ctx, 1, contractA, " census "); err != nil {
+		t.Fatalf("set label: %v", err)
+	}
+	if err := backend.SetContractLabel(ctx, 5, contractA, "census"); err == nil {
+		t.Fatalf("expected error when labeling an unknown contract")
+	}
+	profile := &store.EventProfile{
+		ABI:       `[{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]}]`,
+		Account:   "to",
+		NewWeight: "value",
+	}
+	if err := backend.SetContractEventProfile(ctx, 1, contractA, profile); err != nil {
+		t.Fatalf("set event profile: %v", err)
+	}
+	if err := backend.SetContractEventProfile(ctx, 5, contractA, profile); err == nil {
+		t.Fatalf("expected error when setting the event profile of an unknown contract")
+	}
+	if err := backend.SetContractEventProfile(ctx, 1, contractA, &store.EventProfile{ABI: profile.ABI, Account: "value", NewWeight: "value"}); err == nil {
+		t.Fatalf("expected error for an event profile mapping the account to an integer")
+	}
+	extended := expiresAt.Add(24 * time.Hour)
+	if err := backend.SaveContract(ctx, 1, contractA, 300, extended); err != nil {
+		t.Fatalf("update contract: %v", err)
+	}
+
+	record, ok, err := backend.GetContract(ctx, 1, contractA)
+	if err != nil || !ok {
+		t.Fatalf("get contract: ok=%t err=%v", ok, err)
+	}
+	want := store.ContractRecord{
+		ChainID:          1,
+		Contract:         contractA.Hex(),
+		StartBlock:       100,
+		ExpiresAt:        extended,
+		Label:            "census",
+		DeploymentTx:     deploymentTx.Hex(),
+		StartBlockMethod: "receipt",
+		EventProfile:     profile,
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Fatalf("expected %+v, got %+v", want, record)
+	}
+
+	records, err := backend.ListContracts(ctx)
+	if err != nil {
+		t.Fatalf("list contracts: %v", err)
+	}
+	if len(records) != 2 || records[0].Contract != contractA.Hex() || records[1].Contract != contractB.Hex() {
+		t.Fatalf("expected contracts ordered by address, got %+v", records)
+	}
+	if records[1].StartBlock != 7 || !records[1].ExpiresAt.Equal(expiresAt) || records[1].Label != "" ||
+		records[1].DeploymentTx != "" || records[1].StartBlockMethod != "" || records[1].EventProfile != nil {
+		t.Fatalf("unexpected second contract: %+v", records[1])
+	}
+
+	if err := backend.SetContractLabel(ctx, 1, contractA, ""); err != nil {
+		t.Fatalf("clear label: %v", err)
+	}
+	if record, _, err := backend.GetContract(ctx, 1, contractA); err != nil || record.Label != "" {
+		t.Fatalf("expected the label to be removed, got %q (err=%v)", record.Label, err)
+	}
+	if err := backend.SetContractEventProfile(ctx, 1, contractA, nil); err != nil {
+		t.Fatalf("clear event profile: %v", err)
+	}
+	if record, _, err := backend.GetContract(ctx, 1, contractA); err != nil || record.EventProfile != nil {
+		t.Fatalf("expected the event profile to be removed, got %+v (err=%v)", record.EventProfile, err)
+	}
+}
+
+func testDeleteContractData(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	for _, contract := range []common.Address{contractA, contractB} {
+		if err := backend.SaveContract(ctx, 1, contract, 1, expiresAt); err != nil {
+			t.Fatalf("save contract: %v", err)
+		}
+		if err := backend.SaveEvents(ctx, 1, contract, []store.Event{event(1, contract, 5, 0, "1")}, 10); err != nil {
+			t.Fatalf("save events: %v", err)
+		}
+		if _, err := backend.ScheduleReindex(ctx, 1, contract, 1, 10); err != nil {
+			t.Fatalf("schedule reindex: %v", err)
+		}
+	}
+	if err := backend.DeleteContractData(ctx, 1, contractA); err != nil {
+		t.Fatalf("delete contract data: %v", err)
+	}
+	if _, ok, _ := backend.GetContract(ctx, 1, contractA); ok {
+		t.Fatalf("expected contract record to be deleted")
+	}
+	if _, ok, _ := backend.LastIndexedBlock(ctx, 1, contractA); ok {
+		t.Fatalf("expected indexed cursor to be deleted")
+	}
+	if _, ok, _ := backend.LastVerifiedBlock(ctx, 1, contractA); ok {
+		t.Fatalf("expected verified cursor to be deleted")
+	}
+	if events := listAll(t, backend, 1, contractA); len(events) != 0 {
+		t.Fatalf("expected events to be deleted, got %d", len(events))
+	}
+	if jobs, _ := backend.ListReindexJobs(ctx, 1, contractA); len(jobs) != 0 {
+		t.Fatalf("expected reindex jobs to be deleted, got %d", len(jobs))
+	}
+	if events := listAll(t, backend, 1, contractB); len(events) != 1 {
+		t.Fatalf("expected other contract to keep its events, got %d", len(events))
+	}
+	if jobs, _ := backend.ListReindexJobs(ctx, 0, common.Address{}); len(jobs) != 1 {
+		t.Fatalf("expected other contract to keep its reindex job, got %d", len(jobs))
+	}
+}
+
+func testArchiveContract(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	expiresAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	if err := backend.ArchiveContract(ctx, 1, contractA, store.ContractArchive{}); err == nil {
+		t.Fatalf("expected error for an unknown contract")
+	}
+	if err := backend.SaveContract(ctx, 1, contractA, 1, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := backend.SaveEvents(ctx, 1, contractA, []store.Event{event(1, contractA, 5, 0, "1")}, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	archive := store.ContractArchive{
+		ArchivedAt:    time.Now().UTC().Truncate(time.Second),
+		IndexedUntil:  10,
+		VerifiedUntil: 8,
+		Accounts:      1,
+		TotalWeight:   "1",
+		Root:          common.HexToHash("0x01"),
+	}
+	if err := backend.ArchiveContract(ctx, 1, contractA, archive); err != nil {
+		t.Fatalf("archive contract: %v", err)
+	}
+	record, ok, err := backend.GetContract(ctx, 1, contractA)
+	if err != nil || !ok || record.Archive == nil {
+		t.Fatalf("expected an archived contract, got %+v (ok=%t, err=%v)", record, ok, err)
+	}
+	if !reflect.DeepEqual(*record.Archive, archive) {
+		t.Fatalf("expected archive %+v, got %+v", archive, *record.Archive)
+	}
+	records, err := backend.ListContracts(ctx)
+	if err != nil || len(records) != 1 || records[0].Archive == nil {
+		t.Fatalf("expected the archived contract to be listed, got %+v (err=%v)", records, err)
+	}
+
+	extended := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	if err := backend.SaveContract(ctx, 1, contractA, 1, extended); !errors.Is(err, store.ErrContractArchived) {
+		t.Fatalf("expected ErrContractArchived when saving, got %v", err)
+	}
+	if _, err := backend.ScheduleReindex(ctx, 1, contractA, 1, 10); !errors.Is(err, store.ErrContractArchived) {
+		t.Fatalf("expected ErrContractArchived when scheduling a reindex, got %v", err)
+	}
+	if err := backend.ArchiveContract(ctx, 1, contractA, archive); !errors.Is(err, store.ErrContractArchived) {
+		t.Fatalf("expected ErrContractArchived when archiving twice, got %v", err)
+	}
+
+	if err := backend.UnarchiveContract(ctx, 1, contractA, time.Time{}); err == nil {
+		t.Fatalf("expected error for a missing expiresAt")
+	}
+	if err := backend.UnarchiveContract(ctx, 1, contractA, extended); err != nil {
+		t.Fatalf("unarchive contract: %v", err)
+	}
+	record, _, err = backend.GetContract(ctx, 1, contractA)
+	if err != nil || record.Archive != nil || !record.ExpiresAt.Equal(extended) {
+		t.Fatalf("expected an active contract expiring at %s, got %+v (err=%v)", extended, record, err)
+	}
+	if err := backend.UnarchiveContract(ctx, 1, contractA, extended); err == nil {
+		t.Fatalf("expected error when restoring an active contract")
+	}
+	if events := listAll(t, backend, 1, contractA); len(events) != 1 {
+		t.Fatalf("expected events to be kept across archive and restore, got %d", len(events))
+	}
+	if indexed, ok, err := backend.LastIndexedBlock(ctx, 1, contractA); err != nil || !ok || indexed != 10 {
+		t.Fatalf("expected indexed cursor 10 to be kept, got %d (ok=%t, err=%v)", indexed, ok, err)
+	}
+}
+
+func testContractExpiry(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	maxExpiresAt := expiresAt.Add(24 * time.Hour)
+	policy := &store.ExpiryPolicy{
+		AutoExtend:     store.Duration(6 * time.Hour),
+		MaxExpiresAt:   &maxExpiresAt,
+		LastEventBlock: 7,
+	}
+	if err := backend.SetContractExpiry(ctx, 1, contractA, expiresAt, policy); err == nil {
+		t.Fatalf("expected error for an unknown contract")
+	}
+	if err := backend.SaveContract(ctx, 1, contractA, 1, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := backend.SetContractExpiry(ctx, 1, contractA, maxExpiresAt.Add(time.Second), policy); err == nil {
+		t.Fatalf("expected error for an expiration past maxExpiresAt")
+	}
+
+	extended := expiresAt.Add(6 * time.Hour)
+	if err := backend.SetContractExpiry(ctx, 1, contractA, extended, policy); err != nil {
+		t.Fatalf("set contract expiry: %v", err)
+	}
+	record, ok, err := backend.GetContract(ctx, 1, contractA)
+	if err != nil || !ok {
+		t.Fatalf("get contract: ok=%t, err=%v", ok, err)
+	}
+	if !record.ExpiresAt.Equal(extended) {
+		t.Fatalf("expected expiresAt %s, got %s", extended, record.ExpiresAt)
+	}
+	if !reflect.DeepEqual(record.Policy, policy) {
+		t.Fatalf("expected policy %+v, got %+v", policy, record.Policy)
+	}
+
+	// Updating the expiration keeps the policy.
+	if err := backend.SaveContract(ctx, 1, contractA, 1, expiresAt); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	record, _, err = backend.GetContract(ctx, 1, contractA)
+	if err != nil || !reflect.DeepEqual(record.Policy, policy) {
+		t.Fatalf("expected policy %+v to be kept, got %+v (err=%v)", policy, record.Policy, err)
+	}
+
+	if err := backend.SetContractExpiry(ctx, 1, contractA, expiresAt, &store.ExpiryPolicy{}); err != nil {
+		t.Fatalf("clear contract policy: %v", err)
+	}
+	record, _, err = backend.GetContract(ctx, 1, contractA)
+	if err != nil || record.Policy != nil {
+		t.Fatalf("expected a zero policy to be removed, got %+v (err=%v)", record.Policy, err)
+	}
+
+	if err := backend.ArchiveContract(ctx, 1, contractA, store.ContractArchive{}); err != nil {
+		t.Fatalf("archive contract: %v", err)
+	}
+	if err := backend.SetContractExpiry(ctx, 1, contractA, extended, policy); !errors.Is(err, store.ErrContractArchived) {
+		t.Fatalf("expected ErrContractArchived, got %v", err)
+	}
+}
+
+func testContractUpgrades(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	initial := store.ProxyUpgrade{
+		Implementation: "0x00000000000000000000000000000000000000a1",
+		Block:          10,
+		DetectedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+	if err := backend.RecordContractUpgrade(ctx, 1, contractA, initial, false); err == nil {
+		t.Fatalf("expected error for an unknown contract")
+	}
+	if err := backend.SaveContract(ctx, 1, contractA, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("save contract: %v", err)
+	}
+	if err := backend.RecordContractUpgrade(ctx, 1, contractA, initial, false); err != nil {
+		t.Fatalf("record initial implementation: %v", err)
+	}
+	upgrade := store.ProxyUpgrade{
+		Implementation: "0x00000000000000000000000000000000000000b2",
+		Block:          20,
+		DetectedAt:     initial.DetectedAt.Add(time.Minute),
+	}
+	if err := backend.RecordContractUpgrade(ctx, 1, contractA, upgrade, true); err != nil {
+		t.Fatalf("record upgrade: %v", err)
+	}
+	record, ok, err := backend.GetContract(ctx, 1, contractA)
+	if err != nil || !ok {
+		t.Fatalf("get contract: ok=%t, err=%v", ok, err)
+	}
+	if !reflect.DeepEqual(record.Upgrades, []store.ProxyUpgrade{initial, upgrade}) {
+		t.Fatalf("expected upgrade history %+v, got %+v", []store.ProxyUpgrade{initial, upgrade}, record.Upgrades)
+	}
+	if !record.UpgradePending {
+		t.Fatalf("expected the upgrade to be pending")
+	}
+
+	acknowledged, err := backend.AcknowledgeContractUpgrade(ctx, 1, contractA)
+	if err != nil || !acknowledged {
+		t.Fatalf("expected the upgrade to be acknowledged, got %t (err=%v)", acknowledged, err)
+	}
+	acknowledged, err = backend.AcknowledgeContractUpgrade(ctx, 1, contractA)
+	if err != nil || acknowledged {
+		t.Fatalf("expected no pending upgrade, got %t (err=%v)", acknowledged, err)
+	}
+	record, _, err = backend.GetContract(ctx, 1, contractA)
+	if err != nil || record.UpgradePending || len(record.Upgrades) != 2 {
+		t.Fatalf("expected the history without a pending upgrade, got %+v (err=%v)", record, err)
+	}
+}
+
+func testBackfillSegments(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	if err := backend.SaveEvents(ctx, 1, contractA, nil, 10); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	// Segments completed out of order leave a hole at [11,20].
+	for _, segment := range []store.BackfillSegment{{From: 31, To: 40}, {From: 21, To: 30}, {From: 51, To: 60}} {
+		if err := backend.ReplaceEventsInRange(ctx, 1, contractA, segment.From, segment.To,
+			[]store.Event{event(1, contractA, segment.From, 0, "1")},
+			store.ReplaceOptions{BackfillSegment: &segment},
+		); err != nil {
+			t.Fatalf("store segment %+v: %v", segment, err)
+		}
+	}
+	segments, err := backend.ListBackfillSegments(ctx, 1, contractA)
+	if err != nil {
+		t.Fatalf("list backfill segments: %v", err)
+	}
+	if len(segments) != 3 || segments[0].From != 21 || segments[1].From != 31 || segments[2].From != 51 {
+		t.Fatalf("expected segments from 21, 31 and 51, got %+v", segments)
+	}
+	if other, _ := backend.ListBackfillSegments(ctx, 1, contractB); len(other) != 0 {
+		t.Fatalf("expected no segments for another contract, got %+v", other)
+	}
+
+	merged, err := backend.MergeBackfillSegments(ctx, 1, contractA, 10)
+	if err != nil {
+		t.Fatalf("merge backfill segments: %v", err)
+	}
+	if merged != 10 {
+		t.Fatalf("expected the hole to block the merge at 10, got %d", merged)
+	}
+
+	verified := uint64(20)
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 11, 20, nil, store.ReplaceOptions{
+		IndexedUntil:  &verified,
+		VerifiedUntil: &verified,
+	}); err != nil {
+		t.Fatalf("fill hole: %v", err)
+	}
+	merged, err = backend.MergeBackfillSegments(ctx, 1, contractA, verified)
+	if err != nil {
+		t.Fatalf("merge backfill segments: %v", err)
+	}
+	if merged != 40 {
+		t.Fatalf("expected cursors merged up to 40, got %d", merged)
+	}
+	if block, _, _ := backend.LastVerifiedBlock(ctx, 1, contractA); block != 40 {
+		t.Fatalf("expected verified cursor 40, got %d", block)
+	}
+	if block, _, _ := backend.LastIndexedBlock(ctx, 1, contractA); block != 40 {
+		t.Fatalf("expected indexed cursor 40, got %d", block)
+	}
+	segments, err = backend.ListBackfillSegments(ctx, 1, contractA)
+	if err != nil {
+		t.Fatalf("list backfill segments: %v", err)
+	}
+	if len(segments) != 1 || segments[0] != (store.BackfillSegment{From: 51, To: 60}) {
+		t.Fatalf("expected only segment [51,60] to remain, got %+v", segments)
+	}
+	if events := listAll(t, backend, 1, contractA); len(events) != 3 {
+		t.Fatalf("expected segment events to be kept, got %d", len(events))
+	}
+
+	if err := backend.DeleteContractData(ctx, 1, contractA); err != nil {
+		t.Fatalf("delete contract data: %v", err)
+	}
+	if segments, _ := backend.ListBackfillSegments(ctx, 1, contractA); len(segments) != 0 {
+		t.Fatalf("expected segments to be deleted, got %+v", segments)
+	}
+}
+
+func testReindexJobs(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	if _, err := backend.ScheduleReindex(ctx, 1, contractA, 10, 20); err == nil {
+		t.Fatalf("expected error for an unknown contract")
+	}
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	for _, contract := range []common.Address{contractA, contractB} {
+		if err := backend.SaveContract(ctx, 1, contract, 10, expiresAt); err != nil {
+			t.Fatalf("save contract: %v", err)
+		}
+		if err := backend.SaveEvents(ctx, 1, contract, nil, 100); err != nil {
+			t.Fatalf("save events: %v", err)
+		}
+	}
+	for name, r := range map[string][2]uint64{
+		"beyond cursor":      {50, 101},
+		"inverted":           {60, 50},
+		"before start block": {1, 9},
+	} {
+		if _, err := backend.ScheduleReindex(ctx, 1, contractA, r[0], r[1]); !errors.Is(err, store.ErrInvalidReindexJob) {
+			t.Fatalf("expected an invalid job error for a range %s, got %v", name, err)
+		}
+	}
+
+	first, err := backend.ScheduleReindex(ctx, 1, contractA, 0, 50)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	if first.ID != 1 || first.From != 10 || first.To != 50 || first.Status != store.ReindexPending ||
+		first.IndexedUntil != 9 || first.VerifiedUntil != 9 || first.Contract != contractA.Hex() {
+		t.Fatalf("unexpected job: %+v", first)
+	}
+	second, err := backend.ScheduleReindex(ctx, 1, contractA, 60, 100)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	if second.ID != 2 {
+		t.Fatalf("expected job ID 2, got %d", second.ID)
+	}
+	other, err := backend.ScheduleReindex(ctx, 1, contractB, 20, 30)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	if other.ID != 1 {
+		t.Fatalf("expected job IDs to be scoped to the contract, got %d", other.ID)
+	}
+
+	jobs, err := backend.ListReindexJobs(ctx, 1, contractA)
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != 1 || jobs[1].ID != 2 {
+		t.Fatalf("expected jobs 1 and 2, got %+v", jobs)
+	}
+	all, err := backend.ListReindexJobs(ctx, 0, common.Address{})
+	if err != nil {
+		t.Fatalf("list all reindex jobs: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(all))
+	}
+	got, ok, err := backend.GetReindexJob(ctx, 1, contractA, 2)
+	// Backends may store timestamps with microsecond precision.
+	if err != nil || !ok || got.From != 60 || got.CreatedAt.Sub(second.CreatedAt).Abs() > time.Millisecond {
+		t.Fatalf("expected job 2, got %+v (ok=%t, err=%v)", got, ok, err)
+	}
+	if _, ok, err := backend.GetReindexJob(ctx, 1, contractA, 3); err != nil || ok {
+		t.Fatalf("expected missing job 3, got ok=%t err=%v", ok, err)
+	}
+
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	got.Status = store.ReindexCompleted
+	got.IndexedUntil, got.VerifiedUntil = got.To, got.To
+	got.CompletedAt = &completedAt
+	if err := backend.ReplaceEventsInRange(ctx, 1, contractA, 60, 100, nil, store.ReplaceOptions{ReindexJob: &got}); err != nil {
+		t.Fatalf("update job: %v", err)
+	}
+	updated, _, err := backend.GetReindexJob(ctx, 1, contractA, 2)
+	if err != nil || !updated.Done() || updated.CompletedAt == nil || !updated.CompletedAt.Equal(completedAt) {
+		t.Fatalf("expected completed job, got %+v (err=%v)", updated, err)
+	}
+
+	// Scheduling prunes the oldest completed jobs and keeps the pending ones.
+	for range 20 {
+		job, err := backend.ScheduleReindex(ctx, 1, contractB, 20, 30)
+		if err != nil {
+			t.Fatalf("schedule reindex: %v", err)
+		}
+		job.Status = store.ReindexCompleted
+		job.IndexedUntil, job.VerifiedUntil = job.To, job.To
+		job.CompletedAt = &completedAt
+		if err := backend.ReplaceEventsInRange(ctx, 1, contractB, 20, 30, nil, store.ReplaceOptions{ReindexJob: &job}); err != nil {
+			t.Fatalf("complete job: %v", err)
+		}
+	}
+	last, err := backend.ScheduleReindex(ctx, 1, contractB, 20, 30)
+	if err != nil {
+		t.Fatalf("schedule reindex: %v", err)
+	}
+	jobs, err = backend.ListReindexJobs(ctx, 1, contractB)
+	if err != nil {
+		t.Fatalf("list reindex jobs: %v", err)
+	}
+	if len(jobs) != 18 || jobs[0].ID != other.ID || jobs[0].Done() || jobs[1].ID != 6 || jobs[len(jobs)-1].ID != last.ID {
+		t.Fatalf("expected the pending jobs and the 16 latest completed ones, got %d jobs", len(jobs))
+	}
+}
+
+func testMigrate(t *testing.T, backend store.Backend) {
+	ctx := context.Background()
+	report, err := backend.Migrate(ctx, store.MigrateOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.Pending) != 0 || report.From != report.To {
+		t.Fatalf("expected a migrated backend to have nothing pending, got %+v", report)
+	}
+	if _, err := backend.Migrate(ctx, store.MigrateOptions{}); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProxyUpgrade is an implementation of a contract deployed behind an EIP-1967
+// proxy. The first entry of a contract is the implementation seen when it was
+// first checked; every later entry is an upgrade.
+type ProxyUpgrade struct {
+	Implementation string `json:"implementation"`
+	// Block is the verified block the implementation was first read at. The
+	// upgrade happened after the previous check and at or before Block.
+	Block      uint64    `json:"block"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// RecordContractUpgrade appends an implementation to the upgrade history of an
+// existing contract. With pause, indexing of the contract stays paused until
+// AcknowledgeContractUpgrade is called.
+func (s *Store) RecordContractUpgrade(ctx context.Context, chainID uint64, contract common.Address, upgrade ProxyUpgrade, pause bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("contract not found")
+	}
+	upgrade.DetectedAt = upgrade.DetectedAt.UTC()
+	record.Upgrades = append(record.Upgrades, upgrade)
+	record.UpgradePending = record.UpgradePending || pause
+	return s.putContract(record)
+}
+
+// AcknowledgeContractUpgrade resumes indexing of a contract paused by an
+// upgrade. It reports false when no upgrade was pending.
+func (s *Store) AcknowledgeContractUpgrade(ctx context.Context, chainID uint64, contract common.Address) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.contractsMu.Lock()
+	defer s.contractsMu.Unlock()
+	record, ok, err := s.GetContract(ctx, chainID, contract)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, fmt.Errorf("contract not found")
+	}
+	if !record.UpgradePending {
+		return false, nil
+	}
+	record.UpgradePending = false
+	return true, s.putContract(record)
+}